@@ -1,16 +1,24 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"github.com/joho/godotenv"
 	"github.com/wso2/identity-customer-data-service/config"
 	"github.com/wso2/identity-customer-data-service/pkg/constants"
 	"github.com/wso2/identity-customer-data-service/pkg/handlers"
 	"github.com/wso2/identity-customer-data-service/pkg/locks"
 	"github.com/wso2/identity-customer-data-service/pkg/logger"
+	repositories "github.com/wso2/identity-customer-data-service/pkg/repository"
+	"github.com/wso2/identity-customer-data-service/pkg/repository/memory"
+	"github.com/wso2/identity-customer-data-service/pkg/repository/sqlstore"
 	"github.com/wso2/identity-customer-data-service/pkg/service"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
 	"time"
 
 	"github.com/gin-contrib/cors"
@@ -48,13 +56,63 @@ func main() {
 		MaxAge:           12 * time.Hour,
 	}))
 
-	// Initialize MongoDB
-	mongoDB := locks.ConnectMongoDB(cdsConfig.MongoDB.URI, cdsConfig.MongoDB.Database)
+	// Initialize storage
+	switch cdsConfig.Storage.Type {
+	case constants.StorageTypeMemory:
+		logger.Info("Using in-memory storage. Data will not be persisted across restarts.")
+		service.InitStores(memory.NewStores())
+		locks.InitLocks(locks.NewInMemoryLock())
+	case constants.StorageTypePostgres:
+		db, err := sqlstore.OpenPostgres(cdsConfig.Postgres.DSN, cdsConfig.Postgres.MaxOpenConns)
+		if err != nil {
+			log.Fatalf("Failed to connect to PostgreSQL: %v", err)
+		}
+		// Close PostgreSQL connections on exit
+		defer db.Close()
+		if err := db.Migrate(); err != nil {
+			log.Fatalf("Failed to migrate the PostgreSQL schema: %v", err)
+		}
+
+		service.InitStores(sqlstore.NewStores(db))
+		locks.InitLocks(sqlstore.NewDistributedLock(db))
+	case constants.StorageTypeSQLite:
+		db, err := sqlstore.OpenSQLite(cdsConfig.SQLite.Path)
+		if err != nil {
+			log.Fatalf("Failed to open SQLite database: %v", err)
+		}
+		// Close the SQLite database on exit
+		defer db.Close()
+		if err := db.Migrate(); err != nil {
+			log.Fatalf("Failed to migrate the SQLite schema: %v", err)
+		}
+
+		service.InitStores(sqlstore.NewStores(db))
+		locks.InitLocks(sqlstore.NewDistributedLock(db))
+	default:
+		mongoDB := locks.ConnectMongoDB(cdsConfig.MongoDB.URI, cdsConfig.MongoDB.Database)
+		// Close MongoDB connection on exit
+		defer mongoDB.Client.Disconnect(context.Background())
+
+		stores, err := repositories.NewMongoStores(mongoDB.Database)
+		if err != nil {
+			log.Fatalf("Failed to initialize the MongoDB stores: %v", err)
+		}
+		service.InitStores(stores)
+		locks.InitLocks(locks.NewMongoLock(mongoDB.Database))
+	}
+
+	service.SetEventSchemaEnforcement(cdsConfig.EventSchema.Enforcement)
+
+	// Index the identity keys of the existing profiles for unification, unless the index is up to date with the
+	// unification rules
+	service.RebuildIdentityIndex()
 
-	locks.InitLocks(mongoDB.Database)
+	// Fail the backfill jobs that were interrupted when the service last stopped
+	service.FailInterruptedBackfillJobs()
 
-	// Initialize Event queue
-	service.StartProfileWorker()
+	// Start processing the Event queue
+	service.StartProfileWorker(cdsConfig.EventQueue)
+	service.StartTraitRefresh(cdsConfig.TraitRefresh)
 
 	api := router.Group(constants.ApiBasePath)
 	handlers.RegisterHandlers(api, server)
@@ -67,12 +125,44 @@ func main() {
 		c.Status(200)
 	})
 
-	// Close MongoDB connection on exit
-	defer mongoDB.Client.Disconnect(nil)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
+	go func() {
+		if err := s.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to serve HTTP: %v", err)
+		}
+	}()
 	logger.Info("identity-customer-data-service component has started.")
 
-	// And we serve HTTP until the world ends.
-	log.Fatal(s.ListenAndServe())
+	// Serve HTTP until a shutdown signal is received
+	<-ctx.Done()
+	stop()
+	logger.Info("Shutting down identity-customer-data-service component.")
+
+	timeout := time.Duration(cdsConfig.Shutdown.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
 
+	// Stop accepting requests, then drain the events that were already claimed from the queue
+	if err := s.Shutdown(shutdownCtx); err != nil {
+		logger.Error(err, "Failed to complete in-flight HTTP requests")
+	}
+	if err := service.StopProfileWorker(shutdownCtx); err != nil {
+		logger.Error(err, "Failed to drain the profile worker")
+	}
+	if err := service.StopTraitRefresh(shutdownCtx); err != nil {
+		logger.Error(err, "Failed to stop the trait refresh")
+	}
+	if err := service.StopBackfillJobs(shutdownCtx); err != nil {
+		logger.Error(err, "Failed to stop the backfill jobs")
+	}
+	if err := locks.ReleaseAll(); err != nil {
+		logger.Error(err, "Failed to release held locks")
+	}
+	// The storage connections are closed by the deferred calls above
+	logger.Info("identity-customer-data-service component has stopped.")
 }
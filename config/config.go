@@ -8,6 +8,16 @@ import (
 var AppConfig *Config
 
 type Config struct {
+	Storage struct {
+		Type string `yaml:"type"` // mongodb, postgres, sqlite or memory
+	} `yaml:"storage"`
+	Postgres struct {
+		DSN          string `yaml:"dsn"`
+		MaxOpenConns int    `yaml:"max_open_conns"`
+	} `yaml:"postgres"`
+	SQLite struct {
+		Path string `yaml:"path"`
+	} `yaml:"sqlite"`
 	MongoDB struct {
 		URI               string `yaml:"uri"`
 		Database          string `yaml:"database"`
@@ -19,6 +29,9 @@ type Config struct {
 		Port string `yaml:"port"`
 		Host string `yaml:"host"`
 	} `yaml:"addr"`
+	Shutdown struct {
+		TimeoutSeconds int `yaml:"timeout_seconds"` // time to finish requests and drain claimed events on shutdown
+	} `yaml:"shutdown"`
 	Log struct {
 		DebugEnabled bool `yaml:"debug_enabled"`
 	} `yaml:"log"`
@@ -29,6 +42,29 @@ type Config struct {
 		Host string `yaml:"host"`
 		Port string `yaml:"port"`
 	} `yaml:"identity_server"`
+	EventSchema struct {
+		Enforcement string `yaml:"enforcement"` // reject or quarantine events that do not conform to their schema
+	} `yaml:"event_schema"`
+	EventQueue   EventQueueConfig   `yaml:"event_queue"`
+	TraitRefresh TraitRefreshConfig `yaml:"trait_refresh"`
+}
+
+// EventQueueConfig configures how queued events are claimed and retried. Zero values fall back to defaults.
+type EventQueueConfig struct {
+	Concurrency       int `yaml:"concurrency"`          // workers processing events, events of a profile go to the same worker
+	MaxAttempts       int `yaml:"max_attempts"`         // attempts before an event is moved to the dead-letter store
+	PollIntervalMs    int `yaml:"poll_interval_ms"`     // wait between polls when the queue is empty
+	BatchSize         int `yaml:"batch_size"`           // events claimed per poll
+	LeaseSeconds      int `yaml:"lease_seconds"`        // time after which an unacknowledged claim expires
+	RetryBackoffMs    int `yaml:"retry_backoff_ms"`     // delay before the first retry, doubled on each attempt
+	MaxRetryBackoffMs int `yaml:"max_retry_backoff_ms"` // upper bound of the retry delay
+}
+
+// TraitRefreshConfig configures the periodic re-evaluation of the computed traits that change with time. Zero values
+// fall back to defaults.
+type TraitRefreshConfig struct {
+	Disabled        bool `yaml:"disabled"`
+	IntervalSeconds int  `yaml:"interval_seconds"` // time between refreshes, a single instance refreshes on each interval
 }
 
 // LoadConfig loads and sets AppConfig (global variable)
@@ -40,6 +40,26 @@ func ValidateAuthentication(c *gin.Context) (map[string]interface{}, error) {
 	return claims, nil
 }
 
+// ValidateWriteKeyAuthentication validates the write key that Segment compatible SDKs send as the username of HTTP
+// basic authentication. A bearer token is accepted as well.
+func ValidateWriteKeyAuthentication(c *gin.Context) (map[string]interface{}, error) {
+
+	if writeKey, _, ok := c.Request.BasicAuth(); ok && writeKey != "" {
+		return validateToken(writeKey)
+	}
+	return ValidateAuthentication(c)
+}
+
+// ClaimedUser returns the user that the token with the given introspection claims was issued to
+func ClaimedUser(claims map[string]interface{}) string {
+	for _, claim := range []string{"username", "sub", "client_id"} {
+		if user, ok := claims[claim].(string); ok && user != "" {
+			return user
+		}
+	}
+	return ""
+}
+
 func extractBearerToken(c *gin.Context) (string, error) {
 
 	authHeader := c.GetHeader("Authorization")
@@ -8,11 +8,50 @@ const (
 	EventCollection            = "events"
 	ProfileCollection          = "profiles"
 	ProfileSchemaCollection    = "profile_schema"
+	ConsentCollection          = "consents"
+	EventSchemaCollection      = "event_schemas"
+	EventQueueCollection       = "event_queue"
+	DeadLetterEventCollection  = "dead_letter_events"
+	BackfillJobCollection      = "backfill_jobs"
+	RuleVersionCollection      = "rule_versions"
+	IdentityKeyCollection      = "identity_keys"
+	IdentityIndexCollection    = "identity_index"
+	ProfileUnmergeCollection   = "profile_unmerges"
+)
+
+// Storage types
+const (
+	StorageTypeMongoDB  = "mongodb"
+	StorageTypePostgres = "postgres"
+	StorageTypeSQLite   = "sqlite"
+	StorageTypeMemory   = "memory"
 )
 const MaxRetryAttempts = 10
 const RetryDelay = 100 * time.Millisecond
 const ApiBasePath = "/api/v1"
 const Filter = "filter"
+const MaxEventBatchSize = 500
+
+// Ways of handling events that do not conform to their event schema
+const (
+	EventSchemaEnforcementReject     = "reject"     // the event is refused with an error
+	EventSchemaEnforcementQuarantine = "quarantine" // the event is set aside in the dead-letter store unprocessed
+)
+
+// Triggers of enrichment rules can match any event type or name with a wildcard, e.g. `track:*`. Profile triggers
+// fire on changes of traits and identity attributes instead of on events.
+const (
+	WildcardTrigger         = "*"
+	ProfileTriggerEventType = "profile"
+	TraitUpdatedEventName   = "trait_updated"
+)
+
+// Alias events link the profile of `previous_id` to the profile of the event under this rule name
+const (
+	AliasEventType          = "alias"
+	AliasPreviousIdProperty = "previous_id"
+	AliasRuleName           = "alias"
+)
 
 const (
 	TokenEndpoint      = "/oauth2/token"
@@ -41,6 +80,32 @@ var AllowedTraitTypes = map[string]bool{
 	"computed": true,
 }
 
+var AllowedComputations = map[string]bool{
+	"copy":       true,
+	"concat":     true,
+	"count":      true,
+	"sum":        true,
+	"min":        true,
+	"max":        true,
+	"avg":        true,
+	"first":      true,
+	"last":       true,
+	"distinct":   true,
+	"expression": true,
+	"decay":      true,
+}
+
+// AggregateComputations aggregate a source field across the events matching the trigger of the rule
+var AggregateComputations = map[string]bool{
+	"sum":      true,
+	"min":      true,
+	"max":      true,
+	"avg":      true,
+	"first":    true,
+	"last":     true,
+	"distinct": true,
+}
+
 var AllowedMergeStrategies = map[string]bool{
 	"overwrite": true,
 	"combine":   true,
@@ -57,6 +122,20 @@ var AllowedEventTypes = map[string]bool{
 	"track":    true,
 	"identify": true,
 	"page":     true,
+	"screen":   true,
+	"group":    true,
+	"alias":    true,
+}
+
+// IdentityTraits are the traits of identify events that are kept as identity attributes of the profile. The other
+// traits are kept as profile traits.
+var IdentityTraits = map[string]bool{
+	"email":     true,
+	"phone":     true,
+	"username":  true,
+	"name":      true,
+	"firstName": true,
+	"lastName":  true,
 }
 
 var AllowedProfileDataScopes = map[string]bool{
@@ -77,4 +156,61 @@ var AllowedConditionOperators = map[string]bool{
 	"greater_than_equals": true,
 	"less_than":           true,
 	"less_than_equals":    true,
+	"in":                  true,
+	"not_in":              true,
+	"regex":               true,
+	"starts_with":         true,
+	"ends_with":           true,
+	"before":              true,
+	"after":               true,
+	"within_last":         true,
+}
+
+// MaxConditionDepth bounds the nesting of condition groups in rule triggers
+const MaxConditionDepth = 10
+
+// Statuses of the jobs that backfill the properties written by an enrichment rule
+const (
+	BackfillJobPending   = "pending"
+	BackfillJobRunning   = "running"
+	BackfillJobCompleted = "completed"
+	BackfillJobFailed    = "failed"
+	BackfillJobCancelled = "cancelled"
+)
+
+// MaxBackfillJobErrors bounds the profile errors kept on a backfill job. Failures beyond it are only counted.
+const MaxBackfillJobErrors = 100
+
+// Types of the rules that are versioned
+const (
+	EnrichmentRuleType  = "enrichment"
+	UnificationRuleType = "unification"
+)
+
+// Changes recorded as rule versions
+const (
+	RuleCreated    = "created"
+	RuleUpdated    = "updated"
+	RuleDeleted    = "deleted"
+	RuleRolledBack = "rolled_back"
+)
+
+// Operators combining the properties of a composite unification rule
+const (
+	UnificationOperatorAnd = "and"
+	UnificationOperatorOr  = "or"
+)
+
+// Modes of matching the properties of unification rules
+const (
+	UnificationModeExact         = "exact"
+	UnificationModeProbabilistic = "probabilistic"
+)
+
+// MatchComparators compare the attributes of probabilistic unification rules
+var MatchComparators = map[string]bool{
+	"exact":        true,
+	"levenshtein":  true,
+	"jaro_winkler": true,
+	"soundex":      true,
 }
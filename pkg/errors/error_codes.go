@@ -92,6 +92,102 @@ var (
 		Message: "Error while generating the write key.",
 	}
 
+	ErrWhileCreatingProfiles = ErrorMessage{
+		Code:        errorPrefix + "15016",
+		Message:     "Error while creating profiles.",
+		Description: "Error while creating the profiles of the submitted events.",
+	}
+
+	ErrWhileQueueingEvents = ErrorMessage{
+		Code:        errorPrefix + "15017",
+		Message:     "Error while queueing events.",
+		Description: "Error while queueing events for enrichment and unification.",
+	}
+
+	ErrWhileFetchingEventQueueStats = ErrorMessage{
+		Code:        errorPrefix + "15018",
+		Message:     "Error while fetching event queue statistics.",
+		Description: "Error while fetching the statistics of the event processing queue.",
+	}
+
+	ErrWhileAddingEventSchema = ErrorMessage{
+		Code:        errorPrefix + "15019",
+		Message:     "Error while adding event schema.",
+		Description: "Error while adding the event schema.",
+	}
+
+	ErrWhileFetchingEventSchemas = ErrorMessage{
+		Code:        errorPrefix + "15020",
+		Message:     "Error while fetching event schemas.",
+		Description: "Error while fetching event schemas.",
+	}
+
+	ErrWhileUpdatingEventSchema = ErrorMessage{
+		Code:        errorPrefix + "15021",
+		Message:     "Error while updating event schema.",
+		Description: "Error while updating the event schema.",
+	}
+
+	ErrWhileDeletingEventSchema = ErrorMessage{
+		Code:        errorPrefix + "15022",
+		Message:     "Error while deleting event schema.",
+		Description: "Error while deleting the event schema.",
+	}
+
+	ErrWhileQuarantiningEvents = ErrorMessage{
+		Code:        errorPrefix + "15023",
+		Message:     "Error while quarantining events.",
+		Description: "Error while quarantining the events that do not conform to their schema.",
+	}
+
+	ErrWhileFetchingEvents = ErrorMessage{
+		Code:        errorPrefix + "15024",
+		Message:     "Error while fetching events.",
+		Description: "Error while fetching the events.",
+	}
+
+	ErrWhileStartingBackfillJob = ErrorMessage{
+		Code:        errorPrefix + "15025",
+		Message:     "Error while starting backfill job.",
+		Description: "Error while starting the job that backfills the enrichment rule.",
+	}
+
+	ErrWhileFetchingBackfillJobs = ErrorMessage{
+		Code:        errorPrefix + "15026",
+		Message:     "Error while fetching backfill jobs.",
+		Description: "Error while fetching the backfill jobs.",
+	}
+
+	ErrWhileUpdatingBackfillJob = ErrorMessage{
+		Code:        errorPrefix + "15027",
+		Message:     "Error while updating backfill job.",
+		Description: "Error while updating the backfill job.",
+	}
+
+	ErrWhileRecordingRuleVersion = ErrorMessage{
+		Code:        errorPrefix + "15028",
+		Message:     "Error while recording rule version.",
+		Description: "The rule was changed but its version could not be recorded.",
+	}
+
+	ErrWhileFetchingRuleVersions = ErrorMessage{
+		Code:        errorPrefix + "15029",
+		Message:     "Error while fetching rule versions.",
+		Description: "Error while fetching the versions of the rule.",
+	}
+
+	ErrWhileUnmergingProfile = ErrorMessage{
+		Code:        errorPrefix + "15030",
+		Message:     "Error while unmerging profile.",
+		Description: "Error while splitting the profile from its master profile.",
+	}
+
+	ErrWhileFetchingProfileUnmerges = ErrorMessage{
+		Code:        errorPrefix + "15031",
+		Message:     "Error while fetching profile unmerges.",
+		Description: "Error while fetching the unmerges of the profile.",
+	}
+
 	// Client error codes
 
 	ErrBadRequest = ErrorMessage{
@@ -218,4 +314,87 @@ var (
 		Code:    errorPrefix + "11020",
 		Message: "Property does not exist.",
 	}
+
+	ErrEmptyEventBatch = ErrorMessage{
+		Code:        errorPrefix + "11021",
+		Message:     "Empty event batch.",
+		Description: "At least one event should be submitted in the batch.",
+	}
+
+	ErrEventBatchTooLarge = ErrorMessage{
+		Code:        errorPrefix + "11022",
+		Message:     "Event batch too large.",
+		Description: "A batch can contain at most %d events.",
+	}
+
+	ErrInvalidEvent = ErrorMessage{
+		Code:    errorPrefix + "11023",
+		Message: "Invalid event.",
+	}
+
+	ErrEventSchemaNotFound = ErrorMessage{
+		Code:        errorPrefix + "11024",
+		Message:     "Event schema not found.",
+		Description: "No event schema found for the given event_schema_id.",
+	}
+
+	ErrEventSchemaAlreadyExists = ErrorMessage{
+		Code:        errorPrefix + "11025",
+		Message:     "Event schema already exists.",
+		Description: "An event schema already exists for event type '%s' and event name '%s'.",
+	}
+
+	ErrInvalidEventSchema = ErrorMessage{
+		Code:    errorPrefix + "11026",
+		Message: "Invalid event schema.",
+	}
+
+	ErrEventSchemaViolation = ErrorMessage{
+		Code:    errorPrefix + "11027",
+		Message: "Event does not conform to its schema.",
+	}
+
+	ErrInvalidExpression = ErrorMessage{
+		Code:    errorPrefix + "11028",
+		Message: "Invalid expression.",
+	}
+
+	ErrEnrichmentRuleNotFound = ErrorMessage{
+		Code:        errorPrefix + "11029",
+		Message:     "Enrichment rule not found.",
+		Description: "No enrichment rule found for the provided rule_id.",
+	}
+
+	ErrBackfillJobNotFound = ErrorMessage{
+		Code:        errorPrefix + "11030",
+		Message:     "Backfill job not found.",
+		Description: "No backfill job found for the provided job_id.",
+	}
+
+	ErrInvalidBackfillJob = ErrorMessage{
+		Code:    errorPrefix + "11031",
+		Message: "Invalid backfill job.",
+	}
+
+	ErrRuleVersionNotFound = ErrorMessage{
+		Code:        errorPrefix + "11032",
+		Message:     "Rule version not found.",
+		Description: "No version of the rule found for the provided version number.",
+	}
+
+	ErrInvalidTimeWindow = ErrorMessage{
+		Code:    errorPrefix + "11033",
+		Message: "Invalid time window.",
+	}
+
+	ErrInvalidUnificationRule = ErrorMessage{
+		Code:    errorPrefix + "11034",
+		Message: "Invalid unification rule.",
+	}
+
+	ErrProfileNotMerged = ErrorMessage{
+		Code:        errorPrefix + "11035",
+		Message:     "Profile is not merged.",
+		Description: "The profile is not unified with a master profile and cannot be unmerged.",
+	}
 )
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"io"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wso2/identity-customer-data-service/pkg/errors"
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+	"github.com/wso2/identity-customer-data-service/pkg/service"
+	"github.com/wso2/identity-customer-data-service/pkg/utils"
+	"net/http"
+)
+
+// StartBackfillJob handles starting a job that backfills an enrichment rule. The body is optional.
+func (s Server) StartBackfillJob(c *gin.Context, ruleId string) {
+
+	var request models.BackfillJobRequest
+	if err := c.ShouldBindJSON(&request); err != nil && err != io.EOF {
+		badReq := errors.NewClientError(errors.ErrorMessage{
+			Code:        errors.ErrBadRequest.Code,
+			Message:     errors.ErrBadRequest.Message,
+			Description: err.Error(),
+		}, http.StatusBadRequest)
+		utils.HandleError(c, badReq)
+		return
+	}
+
+	job, err := service.StartBackfillJob(ruleId, request)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, job)
+}
+
+// GetBackfillJobs handles retrieving the backfill jobs of an enrichment rule
+func (s Server) GetBackfillJobs(c *gin.Context, ruleId string) {
+
+	jobs, err := service.GetBackfillJobs(ruleId)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, jobs)
+}
+
+// GetBackfillJob handles retrieving the progress of a backfill job
+func (s Server) GetBackfillJob(c *gin.Context, jobId string) {
+
+	job, err := service.GetBackfillJob(jobId)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// CancelBackfillJob handles cancelling a pending or running backfill job
+func (s Server) CancelBackfillJob(c *gin.Context, jobId string) {
+
+	job, err := service.CancelBackfillJob(jobId)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
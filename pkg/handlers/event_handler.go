@@ -7,7 +7,6 @@ import (
 	"github.com/wso2/identity-customer-data-service/pkg/models"
 	"github.com/wso2/identity-customer-data-service/pkg/service"
 	"github.com/wso2/identity-customer-data-service/pkg/utils"
-	"go.mongodb.org/mongo-driver/bson"
 	"net/http"
 	"strconv"
 	"time"
@@ -38,12 +37,69 @@ func (s Server) AddEvent(c *gin.Context) {
 		return
 	}
 
-	if err := service.AddEvents(event); err != nil {
+	eventId, status, err := service.AddEvents(event)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"event_id": eventId, "status": status})
+}
+
+// AddEventBatch handles adding a batch of events and reports the outcome for each event
+func (s Server) AddEventBatch(c *gin.Context) {
+
+	if _, err := authentication.ValidateAuthentication(c); err != nil {
+		clientError := errors.NewClientError(errors.ErrorMessage{
+			Code:        errors.ErrUnAuthorizedRequest.Code,
+			Message:     errors.ErrUnAuthorizedRequest.Message,
+			Description: errors.ErrUnAuthorizedRequest.Description,
+		}, http.StatusUnauthorized)
+		c.JSON(http.StatusUnauthorized, clientError)
+		return
+	}
+
+	var batch models.EventBatch
+
+	if err := c.ShouldBindJSON(&batch); err != nil {
+		clientError := errors.NewClientError(errors.ErrorMessage{
+			Code:        errors.ErrBadRequest.Code,
+			Message:     errors.ErrBadRequest.Message,
+			Description: err.Error(),
+		}, http.StatusBadRequest)
+		c.JSON(http.StatusBadRequest, clientError)
+		return
+	}
+
+	response, err := service.AddEventBatch(batch.Events)
+	if err != nil {
 		utils.HandleError(c, err)
 		return
 	}
 
-	c.Status(http.StatusAccepted)
+	c.JSON(http.StatusAccepted, response)
+}
+
+// GetEventQueueStats reports the depth of the event processing queue and the processing latency
+func (s Server) GetEventQueueStats(c *gin.Context) {
+
+	if _, err := authentication.ValidateAuthentication(c); err != nil {
+		clientError := errors.NewClientError(errors.ErrorMessage{
+			Code:        errors.ErrUnAuthorizedRequest.Code,
+			Message:     errors.ErrUnAuthorizedRequest.Message,
+			Description: errors.ErrUnAuthorizedRequest.Description,
+		}, http.StatusUnauthorized)
+		c.JSON(http.StatusUnauthorized, clientError)
+		return
+	}
+
+	stats, err := service.GetEventQueueStats()
+	if err != nil {
+		utils.HandleError(c, errors.NewServerError(errors.ErrWhileFetchingEventQueueStats, err))
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
 }
 
 // GetEvent fetches a specific event
@@ -62,18 +118,15 @@ func (s Server) GetEvents(c *gin.Context) {
 	rawFilters := c.QueryArray("filter")
 
 	// Step 2: Parse optional time range
-	var timeFilter bson.M
+	var startTime int64
 	if timeStr := c.Query("time_range"); timeStr != "" {
-		durationSec, _ := strconv.Atoi(timeStr)       // parse string to int
-		currentTime := time.Now().UTC().Unix()        // current time in seconds
-		startTime := currentTime - int64(durationSec) // assuming value is in minutes
-		timeFilter = bson.M{
-			"event_timestamp": bson.M{"$gte": startTime},
-		}
+		durationSec, _ := strconv.Atoi(timeStr)      // parse string to int
+		currentTime := time.Now().UTC().Unix()       // current time in seconds
+		startTime = currentTime - int64(durationSec) // assuming value is in minutes
 	}
 
 	// Step 3: Fetch events with filter strings
-	events, err := service.GetEvents(rawFilters, timeFilter)
+	events, err := service.GetEvents(rawFilters, startTime)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch events"})
 		return
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/wso2/identity-customer-data-service/pkg/errors"
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+	"github.com/wso2/identity-customer-data-service/pkg/service"
+	"github.com/wso2/identity-customer-data-service/pkg/utils"
+	"net/http"
+)
+
+// AddEventSchema handles creating a new event schema
+func (s Server) AddEventSchema(c *gin.Context) {
+
+	var schema models.EventSchema
+	if err := c.ShouldBindJSON(&schema); err != nil {
+		badReq := errors.NewClientError(errors.ErrorMessage{
+			Code:        errors.ErrBadRequest.Code,
+			Message:     errors.ErrBadRequest.Message,
+			Description: err.Error(),
+		}, http.StatusBadRequest)
+		utils.HandleError(c, badReq)
+		return
+	}
+
+	created, err := service.AddEventSchema(schema)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+// GetEventSchemas handles retrieving all event schemas
+func (s Server) GetEventSchemas(c *gin.Context) {
+
+	schemas, err := service.GetEventSchemas()
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, schemas)
+}
+
+// GetEventSchema handles retrieving a specific event schema
+func (s Server) GetEventSchema(c *gin.Context, eventSchemaId string) {
+
+	schema, err := service.GetEventSchema(eventSchemaId)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, schema)
+}
+
+// PatchEventSchema applies partial updates to an event schema
+func (s Server) PatchEventSchema(c *gin.Context, eventSchemaId string) {
+
+	var updates map[string]interface{}
+	if err := c.ShouldBindJSON(&updates); err != nil {
+		badReq := errors.NewClientError(errors.ErrorMessage{
+			Code:        errors.ErrBadRequest.Code,
+			Message:     errors.ErrBadRequest.Message,
+			Description: err.Error(),
+		}, http.StatusBadRequest)
+		utils.HandleError(c, badReq)
+		return
+	}
+
+	schema, err := service.PatchEventSchema(eventSchemaId, updates)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, schema)
+}
+
+// DeleteEventSchema handles removing an event schema
+func (s Server) DeleteEventSchema(c *gin.Context, eventSchemaId string) {
+
+	if err := service.DeleteEventSchema(eventSchemaId); err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -33,6 +33,44 @@ func (s Server) DeleteProfile(c *gin.Context, profileId string) {
 	c.Status(http.StatusNoContent)
 }
 
+// UnmergeProfile handles splitting a profile from its master profile
+func (s Server) UnmergeProfile(c *gin.Context, profileId string) {
+
+	var request models.ProfileUnmergeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		clientError := errors.NewClientErrorWithoutCode(errors.ErrorMessage{
+			Code:        errors.ErrBadRequest.Code,
+			Message:     errors.ErrBadRequest.Message,
+			Description: err.Error(),
+		})
+		c.JSON(http.StatusBadRequest, clientError)
+		return
+	}
+
+	author, err := requestAuthor(c)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	unmerge, err := service.UnmergeProfile(profileId, request, author)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, unmerge)
+}
+
+// GetProfileUnmerges handles retrieving the unmerges of a profile
+func (s Server) GetProfileUnmerges(c *gin.Context, profileId string) {
+
+	unmerges, err := service.GetProfileUnmerges(profileId)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, unmerges)
+}
+
 // GetAllProfiles handles profile retrieval with and without filters
 func (s Server) GetAllProfiles(c *gin.Context) {
 
@@ -66,7 +104,12 @@ func (s Server) CreateEnrichmentRule(c *gin.Context) {
 		return
 	}
 
-	err := service.AddEnrichmentRule(rules)
+	author, err := requestAuthor(c)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	err = service.AddEnrichmentRule(rules, author)
 	if err != nil {
 		utils.HandleError(c, err)
 		return
@@ -74,6 +117,28 @@ func (s Server) CreateEnrichmentRule(c *gin.Context) {
 	c.JSON(http.StatusCreated, rules)
 }
 
+// PreviewEnrichmentRule handles previewing what a rule would write for a sample event or a profile
+func (s Server) PreviewEnrichmentRule(c *gin.Context) {
+
+	var request models.EnrichmentRulePreviewRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		clientError := errors.NewClientErrorWithoutCode(errors.ErrorMessage{
+			Code:        errors.ErrBadRequest.Code,
+			Message:     errors.ErrBadRequest.Message,
+			Description: err.Error(),
+		})
+		c.JSON(http.StatusBadRequest, clientError)
+		return
+	}
+
+	preview, err := service.PreviewEnrichmentRule(request)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, preview)
+}
+
 // GetEnrichmentRules handles retrieve of all rules with or without filters
 func (s Server) GetEnrichmentRules(c *gin.Context) {
 
@@ -121,8 +186,15 @@ func (s Server) PutEnrichmentRule(c *gin.Context, ruleId string) {
 			Description: err.Error(),
 		}, http.StatusBadRequest)
 		utils.HandleError(c, badReq)
+		return
+	}
+	rules.RuleId = ruleId
+	author, err := requestAuthor(c)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
 	}
-	err := service.PutEnrichmentRule(rules)
+	err = service.PutEnrichmentRule(rules, author)
 	if err != nil {
 		utils.HandleError(c, err)
 		return
@@ -137,9 +209,15 @@ func (s Server) DeleteEnrichmentRule(c *gin.Context, ruleId string) {
 		return
 	}
 
-	err := service.DeleteEnrichmentRule(ruleId)
+	author, err := requestAuthor(c)
 	if err != nil {
 		utils.HandleError(c, err)
+		return
+	}
+	err = service.DeleteEnrichmentRule(ruleId, author)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
 	}
 	c.JSON(http.StatusNoContent, nil)
 }
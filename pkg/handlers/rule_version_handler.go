@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/wso2/identity-customer-data-service/pkg/authentication"
+	"github.com/wso2/identity-customer-data-service/pkg/service"
+	"github.com/wso2/identity-customer-data-service/pkg/utils"
+	"net/http"
+)
+
+// GetEnrichmentRuleVersions handles retrieving the change history of an enrichment rule
+func (s Server) GetEnrichmentRuleVersions(c *gin.Context, ruleId string) {
+
+	versions, err := service.GetEnrichmentRuleVersions(ruleId)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, versions)
+}
+
+// RollbackEnrichmentRule handles restoring an enrichment rule to a previous version
+func (s Server) RollbackEnrichmentRule(c *gin.Context, ruleId string, version int) {
+
+	author, err := requestAuthor(c)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	rule, err := service.RollbackEnrichmentRule(ruleId, version, author)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, rule)
+}
+
+// GetUnificationRuleVersions handles retrieving the change history of a unification rule
+func (s Server) GetUnificationRuleVersions(c *gin.Context, ruleId string) {
+
+	versions, err := service.GetUnificationRuleVersions(ruleId)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, versions)
+}
+
+// RollbackUnificationRule handles restoring a unification rule to a previous version
+func (s Server) RollbackUnificationRule(c *gin.Context, ruleId string, version int) {
+
+	author, err := requestAuthor(c)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	rule, err := service.RollbackUnificationRule(ruleId, version, author)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, rule)
+}
+
+// requestAuthor identifies who changes a rule from the claims of the bearer token of the request. Changes made
+// without a token are recorded without an author.
+func requestAuthor(c *gin.Context) (string, error) {
+	if c.GetHeader("Authorization") == "" {
+		return "", nil
+	}
+	claims, err := authentication.ValidateAuthentication(c)
+	if err != nil {
+		return "", err
+	}
+	return authentication.ClaimedUser(claims), nil
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/wso2/identity-customer-data-service/pkg/authentication"
+	"github.com/wso2/identity-customer-data-service/pkg/errors"
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+	"github.com/wso2/identity-customer-data-service/pkg/service"
+	"github.com/wso2/identity-customer-data-service/pkg/utils"
+	"net/http"
+)
+
+// SegmentTrack handles a Segment track call
+func (s Server) SegmentTrack(c *gin.Context) {
+	handleSegmentCall(c, "track")
+}
+
+// SegmentIdentify handles a Segment identify call
+func (s Server) SegmentIdentify(c *gin.Context) {
+	handleSegmentCall(c, "identify")
+}
+
+// SegmentPage handles a Segment page call
+func (s Server) SegmentPage(c *gin.Context) {
+	handleSegmentCall(c, "page")
+}
+
+// SegmentScreen handles a Segment screen call
+func (s Server) SegmentScreen(c *gin.Context) {
+	handleSegmentCall(c, "screen")
+}
+
+// SegmentGroup handles a Segment group call
+func (s Server) SegmentGroup(c *gin.Context) {
+	handleSegmentCall(c, "group")
+}
+
+// SegmentAlias handles a Segment alias call
+func (s Server) SegmentAlias(c *gin.Context) {
+	handleSegmentCall(c, "alias")
+}
+
+// SegmentBatch handles a batch of Segment calls and reports the outcome for each call
+func (s Server) SegmentBatch(c *gin.Context) {
+
+	if !authenticateSegmentCall(c) {
+		return
+	}
+
+	var batch models.SegmentBatch
+	if err := c.ShouldBindJSON(&batch); err != nil {
+		badReq := errors.NewClientError(errors.ErrorMessage{
+			Code:        errors.ErrBadRequest.Code,
+			Message:     errors.ErrBadRequest.Message,
+			Description: err.Error(),
+		}, http.StatusBadRequest)
+		utils.HandleError(c, badReq)
+		return
+	}
+
+	response, err := service.AddSegmentBatch(batch)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func handleSegmentCall(c *gin.Context, callType string) {
+
+	if !authenticateSegmentCall(c) {
+		return
+	}
+
+	var message models.SegmentMessage
+	if err := c.ShouldBindJSON(&message); err != nil {
+		badReq := errors.NewClientError(errors.ErrorMessage{
+			Code:        errors.ErrBadRequest.Code,
+			Message:     errors.ErrBadRequest.Message,
+			Description: err.Error(),
+		}, http.StatusBadRequest)
+		utils.HandleError(c, badReq)
+		return
+	}
+
+	if err := service.AddSegmentEvent(callType, message); err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	// Segment SDKs only check for a successful status
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+func authenticateSegmentCall(c *gin.Context) bool {
+
+	if _, err := authentication.ValidateWriteKeyAuthentication(c); err != nil {
+		clientError := errors.NewClientError(errors.ErrorMessage{
+			Code:        errors.ErrUnAuthorizedRequest.Code,
+			Message:     errors.ErrUnAuthorizedRequest.Message,
+			Description: errors.ErrUnAuthorizedRequest.Description,
+		}, http.StatusUnauthorized)
+		c.JSON(http.StatusUnauthorized, clientError)
+		return false
+	}
+	return true
+}
@@ -116,6 +116,8 @@ type ProfileEnrichmentRule struct {
 	Computation   *string                             `json:"computation,omitempty"`
 	CreatedAt     *int                                `json:"created_at,omitempty"`
 	Description   *string                             `json:"description,omitempty"`
+	Expression    *string                             `json:"expression,omitempty"`
+	HalfLife      *string                             `json:"half_life,omitempty"`
 	MergeStrategy *ProfileEnrichmentRuleMergeStrategy `json:"merge_strategy,omitempty"`
 	PropertyName  *string                             `json:"property_name,omitempty"`
 	PropertyType  *ProfileEnrichmentRulePropertyType  `json:"property_type,omitempty"`
@@ -134,6 +136,21 @@ type ProfileEnrichmentRuleMergeStrategy string
 // ProfileEnrichmentRulePropertyType defines model for ProfileEnrichmentRule.PropertyType.
 type ProfileEnrichmentRulePropertyType string
 
+// MatchAttribute defines model for MatchAttribute.
+type MatchAttribute struct {
+	// Comparator Comparator scoring the similarity of the values
+	Comparator *MatchAttributeComparator `json:"comparator,omitempty"`
+
+	// Property Property path to compare
+	Property string `json:"property"`
+
+	// Weight Relative weight of the attribute in the confidence
+	Weight *float32 `json:"weight,omitempty"`
+}
+
+// MatchAttributeComparator Comparator scoring the similarity of the values
+type MatchAttributeComparator string
+
 // ProfileHierarchy defines model for ProfileHierarchy.
 type ProfileHierarchy struct {
 	IsPermanent         *bool               `json:"is_permanent,omitempty"`
@@ -144,28 +161,33 @@ type ProfileHierarchy struct {
 
 // RuleCondition defines model for RuleCondition.
 type RuleCondition struct {
-	Field    *string `json:"field,omitempty"`
-	Operator *string `json:"operator,omitempty"`
-	Value    *string `json:"value,omitempty"`
+	All      *[]RuleCondition `json:"all,omitempty"`
+	Any      *[]RuleCondition `json:"any,omitempty"`
+	Field    *string          `json:"field,omitempty"`
+	Not      *RuleCondition   `json:"not,omitempty"`
+	Operator *string          `json:"operator,omitempty"`
+	Value    *string          `json:"value,omitempty"`
 }
 
 // RuleTrigger defines model for RuleTrigger.
 type RuleTrigger struct {
 	Conditions *[]RuleCondition `json:"conditions,omitempty"`
 	EventName  *string          `json:"event_name,omitempty"`
+	EventNames *[]string        `json:"event_names,omitempty"`
 	EventType  *string          `json:"event_type,omitempty"`
 }
 
 // TemporaryProfile defines model for TemporaryProfile.
 type TemporaryProfile struct {
-	RuleName           *string `json:"rule_name,omitempty"`
-	TemporaryProfileId *string `json:"temporary_profile_id,omitempty"`
+	Confidence         *float32 `json:"confidence,omitempty"`
+	RuleName           *string  `json:"rule_name,omitempty"`
+	TemporaryProfileId *string  `json:"temporary_profile_id,omitempty"`
 }
 
 // UnificationRule defines model for UnificationRule.
 type UnificationRule struct {
-	// Attribute Attribute path to be used for unification
-	Attribute string `json:"attribute"`
+	// Attributes Attributes compared by probabilistic rules
+	Attributes *[]MatchAttribute `json:"attributes,omitempty"`
 
 	// CreatedAt UNIX timestamp of creation
 	CreatedAt *int64 `json:"created_at,omitempty"`
@@ -173,19 +195,46 @@ type UnificationRule struct {
 	// IsActive Whether the rule is currently active
 	IsActive bool `json:"is_active"`
 
+	// Mode Whether the properties must match exactly or the attributes are scored by their similarity
+	Mode *UnificationRuleMode `json:"mode,omitempty"`
+
+	// Normalizers Normalizers applied in order to the values before they are compared
+	Normalizers *[]UnificationRuleNormalizers `json:"normalizers,omitempty"`
+
+	// Operator Whether all (and) or any (or) of the properties must match
+	Operator *UnificationRuleOperator `json:"operator,omitempty"`
+
 	// Priority Priority of the rule (lower number = higher priority)
 	Priority int `json:"priority"`
 
+	// Properties Property paths of a composite rule, combined by the operator
+	Properties *[]string `json:"properties,omitempty"`
+
+	// Property Property path to be used for unification
+	Property *string `json:"property,omitempty"`
+
 	// RuleId Unique identifier for the resolution rule
 	RuleId *openapi_types.UUID `json:"rule_id,omitempty"`
 
 	// RuleName Descriptive name for the rule
 	RuleName string `json:"rule_name"`
 
+	// Threshold Confidence from which probabilistic matches merge
+	Threshold *float32 `json:"threshold,omitempty"`
+
 	// UpdatedAt UNIX timestamp of last update
 	UpdatedAt *int64 `json:"updated_at,omitempty"`
 }
 
+// UnificationRuleMode Whether the properties must match exactly or the attributes are scored by their similarity
+type UnificationRuleMode string
+
+// UnificationRuleNormalizers defines model for UnificationRule.Normalizers.
+type UnificationRuleNormalizers string
+
+// UnificationRuleOperator Whether all (and) or any (or) of the properties must match
+type UnificationRuleOperator string
+
 // UnificationRulePatch defines model for UnificationRulePatch.
 type UnificationRulePatch struct {
 	// IsActive Whether the rule is currently active
@@ -224,6 +273,12 @@ type PatchUnificationRuleJSONRequestBody = UnificationRulePatch
 
 // ServerInterface represents all server handlers.
 type ServerInterface interface {
+	// Get backfill job by ID
+	// (GET /backfill-jobs/{job_id})
+	GetBackfillJob(c *gin.Context, jobId string)
+	// Cancel backfill job
+	// (POST /backfill-jobs/{job_id}/cancel)
+	CancelBackfillJob(c *gin.Context, jobId string)
 	// Give or update consent
 	// (POST /consents)
 	GiveConsent(c *gin.Context)
@@ -239,6 +294,9 @@ type ServerInterface interface {
 	// Create profile enrichment rule
 	// (POST /enrichment-rules)
 	CreateEnrichmentRule(c *gin.Context)
+	// Preview what a profile enrichment rule would write
+	// (POST /enrichment-rules/preview)
+	PreviewEnrichmentRule(c *gin.Context)
 	// Delete profile enrichment rule
 	// (DELETE /enrichment-rules/{rule_id})
 	DeleteEnrichmentRule(c *gin.Context, ruleId string)
@@ -248,12 +306,45 @@ type ServerInterface interface {
 	// Replace profile enrichment rule
 	// (PUT /enrichment-rules/{rule_id})
 	PutEnrichmentRule(c *gin.Context, ruleId string)
+	// Get the backfill jobs of a profile enrichment rule
+	// (GET /enrichment-rules/{rule_id}/backfill-jobs)
+	GetBackfillJobs(c *gin.Context, ruleId string)
+	// Start backfilling a profile enrichment rule
+	// (POST /enrichment-rules/{rule_id}/backfill-jobs)
+	StartBackfillJob(c *gin.Context, ruleId string)
+	// Get the versions of a profile enrichment rule
+	// (GET /enrichment-rules/{rule_id}/versions)
+	GetEnrichmentRuleVersions(c *gin.Context, ruleId string)
+	// Roll back a profile enrichment rule to a previous version
+	// (POST /enrichment-rules/{rule_id}/versions/{version}/rollback)
+	RollbackEnrichmentRule(c *gin.Context, ruleId string, version int)
+	// Get all event schemas
+	// (GET /event-schemas)
+	GetEventSchemas(c *gin.Context)
+	// Create event schema
+	// (POST /event-schemas)
+	AddEventSchema(c *gin.Context)
+	// Delete event schema
+	// (DELETE /event-schemas/{event_schema_id})
+	DeleteEventSchema(c *gin.Context, eventSchemaId string)
+	// Get event schema by ID
+	// (GET /event-schemas/{event_schema_id})
+	GetEventSchema(c *gin.Context, eventSchemaId string)
+	// Patch event schema
+	// (PATCH /event-schemas/{event_schema_id})
+	PatchEventSchema(c *gin.Context, eventSchemaId string)
 	// Get events
 	// (GET /events)
 	GetEvents(c *gin.Context)
 	// Add a single event
 	// (POST /events)
 	AddEvent(c *gin.Context)
+	// Add a batch of events
+	// (POST /events/batch)
+	AddEventBatch(c *gin.Context)
+	// Get event processing queue statistics
+	// (GET /events/queue)
+	GetEventQueueStats(c *gin.Context)
 	// Get write key
 	// (GET /events/write-key/{application_id})
 	GetWriteKey(c *gin.Context, applicationId string)
@@ -269,6 +360,33 @@ type ServerInterface interface {
 	// Retrieve profile by Id
 	// (GET /profiles/{profile_id})
 	GetProfile(c *gin.Context, profileId string)
+	// Split a profile from its master profile
+	// (POST /profiles/{profile_id}/unmerge)
+	UnmergeProfile(c *gin.Context, profileId string)
+	// Get the unmerges of a profile
+	// (GET /profiles/{profile_id}/unmerges)
+	GetProfileUnmerges(c *gin.Context, profileId string)
+	// Segment alias call
+	// (POST /segment/v1/alias)
+	SegmentAlias(c *gin.Context)
+	// Batch of Segment calls
+	// (POST /segment/v1/batch)
+	SegmentBatch(c *gin.Context)
+	// Segment group call
+	// (POST /segment/v1/group)
+	SegmentGroup(c *gin.Context)
+	// Segment identify call
+	// (POST /segment/v1/identify)
+	SegmentIdentify(c *gin.Context)
+	// Segment page call
+	// (POST /segment/v1/page)
+	SegmentPage(c *gin.Context)
+	// Segment screen call
+	// (POST /segment/v1/screen)
+	SegmentScreen(c *gin.Context)
+	// Segment track call
+	// (POST /segment/v1/track)
+	SegmentTrack(c *gin.Context)
 	// Get all unification rules
 	// (GET /unification-rules)
 	GetUnificationRules(c *gin.Context)
@@ -284,6 +402,12 @@ type ServerInterface interface {
 	// Patch unification rule
 	// (PATCH /unification-rules/{rule_id})
 	PatchUnificationRule(c *gin.Context, ruleId string)
+	// Get the versions of a unification rule
+	// (GET /unification-rules/{rule_id}/versions)
+	GetUnificationRuleVersions(c *gin.Context, ruleId string)
+	// Roll back a unification rule to a previous version
+	// (POST /unification-rules/{rule_id}/versions/{version}/rollback)
+	RollbackUnificationRule(c *gin.Context, ruleId string, version int)
 }
 
 // ServerInterfaceWrapper converts contexts to parameters.
@@ -295,6 +419,54 @@ type ServerInterfaceWrapper struct {
 
 type MiddlewareFunc func(c *gin.Context)
 
+// GetBackfillJob operation middleware
+func (siw *ServerInterfaceWrapper) GetBackfillJob(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "job_id" -------------
+	var jobId string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "job_id", c.Param("job_id"), &jobId, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter job_id: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.GetBackfillJob(c, jobId)
+}
+
+// CancelBackfillJob operation middleware
+func (siw *ServerInterfaceWrapper) CancelBackfillJob(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "job_id" -------------
+	var jobId string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "job_id", c.Param("job_id"), &jobId, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter job_id: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.CancelBackfillJob(c, jobId)
+}
+
 // GiveConsent operation middleware
 func (siw *ServerInterfaceWrapper) GiveConsent(c *gin.Context) {
 
@@ -401,6 +573,19 @@ func (siw *ServerInterfaceWrapper) CreateEnrichmentRule(c *gin.Context) {
 	siw.Handler.CreateEnrichmentRule(c)
 }
 
+// PreviewEnrichmentRule operation middleware
+func (siw *ServerInterfaceWrapper) PreviewEnrichmentRule(c *gin.Context) {
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.PreviewEnrichmentRule(c)
+}
+
 // DeleteEnrichmentRule operation middleware
 func (siw *ServerInterfaceWrapper) DeleteEnrichmentRule(c *gin.Context) {
 
@@ -473,6 +658,209 @@ func (siw *ServerInterfaceWrapper) PutEnrichmentRule(c *gin.Context) {
 	siw.Handler.PutEnrichmentRule(c, ruleId)
 }
 
+// GetBackfillJobs operation middleware
+func (siw *ServerInterfaceWrapper) GetBackfillJobs(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "rule_id" -------------
+	var ruleId string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "rule_id", c.Param("rule_id"), &ruleId, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter rule_id: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.GetBackfillJobs(c, ruleId)
+}
+
+// StartBackfillJob operation middleware
+func (siw *ServerInterfaceWrapper) StartBackfillJob(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "rule_id" -------------
+	var ruleId string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "rule_id", c.Param("rule_id"), &ruleId, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter rule_id: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.StartBackfillJob(c, ruleId)
+}
+
+// GetEnrichmentRuleVersions operation middleware
+func (siw *ServerInterfaceWrapper) GetEnrichmentRuleVersions(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "rule_id" -------------
+	var ruleId string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "rule_id", c.Param("rule_id"), &ruleId, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter rule_id: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.GetEnrichmentRuleVersions(c, ruleId)
+}
+
+// RollbackEnrichmentRule operation middleware
+func (siw *ServerInterfaceWrapper) RollbackEnrichmentRule(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "rule_id" -------------
+	var ruleId string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "rule_id", c.Param("rule_id"), &ruleId, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter rule_id: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Path parameter "version" -------------
+	var version int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "version", c.Param("version"), &version, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter version: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.RollbackEnrichmentRule(c, ruleId, version)
+}
+
+// GetEventSchemas operation middleware
+func (siw *ServerInterfaceWrapper) GetEventSchemas(c *gin.Context) {
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.GetEventSchemas(c)
+}
+
+// AddEventSchema operation middleware
+func (siw *ServerInterfaceWrapper) AddEventSchema(c *gin.Context) {
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.AddEventSchema(c)
+}
+
+// DeleteEventSchema operation middleware
+func (siw *ServerInterfaceWrapper) DeleteEventSchema(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "event_schema_id" -------------
+	var eventSchemaId string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "event_schema_id", c.Param("event_schema_id"), &eventSchemaId, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter event_schema_id: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.DeleteEventSchema(c, eventSchemaId)
+}
+
+// GetEventSchema operation middleware
+func (siw *ServerInterfaceWrapper) GetEventSchema(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "event_schema_id" -------------
+	var eventSchemaId string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "event_schema_id", c.Param("event_schema_id"), &eventSchemaId, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter event_schema_id: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.GetEventSchema(c, eventSchemaId)
+}
+
+// PatchEventSchema operation middleware
+func (siw *ServerInterfaceWrapper) PatchEventSchema(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "event_schema_id" -------------
+	var eventSchemaId string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "event_schema_id", c.Param("event_schema_id"), &eventSchemaId, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter event_schema_id: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.PatchEventSchema(c, eventSchemaId)
+}
+
 // GetEvents operation middleware
 func (siw *ServerInterfaceWrapper) GetEvents(c *gin.Context) {
 
@@ -501,6 +889,36 @@ func (siw *ServerInterfaceWrapper) AddEvent(c *gin.Context) {
 	siw.Handler.AddEvent(c)
 }
 
+// AddEventBatch operation middleware
+func (siw *ServerInterfaceWrapper) AddEventBatch(c *gin.Context) {
+
+	c.Set(BearerAuthScopes, []string{})
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.AddEventBatch(c)
+}
+
+// GetEventQueueStats operation middleware
+func (siw *ServerInterfaceWrapper) GetEventQueueStats(c *gin.Context) {
+
+	c.Set(BearerAuthScopes, []string{})
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.GetEventQueueStats(c)
+}
+
 // GetWriteKey operation middleware
 func (siw *ServerInterfaceWrapper) GetWriteKey(c *gin.Context) {
 
@@ -610,6 +1028,159 @@ func (siw *ServerInterfaceWrapper) GetProfile(c *gin.Context) {
 	siw.Handler.GetProfile(c, profileId)
 }
 
+// UnmergeProfile operation middleware
+func (siw *ServerInterfaceWrapper) UnmergeProfile(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "profile_id" -------------
+	var profileId string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "profile_id", c.Param("profile_id"), &profileId, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter profile_id: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.UnmergeProfile(c, profileId)
+}
+
+// GetProfileUnmerges operation middleware
+func (siw *ServerInterfaceWrapper) GetProfileUnmerges(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "profile_id" -------------
+	var profileId string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "profile_id", c.Param("profile_id"), &profileId, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter profile_id: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.GetProfileUnmerges(c, profileId)
+}
+
+// SegmentAlias operation middleware
+func (siw *ServerInterfaceWrapper) SegmentAlias(c *gin.Context) {
+
+	c.Set(BearerAuthScopes, []string{})
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.SegmentAlias(c)
+}
+
+// SegmentBatch operation middleware
+func (siw *ServerInterfaceWrapper) SegmentBatch(c *gin.Context) {
+
+	c.Set(BearerAuthScopes, []string{})
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.SegmentBatch(c)
+}
+
+// SegmentGroup operation middleware
+func (siw *ServerInterfaceWrapper) SegmentGroup(c *gin.Context) {
+
+	c.Set(BearerAuthScopes, []string{})
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.SegmentGroup(c)
+}
+
+// SegmentIdentify operation middleware
+func (siw *ServerInterfaceWrapper) SegmentIdentify(c *gin.Context) {
+
+	c.Set(BearerAuthScopes, []string{})
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.SegmentIdentify(c)
+}
+
+// SegmentPage operation middleware
+func (siw *ServerInterfaceWrapper) SegmentPage(c *gin.Context) {
+
+	c.Set(BearerAuthScopes, []string{})
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.SegmentPage(c)
+}
+
+// SegmentScreen operation middleware
+func (siw *ServerInterfaceWrapper) SegmentScreen(c *gin.Context) {
+
+	c.Set(BearerAuthScopes, []string{})
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.SegmentScreen(c)
+}
+
+// SegmentTrack operation middleware
+func (siw *ServerInterfaceWrapper) SegmentTrack(c *gin.Context) {
+
+	c.Set(BearerAuthScopes, []string{})
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.SegmentTrack(c)
+}
+
 // GetUnificationRules operation middleware
 func (siw *ServerInterfaceWrapper) GetUnificationRules(c *gin.Context) {
 
@@ -708,6 +1279,63 @@ func (siw *ServerInterfaceWrapper) PatchUnificationRule(c *gin.Context) {
 	siw.Handler.PatchUnificationRule(c, ruleId)
 }
 
+// GetUnificationRuleVersions operation middleware
+func (siw *ServerInterfaceWrapper) GetUnificationRuleVersions(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "rule_id" -------------
+	var ruleId string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "rule_id", c.Param("rule_id"), &ruleId, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter rule_id: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.GetUnificationRuleVersions(c, ruleId)
+}
+
+// RollbackUnificationRule operation middleware
+func (siw *ServerInterfaceWrapper) RollbackUnificationRule(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "rule_id" -------------
+	var ruleId string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "rule_id", c.Param("rule_id"), &ruleId, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter rule_id: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Path parameter "version" -------------
+	var version int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "version", c.Param("version"), &version, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter version: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.RollbackUnificationRule(c, ruleId, version)
+}
+
 // GinServerOptions provides options for the Gin server.
 type GinServerOptions struct {
 	BaseURL      string
@@ -735,24 +1363,49 @@ func RegisterHandlersWithOptions(router gin.IRouter, si ServerInterface, options
 		ErrorHandler:       errorHandler,
 	}
 
+	router.GET(options.BaseURL+"/backfill-jobs/:job_id", wrapper.GetBackfillJob)
+	router.POST(options.BaseURL+"/backfill-jobs/:job_id/cancel", wrapper.CancelBackfillJob)
 	router.POST(options.BaseURL+"/consents", wrapper.GiveConsent)
 	router.DELETE(options.BaseURL+"/consents/:profile_id", wrapper.RevokeAllConsents)
 	router.GET(options.BaseURL+"/consents/:profile_id", wrapper.GetUserConsents)
 	router.GET(options.BaseURL+"/enrichment-rules", wrapper.GetEnrichmentRules)
 	router.POST(options.BaseURL+"/enrichment-rules", wrapper.CreateEnrichmentRule)
+	router.POST(options.BaseURL+"/enrichment-rules/preview", wrapper.PreviewEnrichmentRule)
 	router.DELETE(options.BaseURL+"/enrichment-rules/:rule_id", wrapper.DeleteEnrichmentRule)
 	router.GET(options.BaseURL+"/enrichment-rules/:rule_id", wrapper.GetEnrichmentRule)
 	router.PUT(options.BaseURL+"/enrichment-rules/:rule_id", wrapper.PutEnrichmentRule)
+	router.GET(options.BaseURL+"/enrichment-rules/:rule_id/backfill-jobs", wrapper.GetBackfillJobs)
+	router.POST(options.BaseURL+"/enrichment-rules/:rule_id/backfill-jobs", wrapper.StartBackfillJob)
+	router.GET(options.BaseURL+"/enrichment-rules/:rule_id/versions", wrapper.GetEnrichmentRuleVersions)
+	router.POST(options.BaseURL+"/enrichment-rules/:rule_id/versions/:version/rollback", wrapper.RollbackEnrichmentRule)
+	router.GET(options.BaseURL+"/event-schemas", wrapper.GetEventSchemas)
+	router.POST(options.BaseURL+"/event-schemas", wrapper.AddEventSchema)
+	router.DELETE(options.BaseURL+"/event-schemas/:event_schema_id", wrapper.DeleteEventSchema)
+	router.GET(options.BaseURL+"/event-schemas/:event_schema_id", wrapper.GetEventSchema)
+	router.PATCH(options.BaseURL+"/event-schemas/:event_schema_id", wrapper.PatchEventSchema)
 	router.GET(options.BaseURL+"/events", wrapper.GetEvents)
 	router.POST(options.BaseURL+"/events", wrapper.AddEvent)
+	router.POST(options.BaseURL+"/events/batch", wrapper.AddEventBatch)
+	router.GET(options.BaseURL+"/events/queue", wrapper.GetEventQueueStats)
 	router.GET(options.BaseURL+"/events/write-key/:application_id", wrapper.GetWriteKey)
 	router.GET(options.BaseURL+"/events/:event_id", wrapper.GetEvent)
 	router.GET(options.BaseURL+"/profiles", wrapper.GetAllProfiles)
 	router.DELETE(options.BaseURL+"/profiles/:profile_id", wrapper.DeleteProfile)
 	router.GET(options.BaseURL+"/profiles/:profile_id", wrapper.GetProfile)
+	router.POST(options.BaseURL+"/profiles/:profile_id/unmerge", wrapper.UnmergeProfile)
+	router.GET(options.BaseURL+"/profiles/:profile_id/unmerges", wrapper.GetProfileUnmerges)
+	router.POST(options.BaseURL+"/segment/v1/alias", wrapper.SegmentAlias)
+	router.POST(options.BaseURL+"/segment/v1/batch", wrapper.SegmentBatch)
+	router.POST(options.BaseURL+"/segment/v1/group", wrapper.SegmentGroup)
+	router.POST(options.BaseURL+"/segment/v1/identify", wrapper.SegmentIdentify)
+	router.POST(options.BaseURL+"/segment/v1/page", wrapper.SegmentPage)
+	router.POST(options.BaseURL+"/segment/v1/screen", wrapper.SegmentScreen)
+	router.POST(options.BaseURL+"/segment/v1/track", wrapper.SegmentTrack)
 	router.GET(options.BaseURL+"/unification-rules", wrapper.GetUnificationRules)
 	router.POST(options.BaseURL+"/unification-rules", wrapper.AddUnificationRule)
 	router.DELETE(options.BaseURL+"/unification-rules/:rule_id", wrapper.DeleteUnificationRule)
 	router.GET(options.BaseURL+"/unification-rules/:rule_id", wrapper.GetUnificationRule)
 	router.PATCH(options.BaseURL+"/unification-rules/:rule_id", wrapper.PatchUnificationRule)
+	router.GET(options.BaseURL+"/unification-rules/:rule_id/versions", wrapper.GetUnificationRuleVersions)
+	router.POST(options.BaseURL+"/unification-rules/:rule_id/versions/:version/rollback", wrapper.RollbackUnificationRule)
 }
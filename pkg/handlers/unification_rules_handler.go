@@ -6,7 +6,6 @@ import (
 	"github.com/wso2/identity-customer-data-service/pkg/models"
 	"github.com/wso2/identity-customer-data-service/pkg/service"
 	"github.com/wso2/identity-customer-data-service/pkg/utils"
-	"go.mongodb.org/mongo-driver/bson"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -29,7 +28,12 @@ func (s Server) AddUnificationRule(c *gin.Context) {
 		utils.HandleError(c, badReq)
 		return
 	}
-	err := service.AddUnificationRule(rule)
+	author, err := requestAuthor(c)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	err = service.AddUnificationRule(rule, author)
 	if err != nil {
 		utils.HandleError(c, err)
 		return
@@ -62,7 +66,7 @@ func (s Server) GetUnificationRule(c *gin.Context, ruleId string) {
 // PatchUnificationRule applies partial updates to a unification rule.
 func (s Server) PatchUnificationRule(c *gin.Context, ruleId string) {
 
-	var updates bson.M
+	var updates map[string]interface{}
 	if err := c.ShouldBindJSON(&updates); err != nil {
 		badReq := errors.NewClientError(errors.ErrorMessage{
 			Code:        errors.ErrBadRequest.Code,
@@ -74,7 +78,12 @@ func (s Server) PatchUnificationRule(c *gin.Context, ruleId string) {
 		return
 	}
 
-	err := service.PatchResolutionRule(ruleId, updates)
+	author, err := requestAuthor(c)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	err = service.PatchResolutionRule(ruleId, updates, author)
 	if err != nil {
 		utils.HandleError(c, err)
 		return
@@ -88,7 +97,12 @@ func (s Server) PatchUnificationRule(c *gin.Context, ruleId string) {
 // DeleteUnificationRule removes a resolution rule.
 func (s Server) DeleteUnificationRule(c *gin.Context, ruleId string) {
 
-	err := service.DeleteUnificationRule(ruleId)
+	author, err := requestAuthor(c)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	err = service.DeleteUnificationRule(ruleId, author)
 	if err != nil {
 		utils.HandleError(c, err)
 		return
@@ -1,21 +1,84 @@
 package locks
 
 import (
-	"go.mongodb.org/mongo-driver/mongo"
+	"sync"
 	"time"
+
+	"github.com/google/uuid"
 )
 
+// DistributedLock is a lock shared by the instances of the service. A lock that is held past its ttl can be taken
+// over by another owner, so each acquisition gets a token of its own that releasing it requires.
 type DistributedLock interface {
-	Acquire(key string, ttl time.Duration) (bool, error)
-	Release(key string) error
+	// Acquire takes the lock for the ttl and returns the token of the new owner, or an empty token if another owner
+	// holds the lock
+	Acquire(key string, ttl time.Duration) (string, error)
+	// Release releases the lock if the owner still holds it. A lock taken over by another owner is left to it.
+	Release(key string, owner string) error
+}
+
+// newOwnerToken identifies an acquisition of a lock
+func newOwnerToken() string {
+	return uuid.New().String()
 }
 
-var mongoLock DistributedLock
+var distributedLock *heldLocks
 
-func InitLocks(db *mongo.Database) {
-	mongoLock = NewMongoLock(db)
+// InitLocks sets the lock implementation used across the service
+func InitLocks(lock DistributedLock) {
+	distributedLock = &heldLocks{DistributedLock: lock, owners: make(map[string]string)}
 }
 
 func GetDistributedLock() DistributedLock {
-	return mongoLock
+	return distributedLock
+}
+
+// ReleaseAll releases the locks that are currently held by this instance so that other instances do not have to
+// wait for them to expire. It is called on shutdown.
+func ReleaseAll() error {
+	if distributedLock == nil {
+		return nil
+	}
+	return distributedLock.releaseAll()
+}
+
+// heldLocks keeps track of the owners of the keys acquired through the underlying lock until they are released
+type heldLocks struct {
+	DistributedLock
+	mutex  sync.Mutex
+	owners map[string]string
+}
+
+func (l *heldLocks) Acquire(key string, ttl time.Duration) (string, error) {
+	owner, err := l.DistributedLock.Acquire(key, ttl)
+	if owner != "" {
+		l.mutex.Lock()
+		l.owners[key] = owner
+		l.mutex.Unlock()
+	}
+	return owner, err
+}
+
+func (l *heldLocks) Release(key string, owner string) error {
+	l.mutex.Lock()
+	if l.owners[key] == owner {
+		delete(l.owners, key)
+	}
+	l.mutex.Unlock()
+	return l.DistributedLock.Release(key, owner)
+}
+
+func (l *heldLocks) releaseAll() error {
+	l.mutex.Lock()
+	owners := l.owners
+	l.owners = make(map[string]string)
+	l.mutex.Unlock()
+
+	var firstErr error
+	for key, owner := range owners {
+		if err := l.DistributedLock.Release(key, owner); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
 }
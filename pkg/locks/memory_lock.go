@@ -0,0 +1,46 @@
+package locks
+
+import (
+	"sync"
+	"time"
+)
+
+// InMemoryLock is a process local lock used when running without a shared database
+type InMemoryLock struct {
+	mutex sync.Mutex
+	held  map[string]heldLock
+}
+
+type heldLock struct {
+	owner     string
+	expiresAt time.Time
+}
+
+func NewInMemoryLock() DistributedLock {
+	return &InMemoryLock{
+		held: make(map[string]heldLock),
+	}
+}
+
+func (l *InMemoryLock) Acquire(key string, ttl time.Duration) (string, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if lock, ok := l.held[key]; ok && time.Now().Before(lock.expiresAt) {
+		// lock already held and not expired yet
+		return "", nil
+	}
+	owner := newOwnerToken()
+	l.held[key] = heldLock{owner: owner, expiresAt: time.Now().Add(ttl)}
+	return owner, nil
+}
+
+func (l *InMemoryLock) Release(key string, owner string) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.held[key].owner == owner {
+		delete(l.held, key)
+	}
+	return nil
+}
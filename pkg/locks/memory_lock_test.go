@@ -0,0 +1,60 @@
+package locks
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInMemoryLockExpiresAndKeepsItsOwner(t *testing.T) {
+	lock := NewInMemoryLock()
+
+	first, err := lock.Acquire("key", 20*time.Millisecond)
+	if err != nil || first == "" {
+		t.Fatalf("failed to acquire a free lock: %v", err)
+	}
+	if owner, _ := lock.Acquire("key", time.Second); owner != "" {
+		t.Fatalf("acquired a lock that is held")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	second, err := lock.Acquire("key", time.Second)
+	if err != nil || second == "" || second == first {
+		t.Fatalf("failed to take over an expired lock: %q, %v", second, err)
+	}
+
+	// The first owner releasing late leaves the lock to the second one
+	if err := lock.Release("key", first); err != nil {
+		t.Fatalf("failed to release the lock: %v", err)
+	}
+	if owner, _ := lock.Acquire("key", time.Second); owner != "" {
+		t.Fatalf("a lock taken over was released by its previous owner")
+	}
+
+	if err := lock.Release("key", second); err != nil {
+		t.Fatalf("failed to release the lock: %v", err)
+	}
+	if owner, _ := lock.Acquire("key", time.Second); owner == "" {
+		t.Fatalf("failed to acquire a released lock")
+	}
+}
+
+func TestReleaseAllReleasesTheHeldLocks(t *testing.T) {
+	InitLocks(NewInMemoryLock())
+	lock := GetDistributedLock()
+
+	released, _ := lock.Acquire("released", time.Minute)
+	if _, err := lock.Acquire("held", time.Minute); err != nil {
+		t.Fatalf("failed to acquire the lock: %v", err)
+	}
+	if err := lock.Release("released", released); err != nil {
+		t.Fatalf("failed to release the lock: %v", err)
+	}
+	if err := ReleaseAll(); err != nil {
+		t.Fatalf("failed to release all locks: %v", err)
+	}
+	for _, key := range []string{"released", "held"} {
+		if owner, _ := lock.Acquire(key, time.Minute); owner == "" {
+			t.Errorf("lock %s is still held after releasing all locks", key)
+		}
+	}
+}
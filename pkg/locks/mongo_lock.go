@@ -18,12 +18,14 @@ func NewMongoLock(db *mongo.Database) DistributedLock {
 	}
 }
 
-func (l *MongoLock) Acquire(key string, ttl time.Duration) (bool, error) {
+func (l *MongoLock) Acquire(key string, ttl time.Duration) (string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
 
+	owner := newOwnerToken()
 	lock := bson.M{
 		"_id":        key,
+		"owner":      owner,
 		"created_at": time.Now(),
 		"expires_at": time.Now().Add(ttl),
 	}
@@ -31,16 +33,16 @@ func (l *MongoLock) Acquire(key string, ttl time.Duration) (bool, error) {
 	_, err := l.Collection.InsertOne(ctx, lock)
 	if err != nil {
 		// Duplicate key => lock already held
-		return false, nil
+		return "", nil
 	}
 
-	return true, nil
+	return owner, nil
 }
 
-func (l *MongoLock) Release(key string) error {
+func (l *MongoLock) Release(key string, owner string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
 
-	_, err := l.Collection.DeleteOne(ctx, bson.M{"_id": key})
+	_, err := l.Collection.DeleteOne(ctx, bson.M{"_id": key, "owner": owner})
 	return err
 }
@@ -0,0 +1,29 @@
+package models
+
+// BackfillJob replays the stored events of profiles through an enrichment rule to recompute the property the rule
+// writes. Times are unix seconds.
+type BackfillJob struct {
+	JobId             string             `json:"job_id" bson:"job_id"`
+	RuleId            string             `json:"rule_id" bson:"rule_id"`
+	Filters           []string           `json:"filters,omitempty" bson:"filters,omitempty"` // profile filters, all profiles if empty
+	Status            string             `json:"status" bson:"status"`                       // pending, running, completed, failed, cancelled
+	TotalProfiles     int                `json:"total_profiles" bson:"total_profiles"`
+	ProcessedProfiles int                `json:"processed_profiles" bson:"processed_profiles"`
+	UpdatedProfiles   int                `json:"updated_profiles" bson:"updated_profiles"`
+	FailedProfiles    int                `json:"failed_profiles" bson:"failed_profiles"`
+	Errors            []BackfillJobError `json:"errors,omitempty" bson:"errors,omitempty"`
+	CreatedAt         int64              `json:"created_at" bson:"created_at"`
+	StartedAt         int64              `json:"started_at,omitempty" bson:"started_at,omitempty"`
+	FinishedAt        int64              `json:"finished_at,omitempty" bson:"finished_at,omitempty"`
+}
+
+// BackfillJobError describes why a profile, or the job as a whole when there is no profile id, could not be backfilled
+type BackfillJobError struct {
+	ProfileId string `json:"profile_id,omitempty" bson:"profile_id,omitempty"`
+	Error     string `json:"error" bson:"error"`
+}
+
+// BackfillJobRequest selects the profiles to backfill an enrichment rule for
+type BackfillJobRequest struct {
+	Filters []string `json:"filters,omitempty"`
+}
@@ -0,0 +1,19 @@
+package models
+
+// EnrichmentRulePreviewRequest is a rule to preview against a sample event or the latest matching event of a profile
+type EnrichmentRulePreviewRequest struct {
+	Rule      ProfileEnrichmentRule `json:"rule" binding:"required"`
+	Event     *Event                `json:"event,omitempty"`
+	ProfileId string                `json:"profile_id,omitempty"`
+}
+
+// EnrichmentRulePreview is what a rule would write for an event. Nothing of it is persisted.
+type EnrichmentRulePreview struct {
+	Matched       bool        `json:"matched"`
+	PropertyName  string      `json:"property_name"`
+	Event         *Event      `json:"event,omitempty"`
+	Value         interface{} `json:"value,omitempty"`
+	ExistingValue interface{} `json:"existing_value,omitempty"`
+	MergedValue   interface{} `json:"merged_value,omitempty"`
+	Error         string      `json:"error,omitempty"`
+}
@@ -1,5 +1,7 @@
 package models
 
+import "encoding/json"
+
 type Event struct {
 	ProfileId      string                 `json:"profile_id" bson:"profile_id"`
 	EventType      string                 `json:"event_type" bson:"event_type"`
@@ -11,3 +13,27 @@ type Event struct {
 	Properties     map[string]interface{} `json:"properties,omitempty" bson:"properties,omitempty"`
 	Context        map[string]interface{} `json:"context,omitempty" bson:"context,omitempty"`
 }
+
+// EventBatch is a batch of events submitted together. Events are kept raw so that a malformed event only
+// rejects itself instead of the whole batch.
+type EventBatch struct {
+	Events []json.RawMessage `json:"events" binding:"required"`
+}
+
+// EventBatchResult reports the outcome for a single event of a batch
+type EventBatchResult struct {
+	Index        int    `json:"index"`
+	EventId      string `json:"event_id,omitempty"`
+	Status       string `json:"status"` // accepted, rejected, quarantined or duplicate
+	ErrorCode    string `json:"error_code,omitempty"`
+	ErrorMessage string `json:"error_message,omitempty"`
+}
+
+// EventBatchResponse summarizes the outcome of a batch ingestion
+type EventBatchResponse struct {
+	Accepted    int                `json:"accepted"`
+	Rejected    int                `json:"rejected"`
+	Quarantined int                `json:"quarantined"`
+	Duplicates  int                `json:"duplicates"` // replayed events that were already stored
+	Results     []EventBatchResult `json:"results"`
+}
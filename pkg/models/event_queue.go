@@ -0,0 +1,30 @@
+package models
+
+// QueuedEvent is an event waiting in the processing queue to be enriched and unified
+type QueuedEvent struct {
+	QueueId        string `json:"queue_id" bson:"queue_id"`
+	Event          Event  `json:"event" bson:"event"`
+	Attempts       int    `json:"attempts" bson:"attempts"`
+	EnqueuedAt     int64  `json:"enqueued_at" bson:"enqueued_at"`   // unix nanoseconds, defines the processing order
+	AvailableAt    int64  `json:"available_at" bson:"available_at"` // unix milliseconds after which it can be claimed
+	ClaimedBy      string `json:"claimed_by,omitempty" bson:"claimed_by,omitempty"`
+	LeaseExpiresAt int64  `json:"lease_expires_at" bson:"lease_expires_at"` // unix milliseconds until which the claim holds
+	LastError      string `json:"last_error,omitempty" bson:"last_error,omitempty"`
+}
+
+// DeadLetterEvent is a queued event that could not be processed within the allowed number of attempts
+type DeadLetterEvent struct {
+	QueuedEvent `bson:",inline"`
+	FailedAt    int64 `json:"failed_at" bson:"failed_at"`
+}
+
+// EventQueueStats reports the backlog of the event processing queue and the performance of the workers draining it
+type EventQueueStats struct {
+	QueueDepth            int64   `json:"queue_depth"` // events waiting in the queue, including the ones in progress
+	Workers               int     `json:"workers"`
+	InFlight              int64   `json:"in_flight"`
+	Processed             int64   `json:"processed"`
+	Failed                int64   `json:"failed"`
+	AverageProcessingMs   float64 `json:"average_processing_ms"`    // time spent enriching and unifying an event
+	AverageQueueLatencyMs float64 `json:"average_queue_latency_ms"` // time from enqueueing to the end of processing
+}
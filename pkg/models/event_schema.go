@@ -10,4 +10,5 @@ type EventSchema struct {
 type EventProperty struct {
 	PropertyName string `json:"property_name" bson:"property_name" bind:"required"`
 	PropertyType string `json:"property_type" bson:"property_type" bind:"required"`
+	Required     bool   `json:"required,omitempty" bson:"required,omitempty"` // events without the property are rejected
 }
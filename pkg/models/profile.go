@@ -10,8 +10,9 @@ type ProfileHierarchy struct {
 }
 
 type ChildProfile struct {
-	ChildProfileId string `json:"child_profile_id,omitempty" bson:"child_profile_id,omitempty"`
-	RuleName       string `json:"rule_name,omitempty" bson:"rule_name,omitempty"`
+	ChildProfileId string  `json:"child_profile_id,omitempty" bson:"child_profile_id,omitempty"`
+	RuleName       string  `json:"rule_name,omitempty" bson:"rule_name,omitempty"`
+	Confidence     float64 `json:"confidence,omitempty" bson:"confidence,omitempty"` // of the match that joined the profile, 1 for exact matches
 }
 
 type Profile struct {
@@ -32,7 +33,9 @@ type ProfileEnrichmentRule struct {
 	ValueType       string      `json:"value_type,omitempty" bson:"value_type,omitempty"`       // required if trait_type == static
 	Computation     string      `json:"computation,omitempty" bson:"computation,omitempty"`     // if trait_type == computed
 	SourceFields    []string    `json:"source_fields,omitempty" bson:"source_fields,omitempty"` // For concat
+	Expression      string      `json:"expression,omitempty" bson:"expression,omitempty"`       // if computation == expression
 	TimeRange       string      `json:"time_range,omitempty" bson:"time_range,omitempty"`       // e.g., "7d", "30d" for count aggregation
+	HalfLife        string      `json:"half_life,omitempty" bson:"half_life,omitempty"`         // e.g., "7d" if computation == decay
 	MergeStrategy   string      `json:"merge_strategy" bson:"merge_strategy"`                   // overwrite, combine, ignore
 	MaskingRequired bool        `json:"masking_required" bson:"masking_required"`
 	MaskingStrategy string      `json:"masking_strategy,omitempty" bson:"masking_strategy,omitempty"` // optional if MaskingRequired == false
@@ -44,13 +47,19 @@ type ProfileEnrichmentRule struct {
 type RuleTrigger struct {
 	EventType  string          `json:"event_type" bson:"event_type"`
 	EventName  string          `json:"event_name" bson:"event_name"`
+	EventNames []string        `json:"event_names,omitempty" bson:"event_names,omitempty"` // any of, instead of event_name
 	Conditions []RuleCondition `json:"conditions" bson:"conditions"`
 }
 
+// RuleCondition is either a comparison of an event field or a group of conditions of which all, any or none
+// (not) must hold
 type RuleCondition struct {
-	Field    string `json:"field" bson:"field"`
-	Operator string `json:"operator" bson:"operator"`
-	Value    string `json:"value" bson:"value"`
+	Field    string          `json:"field,omitempty" bson:"field,omitempty"`
+	Operator string          `json:"operator,omitempty" bson:"operator,omitempty"`
+	Value    string          `json:"value,omitempty" bson:"value,omitempty"`
+	All      []RuleCondition `json:"all,omitempty" bson:"all,omitempty"`
+	Any      []RuleCondition `json:"any,omitempty" bson:"any,omitempty"`
+	Not      *RuleCondition  `json:"not,omitempty" bson:"not,omitempty"`
 }
 
 func (a ApplicationData) MarshalJSON() ([]byte, error) {
@@ -0,0 +1,19 @@
+package models
+
+// ProfileUnmerge records the split of a profile from the master profile it was unified with. The profile is kept
+// apart from the profiles that remained with the master, so that the unification rules do not merge them again.
+type ProfileUnmerge struct {
+	UnmergeId       string   `json:"unmerge_id" bson:"unmerge_id"`
+	ProfileId       string   `json:"profile_id" bson:"profile_id"`
+	MasterProfileId string   `json:"master_profile_id" bson:"master_profile_id"`
+	RuleName        string   `json:"rule_name,omitempty" bson:"rule_name,omitempty"` // that had merged the profile
+	SeparatedFrom   []string `json:"separated_from,omitempty" bson:"separated_from,omitempty"`
+	Reason          string   `json:"reason" bson:"reason"`
+	Author          string   `json:"author,omitempty" bson:"author,omitempty"`
+	UnmergedAt      int64    `json:"unmerged_at" bson:"unmerged_at"`
+}
+
+// ProfileUnmergeRequest is the request to split a profile from its master profile
+type ProfileUnmergeRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
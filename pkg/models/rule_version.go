@@ -0,0 +1,23 @@
+package models
+
+// RuleVersion is an immutable snapshot of an enrichment or unification rule, recorded every time the rule is
+// created, changed, deleted or rolled back
+type RuleVersion struct {
+	RuleId          string                 `json:"rule_id" bson:"rule_id"`
+	RuleType        string                 `json:"rule_type" bson:"rule_type"` // enrichment or unification
+	Version         int                    `json:"version" bson:"version"`     // starts at 1 for each rule
+	Change          string                 `json:"change" bson:"change"`       // created, updated, deleted or rolled_back
+	RolledBackTo    int                    `json:"rolled_back_to,omitempty" bson:"rolled_back_to,omitempty"`
+	Author          string                 `json:"author,omitempty" bson:"author,omitempty"`
+	Diff            []RuleFieldChange      `json:"diff,omitempty" bson:"diff,omitempty"`
+	EnrichmentRule  *ProfileEnrichmentRule `json:"enrichment_rule,omitempty" bson:"enrichment_rule,omitempty"`
+	UnificationRule *UnificationRule       `json:"unification_rule,omitempty" bson:"unification_rule,omitempty"`
+	CreatedAt       int64                  `json:"created_at" bson:"created_at"`
+}
+
+// RuleFieldChange is a field of a rule that differs from the previous version of the rule
+type RuleFieldChange struct {
+	Field    string      `json:"field" bson:"field"`
+	Previous interface{} `json:"previous,omitempty" bson:"previous,omitempty"`
+	Current  interface{} `json:"current,omitempty" bson:"current,omitempty"`
+}
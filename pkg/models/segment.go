@@ -0,0 +1,28 @@
+package models
+
+import "encoding/json"
+
+// SegmentMessage is a call of the Segment HTTP tracking API (track, identify, page, screen, group or alias). The
+// same payload is accepted by RudderStack.
+type SegmentMessage struct {
+	Type              string                 `json:"type"`
+	MessageId         string                 `json:"messageId"`
+	AnonymousId       string                 `json:"anonymousId"`
+	UserId            string                 `json:"userId"`
+	Event             string                 `json:"event"`      // track
+	Name              string                 `json:"name"`       // page and screen
+	Category          string                 `json:"category"`   // page
+	GroupId           string                 `json:"groupId"`    // group
+	PreviousId        string                 `json:"previousId"` // alias
+	Properties        map[string]interface{} `json:"properties,omitempty"`
+	Traits            map[string]interface{} `json:"traits,omitempty"`
+	Context           map[string]interface{} `json:"context,omitempty"`
+	Timestamp         string                 `json:"timestamp"` // ISO-8601
+	OriginalTimestamp string                 `json:"originalTimestamp"`
+}
+
+// SegmentBatch is a batch of Segment calls. The batch context applies to the calls that have none.
+type SegmentBatch struct {
+	Batch   []json.RawMessage      `json:"batch" binding:"required"`
+	Context map[string]interface{} `json:"context,omitempty"`
+}
@@ -2,11 +2,25 @@ package models
 
 // UnificationRule represents rules for merging user profiles
 type UnificationRule struct {
-	RuleId    string `json:"rule_id" bson:"rule_id" binding:"required"`
-	RuleName  string `json:"rule_name" bson:"rule_name" binding:"required"`
-	Property  string `json:"property" bson:"property" binding:"required"`
-	Priority  int    `json:"priority" bson:"priority" binding:"required"`
-	IsActive  bool   `json:"is_active" bson:"is_active" binding:"required"`
-	CreatedAt int64  `json:"created_at" bson:"created_at"`
-	UpdatedAt int64  `json:"updated_at" bson:"updated_at"`
+	RuleId      string           `json:"rule_id" bson:"rule_id" binding:"required"`
+	RuleName    string           `json:"rule_name" bson:"rule_name" binding:"required"`
+	Property    string           `json:"property,omitempty" bson:"property"`                 // single property to match on
+	Properties  []string         `json:"properties,omitempty" bson:"properties,omitempty"`   // instead of property, combined by operator
+	Operator    string           `json:"operator,omitempty" bson:"operator,omitempty"`       // and (default) or or, for properties
+	Normalizers []string         `json:"normalizers,omitempty" bson:"normalizers,omitempty"` // applied in order before values are compared
+	Mode        string           `json:"mode,omitempty" bson:"mode,omitempty"`               // exact (default) or probabilistic
+	Attributes  []MatchAttribute `json:"attributes,omitempty" bson:"attributes,omitempty"`   // compared if mode == probabilistic
+	Threshold   float64          `json:"threshold,omitempty" bson:"threshold,omitempty"`     // confidence from which probabilistic matches merge
+	Priority    int              `json:"priority" bson:"priority" binding:"required"`
+	IsActive    bool             `json:"is_active" bson:"is_active" binding:"required"`
+	CreatedAt   int64            `json:"created_at" bson:"created_at"`
+	UpdatedAt   int64            `json:"updated_at" bson:"updated_at"`
+}
+
+// MatchAttribute is a property compared by a probabilistic unification rule. The confidence of a match is the
+// weighted average of the similarities of the attributes.
+type MatchAttribute struct {
+	Property   string  `json:"property" bson:"property"`
+	Comparator string  `json:"comparator,omitempty" bson:"comparator,omitempty"` // exact (default), levenshtein, jaro_winkler or soundex
+	Weight     float64 `json:"weight,omitempty" bson:"weight,omitempty"`         // 1 by default
 }
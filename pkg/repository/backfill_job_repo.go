@@ -0,0 +1,83 @@
+package repositories
+
+import (
+	"context"
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+type BackfillJobRepository struct {
+	Collection *mongo.Collection
+}
+
+func NewBackfillJobRepository(db *mongo.Database, collection string) *BackfillJobRepository {
+	return &BackfillJobRepository{Collection: db.Collection(collection)}
+}
+
+func (r *BackfillJobRepository) AddBackfillJob(job models.BackfillJob) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := r.Collection.InsertOne(ctx, job)
+	return err
+}
+
+// GetBackfillJob retrieves a backfill job. Nil is returned if there is none with the id.
+func (r *BackfillJobRepository) GetBackfillJob(jobId string) (*models.BackfillJob, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var job models.BackfillJob
+	err := r.Collection.FindOne(ctx, bson.M{"job_id": jobId}).Decode(&job)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r *BackfillJobRepository) GetBackfillJobs(ruleId string) ([]models.BackfillJob, error) {
+	return r.findBackfillJobs(bson.M{"rule_id": ruleId})
+}
+
+func (r *BackfillJobRepository) GetBackfillJobsByStatus(status string) ([]models.BackfillJob, error) {
+	return r.findBackfillJobs(bson.M{"status": status})
+}
+
+// findBackfillJobs returns the jobs matching the filter, the most recently created first
+func (r *BackfillJobRepository) findBackfillJobs(filter bson.M) ([]models.BackfillJob, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := r.Collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var jobs []models.BackfillJob
+	err = cursor.All(ctx, &jobs)
+	return jobs, err
+}
+
+func (r *BackfillJobRepository) UpdateBackfillJob(job models.BackfillJob) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := r.Collection.ReplaceOne(ctx, bson.M{"job_id": job.JobId}, job)
+	return err
+}
+
+func (r *BackfillJobRepository) UpdateBackfillJobIfStatus(job models.BackfillJob, status string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	result, err := r.Collection.ReplaceOne(ctx, bson.M{"job_id": job.JobId, "status": status}, job)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
@@ -0,0 +1,163 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// EventQueueRepository handles MongoDB operations for the event processing queue
+type EventQueueRepository struct {
+	Collection           *mongo.Collection
+	DeadLetterCollection *mongo.Collection
+}
+
+// NewEventQueueRepository creates a new event queue repository instance
+func NewEventQueueRepository(db *mongo.Database, collectionName, deadLetterCollectionName string) *EventQueueRepository {
+	return &EventQueueRepository{
+		Collection:           db.Collection(collectionName),
+		DeadLetterCollection: db.Collection(deadLetterCollectionName),
+	}
+}
+
+// Enqueue adds events to the queue
+func (repo *EventQueueRepository) Enqueue(events []models.QueuedEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	docs := make([]interface{}, 0, len(events))
+	for _, event := range events {
+		docs = append(docs, event)
+	}
+	_, err := repo.Collection.InsertMany(ctx, docs)
+	return err
+}
+
+// Claim leases up to `limit` available events to the worker in queue order. Only the first queued event of a profile
+// is claimed, and it stays in the queue while it is leased or waiting for a retry, so that the events of a profile are
+// processed in order. Each event is leased with a conditional update, so an event claimed by another worker in the
+// meantime is skipped.
+func (repo *EventQueueRepository) Claim(workerId string, limit int, lease time.Duration) ([]models.QueuedEvent, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	available := bson.M{
+		"available_at":     bson.M{"$lte": now.UnixMilli()},
+		"lease_expires_at": bson.M{"$lte": now.UnixMilli()},
+	}
+	queueOrder := bson.D{{Key: "enqueued_at", Value: 1}, {Key: "queue_id", Value: 1}}
+	pipeline := mongo.Pipeline{
+		{{Key: "$sort", Value: queueOrder}},
+		{{Key: "$group", Value: bson.M{"_id": "$event.profile_id", "head": bson.M{"$first": "$$ROOT"}}}},
+		{{Key: "$replaceRoot", Value: bson.M{"newRoot": "$head"}}},
+		{{Key: "$match", Value: available}},
+		{{Key: "$sort", Value: queueOrder}},
+		{{Key: "$limit", Value: limit}},
+	}
+	cursor, err := repo.Collection.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, err
+	}
+	var heads []models.QueuedEvent
+	if err := cursor.All(ctx, &heads); err != nil {
+		return nil, err
+	}
+
+	update := bson.M{
+		"$set": bson.M{"claimed_by": workerId, "lease_expires_at": now.Add(lease).UnixMilli()},
+		"$inc": bson.M{"attempts": 1},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var claimed []models.QueuedEvent
+	for _, head := range heads {
+		filter := bson.M{
+			"queue_id":         head.QueueId,
+			"available_at":     available["available_at"],
+			"lease_expires_at": available["lease_expires_at"],
+		}
+		var event models.QueuedEvent
+		err := repo.Collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&event)
+		if err == mongo.ErrNoDocuments {
+			continue
+		}
+		if err != nil {
+			return claimed, err
+		}
+		claimed = append(claimed, event)
+	}
+	return claimed, nil
+}
+
+// Ack removes a processed event from the queue
+func (repo *EventQueueRepository) Ack(event models.QueuedEvent) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := repo.Collection.DeleteOne(ctx, bson.M{"queue_id": event.QueueId, "claimed_by": event.ClaimedBy})
+	return err
+}
+
+// Release returns a failed event to the queue to be claimed again after `availableAt`
+func (repo *EventQueueRepository) Release(event models.QueuedEvent, availableAt int64, lastError string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"queue_id": event.QueueId, "claimed_by": event.ClaimedBy}
+	update := bson.M{
+		"$set": bson.M{"attempts": event.Attempts, "available_at": availableAt, "lease_expires_at": int64(0),
+			"last_error": lastError},
+		"$unset": bson.M{"claimed_by": ""},
+	}
+	_, err := repo.Collection.UpdateOne(ctx, filter, update)
+	return err
+}
+
+// DeadLetter moves an event from the queue to the dead-letter collection
+func (repo *EventQueueRepository) DeadLetter(event models.QueuedEvent, lastError string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	event.LastError = lastError
+	deadLetter := models.DeadLetterEvent{QueuedEvent: event, FailedAt: time.Now().UTC().Unix()}
+	_, err := repo.DeadLetterCollection.UpdateOne(ctx, bson.M{"queue_id": event.QueueId},
+		bson.M{"$set": deadLetter}, options.Update().SetUpsert(true))
+	if err != nil {
+		return err
+	}
+	_, err = repo.Collection.DeleteOne(ctx, bson.M{"queue_id": event.QueueId, "claimed_by": event.ClaimedBy})
+	return err
+}
+
+// GetDeadLetterEvents retrieves all events in the dead-letter collection
+func (repo *EventQueueRepository) GetDeadLetterEvents() ([]models.DeadLetterEvent, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := repo.DeadLetterCollection.Find(ctx, bson.M{},
+		options.Find().SetSort(bson.D{{Key: "failed_at", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var events []models.DeadLetterEvent
+	err = cursor.All(ctx, &events)
+	return events, err
+}
+
+// Depth counts the events in the queue collection
+func (repo *EventQueueRepository) Depth() (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return repo.Collection.CountDocuments(ctx, bson.M{})
+}
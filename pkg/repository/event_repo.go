@@ -2,6 +2,7 @@ package repositories
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/wso2/identity-customer-data-service/pkg/models"
 	"strings"
@@ -9,6 +10,7 @@ import (
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // EventRepository handles MongoDB operations for user events
@@ -16,52 +18,175 @@ type EventRepository struct {
 	Collection *mongo.Collection
 }
 
-// NewEventRepository initializes a repository for `events` collection
-func NewEventRepository(db *mongo.Database, collectionName string) *EventRepository {
-	return &EventRepository{
+// NewEventRepository initializes a repository for `events` collection. It fails if the unique event_id index can
+// not be created, since replayed events would be stored and processed again without it.
+func NewEventRepository(db *mongo.Database, collectionName string) (*EventRepository, error) {
+	repo := &EventRepository{
 		Collection: db.Collection(collectionName),
 	}
+	if err := repo.ensureIndexes(); err != nil {
+		return nil, err
+	}
+	return repo, nil
 }
 
-// AddEvent inserts a single event into MongoDB
-func (repo *EventRepository) AddEvent(event models.Event) error {
-	//logger := pkg.GetLogger()
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// storedEvent is the document of an event, which records whether the event was queued for processing. Events
+// stored without the flag were queued when they were stored.
+type storedEvent struct {
+	models.Event `bson:",inline"`
+	Queued       bool `bson:"queued"`
+}
+
+// maxReportedDuplicates limits the duplicate events listed when the unique event_id index can not be created
+const maxReportedDuplicates = 20
+
+// ensureIndexes creates the unique index that keeps replayed events with the same event_id of an org out. It fails,
+// listing the duplicates, if events were stored more than once before the index existed, so that they can be
+// reviewed and removed before the service is started again.
+func (repo *EventRepository) ensureIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 	defer cancel()
 
-	_, err := repo.Collection.InsertOne(ctx, event)
+	cursor, err := repo.Collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"event_id": bson.M{"$gt": ""}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"org_id": "$org_id", "event_id": "$event_id"},
+			"count": bson.M{"$sum": 1},
+		}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "_id.org_id", Value: 1}, {Key: "_id.event_id", Value: 1}}}},
+	}, options.Aggregate().SetAllowDiskUse(true))
 	if err != nil {
-		//logger.LogMessage("ERROR", "Failed to insert event: "+err.Error())
-		return err
+		return fmt.Errorf("failed to find duplicate events: %w", err)
 	}
+	defer cursor.Close(ctx)
 
-	//logger.LogMessage("INFO", "Event inserted successfully for user "+event.PermaId)
+	var duplicates []string
+	total := 0
+	for cursor.Next(ctx) {
+		var duplicate struct {
+			Id struct {
+				OrgId   string `bson:"org_id"`
+				EventId string `bson:"event_id"`
+			} `bson:"_id"`
+			Count int `bson:"count"`
+		}
+		if err := cursor.Decode(&duplicate); err != nil {
+			return fmt.Errorf("failed to decode duplicate events: %w", err)
+		}
+		total++
+		if len(duplicates) < maxReportedDuplicates {
+			duplicates = append(duplicates, fmt.Sprintf("org_id=%s event_id=%s (%d events)", duplicate.Id.OrgId,
+				duplicate.Id.EventId, duplicate.Count))
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return fmt.Errorf("failed to find duplicate events: %w", err)
+	}
+	if total > 0 {
+		return fmt.Errorf("the unique event_id index can not be created because %d event ids of an org are stored "+
+			"more than once, remove the duplicates and restart: %s", total, strings.Join(duplicates, ", "))
+	}
+
+	_, err = repo.Collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "org_id", Value: 1}, {Key: "event_id", Value: 1}},
+		Options: options.Index().SetUnique(true).
+			SetPartialFilterExpression(bson.M{"event_id": bson.M{"$gt": ""}}),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create the unique event_id index on the events collection: %w", err)
+	}
 	return nil
 }
 
-// AddEvents inserts multiple events in bulk
-func (repo *EventRepository) AddEvents(events []models.Event) error {
-	//logger := pkg.GetLogger()
+// AddEvent inserts a single event into MongoDB and reports whether it has to be queued for processing. It is
+// skipped if the event_id was already stored for the org.
+func (repo *EventRepository) AddEvent(event models.Event) (bool, error) {
+	added, err := repo.AddEvents([]models.Event{event})
+	return len(added) == 1, err
+}
+
+// AddEvents inserts multiple events in bulk and returns the ones that have to be queued for processing: the
+// inserted events, and replays of events that were stored but not queued. Other replayed events are skipped.
+func (repo *EventRepository) AddEvents(events []models.Event) ([]models.Event, error) {
+	if len(events) == 0 {
+		return nil, nil
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	var docs []interface{}
 	for _, event := range events {
-		docs = append(docs, event)
+		docs = append(docs, storedEvent{Event: event})
 	}
 
-	_, err := repo.Collection.InsertMany(ctx, docs)
-	if err != nil {
-		//logger.LogMessage("ERROR", "Failed to insert multiple events: "+err.Error())
-		return err
+	duplicates := map[int]bool{}
+	_, err := repo.Collection.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	var bulkErr mongo.BulkWriteException
+	if errors.As(err, &bulkErr) && bulkErr.WriteConcernError == nil {
+		for _, writeErr := range bulkErr.WriteErrors {
+			if !mongo.IsDuplicateKeyError(writeErr) {
+				return nil, err
+			}
+			duplicates[writeErr.Index] = true
+		}
+	} else if err != nil {
+		return nil, err
 	}
 
-	//logger.LogMessage("INFO", "Batch events inserted successfully")
-	return nil
+	var added []models.Event
+	seen := map[string]bool{}
+	for i, event := range events {
+		key := event.OrgId + "/" + event.EventId
+		if !duplicates[i] {
+			seen[key] = true
+			added = append(added, event)
+			continue
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		// A replay of an event that was stored but never queued
+		unqueued, err := repo.Collection.CountDocuments(ctx,
+			bson.M{"org_id": event.OrgId, "event_id": event.EventId, "queued": false})
+		if err != nil {
+			return nil, err
+		}
+		if unqueued > 0 {
+			added = append(added, event)
+		}
+	}
+	return added, nil
+}
+
+// MarkEventsQueued records that the events were queued for processing
+func (repo *EventRepository) MarkEventsQueued(events []models.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var writes []mongo.WriteModel
+	for _, event := range events {
+		if event.EventId == "" {
+			continue
+		}
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"org_id": event.OrgId, "event_id": event.EventId}).
+			SetUpdate(bson.M{"$set": bson.M{"queued": true}}))
+	}
+	if len(writes) == 0 {
+		return nil
+	}
+	_, err := repo.Collection.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	return err
 }
 
-// FindEvents fetches all events for a user
-func (repo *EventRepository) FindEvents(filters []string, timeFilter bson.M) ([]models.Event, error) {
+// FindEvents fetches all events matching the filters that occurred on or after `fromTimestamp`
+func (repo *EventRepository) FindEvents(filters []string, fromTimestamp int64) ([]models.Event, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -84,8 +209,8 @@ func (repo *EventRepository) FindEvents(filters []string, timeFilter bson.M) ([]
 	}
 
 	// Add time filter if provided
-	for k, v := range timeFilter {
-		filter[k] = v
+	if fromTimestamp > 0 {
+		filter["event_timestamp"] = bson.M{"$gte": fromTimestamp}
 	}
 
 	cursor, err := repo.Collection.Find(ctx, filter)
@@ -117,8 +242,8 @@ func (repo *EventRepository) DeleteEvent(eventId string) error {
 	return err
 }
 
-func (repo *EventRepository) DeleteEventsByProfileId(permaID string) error {
-	_, err := repo.Collection.DeleteMany(context.TODO(), bson.M{"perma_id": permaID})
+func (repo *EventRepository) DeleteEventsByProfileId(profileId string) error {
+	_, err := repo.Collection.DeleteMany(context.TODO(), bson.M{"profile_id": profileId})
 	return err
 }
 
@@ -128,10 +253,24 @@ func (repo *EventRepository) DeleteEventsByAppID(permaID, appID string) error {
 	return err
 }
 
-func (repo *EventRepository) FindEventsWithFilter(filter bson.M) ([]models.Event, error) {
+func (repo *EventRepository) FindEventsWithFilter(eventFilter EventFilter) ([]models.Event, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	filter := bson.M{}
+	if eventFilter.ProfileId != "" {
+		filter["profile_id"] = eventFilter.ProfileId
+	}
+	if eventFilter.EventType != "" {
+		filter["event_type"] = eventFilter.EventType
+	}
+	if eventFilter.EventName != "" {
+		filter["event_name"] = eventFilter.EventName
+	}
+	if eventFilter.FromTimestamp > 0 {
+		filter["event_timestamp"] = bson.M{"$gte": eventFilter.FromTimestamp}
+	}
+
 	cursor, err := repo.Collection.Find(ctx, filter)
 	if err != nil {
 		return nil, err
@@ -39,18 +39,15 @@ func (r *EventSchemaRepository) GetAllEventSchemas() ([]models.EventSchema, erro
 }
 
 func (r *EventSchemaRepository) GetById(id string) (*models.EventSchema, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	return r.findOne(bson.M{"event_schema_id": id})
+}
 
-	var schema models.EventSchema
-	err := r.Collection.FindOne(ctx, bson.M{"event_schema_id": id}).Decode(&schema)
-	if err == mongo.ErrNoDocuments {
-		return nil, nil
-	}
-	return &schema, err
+// GetByEvent retrieves the schema defined for the event type and name. Nil is returned if there is none.
+func (r *EventSchemaRepository) GetByEvent(eventType, eventName string) (*models.EventSchema, error) {
+	return r.findOne(bson.M{"event_type": eventType, "event_name": eventName})
 }
 
-func (r *EventSchemaRepository) Patch(id string, updates bson.M) error {
+func (r *EventSchemaRepository) Patch(id string, updates map[string]interface{}) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -65,3 +62,18 @@ func (r *EventSchemaRepository) Delete(id string) error {
 	_, err := r.Collection.DeleteOne(ctx, bson.M{"event_schema_id": id})
 	return err
 }
+
+func (r *EventSchemaRepository) findOne(filter bson.M) (*models.EventSchema, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var schema models.EventSchema
+	err := r.Collection.FindOne(ctx, filter).Decode(&schema)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
@@ -0,0 +1,137 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"github.com/wso2/identity-customer-data-service/pkg/logger"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"sort"
+	"time"
+)
+
+// IdentityIndexRepository keeps the identity index as one document per key and profile, and its version in a
+// document of its own
+type IdentityIndexRepository struct {
+	Collection        *mongo.Collection
+	VersionCollection *mongo.Collection
+}
+
+type identityKeyDocument struct {
+	IdentityKey string `bson:"identity_key"`
+	ProfileId   string `bson:"profile_id"`
+}
+
+func NewIdentityIndexRepository(db *mongo.Database, collection string,
+	versionCollection string) *IdentityIndexRepository {
+	repo := &IdentityIndexRepository{
+		Collection:        db.Collection(collection),
+		VersionCollection: db.Collection(versionCollection),
+	}
+	repo.ensureIndexes()
+	return repo
+}
+
+// ensureIndexes creates the indexes for looking up profiles by key and keys by profile
+func (r *IdentityIndexRepository) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.Collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "identity_key", Value: 1}, {Key: "profile_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "profile_id", Value: 1}}},
+	})
+	if err != nil {
+		logger.Error(err, "Failed to create the indexes on the identity keys collection")
+	}
+}
+
+func (r *IdentityIndexRepository) SetIdentityKeys(profileId string, keys []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := r.Collection.DeleteMany(ctx, bson.M{"profile_id": profileId}); err != nil {
+		return err
+	}
+	var documents []interface{}
+	seen := map[string]bool{}
+	for _, key := range keys {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		documents = append(documents, identityKeyDocument{IdentityKey: key, ProfileId: profileId})
+	}
+	if len(documents) == 0 {
+		return nil
+	}
+	// Keys written concurrently for the same profile are skipped, any other failed write fails the update
+	_, err := r.Collection.InsertMany(ctx, documents, options.InsertMany().SetOrdered(false))
+	var bulkErr mongo.BulkWriteException
+	if errors.As(err, &bulkErr) && bulkErr.WriteConcernError == nil {
+		for _, writeErr := range bulkErr.WriteErrors {
+			if !mongo.IsDuplicateKeyError(writeErr) {
+				return err
+			}
+		}
+		return nil
+	}
+	return err
+}
+
+func (r *IdentityIndexRepository) DeleteIdentityKeys(profileId string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := r.Collection.DeleteMany(ctx, bson.M{"profile_id": profileId})
+	return err
+}
+
+func (r *IdentityIndexRepository) FindProfileIdsByIdentityKeys(keys []string) ([]string, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	values, err := r.Collection.Distinct(ctx, "profile_id", bson.M{"identity_key": bson.M{"$in": keys}})
+	if err != nil {
+		return nil, err
+	}
+	profileIds := make([]string, 0, len(values))
+	for _, value := range values {
+		if profileId, ok := value.(string); ok {
+			profileIds = append(profileIds, profileId)
+		}
+	}
+	sort.Strings(profileIds)
+	return profileIds, nil
+}
+
+const identityIndexVersionId = "identity_index"
+
+func (r *IdentityIndexRepository) GetIndexVersion() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var document struct {
+		Version string `bson:"version"`
+	}
+	err := r.VersionCollection.FindOne(ctx, bson.M{"_id": identityIndexVersionId}).Decode(&document)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return "", nil
+	}
+	return document.Version, err
+}
+
+func (r *IdentityIndexRepository) SetIndexVersion(version string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := r.VersionCollection.UpdateOne(ctx, bson.M{"_id": identityIndexVersionId},
+		bson.M{"$set": bson.M{"version": version}}, options.Update().SetUpsert(true))
+	return err
+}
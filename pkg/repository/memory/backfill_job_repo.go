@@ -0,0 +1,90 @@
+package memory
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+)
+
+// BackfillJobRepository keeps the backfill jobs of enrichment rules in memory
+type BackfillJobRepository struct {
+	mutex sync.RWMutex
+	jobs  map[string]models.BackfillJob
+}
+
+// NewBackfillJobRepository creates a new in-memory backfill job repository
+func NewBackfillJobRepository() *BackfillJobRepository {
+	return &BackfillJobRepository{jobs: make(map[string]models.BackfillJob)}
+}
+
+func (repo *BackfillJobRepository) AddBackfillJob(job models.BackfillJob) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	repo.jobs[job.JobId] = cloneBackfillJob(job)
+	return nil
+}
+
+func (repo *BackfillJobRepository) GetBackfillJob(jobId string) (*models.BackfillJob, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	job, ok := repo.jobs[jobId]
+	if !ok {
+		return nil, nil
+	}
+	cloned := cloneBackfillJob(job)
+	return &cloned, nil
+}
+
+func (repo *BackfillJobRepository) GetBackfillJobs(ruleId string) ([]models.BackfillJob, error) {
+	return repo.findBackfillJobs(func(job models.BackfillJob) bool {
+		return job.RuleId == ruleId
+	}), nil
+}
+
+func (repo *BackfillJobRepository) GetBackfillJobsByStatus(status string) ([]models.BackfillJob, error) {
+	return repo.findBackfillJobs(func(job models.BackfillJob) bool {
+		return job.Status == status
+	}), nil
+}
+
+// findBackfillJobs returns the jobs that match, the most recently created first
+func (repo *BackfillJobRepository) findBackfillJobs(matches func(job models.BackfillJob) bool) []models.BackfillJob {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	var jobs []models.BackfillJob
+	for _, job := range repo.jobs {
+		if matches(job) {
+			jobs = append(jobs, cloneBackfillJob(job))
+		}
+	}
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt > jobs[j].CreatedAt
+	})
+	return jobs
+}
+
+func (repo *BackfillJobRepository) UpdateBackfillJob(job models.BackfillJob) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	if _, ok := repo.jobs[job.JobId]; ok {
+		repo.jobs[job.JobId] = cloneBackfillJob(job)
+	}
+	return nil
+}
+
+func (repo *BackfillJobRepository) UpdateBackfillJobIfStatus(job models.BackfillJob, status string) (bool, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	stored, ok := repo.jobs[job.JobId]
+	if !ok || stored.Status != status {
+		return false, nil
+	}
+	repo.jobs[job.JobId] = cloneBackfillJob(job)
+	return true, nil
+}
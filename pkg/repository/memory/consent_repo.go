@@ -0,0 +1,119 @@
+package memory
+
+import (
+	"sync"
+
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+)
+
+// ConsentRepository keeps consents in memory
+type ConsentRepository struct {
+	mutex    sync.RWMutex
+	consents []models.Consent
+}
+
+// NewConsentRepository creates a new in-memory consent repository
+func NewConsentRepository() *ConsentRepository {
+	return &ConsentRepository{}
+}
+
+// GetConsentedApps fetches all apps with consent status
+func (repo *ConsentRepository) GetConsentedApps(permaID string) ([]models.Consent, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	var consents []models.Consent
+	for _, consent := range repo.consents {
+		if consent.PermaID == permaID {
+			consents = append(consents, consent)
+		}
+	}
+	return consents, nil
+}
+
+// GetConsentedAppsToCollect fetches only apps where `consented_to_collect=true`
+func (repo *ConsentRepository) GetConsentedAppsToCollect(permaID string) ([]string, error) {
+	return repo.getConsentedAppsByType(permaID, "consented_to_collect")
+}
+
+// GetConsentedAppsToShare fetches only apps where `consented_to_share=true`
+func (repo *ConsentRepository) GetConsentedAppsToShare(permaID string) ([]string, error) {
+	return repo.getConsentedAppsByType(permaID, "consented_to_share")
+}
+
+func (repo *ConsentRepository) getConsentedAppsByType(permaID, consentType string) ([]string, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	var apps []string
+	for _, consent := range repo.consents {
+		if consent.PermaID == permaID && consentValue(consent, consentType) {
+			apps = append(apps, consent.AppID)
+		}
+	}
+	return apps, nil
+}
+
+// GiveConsent updates or creates a consent record
+func (repo *ConsentRepository) GiveConsent(permaID, appID string, consentType string) error {
+	repo.setConsent(permaID, appID, consentType, true, true)
+	return nil
+}
+
+// RevokeConsent removes a user's consent for an app
+func (repo *ConsentRepository) RevokeConsent(permaID, appID string, consentType string) error {
+	repo.setConsent(permaID, appID, consentType, false, false)
+	return nil
+}
+
+// RevokeAllConsents removes all consents for a user
+func (repo *ConsentRepository) RevokeAllConsents(permaID string) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	remaining := repo.consents[:0]
+	for _, consent := range repo.consents {
+		if consent.PermaID != permaID {
+			remaining = append(remaining, consent)
+		}
+	}
+	repo.consents = remaining
+	return nil
+}
+
+func (repo *ConsentRepository) setConsent(permaID, appID, consentType string, value bool, upsert bool) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	for i, consent := range repo.consents {
+		if consent.PermaID == permaID && consent.AppID == appID {
+			repo.consents[i] = withConsentValue(consent, consentType, value)
+			return
+		}
+	}
+	if upsert {
+		repo.consents = append(repo.consents, withConsentValue(models.Consent{PermaID: permaID, AppID: appID},
+			consentType, value))
+	}
+}
+
+func consentValue(consent models.Consent, consentType string) bool {
+	switch consentType {
+	case "consented_to_collect":
+		return consent.ConsentedToCollect
+	case "consented_to_share":
+		return consent.ConsentedToShare
+	default:
+		return false
+	}
+}
+
+func withConsentValue(consent models.Consent, consentType string, value bool) models.Consent {
+	switch consentType {
+	case "consented_to_collect":
+		consent.ConsentedToCollect = value
+	case "consented_to_share":
+		consent.ConsentedToShare = value
+	}
+	return consent
+}
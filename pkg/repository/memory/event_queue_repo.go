@@ -0,0 +1,133 @@
+package memory
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+)
+
+// EventQueueRepository keeps the event processing queue in memory
+type EventQueueRepository struct {
+	mutex       sync.Mutex
+	queue       []models.QueuedEvent
+	deadLetters []models.DeadLetterEvent
+}
+
+// NewEventQueueRepository creates a new in-memory event queue repository
+func NewEventQueueRepository() *EventQueueRepository {
+	return &EventQueueRepository{}
+}
+
+func (repo *EventQueueRepository) Enqueue(events []models.QueuedEvent) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	for _, event := range events {
+		event.Event = cloneEvent(event.Event)
+		repo.queue = append(repo.queue, event)
+	}
+	sort.SliceStable(repo.queue, func(i, j int) bool {
+		return repo.queue[i].EnqueuedAt < repo.queue[j].EnqueuedAt
+	})
+	return nil
+}
+
+// Claim leases available events in queue order. Only the first queued event of a profile can be claimed, and it stays
+// in the queue while it is leased or waiting for a retry, so that the events of a profile are processed in order.
+func (repo *EventQueueRepository) Claim(workerId string, limit int, lease time.Duration) ([]models.QueuedEvent, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	now := time.Now()
+	seen := map[string]bool{}
+	var claimed []models.QueuedEvent
+	for i := range repo.queue {
+		if len(claimed) >= limit {
+			break
+		}
+		event := &repo.queue[i]
+		head := !seen[event.Event.ProfileId]
+		seen[event.Event.ProfileId] = true
+		if !head || !claimable(*event, now) {
+			continue
+		}
+		event.ClaimedBy = workerId
+		event.LeaseExpiresAt = now.Add(lease).UnixMilli()
+		event.Attempts++
+		claimedEvent := *event
+		claimedEvent.Event = cloneEvent(event.Event)
+		claimed = append(claimed, claimedEvent)
+	}
+	return claimed, nil
+}
+
+func (repo *EventQueueRepository) Ack(event models.QueuedEvent) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	repo.remove(event)
+	return nil
+}
+
+func (repo *EventQueueRepository) Release(event models.QueuedEvent, availableAt int64, lastError string) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	for i := range repo.queue {
+		queued := &repo.queue[i]
+		if queued.QueueId == event.QueueId && queued.ClaimedBy == event.ClaimedBy {
+			queued.Attempts = event.Attempts
+			queued.AvailableAt = availableAt
+			queued.LeaseExpiresAt = 0
+			queued.ClaimedBy = ""
+			queued.LastError = lastError
+		}
+	}
+	return nil
+}
+
+func (repo *EventQueueRepository) DeadLetter(event models.QueuedEvent, lastError string) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	repo.remove(event)
+	event.LastError = lastError
+	repo.deadLetters = append(repo.deadLetters, models.DeadLetterEvent{
+		QueuedEvent: event,
+		FailedAt:    time.Now().UTC().Unix(),
+	})
+	return nil
+}
+
+func (repo *EventQueueRepository) GetDeadLetterEvents() ([]models.DeadLetterEvent, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	return append([]models.DeadLetterEvent(nil), repo.deadLetters...), nil
+}
+
+// Depth counts the events in the queue, including the ones claimed but not yet acknowledged
+func (repo *EventQueueRepository) Depth() (int64, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	return int64(len(repo.queue)), nil
+}
+
+// claimable reports whether the event is neither leased to a worker nor waiting for a retry
+func claimable(event models.QueuedEvent, now time.Time) bool {
+	return event.AvailableAt <= now.UnixMilli() && event.LeaseExpiresAt <= now.UnixMilli()
+}
+
+// remove drops the event from the queue if it is still claimed by the same worker
+func (repo *EventQueueRepository) remove(event models.QueuedEvent) {
+	remaining := repo.queue[:0]
+	for _, queued := range repo.queue {
+		if queued.QueueId != event.QueueId || queued.ClaimedBy != event.ClaimedBy {
+			remaining = append(remaining, queued)
+		}
+	}
+	repo.queue = remaining
+}
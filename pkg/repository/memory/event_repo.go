@@ -0,0 +1,172 @@
+package memory
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+	repositories "github.com/wso2/identity-customer-data-service/pkg/repository"
+)
+
+// EventRepository keeps events in memory in insertion order
+type EventRepository struct {
+	mutex  sync.RWMutex
+	events []models.Event
+	queued map[string]bool // whether the stored events, by org and event id, were queued for processing
+}
+
+// NewEventRepository creates a new in-memory event repository
+func NewEventRepository() *EventRepository {
+	return &EventRepository{queued: map[string]bool{}}
+}
+
+// AddEvent stores a single event unless an event with the same event_id was already stored for the org, and
+// reports whether it has to be queued for processing
+func (repo *EventRepository) AddEvent(event models.Event) (bool, error) {
+	added, err := repo.AddEvents([]models.Event{event})
+	return len(added) == 1, err
+}
+
+// AddEvents stores multiple events in bulk, skipping the ones whose event_id was already stored for the org, and
+// returns the events that have to be queued for processing
+func (repo *EventRepository) AddEvents(events []models.Event) ([]models.Event, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	var added []models.Event
+	seen := map[string]bool{}
+	for _, event := range events {
+		key := eventKey(event)
+		if event.EventId != "" {
+			if queued, stored := repo.queued[key]; stored && (queued || seen[key]) {
+				continue
+			} else if stored {
+				// A replay of an event that was stored but never queued
+				seen[key] = true
+				added = append(added, event)
+				continue
+			}
+			seen[key] = true
+		}
+		repo.queued[key] = false
+		repo.events = append(repo.events, cloneEvent(event))
+		added = append(added, event)
+	}
+	return added, nil
+}
+
+// MarkEventsQueued records that the events were queued for processing
+func (repo *EventRepository) MarkEventsQueued(events []models.Event) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	for _, event := range events {
+		if _, stored := repo.queued[eventKey(event)]; stored && event.EventId != "" {
+			repo.queued[eventKey(event)] = true
+		}
+	}
+	return nil
+}
+
+// FindEvents fetches all events matching the filters that occurred on or after `fromTimestamp`
+func (repo *EventRepository) FindEvents(filters []string, fromTimestamp int64) ([]models.Event, error) {
+	var clauses []filterClause
+	for _, f := range filters {
+		clause, err := parseFilter(f)
+		if err != nil {
+			continue
+		}
+		clauses = append(clauses, clause)
+	}
+
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	var events []models.Event
+	for _, event := range repo.events {
+		if int64(event.EventTimestamp) < fromTimestamp {
+			continue
+		}
+		doc := toDocument(event)
+		matched := true
+		for _, clause := range clauses {
+			if !clause.matches(doc) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			events = append(events, cloneEvent(event))
+		}
+	}
+	return events, nil
+}
+
+func (repo *EventRepository) FindEvent(eventId string) (*models.Event, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	for _, event := range repo.events {
+		if event.EventId == eventId {
+			cloned := cloneEvent(event)
+			return &cloned, nil
+		}
+	}
+	return nil, fmt.Errorf("event %s not found", eventId)
+}
+
+func (repo *EventRepository) FindEventsWithFilter(filter repositories.EventFilter) ([]models.Event, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	var events []models.Event
+	for _, event := range repo.events {
+		if filter.ProfileId != "" && event.ProfileId != filter.ProfileId {
+			continue
+		}
+		if filter.EventType != "" && event.EventType != filter.EventType {
+			continue
+		}
+		if filter.EventName != "" && event.EventName != filter.EventName {
+			continue
+		}
+		if int64(event.EventTimestamp) < filter.FromTimestamp {
+			continue
+		}
+		events = append(events, cloneEvent(event))
+	}
+	return events, nil
+}
+
+func (repo *EventRepository) DeleteEventsByProfileId(profileId string) error {
+	repo.deleteWhere(func(event models.Event) bool {
+		return event.ProfileId == profileId
+	})
+	return nil
+}
+
+func (repo *EventRepository) DeleteEventsByAppID(profileId, appID string) error {
+	repo.deleteWhere(func(event models.Event) bool {
+		return event.ProfileId == profileId && event.AppId == appID
+	})
+	return nil
+}
+
+func (repo *EventRepository) deleteWhere(match func(event models.Event) bool) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	remaining := repo.events[:0]
+	for _, event := range repo.events {
+		if match(event) {
+			delete(repo.queued, eventKey(event))
+		} else {
+			remaining = append(remaining, event)
+		}
+	}
+	repo.events = remaining
+}
+
+func eventKey(event models.Event) string {
+	return event.OrgId + "/" + event.EventId
+}
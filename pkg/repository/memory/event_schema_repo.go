@@ -0,0 +1,103 @@
+package memory
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+)
+
+// EventSchemaRepository keeps event schemas in memory
+type EventSchemaRepository struct {
+	mutex   sync.RWMutex
+	schemas []models.EventSchema
+}
+
+// NewEventSchemaRepository creates a new in-memory event schema repository
+func NewEventSchemaRepository() *EventSchemaRepository {
+	return &EventSchemaRepository{}
+}
+
+func (repo *EventSchemaRepository) AddEventSchema(schema models.EventSchema) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	repo.schemas = append(repo.schemas, cloneEventSchema(schema))
+	return nil
+}
+
+func (repo *EventSchemaRepository) GetAllEventSchemas() ([]models.EventSchema, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	var schemas []models.EventSchema
+	for _, schema := range repo.schemas {
+		schemas = append(schemas, cloneEventSchema(schema))
+	}
+	return schemas, nil
+}
+
+func (repo *EventSchemaRepository) GetById(id string) (*models.EventSchema, error) {
+	return repo.find(func(schema models.EventSchema) bool {
+		return schema.EventSchemaId == id
+	}), nil
+}
+
+func (repo *EventSchemaRepository) GetByEvent(eventType, eventName string) (*models.EventSchema, error) {
+	return repo.find(func(schema models.EventSchema) bool {
+		return schema.EventType == eventType && schema.EventName == eventName
+	}), nil
+}
+
+func (repo *EventSchemaRepository) Patch(id string, updates map[string]interface{}) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	for i, schema := range repo.schemas {
+		if schema.EventSchemaId != id {
+			continue
+		}
+		doc := toDocument(schema)
+		for field, value := range updates {
+			doc[field] = value
+		}
+		data, err := json.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		var patched models.EventSchema
+		if err := json.Unmarshal(data, &patched); err != nil {
+			return err
+		}
+		repo.schemas[i] = patched
+		return nil
+	}
+	return nil
+}
+
+func (repo *EventSchemaRepository) Delete(id string) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	remaining := repo.schemas[:0]
+	for _, schema := range repo.schemas {
+		if schema.EventSchemaId != id {
+			remaining = append(remaining, schema)
+		}
+	}
+	repo.schemas = remaining
+	return nil
+}
+
+func (repo *EventSchemaRepository) find(matches func(schema models.EventSchema) bool) *models.EventSchema {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	for _, schema := range repo.schemas {
+		if matches(schema) {
+			cloned := cloneEventSchema(schema)
+			return &cloned
+		}
+	}
+	return nil
+}
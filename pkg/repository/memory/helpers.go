@@ -0,0 +1,227 @@
+package memory
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+)
+
+// filterClause is a parsed "<field> <operator> <value>" filter
+type filterClause struct {
+	field    string
+	operator string
+	value    string
+}
+
+// parseFilter parses a single filter string of the form "<field> <operator> <value>"
+func parseFilter(filter string) (filterClause, error) {
+	parts := strings.SplitN(filter, " ", 3)
+	if len(parts) != 3 {
+		return filterClause{}, fmt.Errorf("invalid filter format: %s", filter)
+	}
+	clause := filterClause{
+		field:    parts[0],
+		operator: strings.ToLower(parts[1]),
+		value:    strings.TrimSpace(parts[2]),
+	}
+	switch clause.operator {
+	case "eq", "sw", "co":
+		return clause, nil
+	default:
+		return filterClause{}, fmt.Errorf("unsupported operator: %s", clause.operator)
+	}
+}
+
+// matches reports whether any value found at the clause field satisfies the clause
+func (clause filterClause) matches(doc map[string]interface{}) bool {
+	for _, actual := range lookupField(doc, clause.field) {
+		if actual == nil {
+			continue
+		}
+		str := fmt.Sprintf("%v", actual)
+		switch clause.operator {
+		case "eq":
+			if str == clause.value {
+				return true
+			}
+		case "sw":
+			if strings.HasPrefix(str, clause.value) {
+				return true
+			}
+		case "co":
+			if strings.Contains(str, clause.value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// toDocument converts a model into a generic document so that filters can address fields by their json path
+func toDocument(v interface{}) map[string]interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil
+	}
+	return doc
+}
+
+// lookupField resolves a dotted field path within a document. Arrays along the path are expanded.
+func lookupField(value interface{}, path string) []interface{} {
+	if path == "" {
+		if list, ok := value.([]interface{}); ok {
+			return list
+		}
+		return []interface{}{value}
+	}
+
+	field, rest, _ := strings.Cut(path, ".")
+	switch v := value.(type) {
+	case map[string]interface{}:
+		next, ok := v[field]
+		if !ok {
+			return nil
+		}
+		return lookupField(next, rest)
+	case []interface{}:
+		var results []interface{}
+		for _, item := range v {
+			results = append(results, lookupField(item, path)...)
+		}
+		return results
+	default:
+		return nil
+	}
+}
+
+// cloneProfile returns a deep copy of the profile so that callers can't mutate stored state
+func cloneProfile(profile models.Profile) models.Profile {
+	cloned := profile
+	cloned.IdentityAttributes = cloneMap(profile.IdentityAttributes)
+	cloned.Traits = cloneMap(profile.Traits)
+	if profile.ApplicationData != nil {
+		cloned.ApplicationData = make([]models.ApplicationData, len(profile.ApplicationData))
+		for i, app := range profile.ApplicationData {
+			app.Devices = append([]models.Devices(nil), app.Devices...)
+			app.AppSpecificData = cloneMap(app.AppSpecificData)
+			cloned.ApplicationData[i] = app
+		}
+	}
+	if profile.ProfileHierarchy != nil {
+		hierarchy := *profile.ProfileHierarchy
+		hierarchy.ChildProfiles = append([]models.ChildProfile(nil), profile.ProfileHierarchy.ChildProfiles...)
+		cloned.ProfileHierarchy = &hierarchy
+	}
+	return cloned
+}
+
+// cloneEvent returns a deep copy of the event
+func cloneEvent(event models.Event) models.Event {
+	cloned := event
+	cloned.Properties = cloneMap(event.Properties)
+	cloned.Context = cloneMap(event.Context)
+	return cloned
+}
+
+// cloneEnrichmentRule returns a deep copy of the enrichment rule
+func cloneEnrichmentRule(rule models.ProfileEnrichmentRule) models.ProfileEnrichmentRule {
+	cloned := rule
+	cloned.Value = cloneValue(rule.Value)
+	cloned.SourceFields = append([]string(nil), rule.SourceFields...)
+	cloned.Trigger.EventNames = append([]string(nil), rule.Trigger.EventNames...)
+	cloned.Trigger.Conditions = cloneConditions(rule.Trigger.Conditions)
+	return cloned
+}
+
+// cloneConditions returns a deep copy of the condition trees
+func cloneConditions(conditions []models.RuleCondition) []models.RuleCondition {
+	if conditions == nil {
+		return nil
+	}
+	cloned := make([]models.RuleCondition, len(conditions))
+	for i, condition := range conditions {
+		cloned[i] = condition
+		cloned[i].All = cloneConditions(condition.All)
+		cloned[i].Any = cloneConditions(condition.Any)
+		if condition.Not != nil {
+			not := cloneConditions([]models.RuleCondition{*condition.Not})[0]
+			cloned[i].Not = &not
+		}
+	}
+	return cloned
+}
+
+// cloneEventSchema returns a copy of the event schema that does not share its properties
+func cloneEventSchema(schema models.EventSchema) models.EventSchema {
+	cloned := schema
+	cloned.Properties = append([]models.EventProperty(nil), schema.Properties...)
+	return cloned
+}
+
+// cloneBackfillJob returns a copy of the backfill job that does not share its filters and errors
+func cloneBackfillJob(job models.BackfillJob) models.BackfillJob {
+	cloned := job
+	cloned.Filters = append([]string(nil), job.Filters...)
+	cloned.Errors = append([]models.BackfillJobError(nil), job.Errors...)
+	return cloned
+}
+
+// cloneRuleVersion returns a copy of the rule version that does not share its snapshot and diff
+func cloneRuleVersion(version models.RuleVersion) models.RuleVersion {
+	cloned := version
+	if version.Diff != nil {
+		cloned.Diff = make([]models.RuleFieldChange, len(version.Diff))
+		for i, change := range version.Diff {
+			cloned.Diff[i] = models.RuleFieldChange{
+				Field:    change.Field,
+				Previous: cloneValue(change.Previous),
+				Current:  cloneValue(change.Current),
+			}
+		}
+	}
+	if version.EnrichmentRule != nil {
+		rule := cloneEnrichmentRule(*version.EnrichmentRule)
+		cloned.EnrichmentRule = &rule
+	}
+	if version.UnificationRule != nil {
+		rule := *version.UnificationRule
+		cloned.UnificationRule = &rule
+	}
+	return cloned
+}
+
+func cloneMap(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	cloned := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		cloned[k] = cloneValue(v)
+	}
+	return cloned
+}
+
+func cloneValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return cloneMap(v)
+	case []interface{}:
+		cloned := make([]interface{}, len(v))
+		for i, item := range v {
+			cloned[i] = cloneValue(item)
+		}
+		return cloned
+	case []string:
+		return append([]string(nil), v...)
+	case []int:
+		return append([]int(nil), v...)
+	default:
+		return v
+	}
+}
@@ -0,0 +1,89 @@
+package memory
+
+import (
+	"sort"
+	"sync"
+)
+
+// IdentityIndexRepository keeps the identity index in memory, by key and by profile
+type IdentityIndexRepository struct {
+	mutex    sync.RWMutex
+	profiles map[string]map[string]bool
+	keys     map[string][]string
+	version  string
+}
+
+// NewIdentityIndexRepository creates a new in-memory identity index repository
+func NewIdentityIndexRepository() *IdentityIndexRepository {
+	return &IdentityIndexRepository{
+		profiles: make(map[string]map[string]bool),
+		keys:     make(map[string][]string),
+	}
+}
+
+func (repo *IdentityIndexRepository) SetIdentityKeys(profileId string, keys []string) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	repo.removeKeys(profileId)
+	for _, key := range keys {
+		if repo.profiles[key] == nil {
+			repo.profiles[key] = make(map[string]bool)
+		}
+		repo.profiles[key][profileId] = true
+	}
+	if len(keys) > 0 {
+		repo.keys[profileId] = append([]string(nil), keys...)
+	}
+	return nil
+}
+
+func (repo *IdentityIndexRepository) DeleteIdentityKeys(profileId string) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	repo.removeKeys(profileId)
+	return nil
+}
+
+func (repo *IdentityIndexRepository) FindProfileIdsByIdentityKeys(keys []string) ([]string, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	found := map[string]bool{}
+	for _, key := range keys {
+		for profileId := range repo.profiles[key] {
+			found[profileId] = true
+		}
+	}
+	profileIds := make([]string, 0, len(found))
+	for profileId := range found {
+		profileIds = append(profileIds, profileId)
+	}
+	sort.Strings(profileIds)
+	return profileIds, nil
+}
+
+func (repo *IdentityIndexRepository) GetIndexVersion() (string, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	return repo.version, nil
+}
+
+func (repo *IdentityIndexRepository) SetIndexVersion(version string) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	repo.version = version
+	return nil
+}
+
+// removeKeys removes the keys of the profile, the caller holds the write lock
+func (repo *IdentityIndexRepository) removeKeys(profileId string) {
+	for _, key := range repo.keys[profileId] {
+		delete(repo.profiles[key], profileId)
+		if len(repo.profiles[key]) == 0 {
+			delete(repo.profiles, key)
+		}
+	}
+	delete(repo.keys, profileId)
+}
@@ -0,0 +1,266 @@
+package memory
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+	repositories "github.com/wso2/identity-customer-data-service/pkg/repository"
+)
+
+// ProfileRepository keeps profiles in memory
+type ProfileRepository struct {
+	mutex    sync.RWMutex
+	profiles map[string]models.Profile
+}
+
+// NewProfileRepository creates a new in-memory profile repository
+func NewProfileRepository() *ProfileRepository {
+	return &ProfileRepository{
+		profiles: make(map[string]models.Profile),
+	}
+}
+
+// InsertProfile saves a profile if no profile exists with the same id
+func (repo *ProfileRepository) InsertProfile(profile models.Profile) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	if _, exists := repo.profiles[profile.ProfileId]; !exists {
+		repo.profiles[profile.ProfileId] = cloneProfile(profile)
+	}
+	return nil
+}
+
+// InsertProfiles saves the profiles that do not exist yet
+func (repo *ProfileRepository) InsertProfiles(profiles []models.Profile) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	for _, profile := range profiles {
+		if _, exists := repo.profiles[profile.ProfileId]; !exists {
+			repo.profiles[profile.ProfileId] = cloneProfile(profile)
+		}
+	}
+	return nil
+}
+
+// FindProfileByID retrieves a profile by `profile_id`
+func (repo *ProfileRepository) FindProfileByID(profileId string) (*models.Profile, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	profile, exists := repo.profiles[profileId]
+	if !exists {
+		return nil, nil // Profile not found is not an error
+	}
+	cloned := cloneProfile(profile)
+	return &cloned, nil
+}
+
+// DeleteProfile removes a profile using `profile_id`
+func (repo *ProfileRepository) DeleteProfile(profileId string) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	if _, exists := repo.profiles[profileId]; !exists {
+		return fmt.Errorf("profile %s not found", profileId)
+	}
+	delete(repo.profiles, profileId)
+	return nil
+}
+
+func (repo *ProfileRepository) DetachChildFromParent(parentID, childID string) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	parent, exists := repo.profiles[parentID]
+	if !exists {
+		return nil
+	}
+	repositories.RemoveChildProfile(&parent, childID)
+	repo.profiles[parentID] = parent
+	return nil
+}
+
+func (repo *ProfileRepository) AddOrUpdateAppContext(profileId string, newAppCtx models.ApplicationData) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	profile, exists := repo.profiles[profileId]
+	if !exists {
+		return fmt.Errorf("failed to fetch profile: profile %s not found", profileId)
+	}
+	newAppCtx.Devices = append([]models.Devices(nil), newAppCtx.Devices...)
+	newAppCtx.AppSpecificData = cloneMap(newAppCtx.AppSpecificData)
+	repositories.ApplyAppContext(&profile, newAppCtx)
+	repo.profiles[profileId] = profile
+	return nil
+}
+
+// AddOrUpdateTraitsData replaces (PUT) the traits inside Profile
+func (repo *ProfileRepository) AddOrUpdateTraitsData(profileId string, traits map[string]interface{}) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	profile := repo.profiles[profileId]
+	profile.ProfileId = profileId
+	profile.Traits = cloneMap(traits)
+	repo.profiles[profileId] = profile
+	return nil
+}
+
+// UpsertIdentityData sets the given identity attributes of a Profile
+func (repo *ProfileRepository) UpsertIdentityData(profileId string, identityData map[string]interface{}) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	profile := repo.profiles[profileId]
+	profile.ProfileId = profileId
+	if profile.IdentityAttributes == nil {
+		profile.IdentityAttributes = map[string]interface{}{}
+	}
+	for k, v := range identityData {
+		profile.IdentityAttributes[k] = cloneValue(v)
+	}
+	repo.profiles[profileId] = profile
+	return nil
+}
+
+// GetAllProfiles retrieves all listable profiles
+func (repo *ProfileRepository) GetAllProfiles() ([]models.Profile, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	var profiles []models.Profile
+	for _, profile := range repo.profiles {
+		if profile.ProfileHierarchy != nil && profile.ProfileHierarchy.ListProfile {
+			profiles = append(profiles, cloneProfile(profile))
+		}
+	}
+	return profiles, nil
+}
+
+func (repo *ProfileRepository) GetAllProfilesWithFilter(filters []string) ([]models.Profile, error) {
+	var clauses []filterClause
+	for _, f := range filters {
+		clause, err := parseFilter(f)
+		if err != nil {
+			continue
+		}
+		clauses = append(clauses, clause)
+	}
+
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	var profiles []models.Profile
+	for _, profile := range repo.profiles {
+		doc := toDocument(profile)
+		matched := true
+		for _, clause := range clauses {
+			if !clause.matches(doc) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			profiles = append(profiles, cloneProfile(profile))
+		}
+	}
+	return profiles, nil
+}
+
+// GetAllMasterProfilesExceptForCurrent retrieves all master profiles excluding the current profile
+func (repo *ProfileRepository) GetAllMasterProfilesExceptForCurrent(currentProfile models.Profile) ([]models.Profile, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	var profiles []models.Profile
+	for id, profile := range repo.profiles {
+		if id == currentProfile.ProfileId {
+			continue
+		}
+		if profile.ProfileHierarchy != nil && profile.ProfileHierarchy.IsParent {
+			profiles = append(profiles, cloneProfile(profile))
+		}
+	}
+	return profiles, nil
+}
+
+func (repo *ProfileRepository) UpdateParent(master models.Profile, newProfile models.Profile) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	profile, exists := repo.profiles[newProfile.ProfileId]
+	if !exists {
+		return nil
+	}
+	repositories.ApplyParent(&profile, master.ProfileId)
+	repo.profiles[newProfile.ProfileId] = profile
+	return nil
+}
+
+func (repo *ProfileRepository) AddChildProfile(parentProfile models.Profile, child models.ChildProfile) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	parent, exists := repo.profiles[parentProfile.ProfileId]
+	if !exists {
+		return nil
+	}
+	repositories.ApplyChildProfile(&parent, child)
+	repo.profiles[parentProfile.ProfileId] = parent
+	return nil
+}
+
+func (repo *ProfileRepository) UpsertIdentityAttribute(profileId string, updates map[string]interface{}) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	profile, exists := repo.profiles[profileId]
+	if !exists {
+		return fmt.Errorf("failed to fetch profile: profile %s not found", profileId)
+	}
+	repositories.ApplyIdentityAttributeUpdates(&profile, cloneMap(updates))
+	repo.profiles[profileId] = profile
+	return nil
+}
+
+func (repo *ProfileRepository) UpsertTrait(profileId string, updates map[string]interface{}) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	profile, exists := repo.profiles[profileId]
+	if !exists {
+		return fmt.Errorf("failed to fetch profile: profile %s not found", profileId)
+	}
+	repositories.ApplyTraitUpdates(&profile, cloneMap(updates))
+	repo.profiles[profileId] = profile
+	return nil
+}
+
+func (repo *ProfileRepository) UpsertAppDatum(profileId string, appId string, updates map[string]interface{}) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	profile, exists := repo.profiles[profileId]
+	if !exists {
+		return fmt.Errorf("failed to fetch profile: profile %s not found", profileId)
+	}
+	repositories.ApplyAppDatumUpdates(&profile, appId, cloneMap(updates))
+	repo.profiles[profileId] = profile
+	return nil
+}
+
+// ReplaceProfile replaces the stored profile
+func (repo *ProfileRepository) ReplaceProfile(profile models.Profile) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	if _, exists := repo.profiles[profile.ProfileId]; !exists {
+		return fmt.Errorf("profile %s not found", profile.ProfileId)
+	}
+	repo.profiles[profile.ProfileId] = cloneProfile(profile)
+	return nil
+}
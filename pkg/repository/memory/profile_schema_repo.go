@@ -0,0 +1,101 @@
+package memory
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+	repositories "github.com/wso2/identity-customer-data-service/pkg/repository"
+)
+
+// ProfileSchemaRepository keeps profile enrichment rules in memory
+type ProfileSchemaRepository struct {
+	mutex sync.RWMutex
+	rules []models.ProfileEnrichmentRule
+}
+
+// NewProfileSchemaRepository creates a new in-memory enrichment rule repository
+func NewProfileSchemaRepository() *ProfileSchemaRepository {
+	return &ProfileSchemaRepository{}
+}
+
+func (repo *ProfileSchemaRepository) UpsertEnrichmentRule(rule models.ProfileEnrichmentRule) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	for i, existing := range repo.rules {
+		if existing.RuleId == rule.RuleId {
+			repo.rules[i] = cloneEnrichmentRule(rule)
+			return nil
+		}
+	}
+	repo.rules = append(repo.rules, cloneEnrichmentRule(rule))
+	return nil
+}
+
+func (repo *ProfileSchemaRepository) GetProfileEnrichmentRules() ([]models.ProfileEnrichmentRule, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	var rules []models.ProfileEnrichmentRule
+	for _, rule := range repo.rules {
+		rules = append(rules, cloneEnrichmentRule(rule))
+	}
+	return rules, nil
+}
+
+func (repo *ProfileSchemaRepository) GetEnrichmentRulesByFilter(filters []string) ([]models.ProfileEnrichmentRule, error) {
+	var clauses []filterClause
+	for _, f := range filters {
+		clause, err := parseFilter(f)
+		if err != nil {
+			return nil, err
+		}
+		clauses = append(clauses, clause)
+	}
+
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	var rules []models.ProfileEnrichmentRule
+	for _, rule := range repo.rules {
+		doc := toDocument(rule)
+		matched := true
+		for _, clause := range clauses {
+			if !clause.matches(doc) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			rules = append(rules, cloneEnrichmentRule(rule))
+		}
+	}
+	return rules, nil
+}
+
+func (repo *ProfileSchemaRepository) GetSchemaRule(ruleId string) (models.ProfileEnrichmentRule, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	for _, rule := range repo.rules {
+		if rule.RuleId == ruleId {
+			return cloneEnrichmentRule(rule), nil
+		}
+	}
+	return models.ProfileEnrichmentRule{}, fmt.Errorf("%w: %s", repositories.ErrEnrichmentRuleNotFound, ruleId)
+}
+
+func (repo *ProfileSchemaRepository) DeleteSchemaRule(ruleId string) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	remaining := repo.rules[:0]
+	for _, rule := range repo.rules {
+		if rule.RuleId != ruleId {
+			remaining = append(remaining, rule)
+		}
+	}
+	repo.rules = remaining
+	return nil
+}
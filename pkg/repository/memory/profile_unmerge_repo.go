@@ -0,0 +1,49 @@
+package memory
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+)
+
+// ProfileUnmergeRepository keeps the records of profiles split from their master profiles in memory
+type ProfileUnmergeRepository struct {
+	mutex    sync.RWMutex
+	unmerges []models.ProfileUnmerge
+}
+
+// NewProfileUnmergeRepository creates a new in-memory profile unmerge repository
+func NewProfileUnmergeRepository() *ProfileUnmergeRepository {
+	return &ProfileUnmergeRepository{}
+}
+
+func (repo *ProfileUnmergeRepository) AddProfileUnmerge(unmerge models.ProfileUnmerge) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	unmerge.SeparatedFrom = append([]string(nil), unmerge.SeparatedFrom...)
+	repo.unmerges = append(repo.unmerges, unmerge)
+	return nil
+}
+
+func (repo *ProfileUnmergeRepository) GetProfileUnmerges(profileIds []string) ([]models.ProfileUnmerge, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	ids := make(map[string]bool, len(profileIds))
+	for _, profileId := range profileIds {
+		ids[profileId] = true
+	}
+	var unmerges []models.ProfileUnmerge
+	for _, unmerge := range repo.unmerges {
+		if ids[unmerge.ProfileId] || ids[unmerge.MasterProfileId] {
+			unmerge.SeparatedFrom = append([]string(nil), unmerge.SeparatedFrom...)
+			unmerges = append(unmerges, unmerge)
+		}
+	}
+	sort.SliceStable(unmerges, func(i, j int) bool {
+		return unmerges[i].UnmergedAt > unmerges[j].UnmergedAt
+	})
+	return unmerges, nil
+}
@@ -0,0 +1,78 @@
+package memory
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+	repositories "github.com/wso2/identity-customer-data-service/pkg/repository"
+)
+
+// RuleVersionRepository keeps the version history of rules in memory
+type RuleVersionRepository struct {
+	mutex    sync.RWMutex
+	versions []models.RuleVersion
+}
+
+// NewRuleVersionRepository creates a new in-memory rule version repository
+func NewRuleVersionRepository() *RuleVersionRepository {
+	return &RuleVersionRepository{}
+}
+
+func (repo *RuleVersionRepository) AddRuleVersion(version models.RuleVersion) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	for _, existing := range repo.versions {
+		if existing.RuleType == version.RuleType && existing.RuleId == version.RuleId &&
+			existing.Version == version.Version {
+			return fmt.Errorf("%w: version %d of %s rule %s", repositories.ErrRuleVersionExists, version.Version,
+				version.RuleType, version.RuleId)
+		}
+	}
+	repo.versions = append(repo.versions, cloneRuleVersion(version))
+	return nil
+}
+
+func (repo *RuleVersionRepository) GetRuleVersions(ruleType, ruleId string) ([]models.RuleVersion, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	var versions []models.RuleVersion
+	for _, version := range repo.versions {
+		if version.RuleType == ruleType && version.RuleId == ruleId {
+			versions = append(versions, cloneRuleVersion(version))
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].Version > versions[j].Version
+	})
+	return versions, nil
+}
+
+func (repo *RuleVersionRepository) GetRuleVersion(ruleType, ruleId string, version int) (*models.RuleVersion, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	for _, existing := range repo.versions {
+		if existing.RuleType == ruleType && existing.RuleId == ruleId && existing.Version == version {
+			cloned := cloneRuleVersion(existing)
+			return &cloned, nil
+		}
+	}
+	return nil, nil
+}
+
+func (repo *RuleVersionRepository) GetLatestRuleVersion(ruleType, ruleId string) (int, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	latest := 0
+	for _, version := range repo.versions {
+		if version.RuleType == ruleType && version.RuleId == ruleId && version.Version > latest {
+			latest = version.Version
+		}
+	}
+	return latest, nil
+}
@@ -0,0 +1,24 @@
+// Package memory provides in-memory implementations of the repository stores. Data is kept
+// for the lifetime of the process only, which makes it suitable for local runs and tests.
+package memory
+
+import (
+	repositories "github.com/wso2/identity-customer-data-service/pkg/repository"
+)
+
+// NewStores builds a fresh set of in-memory stores
+func NewStores() repositories.Stores {
+	return repositories.Stores{
+		Profiles:         NewProfileRepository(),
+		Events:           NewEventRepository(),
+		Consents:         NewConsentRepository(),
+		EnrichmentRules:  NewProfileSchemaRepository(),
+		UnificationRules: NewUnificationRuleRepository(),
+		EventSchemas:     NewEventSchemaRepository(),
+		EventQueue:       NewEventQueueRepository(),
+		BackfillJobs:     NewBackfillJobRepository(),
+		RuleVersions:     NewRuleVersionRepository(),
+		IdentityIndex:    NewIdentityIndexRepository(),
+		ProfileUnmerges:  NewProfileUnmergeRepository(),
+	}
+}
@@ -0,0 +1,108 @@
+package memory
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/wso2/identity-customer-data-service/pkg/errors"
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+)
+
+// UnificationRuleRepository keeps unification rules in memory
+type UnificationRuleRepository struct {
+	mutex sync.RWMutex
+	rules []models.UnificationRule
+}
+
+// NewUnificationRuleRepository creates a new in-memory unification rule repository
+func NewUnificationRuleRepository() *UnificationRuleRepository {
+	return &UnificationRuleRepository{}
+}
+
+// AddUnificationRule Inserts a new unification rule
+func (repo *UnificationRuleRepository) AddUnificationRule(rule models.UnificationRule) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	repo.rules = append(repo.rules, rule)
+	return nil
+}
+
+// GetUnificationRules  Retrieves all unification rules
+func (repo *UnificationRuleRepository) GetUnificationRules() ([]models.UnificationRule, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	return append([]models.UnificationRule(nil), repo.rules...), nil
+}
+
+// GetUnificationRule retrieves a specific unification rule by rule_id. An empty rule is returned if none exists.
+func (repo *UnificationRuleRepository) GetUnificationRule(ruleId string) (models.UnificationRule, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	for _, rule := range repo.rules {
+		if rule.RuleId == ruleId {
+			return rule, nil
+		}
+	}
+	return models.UnificationRule{}, nil
+}
+
+// GetUnificationRuleByPropertyName retrieves a specific unification rule by property name.
+func (repo *UnificationRuleRepository) GetUnificationRuleByPropertyName(property string) (models.UnificationRule, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	for _, rule := range repo.rules {
+		if rule.Property == property {
+			return rule, nil
+		}
+	}
+	return models.UnificationRule{}, nil
+}
+
+// PatchUnificationRule modifies specific fields
+func (repo *UnificationRuleRepository) PatchUnificationRule(ruleId string, updates map[string]interface{}) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	for i, rule := range repo.rules {
+		if rule.RuleId != ruleId {
+			continue
+		}
+		doc := toDocument(rule)
+		for field, value := range updates {
+			doc[field] = value
+		}
+		doc["updated_at"] = time.Now().UTC().Unix()
+
+		data, err := json.Marshal(doc)
+		if err != nil {
+			return errors.NewServerError(errors.ErrWhileUpdatingUnificationRule, err)
+		}
+		var patched models.UnificationRule
+		if err := json.Unmarshal(data, &patched); err != nil {
+			return errors.NewServerError(errors.ErrWhileUpdatingUnificationRule, err)
+		}
+		repo.rules[i] = patched
+		return nil
+	}
+	return nil
+}
+
+// DeleteUnificationRule Removes a unification rule.
+func (repo *UnificationRuleRepository) DeleteUnificationRule(ruleId string) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	remaining := repo.rules[:0]
+	for _, rule := range repo.rules {
+		if rule.RuleId != ruleId {
+			remaining = append(remaining, rule)
+		}
+	}
+	repo.rules = remaining
+	return nil
+}
@@ -0,0 +1,32 @@
+package repositories
+
+import (
+	"github.com/wso2/identity-customer-data-service/pkg/constants"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// NewMongoStores builds the MongoDB backed stores for the given database
+func NewMongoStores(db *mongo.Database) (Stores, error) {
+	events, err := NewEventRepository(db, constants.EventCollection)
+	if err != nil {
+		return Stores{}, err
+	}
+	ruleVersions, err := NewRuleVersionRepository(db, constants.RuleVersionCollection)
+	if err != nil {
+		return Stores{}, err
+	}
+	return Stores{
+		Profiles:         NewProfileRepository(db, constants.ProfileCollection),
+		Events:           events,
+		Consents:         NewConsentRepository(db, constants.ConsentCollection),
+		EnrichmentRules:  NewProfileSchemaRepository(db, constants.ProfileSchemaCollection),
+		UnificationRules: NewUnificationRuleRepository(db, constants.UnificationRulesCollection),
+		EventSchemas:     NewEventSchemaRepository(db, constants.EventSchemaCollection),
+		EventQueue: NewEventQueueRepository(db, constants.EventQueueCollection,
+			constants.DeadLetterEventCollection),
+		BackfillJobs:    NewBackfillJobRepository(db, constants.BackfillJobCollection),
+		RuleVersions:    ruleVersions,
+		IdentityIndex:   NewIdentityIndexRepository(db, constants.IdentityKeyCollection, constants.IdentityIndexCollection),
+		ProfileUnmerges: NewProfileUnmergeRepository(db, constants.ProfileUnmergeCollection),
+	}, nil
+}
@@ -42,6 +42,25 @@ func (repo *ProfileRepository) InsertProfile(profile models.Profile) error {
 	return err
 }
 
+// InsertProfiles saves the profiles that do not exist yet in a single bulk write
+func (repo *ProfileRepository) InsertProfiles(profiles []models.Profile) error {
+	if len(profiles) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	writes := make([]mongo.WriteModel, 0, len(profiles))
+	for _, profile := range profiles {
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"profile_id": profile.ProfileId}).
+			SetUpdate(bson.M{"$setOnInsert": profile}).
+			SetUpsert(true))
+	}
+	_, err := repo.Collection.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
 // UpdateProfile saves a profile in MongoDB
 func (repo *ProfileRepository) UpdateProfile(profile models.Profile) (*mongo.InsertOneResult, error) {
 	//logger := pkg.GetLogger()
@@ -164,7 +183,7 @@ func (repo *ProfileRepository) AddOrUpdateAppContext(profileId string, newAppCtx
 	for _, existing := range profile.ApplicationData {
 		if existing.AppId == newAppCtx.AppId {
 			// Merge devices
-			existing.Devices = mergeDeviceLists(existing.Devices, newAppCtx.Devices)
+			existing.Devices = MergeDeviceLists(existing.Devices, newAppCtx.Devices)
 
 			// Merge app-specific fields
 			if existing.AppSpecificData == nil {
@@ -252,7 +271,8 @@ func decodeDevices(raw interface{}) []models.Devices {
 	return devices
 }
 
-func mergeDeviceLists(existing, incoming []models.Devices) []models.Devices {
+// MergeDeviceLists merges two device lists, keyed by device id
+func MergeDeviceLists(existing, incoming []models.Devices) []models.Devices {
 	deviceMap := make(map[string]models.Devices)
 	for _, d := range existing {
 		deviceMap[d.DeviceId] = d
@@ -528,21 +548,32 @@ func (repo *ProfileRepository) AddChildProfile(parentProfile models.Profile, chi
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	filter := bson.M{"profile_id": parentProfile.ProfileId}
+	// A child that is already present is updated in place, like ApplyChildProfile
+	childField := "profile_hierarchy.child_profile_ids.child_profile_id"
+	result, err := repo.Collection.UpdateOne(ctx,
+		bson.M{"profile_id": parentProfile.ProfileId, childField: child.ChildProfileId},
+		bson.M{"$set": bson.M{"profile_hierarchy.child_profile_ids.$": child}})
+	if err != nil {
+		return fmt.Errorf("failed to add child profile to parent %s: %w", parentProfile.ProfileId, err)
+	}
+	if result.MatchedCount > 0 {
+		return nil
+	}
+
+	filter := bson.M{"profile_id": parentProfile.ProfileId, childField: bson.M{"$ne": child.ChildProfileId}}
 	update := bson.M{
-		"$addToSet": bson.M{
+		"$push": bson.M{
 			"profile_hierarchy.child_profile_ids": child,
 		},
 	}
-
-	_, err := repo.Collection.UpdateOne(ctx, filter, update)
+	_, err = repo.Collection.UpdateOne(ctx, filter, update)
 	if err != nil {
 		return fmt.Errorf("failed to add child profile to parent %s: %w", parentProfile.ProfileId, err)
 	}
 	return nil
 }
 
-func (repo *ProfileRepository) UpsertIdentityAttribute(profileId string, updates bson.M) error {
+func (repo *ProfileRepository) UpsertIdentityAttribute(profileId string, updates map[string]interface{}) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -560,7 +591,7 @@ func (repo *ProfileRepository) UpsertIdentityAttribute(profileId string, updates
 		property := strings.Split(field, ".")
 		propertyName := property[1]
 		existingVal := profile.IdentityAttributes[propertyName]
-		merged := enrichFieldValues(existingVal, incomingVal)
+		merged := EnrichFieldValues(existingVal, incomingVal)
 		finalUpdates[field] = merged
 	}
 
@@ -578,7 +609,7 @@ func (repo *ProfileRepository) UpsertIdentityAttribute(profileId string, updates
 	return nil
 }
 
-func (repo *ProfileRepository) UpsertTrait(profileId string, updates bson.M) error {
+func (repo *ProfileRepository) UpsertTrait(profileId string, updates map[string]interface{}) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -593,7 +624,7 @@ func (repo *ProfileRepository) UpsertTrait(profileId string, updates bson.M) err
 		traitPath := strings.Split(field, ".")
 		traitName := traitPath[1]
 		existingVal := profile.Traits[traitName]
-		finalUpdates[field] = enrichFieldValues(existingVal, incomingVal)
+		finalUpdates[field] = EnrichFieldValues(existingVal, incomingVal)
 	}
 
 	if len(finalUpdates) == 0 {
@@ -604,7 +635,7 @@ func (repo *ProfileRepository) UpsertTrait(profileId string, updates bson.M) err
 	return err
 }
 
-func (repo *ProfileRepository) UpsertAppDatum(profileId string, appId string, update bson.M) error {
+func (repo *ProfileRepository) UpsertAppDatum(profileId string, appId string, update map[string]interface{}) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -634,7 +665,7 @@ func (repo *ProfileRepository) UpsertAppDatum(profileId string, appId string, up
 			}
 			existingVal := existingApp.AppSpecificData[traitName]
 			fieldPath := fmt.Sprintf("application_data.%d.app_specific_data.%s", appIndex, traitName)
-			finalSet[fieldPath] = enrichFieldValues(existingVal, incomingVal)
+			finalSet[fieldPath] = EnrichFieldValues(existingVal, incomingVal)
 		}
 
 		_, err := repo.Collection.UpdateOne(ctx, bson.M{"profile_id": profileId}, bson.M{"$set": finalSet})
@@ -665,7 +696,24 @@ func (repo *ProfileRepository) UpsertAppDatum(profileId string, appId string, up
 	return nil
 }
 
-func enrichFieldValues(existingVal, incomingVal interface{}) interface{} {
+// ReplaceProfile replaces the document of the profile
+func (repo *ProfileRepository) ReplaceProfile(profile models.Profile) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := repo.Collection.ReplaceOne(ctx, bson.M{"profile_id": profile.ProfileId}, profile)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("profile %s not found", profile.ProfileId)
+	}
+	return nil
+}
+
+// EnrichFieldValues merges an incoming value into an existing one. Slices are combined without
+// duplicates while scalar values are overwritten.
+func EnrichFieldValues(existingVal, incomingVal interface{}) interface{} {
 	switch incoming := incomingVal.(type) {
 
 	case []string:
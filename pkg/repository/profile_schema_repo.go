@@ -99,6 +99,9 @@ func (repo *ProfileSchemaRepository) GetSchemaRule(traitId string) (models.Profi
 
 	var rule models.ProfileEnrichmentRule
 	err := repo.Collection.FindOne(ctx, filter).Decode(&rule)
+	if err == mongo.ErrNoDocuments {
+		return models.ProfileEnrichmentRule{}, fmt.Errorf("%w: %s", ErrEnrichmentRuleNotFound, traitId)
+	}
 	if err != nil {
 		return models.ProfileEnrichmentRule{}, err
 	}
@@ -0,0 +1,65 @@
+package repositories
+
+import (
+	"context"
+	"github.com/wso2/identity-customer-data-service/pkg/logger"
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+type ProfileUnmergeRepository struct {
+	Collection *mongo.Collection
+}
+
+func NewProfileUnmergeRepository(db *mongo.Database, collection string) *ProfileUnmergeRepository {
+	repo := &ProfileUnmergeRepository{Collection: db.Collection(collection)}
+	repo.ensureIndexes()
+	return repo
+}
+
+// ensureIndexes creates the indexes for looking up the unmerges by the split profile and by its master
+func (r *ProfileUnmergeRepository) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.Collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "profile_id", Value: 1}}},
+		{Keys: bson.D{{Key: "master_profile_id", Value: 1}}},
+	})
+	if err != nil {
+		logger.Error(err, "Failed to create the indexes on the profile unmerges collection")
+	}
+}
+
+func (r *ProfileUnmergeRepository) AddProfileUnmerge(unmerge models.ProfileUnmerge) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := r.Collection.InsertOne(ctx, unmerge)
+	return err
+}
+
+func (r *ProfileUnmergeRepository) GetProfileUnmerges(profileIds []string) ([]models.ProfileUnmerge, error) {
+	if len(profileIds) == 0 {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"$or": []bson.M{
+		{"profile_id": bson.M{"$in": profileIds}},
+		{"master_profile_id": bson.M{"$in": profileIds}},
+	}}
+	findOptions := options.Find().SetSort(bson.D{{Key: "unmerged_at", Value: -1}})
+	cursor, err := r.Collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var unmerges []models.ProfileUnmerge
+	err = cursor.All(ctx, &unmerges)
+	return unmerges, err
+}
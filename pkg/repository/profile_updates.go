@@ -0,0 +1,114 @@
+package repositories
+
+import (
+	"strings"
+
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+)
+
+// The helpers below apply profile updates on a decoded profile. They are shared by the stores that
+// persist profiles as whole documents (in-memory and SQL) so that all of them merge values the same way.
+
+// ApplyAppContext merges the devices and app specific data of `newAppCtx` into the profile
+func ApplyAppContext(profile *models.Profile, newAppCtx models.ApplicationData) {
+	for i, existing := range profile.ApplicationData {
+		if existing.AppId != newAppCtx.AppId {
+			continue
+		}
+		existing.Devices = MergeDeviceLists(existing.Devices, newAppCtx.Devices)
+		if existing.AppSpecificData == nil {
+			existing.AppSpecificData = map[string]interface{}{}
+		}
+		for k, v := range newAppCtx.AppSpecificData {
+			existing.AppSpecificData[k] = v
+		}
+		profile.ApplicationData[i] = existing
+		return
+	}
+	profile.ApplicationData = append(profile.ApplicationData, newAppCtx)
+}
+
+// ApplyIdentityAttributeUpdates merges `identity_attributes.<name>` updates into the profile
+func ApplyIdentityAttributeUpdates(profile *models.Profile, updates map[string]interface{}) {
+	if profile.IdentityAttributes == nil {
+		profile.IdentityAttributes = map[string]interface{}{}
+	}
+	for field, incomingVal := range updates {
+		name := strings.TrimPrefix(field, "identity_attributes.")
+		profile.IdentityAttributes[name] = EnrichFieldValues(profile.IdentityAttributes[name], incomingVal)
+	}
+}
+
+// ApplyTraitUpdates merges `traits.<name>` updates into the profile
+func ApplyTraitUpdates(profile *models.Profile, updates map[string]interface{}) {
+	if profile.Traits == nil {
+		profile.Traits = map[string]interface{}{}
+	}
+	for field, incomingVal := range updates {
+		name := strings.TrimPrefix(field, "traits.")
+		profile.Traits[name] = EnrichFieldValues(profile.Traits[name], incomingVal)
+	}
+}
+
+// ApplyAppDatumUpdates merges `application_data.<name>` updates into the app specific data of the given app
+func ApplyAppDatumUpdates(profile *models.Profile, appId string, updates map[string]interface{}) {
+	appIndex := -1
+	for i, app := range profile.ApplicationData {
+		if app.AppId == appId {
+			appIndex = i
+			break
+		}
+	}
+	if appIndex == -1 {
+		profile.ApplicationData = append(profile.ApplicationData, models.ApplicationData{AppId: appId})
+		appIndex = len(profile.ApplicationData) - 1
+	}
+
+	app := profile.ApplicationData[appIndex]
+	if app.AppSpecificData == nil {
+		app.AppSpecificData = map[string]interface{}{}
+	}
+	for key, incomingVal := range updates {
+		name := strings.TrimPrefix(key, "application_data.")
+		app.AppSpecificData[name] = EnrichFieldValues(app.AppSpecificData[name], incomingVal)
+	}
+	profile.ApplicationData[appIndex] = app
+}
+
+// ApplyParent marks the profile as a child of the given parent
+func ApplyParent(profile *models.Profile, parentProfileId string) {
+	if profile.ProfileHierarchy == nil {
+		profile.ProfileHierarchy = &models.ProfileHierarchy{}
+	}
+	profile.ProfileHierarchy.ParentProfileID = parentProfileId
+	profile.ProfileHierarchy.IsParent = false
+}
+
+// ApplyChildProfile adds the child to the profile. A child that is already present keeps its place and takes the
+// rule name and confidence of the given one.
+func ApplyChildProfile(profile *models.Profile, child models.ChildProfile) {
+	if profile.ProfileHierarchy == nil {
+		profile.ProfileHierarchy = &models.ProfileHierarchy{}
+	}
+	for i, existing := range profile.ProfileHierarchy.ChildProfiles {
+		if existing.ChildProfileId == child.ChildProfileId {
+			profile.ProfileHierarchy.ChildProfiles[i] = child
+			return
+		}
+	}
+	profile.ProfileHierarchy.ChildProfiles = append(profile.ProfileHierarchy.ChildProfiles, child)
+}
+
+// RemoveChildProfile removes the child with the given id from the profile
+func RemoveChildProfile(profile *models.Profile, childId string) {
+	if profile.ProfileHierarchy == nil {
+		return
+	}
+	var remaining []models.ChildProfile
+	for _, child := range profile.ProfileHierarchy.ChildProfiles {
+		if child.ChildProfileId != childId {
+			remaining = append(remaining, child)
+		}
+	}
+	profile.ProfileHierarchy.ChildProfiles = remaining
+}
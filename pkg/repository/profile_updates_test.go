@@ -0,0 +1,22 @@
+package repositories
+
+import (
+	"testing"
+
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+)
+
+func TestApplyChildProfileUpdatesAnExistingChild(t *testing.T) {
+	profile := &models.Profile{ProfileId: "master"}
+	ApplyChildProfile(profile, models.ChildProfile{ChildProfileId: "p1", RuleName: "email", Confidence: 1})
+	ApplyChildProfile(profile, models.ChildProfile{ChildProfileId: "p2", RuleName: "email", Confidence: 1})
+	ApplyChildProfile(profile, models.ChildProfile{ChildProfileId: "p1", RuleName: "name", Confidence: 0.9})
+
+	children := profile.ProfileHierarchy.ChildProfiles
+	if len(children) != 2 {
+		t.Fatalf("children = %+v, want p1 and p2 once each", children)
+	}
+	if children[0] != (models.ChildProfile{ChildProfileId: "p1", RuleName: "name", Confidence: 0.9}) {
+		t.Errorf("child p1 = %+v, want it updated in place with the new rule and confidence", children[0])
+	}
+}
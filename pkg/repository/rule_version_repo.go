@@ -0,0 +1,107 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+type RuleVersionRepository struct {
+	Collection *mongo.Collection
+}
+
+// NewRuleVersionRepository creates a repository for the version history of rules. It fails if the unique version
+// index can not be created, since concurrent changes of a rule could record the same version without it.
+func NewRuleVersionRepository(db *mongo.Database, collection string) (*RuleVersionRepository, error) {
+	// Values in the diffs are decoded as maps so that they are rendered as JSON objects
+	collectionOptions := options.Collection().SetBSONOptions(&options.BSONOptions{DefaultDocumentM: true})
+	repo := &RuleVersionRepository{Collection: db.Collection(collection, collectionOptions)}
+	if err := repo.ensureIndexes(); err != nil {
+		return nil, err
+	}
+	return repo, nil
+}
+
+// ensureIndexes creates the unique index that keeps concurrent changes of a rule from recording the same version
+func (r *RuleVersionRepository) ensureIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.Collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "rule_type", Value: 1}, {Key: "rule_id", Value: 1}, {Key: "version", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create the unique version index on the rule versions collection: %w", err)
+	}
+	return nil
+}
+
+func (r *RuleVersionRepository) AddRuleVersion(version models.RuleVersion) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := r.Collection.InsertOne(ctx, version)
+	if mongo.IsDuplicateKeyError(err) {
+		return fmt.Errorf("%w: version %d of %s rule %s", ErrRuleVersionExists, version.Version, version.RuleType,
+			version.RuleId)
+	}
+	return err
+}
+
+func (r *RuleVersionRepository) GetRuleVersions(ruleType, ruleId string) ([]models.RuleVersion, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "version", Value: -1}})
+	cursor, err := r.Collection.Find(ctx, bson.M{"rule_type": ruleType, "rule_id": ruleId}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var versions []models.RuleVersion
+	err = cursor.All(ctx, &versions)
+	return versions, err
+}
+
+// GetRuleVersion retrieves a version of a rule. Nil is returned if the rule has no such version.
+func (r *RuleVersionRepository) GetRuleVersion(ruleType, ruleId string, version int) (*models.RuleVersion, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var ruleVersion models.RuleVersion
+	err := r.Collection.FindOne(ctx, bson.M{"rule_type": ruleType, "rule_id": ruleId, "version": version}).
+		Decode(&ruleVersion)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ruleVersion, nil
+}
+
+// GetLatestRuleVersion retrieves the number of the latest version of a rule. 0 is returned if the rule has no
+// versions.
+func (r *RuleVersionRepository) GetLatestRuleVersion(ruleType, ruleId string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	findOptions := options.FindOne().SetSort(bson.D{{Key: "version", Value: -1}}).
+		SetProjection(bson.M{"version": 1})
+	var latest struct {
+		Version int `bson:"version"`
+	}
+	err := r.Collection.FindOne(ctx, bson.M{"rule_type": ruleType, "rule_id": ruleId}, findOptions).Decode(&latest)
+	if err == mongo.ErrNoDocuments {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return latest.Version, nil
+}
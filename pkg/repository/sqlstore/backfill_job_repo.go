@@ -0,0 +1,131 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+)
+
+// BackfillJobRepository keeps the backfill jobs of enrichment rules in the `backfill_jobs` table. The rule id, status
+// and creation time are kept in their own columns to list the jobs and to update them conditionally.
+type BackfillJobRepository struct {
+	db *Database
+}
+
+// NewBackfillJobRepository creates a new SQL backed backfill job repository
+func NewBackfillJobRepository(db *Database) *BackfillJobRepository {
+	return &BackfillJobRepository{db: db}
+}
+
+func (repo *BackfillJobRepository) AddBackfillJob(job models.BackfillJob) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	definition, err := toJSON(job)
+	if err != nil {
+		return fmt.Errorf("failed to encode backfill job %s: %w", job.JobId, err)
+	}
+	_, err = repo.db.exec(ctx, "INSERT INTO backfill_jobs (job_id, rule_id, status, created_at, definition) "+
+		"VALUES (?, ?, ?, ?, "+repo.db.dialect.jsonParam+")", job.JobId, job.RuleId, job.Status, job.CreatedAt,
+		definition)
+	return err
+}
+
+func (repo *BackfillJobRepository) GetBackfillJob(jobId string) (*models.BackfillJob, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	row := repo.db.DB.QueryRowContext(ctx, repo.db.rebind("SELECT definition FROM backfill_jobs WHERE job_id = ?"),
+		jobId)
+	job, err := scanBackfillJob(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (repo *BackfillJobRepository) GetBackfillJobs(ruleId string) ([]models.BackfillJob, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	rows, err := repo.db.query(ctx, "SELECT definition FROM backfill_jobs WHERE rule_id = ? "+
+		"ORDER BY created_at DESC", ruleId)
+	if err != nil {
+		return nil, err
+	}
+	return scanBackfillJobs(rows)
+}
+
+func (repo *BackfillJobRepository) GetBackfillJobsByStatus(status string) ([]models.BackfillJob, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	rows, err := repo.db.query(ctx, "SELECT definition FROM backfill_jobs WHERE status = ? "+
+		"ORDER BY created_at DESC", status)
+	if err != nil {
+		return nil, err
+	}
+	return scanBackfillJobs(rows)
+}
+
+func scanBackfillJobs(rows *sql.Rows) ([]models.BackfillJob, error) {
+	defer rows.Close()
+
+	var jobs []models.BackfillJob
+	for rows.Next() {
+		job, err := scanBackfillJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+func (repo *BackfillJobRepository) UpdateBackfillJob(job models.BackfillJob) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	definition, err := toJSON(job)
+	if err != nil {
+		return fmt.Errorf("failed to encode backfill job %s: %w", job.JobId, err)
+	}
+	_, err = repo.db.exec(ctx, "UPDATE backfill_jobs SET status = ?, definition = "+repo.db.dialect.jsonParam+
+		" WHERE job_id = ?", job.Status, definition, job.JobId)
+	return err
+}
+
+func (repo *BackfillJobRepository) UpdateBackfillJobIfStatus(job models.BackfillJob, status string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	definition, err := toJSON(job)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode backfill job %s: %w", job.JobId, err)
+	}
+	result, err := repo.db.exec(ctx, "UPDATE backfill_jobs SET status = ?, definition = "+repo.db.dialect.jsonParam+
+		" WHERE job_id = ? AND status = ?", job.Status, definition, job.JobId, status)
+	if err != nil {
+		return false, err
+	}
+	updated, err := result.RowsAffected()
+	return updated > 0, err
+}
+
+func scanBackfillJob(row rowScanner) (models.BackfillJob, error) {
+	var definition sql.NullString
+	if err := row.Scan(&definition); err != nil {
+		return models.BackfillJob{}, err
+	}
+	var job models.BackfillJob
+	if err := fromJSON(definition, &job); err != nil {
+		return models.BackfillJob{}, fmt.Errorf("failed to decode backfill job: %w", err)
+	}
+	return job, nil
+}
@@ -0,0 +1,59 @@
+package sqlstore
+
+import (
+	"testing"
+
+	"github.com/wso2/identity-customer-data-service/pkg/constants"
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+)
+
+func TestUpdateBackfillJobIfStatusKeepsJobsThatChanged(t *testing.T) {
+	repo := NewBackfillJobRepository(openTestDatabase(t))
+	for i, jobId := range []string{"job-1", "job-2"} {
+		job := models.BackfillJob{JobId: jobId, RuleId: "rule-plan", Status: constants.BackfillJobRunning,
+			CreatedAt: int64(i)}
+		if err := repo.AddBackfillJob(job); err != nil {
+			t.Fatalf("failed to add backfill job: %v", err)
+		}
+	}
+
+	cancelled := models.BackfillJob{JobId: "job-1", RuleId: "rule-plan", Status: constants.BackfillJobCancelled}
+	if err := repo.UpdateBackfillJob(cancelled); err != nil {
+		t.Fatalf("failed to cancel backfill job: %v", err)
+	}
+	for _, test := range []struct {
+		jobId   string
+		updated bool
+		status  string
+	}{
+		{jobId: "job-1", status: constants.BackfillJobCancelled},
+		{jobId: "job-2", updated: true, status: constants.BackfillJobCompleted},
+		{jobId: "job-3"},
+	} {
+		completed := models.BackfillJob{JobId: test.jobId, RuleId: "rule-plan", Status: constants.BackfillJobCompleted,
+			ProcessedProfiles: 5}
+		updated, err := repo.UpdateBackfillJobIfStatus(completed, constants.BackfillJobRunning)
+		if err != nil || updated != test.updated {
+			t.Errorf("completing %s = (%v, %v), want %v", test.jobId, updated, err, test.updated)
+		}
+		job, err := repo.GetBackfillJob(test.jobId)
+		if err != nil {
+			t.Fatalf("failed to fetch backfill job %s: %v", test.jobId, err)
+		}
+		status := ""
+		if job != nil {
+			status = job.Status
+		}
+		if status != test.status {
+			t.Errorf("job %s is %q, want %q", test.jobId, status, test.status)
+		}
+	}
+
+	for status, want := range map[string]int{constants.BackfillJobCancelled: 1, constants.BackfillJobCompleted: 1,
+		constants.BackfillJobRunning: 0} {
+		jobs, err := repo.GetBackfillJobsByStatus(status)
+		if err != nil || len(jobs) != want {
+			t.Errorf("%s jobs = %+v (%v), want %d", status, jobs, err, want)
+		}
+	}
+}
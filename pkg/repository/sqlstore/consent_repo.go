@@ -0,0 +1,129 @@
+package sqlstore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+)
+
+// consentColumns maps the consent types onto their columns
+var consentColumns = map[string]string{
+	"consented_to_collect": "consented_to_collect",
+	"consented_to_share":   "consented_to_share",
+}
+
+// ConsentRepository keeps consents in the `consents` table
+type ConsentRepository struct {
+	db *Database
+}
+
+// NewConsentRepository creates a new SQL backed consent repository
+func NewConsentRepository(db *Database) *ConsentRepository {
+	return &ConsentRepository{db: db}
+}
+
+// GetConsentedApps fetches all apps with consent status
+func (repo *ConsentRepository) GetConsentedApps(permaID string) ([]models.Consent, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	rows, err := repo.db.query(ctx, "SELECT perma_id, app_id, consented_to_collect, consented_to_share FROM consents "+
+		"WHERE perma_id = ? ORDER BY app_id", permaID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var consents []models.Consent
+	for rows.Next() {
+		var consent models.Consent
+		if err := rows.Scan(&consent.PermaID, &consent.AppID, &consent.ConsentedToCollect,
+			&consent.ConsentedToShare); err != nil {
+			return nil, err
+		}
+		consents = append(consents, consent)
+	}
+	return consents, rows.Err()
+}
+
+// GetConsentedAppsToCollect fetches only apps where `consented_to_collect=true`
+func (repo *ConsentRepository) GetConsentedAppsToCollect(permaID string) ([]string, error) {
+	return repo.getConsentedAppsByType(permaID, "consented_to_collect")
+}
+
+// GetConsentedAppsToShare fetches only apps where `consented_to_share=true`
+func (repo *ConsentRepository) GetConsentedAppsToShare(permaID string) ([]string, error) {
+	return repo.getConsentedAppsByType(permaID, "consented_to_share")
+}
+
+func (repo *ConsentRepository) getConsentedAppsByType(permaID, consentType string) ([]string, error) {
+	column, err := consentColumn(consentType)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	rows, err := repo.db.query(ctx, "SELECT app_id FROM consents WHERE perma_id = ? AND "+column+" = ? ORDER BY app_id",
+		permaID, true)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var apps []string
+	for rows.Next() {
+		var app string
+		if err := rows.Scan(&app); err != nil {
+			return nil, err
+		}
+		apps = append(apps, app)
+	}
+	return apps, rows.Err()
+}
+
+// GiveConsent updates or creates a consent record
+func (repo *ConsentRepository) GiveConsent(permaID, appID string, consentType string) error {
+	column, err := consentColumn(consentType)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	_, err = repo.db.exec(ctx, "INSERT INTO consents (perma_id, app_id, "+column+") VALUES (?, ?, ?) "+
+		"ON CONFLICT (perma_id, app_id) DO UPDATE SET "+column+" = excluded."+column, permaID, appID, true)
+	return err
+}
+
+// RevokeConsent removes a user's consent for an app
+func (repo *ConsentRepository) RevokeConsent(permaID, appID string, consentType string) error {
+	column, err := consentColumn(consentType)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	_, err = repo.db.exec(ctx, "UPDATE consents SET "+column+" = ? WHERE perma_id = ? AND app_id = ?",
+		false, permaID, appID)
+	return err
+}
+
+// RevokeAllConsents removes all consents for a user
+func (repo *ConsentRepository) RevokeAllConsents(permaID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	_, err := repo.db.exec(ctx, "DELETE FROM consents WHERE perma_id = ?", permaID)
+	return err
+}
+
+func consentColumn(consentType string) (string, error) {
+	column, ok := consentColumns[consentType]
+	if !ok {
+		return "", fmt.Errorf("unsupported consent type: %s", consentType)
+	}
+	return column, nil
+}
@@ -0,0 +1,102 @@
+// Package sqlstore provides SQL implementations of the repository stores for PostgreSQL and SQLite. JSON
+// valued profile and event fields are kept in JSON columns (JSONB on PostgreSQL, TEXT on SQLite) while the
+// fields used for lookups are stored as regular columns.
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const queryTimeout = 10 * time.Second
+
+// Database wraps a SQL connection pool together with the dialect it speaks
+type Database struct {
+	DB      *sql.DB
+	dialect dialect
+}
+
+// Close closes the underlying connection pool
+func (d *Database) Close() error {
+	return d.DB.Close()
+}
+
+// rebind converts `?` placeholders into the placeholder style of the dialect
+func (d *Database) rebind(query string) string {
+	if !d.dialect.numberedPlaceholders {
+		return query
+	}
+	var sb strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			sb.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+func (d *Database) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return d.DB.ExecContext(ctx, d.rebind(query), args...)
+}
+
+func (d *Database) query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return d.DB.QueryContext(ctx, d.rebind(query), args...)
+}
+
+// withTx runs fn inside a transaction which is committed if fn succeeds and rolled back otherwise
+func (d *Database) withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := d.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// dialect captures the differences between the supported SQL databases
+type dialect struct {
+	name string
+	// numberedPlaceholders is true when placeholders are written as $1, $2... instead of `?`
+	numberedPlaceholders bool
+	// lockRow is appended to a SELECT that reads a row which is about to be updated in the same transaction
+	lockRow string
+	// skipLocked is appended to a SELECT that picks rows to claim, skipping rows claimed by concurrent transactions
+	skipLocked string
+	// jsonParam wraps a placeholder that carries a JSON document
+	jsonParam string
+	// migrationLock is executed at the start of the migration transaction to serialize concurrent migrations
+	migrationLock string
+	// jsonValues returns a subquery yielding a `value` text column for the values at a JSON path of the
+	// column. Arrays are expanded to their elements. It consumes a single path argument built by jsonPath.
+	jsonValues func(column string) string
+	// jsonPath builds the path argument for jsonValues from the path keys
+	jsonPath func(keys []string) string
+}
+
+// toJSON marshals a value for storage in a JSON column. Nil maps and slices are stored as JSON null.
+func toJSON(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// fromJSON unmarshals a JSON column into the target, ignoring NULL columns
+func fromJSON(data sql.NullString, target interface{}) error {
+	if !data.Valid || data.String == "" || data.String == "null" {
+		return nil
+	}
+	return json.Unmarshal([]byte(data.String), target)
+}
@@ -0,0 +1,192 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+)
+
+const queuedEventColumns = "queue_id, event, attempts, enqueued_at, available_at, claimed_by, lease_expires_at, last_error"
+
+// headOfProfile holds for a queued event that has no earlier event of its profile left in the queue. An event stays
+// in the queue while it is leased or waiting for a retry, so only one event of a profile is claimed at a time and the
+// events of a profile are processed in order, whichever node claims them.
+const headOfProfile = "NOT EXISTS (SELECT 1 FROM event_queue earlier WHERE earlier.profile_id = %[1]s.profile_id " +
+	"AND (earlier.enqueued_at < %[1]s.enqueued_at " +
+	"OR (earlier.enqueued_at = %[1]s.enqueued_at AND earlier.queue_id < %[1]s.queue_id)))"
+
+// claimableEvents selects the available events that are at the head of their profile
+var claimableEvents = "SELECT queue_id FROM event_queue queued WHERE available_at <= ? AND lease_expires_at <= ? AND " +
+	fmt.Sprintf(headOfProfile, "queued") + " ORDER BY enqueued_at, queue_id LIMIT ?"
+
+// EventQueueRepository keeps the event processing queue in the `event_queue` table and failed events in the
+// `dead_letter_events` table
+type EventQueueRepository struct {
+	db *Database
+}
+
+// NewEventQueueRepository creates a new SQL backed event queue repository
+func NewEventQueueRepository(db *Database) *EventQueueRepository {
+	return &EventQueueRepository{db: db}
+}
+
+func (repo *EventQueueRepository) Enqueue(events []models.QueuedEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	query := repo.db.rebind("INSERT INTO event_queue (" + queuedEventColumns + ", profile_id) VALUES (?, " +
+		repo.db.dialect.jsonParam + ", ?, ?, ?, ?, ?, ?, ?)")
+	return repo.db.withTx(ctx, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, query)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		for _, event := range events {
+			data, err := toJSON(event.Event)
+			if err != nil {
+				return fmt.Errorf("failed to encode queued event %s: %w", event.QueueId, err)
+			}
+			if _, err := stmt.ExecContext(ctx, event.QueueId, data, event.Attempts, event.EnqueuedAt,
+				event.AvailableAt, event.ClaimedBy, event.LeaseExpiresAt, event.LastError,
+				event.Event.ProfileId); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (repo *EventQueueRepository) Claim(workerId string, limit int, lease time.Duration) ([]models.QueuedEvent, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	now := time.Now()
+	// The conditions are checked again on the rows being updated, in case a concurrent claim or acknowledgement
+	// changed them after the subquery read them
+	rows, err := repo.db.query(ctx, "UPDATE event_queue SET claimed_by = ?, lease_expires_at = ?, attempts = attempts + 1 "+
+		"WHERE queue_id IN ("+claimableEvents+repo.db.dialect.skipLocked+") "+
+		"AND available_at <= ? AND lease_expires_at <= ? AND "+fmt.Sprintf(headOfProfile, "event_queue")+
+		" RETURNING "+queuedEventColumns,
+		workerId, now.Add(lease).UnixMilli(), now.UnixMilli(), now.UnixMilli(), limit, now.UnixMilli(), now.UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var claimed []models.QueuedEvent
+	for rows.Next() {
+		event, err := scanQueuedEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		claimed = append(claimed, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// RETURNING does not preserve the order of the subquery
+	sort.Slice(claimed, func(i, j int) bool {
+		return claimed[i].EnqueuedAt < claimed[j].EnqueuedAt
+	})
+	return claimed, nil
+}
+
+func (repo *EventQueueRepository) Ack(event models.QueuedEvent) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	_, err := repo.db.exec(ctx, "DELETE FROM event_queue WHERE queue_id = ? AND claimed_by = ?", event.QueueId,
+		event.ClaimedBy)
+	return err
+}
+
+func (repo *EventQueueRepository) Release(event models.QueuedEvent, availableAt int64, lastError string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	_, err := repo.db.exec(ctx, "UPDATE event_queue SET attempts = ?, available_at = ?, lease_expires_at = 0, "+
+		"claimed_by = '', last_error = ? WHERE queue_id = ? AND claimed_by = ?", event.Attempts, availableAt,
+		lastError, event.QueueId, event.ClaimedBy)
+	return err
+}
+
+func (repo *EventQueueRepository) DeadLetter(event models.QueuedEvent, lastError string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	data, err := toJSON(event.Event)
+	if err != nil {
+		return fmt.Errorf("failed to encode queued event %s: %w", event.QueueId, err)
+	}
+	return repo.db.withTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, repo.db.rebind("INSERT INTO dead_letter_events (queue_id, event, attempts, "+
+			"enqueued_at, last_error, failed_at) VALUES (?, "+repo.db.dialect.jsonParam+", ?, ?, ?, ?) "+
+			"ON CONFLICT (queue_id) DO UPDATE SET event = excluded.event, attempts = excluded.attempts, "+
+			"last_error = excluded.last_error, failed_at = excluded.failed_at"),
+			event.QueueId, data, event.Attempts, event.EnqueuedAt, lastError, time.Now().UTC().Unix())
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, repo.db.rebind("DELETE FROM event_queue WHERE queue_id = ? AND claimed_by = ?"),
+			event.QueueId, event.ClaimedBy)
+		return err
+	})
+}
+
+func (repo *EventQueueRepository) GetDeadLetterEvents() ([]models.DeadLetterEvent, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	rows, err := repo.db.query(ctx, "SELECT queue_id, event, attempts, enqueued_at, last_error, failed_at "+
+		"FROM dead_letter_events ORDER BY failed_at, queue_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []models.DeadLetterEvent
+	for rows.Next() {
+		var deadLetter models.DeadLetterEvent
+		var data sql.NullString
+		if err := rows.Scan(&deadLetter.QueueId, &data, &deadLetter.Attempts, &deadLetter.EnqueuedAt,
+			&deadLetter.LastError, &deadLetter.FailedAt); err != nil {
+			return nil, err
+		}
+		if err := fromJSON(data, &deadLetter.Event); err != nil {
+			return nil, fmt.Errorf("failed to decode dead letter event %s: %w", deadLetter.QueueId, err)
+		}
+		events = append(events, deadLetter)
+	}
+	return events, rows.Err()
+}
+
+func (repo *EventQueueRepository) Depth() (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	var depth int64
+	err := repo.db.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM event_queue").Scan(&depth)
+	return depth, err
+}
+
+func scanQueuedEvent(row rowScanner) (models.QueuedEvent, error) {
+	var event models.QueuedEvent
+	var data sql.NullString
+	if err := row.Scan(&event.QueueId, &data, &event.Attempts, &event.EnqueuedAt, &event.AvailableAt,
+		&event.ClaimedBy, &event.LeaseExpiresAt, &event.LastError); err != nil {
+		return event, err
+	}
+	if err := fromJSON(data, &event.Event); err != nil {
+		return event, fmt.Errorf("failed to decode queued event %s: %w", event.QueueId, err)
+	}
+	return event, nil
+}
@@ -0,0 +1,61 @@
+package sqlstore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+)
+
+func TestClaimLeasesTheHeadEventOfEachProfile(t *testing.T) {
+	repo := NewEventQueueRepository(openTestDatabase(t))
+	now := time.Now()
+	var queued []models.QueuedEvent
+	for i, event := range []struct{ eventId, profileId string }{{"a1", "a"}, {"a2", "a"}, {"b1", "b"}, {"b2", "b"}} {
+		queued = append(queued, models.QueuedEvent{
+			QueueId:     event.eventId,
+			Event:       models.Event{EventId: event.eventId, ProfileId: event.profileId},
+			EnqueuedAt:  now.UnixNano() + int64(i),
+			AvailableAt: now.UnixMilli(),
+		})
+	}
+	if err := repo.Enqueue(queued); err != nil {
+		t.Fatalf("failed to enqueue events: %v", err)
+	}
+
+	claim := func(workerId string) []string {
+		t.Helper()
+		claimed, err := repo.Claim(workerId, 10, time.Minute)
+		if err != nil {
+			t.Fatalf("failed to claim events: %v", err)
+		}
+		var eventIds []string
+		for _, event := range claimed {
+			eventIds = append(eventIds, event.Event.EventId)
+		}
+		return eventIds
+	}
+
+	first := claim("worker-1")
+	if len(first) != 2 || first[0] != "a1" || first[1] != "b1" {
+		t.Fatalf("first worker claimed %v, want a1 and b1", first)
+	}
+	if second := claim("worker-2"); len(second) != 0 {
+		t.Fatalf("second worker claimed %v while the heads are leased", second)
+	}
+
+	// A released head waiting for its retry still holds back the events behind it
+	b1 := queued[2]
+	b1.ClaimedBy, b1.Attempts = "worker-1", 1
+	if err := repo.Release(b1, now.Add(time.Minute).UnixMilli(), "failed"); err != nil {
+		t.Fatalf("failed to release b1: %v", err)
+	}
+	a1 := queued[0]
+	a1.ClaimedBy = "worker-1"
+	if err := repo.Ack(a1); err != nil {
+		t.Fatalf("failed to acknowledge a1: %v", err)
+	}
+	if second := claim("worker-2"); len(second) != 1 || second[0] != "a2" {
+		t.Fatalf("second worker claimed %v, want a2 only", second)
+	}
+}
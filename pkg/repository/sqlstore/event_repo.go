@@ -0,0 +1,219 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+	repositories "github.com/wso2/identity-customer-data-service/pkg/repository"
+)
+
+const eventColumns = "event_id, profile_id, event_type, event_name, application_id, org_id, event_timestamp, " +
+	"properties, context"
+
+// EventRepository keeps events in the `events` table
+type EventRepository struct {
+	db *Database
+}
+
+// NewEventRepository creates a new SQL backed event repository
+func NewEventRepository(db *Database) *EventRepository {
+	return &EventRepository{db: db}
+}
+
+// AddEvent stores a single event unless an event with the same event_id was already stored for the org, and
+// reports whether it has to be queued for processing
+func (repo *EventRepository) AddEvent(event models.Event) (bool, error) {
+	added, err := repo.AddEvents([]models.Event{event})
+	return len(added) == 1, err
+}
+
+// AddEvents stores multiple events in bulk and returns the ones that have to be queued for processing. Events whose
+// event_id was already stored for the org are skipped by the unique index on (org_id, event_id), and only have to
+// be queued if the stored event is not marked as queued.
+func (repo *EventRepository) AddEvents(events []models.Event) ([]models.Event, error) {
+	if len(events) == 0 {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	jsonParam := repo.db.dialect.jsonParam
+	query := repo.db.rebind("INSERT INTO events (" + eventColumns + ", queued) VALUES (?, ?, ?, ?, ?, ?, ?, " +
+		jsonParam + ", " + jsonParam + ", ?) ON CONFLICT DO NOTHING")
+	var added []models.Event
+	err := repo.db.withTx(ctx, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, query)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		seen := map[string]bool{}
+		for _, event := range events {
+			properties, err := toJSON(event.Properties)
+			if err != nil {
+				return fmt.Errorf("failed to encode event %s: %w", event.EventId, err)
+			}
+			eventContext, err := toJSON(event.Context)
+			if err != nil {
+				return fmt.Errorf("failed to encode event %s: %w", event.EventId, err)
+			}
+			result, err := stmt.ExecContext(ctx, event.EventId, event.ProfileId, event.EventType, event.EventName,
+				event.AppId, event.OrgId, int64(event.EventTimestamp), properties, eventContext, false)
+			if err != nil {
+				return err
+			}
+			key := event.OrgId + "/" + event.EventId
+			if inserted, err := result.RowsAffected(); err != nil {
+				return err
+			} else if inserted == 1 {
+				seen[key] = true
+				added = append(added, event)
+				continue
+			}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			// A replay of an event that was stored but never queued
+			var queued bool
+			err = tx.QueryRowContext(ctx, repo.db.rebind("SELECT queued FROM events WHERE org_id = ? AND event_id = ?"),
+				event.OrgId, event.EventId).Scan(&queued)
+			if err != nil {
+				return err
+			}
+			if !queued {
+				added = append(added, event)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return added, nil
+}
+
+// MarkEventsQueued records that the events were queued for processing
+func (repo *EventRepository) MarkEventsQueued(events []models.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	query := repo.db.rebind("UPDATE events SET queued = ? WHERE org_id = ? AND event_id = ? AND event_id <> ''")
+	return repo.db.withTx(ctx, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, query)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		for _, event := range events {
+			if _, err := stmt.ExecContext(ctx, true, event.OrgId, event.EventId); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// FindEvents fetches all events matching the filters that occurred on or after `fromTimestamp`
+func (repo *EventRepository) FindEvents(filters []string, fromTimestamp int64) ([]models.Event, error) {
+	where, args, err := repo.db.whereClause(filters, eventFilterTarget, false)
+	if err != nil {
+		return nil, err
+	}
+	if fromTimestamp > 0 {
+		where, args = appendCondition(where, args, "event_timestamp >= ?", fromTimestamp)
+	}
+	return repo.findEvents(where, args...)
+}
+
+func (repo *EventRepository) FindEvent(eventId string) (*models.Event, error) {
+	events, err := repo.findEvents(" WHERE event_id = ?", eventId)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, fmt.Errorf("event %s not found", eventId)
+	}
+	return &events[0], nil
+}
+
+func (repo *EventRepository) FindEventsWithFilter(filter repositories.EventFilter) ([]models.Event, error) {
+	var where string
+	var args []interface{}
+	if filter.ProfileId != "" {
+		where, args = appendCondition(where, args, "profile_id = ?", filter.ProfileId)
+	}
+	if filter.EventType != "" {
+		where, args = appendCondition(where, args, "event_type = ?", filter.EventType)
+	}
+	if filter.EventName != "" {
+		where, args = appendCondition(where, args, "event_name = ?", filter.EventName)
+	}
+	if filter.FromTimestamp > 0 {
+		where, args = appendCondition(where, args, "event_timestamp >= ?", filter.FromTimestamp)
+	}
+	return repo.findEvents(where, args...)
+}
+
+func (repo *EventRepository) DeleteEventsByProfileId(profileId string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	_, err := repo.db.exec(ctx, "DELETE FROM events WHERE profile_id = ?", profileId)
+	return err
+}
+
+func (repo *EventRepository) DeleteEventsByAppID(profileId, appID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	_, err := repo.db.exec(ctx, "DELETE FROM events WHERE profile_id = ? AND application_id = ?", profileId, appID)
+	return err
+}
+
+// findEvents fetches the events matching the where clause in insertion order
+func (repo *EventRepository) findEvents(where string, args ...interface{}) ([]models.Event, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	rows, err := repo.db.query(ctx, "SELECT "+eventColumns+" FROM events"+where+" ORDER BY id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []models.Event
+	for rows.Next() {
+		var event models.Event
+		var timestamp int64
+		var properties, eventContext sql.NullString
+		if err := rows.Scan(&event.EventId, &event.ProfileId, &event.EventType, &event.EventName, &event.AppId,
+			&event.OrgId, &timestamp, &properties, &eventContext); err != nil {
+			return nil, err
+		}
+		event.EventTimestamp = int(timestamp)
+		if err := errors.Join(fromJSON(properties, &event.Properties), fromJSON(eventContext, &event.Context)); err != nil {
+			return nil, fmt.Errorf("failed to decode event %s: %w", event.EventId, err)
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// appendCondition adds a condition to a where clause built by whereClause
+func appendCondition(where string, args []interface{}, condition string, arg interface{}) (string, []interface{}) {
+	if strings.TrimSpace(where) == "" {
+		return " WHERE " + condition, append(args, arg)
+	}
+	return where + " AND " + condition, append(args, arg)
+}
@@ -0,0 +1,142 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+)
+
+// EventSchemaRepository keeps event schemas in the `event_schemas` table. The event type and name are kept in
+// their own columns to look up the schema of an incoming event.
+type EventSchemaRepository struct {
+	db *Database
+}
+
+// NewEventSchemaRepository creates a new SQL backed event schema repository
+func NewEventSchemaRepository(db *Database) *EventSchemaRepository {
+	return &EventSchemaRepository{db: db}
+}
+
+func (repo *EventSchemaRepository) AddEventSchema(schema models.EventSchema) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	definition, err := toJSON(schema)
+	if err != nil {
+		return fmt.Errorf("failed to encode event schema %s: %w", schema.EventSchemaId, err)
+	}
+	_, err = repo.db.exec(ctx, "INSERT INTO event_schemas (event_schema_id, event_type, event_name, definition) "+
+		"VALUES (?, ?, ?, "+repo.db.dialect.jsonParam+")", schema.EventSchemaId, schema.EventType, schema.EventName,
+		definition)
+	return err
+}
+
+func (repo *EventSchemaRepository) GetAllEventSchemas() ([]models.EventSchema, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	rows, err := repo.db.query(ctx, "SELECT definition FROM event_schemas ORDER BY event_type, event_name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schemas []models.EventSchema
+	for rows.Next() {
+		schema, err := scanEventSchema(rows)
+		if err != nil {
+			return nil, err
+		}
+		schemas = append(schemas, schema)
+	}
+	return schemas, rows.Err()
+}
+
+func (repo *EventSchemaRepository) GetById(id string) (*models.EventSchema, error) {
+	return repo.findOne("event_schema_id = ?", id)
+}
+
+func (repo *EventSchemaRepository) GetByEvent(eventType, eventName string) (*models.EventSchema, error) {
+	return repo.findOne("event_type = ? AND event_name = ?", eventType, eventName)
+}
+
+func (repo *EventSchemaRepository) Patch(id string, updates map[string]interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	return repo.db.withTx(ctx, func(tx *sql.Tx) error {
+		row := tx.QueryRowContext(ctx, repo.db.rebind("SELECT definition FROM event_schemas WHERE event_schema_id = ?"+
+			repo.db.dialect.lockRow), id)
+		var definition sql.NullString
+		if err := row.Scan(&definition); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil
+			}
+			return err
+		}
+
+		doc := map[string]interface{}{}
+		if err := fromJSON(definition, &doc); err != nil {
+			return err
+		}
+		for field, value := range updates {
+			doc[field] = value
+		}
+		data, err := json.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		var patched models.EventSchema
+		if err := json.Unmarshal(data, &patched); err != nil {
+			return err
+		}
+		patchedDefinition, err := toJSON(patched)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, repo.db.rebind("UPDATE event_schemas SET event_type = ?, event_name = ?, "+
+			"definition = "+repo.db.dialect.jsonParam+" WHERE event_schema_id = ?"), patched.EventType,
+			patched.EventName, patchedDefinition, id)
+		return err
+	})
+}
+
+func (repo *EventSchemaRepository) Delete(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	_, err := repo.db.exec(ctx, "DELETE FROM event_schemas WHERE event_schema_id = ?", id)
+	return err
+}
+
+func (repo *EventSchemaRepository) findOne(condition string, args ...interface{}) (*models.EventSchema, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	row := repo.db.DB.QueryRowContext(ctx, repo.db.rebind("SELECT definition FROM event_schemas WHERE "+condition),
+		args...)
+	schema, err := scanEventSchema(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+func scanEventSchema(row rowScanner) (models.EventSchema, error) {
+	var definition sql.NullString
+	if err := row.Scan(&definition); err != nil {
+		return models.EventSchema{}, err
+	}
+	var schema models.EventSchema
+	if err := fromJSON(definition, &schema); err != nil {
+		return models.EventSchema{}, fmt.Errorf("failed to decode event schema: %w", err)
+	}
+	return schema, nil
+}
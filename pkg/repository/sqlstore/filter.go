@@ -0,0 +1,131 @@
+package sqlstore
+
+import (
+	"fmt"
+	"strings"
+)
+
+type fieldKind int
+
+const (
+	textColumn fieldKind = iota
+	numericColumn
+	jsonColumn
+)
+
+// filterField describes how a top level field of a filter maps onto a table column
+type filterField struct {
+	column string
+	kind   fieldKind
+}
+
+// filterTarget describes the filterable fields of a table. Fields that are not listed are looked up
+// inside `fallbackJSON` (using the full field path) when it is set, and never match otherwise.
+type filterTarget struct {
+	fields       map[string]filterField
+	fallbackJSON string
+}
+
+var profileFilterTarget = filterTarget{
+	fields: map[string]filterField{
+		"profile_id":          {column: "profile_id", kind: textColumn},
+		"origin_country":      {column: "origin_country", kind: textColumn},
+		"identity_attributes": {column: "identity_attributes", kind: jsonColumn},
+		"traits":              {column: "traits", kind: jsonColumn},
+		"application_data":    {column: "application_data", kind: jsonColumn},
+		"profile_hierarchy":   {column: "profile_hierarchy", kind: jsonColumn},
+	},
+}
+
+var eventFilterTarget = filterTarget{
+	fields: map[string]filterField{
+		"event_id":        {column: "event_id", kind: textColumn},
+		"profile_id":      {column: "profile_id", kind: textColumn},
+		"event_type":      {column: "event_type", kind: textColumn},
+		"event_name":      {column: "event_name", kind: textColumn},
+		"application_id":  {column: "application_id", kind: textColumn},
+		"org_id":          {column: "org_id", kind: textColumn},
+		"event_timestamp": {column: "event_timestamp", kind: numericColumn},
+		"properties":      {column: "properties", kind: jsonColumn},
+		"context":         {column: "context", kind: jsonColumn},
+	},
+}
+
+var enrichmentRuleFilterTarget = filterTarget{
+	fallbackJSON: "definition",
+}
+
+// filterCondition translates a "<field> <operator> <value>" filter into a SQL condition using `?`
+// placeholders. Supported operators are eq (equals), sw (starts with) and co (contains). When the field
+// resolves to a JSON array, the condition matches if any of its elements matches.
+func (d *Database) filterCondition(filter string, target filterTarget) (string, []interface{}, error) {
+	parts := strings.SplitN(filter, " ", 3)
+	if len(parts) != 3 {
+		return "", nil, fmt.Errorf("invalid filter format: %s", filter)
+	}
+	field, operator, value := parts[0], strings.ToLower(parts[1]), strings.TrimSpace(parts[2])
+
+	var comparison string
+	var arg interface{}
+	switch operator {
+	case "eq":
+		comparison, arg = "= ?", value
+	case "sw":
+		comparison, arg = `LIKE ? ESCAPE '\'`, escapeLike(value)+"%"
+	case "co":
+		comparison, arg = `LIKE ? ESCAPE '\'`, "%"+escapeLike(value)+"%"
+	default:
+		return "", nil, fmt.Errorf("unsupported operator: %s", operator)
+	}
+
+	keys := strings.Split(field, ".")
+	column, found := target.fields[keys[0]]
+	switch {
+	case found && column.kind == textColumn && len(keys) == 1:
+		return column.column + " " + comparison, []interface{}{arg}, nil
+	case found && column.kind == numericColumn && len(keys) == 1:
+		return "CAST(" + column.column + " AS TEXT) " + comparison, []interface{}{arg}, nil
+	case found && column.kind == jsonColumn:
+		return d.jsonCondition(column.column, keys[1:], comparison, arg)
+	case !found && target.fallbackJSON != "":
+		return d.jsonCondition(target.fallbackJSON, keys, comparison, arg)
+	default:
+		// unknown fields never match, the same way a document database treats missing fields
+		return "1 = 0", nil, nil
+	}
+}
+
+func (d *Database) jsonCondition(column string, keys []string, comparison string, arg interface{}) (string,
+	[]interface{}, error) {
+
+	condition := "EXISTS (SELECT 1 FROM (" + d.dialect.jsonValues(column) + ") AS matched WHERE matched.value " +
+		comparison + ")"
+	return condition, []interface{}{d.dialect.jsonPath(keys), arg}, nil
+}
+
+// whereClause combines the conditions of the given filters. Invalid filters are skipped unless strict is set.
+func (d *Database) whereClause(filters []string, target filterTarget, strict bool) (string, []interface{}, error) {
+	var conditions []string
+	var args []interface{}
+	for _, f := range filters {
+		condition, conditionArgs, err := d.filterCondition(f, target)
+		if err != nil {
+			if strict {
+				return "", nil, err
+			}
+			continue
+		}
+		conditions = append(conditions, condition)
+		args = append(args, conditionArgs...)
+	}
+	if len(conditions) == 0 {
+		return "", nil, nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args, nil
+}
+
+func escapeLike(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, "%", `\%`)
+	return strings.ReplaceAll(value, "_", `\_`)
+}
@@ -0,0 +1,141 @@
+package sqlstore
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+)
+
+// openTestDatabase opens a migrated SQLite database in a temporary directory
+func openTestDatabase(t *testing.T) *Database {
+	t.Helper()
+	db, err := OpenSQLite(filepath.Join(t.TempDir(), "cds.db"))
+	if err != nil {
+		t.Fatalf("failed to open the database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("failed to migrate the database: %v", err)
+	}
+	return db
+}
+
+func TestFilterConditionRejectsInvalidFilters(t *testing.T) {
+	db := openTestDatabase(t)
+	for _, filter := range []string{"traits.plan", "traits.plan eq", "traits.plan gt 1"} {
+		if _, _, err := db.filterCondition(filter, profileFilterTarget); err == nil {
+			t.Errorf("filter %q was translated, want an error", filter)
+		}
+	}
+	if _, _, err := db.whereClause([]string{"traits.plan gt 1"}, profileFilterTarget, true); err == nil {
+		t.Errorf("invalid filter was skipped in strict mode")
+	}
+	where, _, err := db.whereClause([]string{"traits.plan gt 1"}, profileFilterTarget, false)
+	if err != nil || where != "" {
+		t.Errorf("invalid filter = %q, %v, want it skipped", where, err)
+	}
+}
+
+func TestGetAllProfilesWithFilterTranslatesFilters(t *testing.T) {
+	repo := NewProfileRepository(openTestDatabase(t))
+	profiles := []models.Profile{
+		{
+			ProfileId:     "p1",
+			OriginCountry: "lk",
+			Traits: map[string]interface{}{
+				"plan": "gold", "interests": []interface{}{"music", "travel"}, "vip": true, "code": "50%_off",
+			},
+			IdentityAttributes: map[string]interface{}{"email": "ann@example.com"},
+		},
+		{
+			ProfileId:     "p2",
+			OriginCountry: "us",
+			Traits: map[string]interface{}{
+				"plan": "golden", "interests": []interface{}{"sports"}, "vip": false, "code": "50 off",
+			},
+			IdentityAttributes: map[string]interface{}{"email": "bob@example.org"},
+		},
+	}
+	if err := repo.InsertProfiles(profiles); err != nil {
+		t.Fatalf("failed to insert profiles: %v", err)
+	}
+
+	for _, test := range []struct {
+		name    string
+		filters []string
+		want    []string
+	}{
+		{name: "column equals", filters: []string{"origin_country eq lk"}, want: []string{"p1"}},
+		{name: "json equals", filters: []string{"traits.plan eq gold"}, want: []string{"p1"}},
+		{name: "json starts with", filters: []string{"traits.plan sw gold"}, want: []string{"p1", "p2"}},
+		{name: "json contains", filters: []string{"identity_attributes.email co example.org"}, want: []string{"p2"}},
+		{name: "operator case", filters: []string{"traits.plan EQ golden"}, want: []string{"p2"}},
+		{name: "array element", filters: []string{"traits.interests eq travel"}, want: []string{"p1"}},
+		{name: "boolean", filters: []string{"traits.vip eq true"}, want: []string{"p1"}},
+		{name: "escaped wildcards", filters: []string{"traits.code sw 50%_"}, want: []string{"p1"}},
+		{name: "combined", filters: []string{"traits.plan sw gold", "origin_country eq us"}, want: []string{"p2"}},
+		{name: "unknown field", filters: []string{"nickname eq ann"}, want: nil},
+		{name: "missing path", filters: []string{"traits.nickname eq ann"}, want: nil},
+		{name: "invalid filter skipped", filters: []string{"traits.plan gt 1"}, want: []string{"p1", "p2"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			found, err := repo.GetAllProfilesWithFilter(test.filters)
+			if err != nil {
+				t.Fatalf("failed to filter profiles: %v", err)
+			}
+			var got []string
+			for _, profile := range found {
+				got = append(got, profile.ProfileId)
+			}
+			sort.Strings(got)
+			if strings.Join(got, ",") != strings.Join(test.want, ",") {
+				t.Errorf("profiles matching %v = %v, want %v", test.filters, got, test.want)
+			}
+		})
+	}
+}
+
+func TestFindEventsTranslatesFilters(t *testing.T) {
+	repo := NewEventRepository(openTestDatabase(t))
+	events := []models.Event{
+		{EventId: "e1", ProfileId: "p1", EventType: "track", EventName: "signup", OrgId: "org", EventTimestamp: 100,
+			Properties: map[string]interface{}{"plan": "gold"}},
+		{EventId: "e2", ProfileId: "p1", EventType: "track", EventName: "purchase", OrgId: "org",
+			EventTimestamp: 200, Properties: map[string]interface{}{"amount": 25}},
+		{EventId: "e3", ProfileId: "p2", EventType: "identify", OrgId: "org", EventTimestamp: 300},
+	}
+	if _, err := repo.AddEvents(events); err != nil {
+		t.Fatalf("failed to add events: %v", err)
+	}
+
+	for _, test := range []struct {
+		name          string
+		filters       []string
+		fromTimestamp int64
+		want          []string
+	}{
+		{name: "column", filters: []string{"event_name eq purchase"}, want: []string{"e2"}},
+		{name: "numeric column", filters: []string{"event_timestamp sw 3"}, want: []string{"e3"}},
+		{name: "json property", filters: []string{"properties.plan eq gold"}, want: []string{"e1"}},
+		{name: "json number", filters: []string{"properties.amount eq 25"}, want: []string{"e2"}},
+		{name: "from timestamp", filters: []string{"profile_id eq p1"}, fromTimestamp: 150, want: []string{"e2"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			found, err := repo.FindEvents(test.filters, test.fromTimestamp)
+			if err != nil {
+				t.Fatalf("failed to filter events: %v", err)
+			}
+			var got []string
+			for _, event := range found {
+				got = append(got, event.EventId)
+			}
+			sort.Strings(got)
+			if strings.Join(got, ",") != strings.Join(test.want, ",") {
+				t.Errorf("events matching %v = %v, want %v", test.filters, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+// IdentityIndexRepository keeps the identity index in the `identity_keys` table, one row per key and profile, and its
+// version in the single row of the `identity_index` table
+type IdentityIndexRepository struct {
+	db *Database
+}
+
+// NewIdentityIndexRepository creates a new SQL backed identity index repository
+func NewIdentityIndexRepository(db *Database) *IdentityIndexRepository {
+	return &IdentityIndexRepository{db: db}
+}
+
+func (repo *IdentityIndexRepository) SetIdentityKeys(profileId string, keys []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	return repo.db.withTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, repo.db.rebind("DELETE FROM identity_keys WHERE profile_id = ?"),
+			profileId); err != nil {
+			return err
+		}
+		seen := map[string]bool{}
+		for _, key := range keys {
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			if _, err := tx.ExecContext(ctx, repo.db.rebind("INSERT INTO identity_keys (identity_key, profile_id) "+
+				"VALUES (?, ?)"), key, profileId); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (repo *IdentityIndexRepository) DeleteIdentityKeys(profileId string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	_, err := repo.db.exec(ctx, "DELETE FROM identity_keys WHERE profile_id = ?", profileId)
+	return err
+}
+
+func (repo *IdentityIndexRepository) FindProfileIdsByIdentityKeys(keys []string) ([]string, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	args := make([]interface{}, len(keys))
+	for i, key := range keys {
+		args[i] = key
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(keys)), ", ")
+	rows, err := repo.db.query(ctx, "SELECT DISTINCT profile_id FROM identity_keys WHERE identity_key IN ("+
+		placeholders+") ORDER BY profile_id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var profileIds []string
+	for rows.Next() {
+		var profileId string
+		if err := rows.Scan(&profileId); err != nil {
+			return nil, err
+		}
+		profileIds = append(profileIds, profileId)
+	}
+	return profileIds, rows.Err()
+}
+
+func (repo *IdentityIndexRepository) GetIndexVersion() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	var version string
+	err := repo.db.DB.QueryRowContext(ctx, "SELECT version FROM identity_index WHERE id = 1").Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return version, err
+}
+
+func (repo *IdentityIndexRepository) SetIndexVersion(version string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	_, err := repo.db.exec(ctx, "INSERT INTO identity_index (id, version) VALUES (1, ?) "+
+		"ON CONFLICT (id) DO UPDATE SET version = excluded.version", version)
+	return err
+}
@@ -0,0 +1,50 @@
+package sqlstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wso2/identity-customer-data-service/pkg/locks"
+)
+
+// Lock is a DistributedLock backed by the `locks` table. A lock whose ttl has passed can be taken over, after which
+// only its new owner can release it.
+type Lock struct {
+	db *Database
+}
+
+// NewDistributedLock creates a distributed lock that is shared through the database
+func NewDistributedLock(db *Database) locks.DistributedLock {
+	return &Lock{db: db}
+}
+
+func (l *Lock) Acquire(key string, ttl time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	owner := uuid.New().String()
+	result, err := l.db.exec(ctx, "INSERT INTO locks (lock_key, owner, expires_at) VALUES (?, ?, ?) "+
+		"ON CONFLICT (lock_key) DO UPDATE SET owner = excluded.owner, expires_at = excluded.expires_at "+
+		"WHERE locks.expires_at < ?", key, owner, now.Add(ttl).UnixMilli(), now.UnixMilli())
+	if err != nil {
+		return "", err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return "", err
+	}
+	if affected != 1 {
+		return "", nil
+	}
+	return owner, nil
+}
+
+func (l *Lock) Release(key string, owner string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	_, err := l.db.exec(ctx, "DELETE FROM locks WHERE lock_key = ? AND owner = ?", key, owner)
+	return err
+}
@@ -0,0 +1,39 @@
+package sqlstore
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockExpiresAndKeepsItsOwner(t *testing.T) {
+	lock := NewDistributedLock(openTestDatabase(t))
+
+	first, err := lock.Acquire("key", 20*time.Millisecond)
+	if err != nil || first == "" {
+		t.Fatalf("failed to acquire a free lock: %v", err)
+	}
+	if owner, err := lock.Acquire("key", time.Second); err != nil || owner != "" {
+		t.Fatalf("acquired a lock that is held: %q, %v", owner, err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	second, err := lock.Acquire("key", time.Second)
+	if err != nil || second == "" || second == first {
+		t.Fatalf("failed to take over an expired lock: %q, %v", second, err)
+	}
+
+	// The first owner releasing late leaves the lock to the second one
+	if err := lock.Release("key", first); err != nil {
+		t.Fatalf("failed to release the lock: %v", err)
+	}
+	if owner, _ := lock.Acquire("key", time.Second); owner != "" {
+		t.Fatalf("a lock taken over was released by its previous owner")
+	}
+
+	if err := lock.Release("key", second); err != nil {
+		t.Fatalf("failed to release the lock: %v", err)
+	}
+	if owner, _ := lock.Acquire("key", time.Second); owner == "" {
+		t.Fatalf("failed to acquire a released lock")
+	}
+}
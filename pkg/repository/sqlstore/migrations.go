@@ -0,0 +1,103 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wso2/identity-customer-data-service/pkg/logger"
+)
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+// Migrate applies the schema migrations of the dialect that have not been applied yet. Migrations are
+// the `.sql` files under migrations/<dialect> and are applied in the order of their file names, each one
+// in its own transaction.
+func (d *Database) Migrate() error {
+	ctx := context.Background()
+	if _, err := d.exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    TEXT PRIMARY KEY,
+		applied_at BIGINT NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	dir := path.Join("migrations", d.dialect.name)
+	entries, err := migrationFiles.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read migrations: %w", err)
+	}
+	var versions []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".sql") {
+			versions = append(versions, entry.Name())
+		}
+	}
+	sort.Strings(versions)
+
+	for _, version := range versions {
+		script, err := migrationFiles.ReadFile(path.Join(dir, version))
+		if err != nil {
+			return fmt.Errorf("failed to read migration %s: %w", version, err)
+		}
+		applied, err := d.applyMigration(ctx, version, string(script))
+		if err != nil {
+			return fmt.Errorf("failed to apply migration %s: %w", version, err)
+		}
+		if applied {
+			logger.Info("Applied database migration", "version", version)
+		}
+	}
+	return nil
+}
+
+func (d *Database) applyMigration(ctx context.Context, version, script string) (bool, error) {
+	applied := false
+	err := d.withTx(ctx, func(tx *sql.Tx) error {
+		if d.dialect.migrationLock != "" {
+			if _, err := tx.ExecContext(ctx, d.dialect.migrationLock); err != nil {
+				return err
+			}
+		}
+		var count int
+		if err := tx.QueryRowContext(ctx, d.rebind("SELECT COUNT(*) FROM schema_migrations WHERE version = ?"),
+			version).Scan(&count); err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+		for _, statement := range splitStatements(script) {
+			if _, err := tx.ExecContext(ctx, statement); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.ExecContext(ctx, d.rebind("INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)"),
+			version, time.Now().UTC().Unix()); err != nil {
+			return err
+		}
+		applied = true
+		return nil
+	})
+	return applied, err
+}
+
+// splitStatements splits a migration script into its statements. Statements are separated by semicolons
+// at the end of a line, so migrations must not contain such semicolons inside literals or function bodies.
+func splitStatements(script string) []string {
+	var statements []string
+	for _, chunk := range strings.SplitAfter(script, ";\n") {
+		statement := strings.TrimSpace(chunk)
+		statement = strings.TrimSuffix(statement, ";")
+		if statement != "" {
+			statements = append(statements, statement)
+		}
+	}
+	return statements
+}
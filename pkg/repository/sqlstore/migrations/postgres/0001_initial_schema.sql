@@ -0,0 +1,63 @@
+CREATE TABLE IF NOT EXISTS profiles (
+    profile_id          TEXT PRIMARY KEY,
+    origin_country      TEXT NOT NULL DEFAULT '',
+    identity_attributes JSONB,
+    traits              JSONB,
+    application_data    JSONB,
+    profile_hierarchy   JSONB,
+    parent_profile_id   TEXT NOT NULL DEFAULT '',
+    is_parent           BOOLEAN NOT NULL DEFAULT FALSE,
+    list_profile        BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE INDEX IF NOT EXISTS idx_profiles_is_parent ON profiles (is_parent);
+
+CREATE INDEX IF NOT EXISTS idx_profiles_list_profile ON profiles (list_profile);
+
+CREATE INDEX IF NOT EXISTS idx_profiles_identity_attributes ON profiles USING GIN (identity_attributes);
+
+CREATE INDEX IF NOT EXISTS idx_profiles_traits ON profiles USING GIN (traits);
+
+CREATE TABLE IF NOT EXISTS events (
+    id              BIGSERIAL PRIMARY KEY,
+    event_id        TEXT NOT NULL,
+    profile_id      TEXT NOT NULL,
+    event_type      TEXT NOT NULL DEFAULT '',
+    event_name      TEXT NOT NULL DEFAULT '',
+    application_id  TEXT NOT NULL DEFAULT '',
+    org_id          TEXT NOT NULL DEFAULT '',
+    event_timestamp BIGINT NOT NULL DEFAULT 0,
+    properties      JSONB,
+    context         JSONB
+);
+
+CREATE INDEX IF NOT EXISTS idx_events_profile ON events (profile_id, event_type, event_name, event_timestamp);
+
+CREATE INDEX IF NOT EXISTS idx_events_event_id ON events (event_id);
+
+CREATE TABLE IF NOT EXISTS consents (
+    perma_id             TEXT NOT NULL,
+    app_id               TEXT NOT NULL,
+    consented_to_collect BOOLEAN NOT NULL DEFAULT FALSE,
+    consented_to_share   BOOLEAN NOT NULL DEFAULT FALSE,
+    PRIMARY KEY (perma_id, app_id)
+);
+
+CREATE TABLE IF NOT EXISTS enrichment_rules (
+    rule_id       TEXT PRIMARY KEY,
+    property_name TEXT NOT NULL,
+    definition    JSONB NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS unification_rules (
+    rule_id    TEXT PRIMARY KEY,
+    property   TEXT NOT NULL,
+    priority   INTEGER NOT NULL DEFAULT 0,
+    definition JSONB NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS locks (
+    lock_key   TEXT PRIMARY KEY,
+    owner      TEXT NOT NULL DEFAULT '',
+    expires_at BIGINT NOT NULL
+);
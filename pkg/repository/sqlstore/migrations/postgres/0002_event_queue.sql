@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS event_queue (
+    queue_id         TEXT PRIMARY KEY,
+    profile_id       TEXT NOT NULL DEFAULT '',
+    event            JSONB NOT NULL,
+    attempts         INTEGER NOT NULL DEFAULT 0,
+    enqueued_at      BIGINT NOT NULL,
+    available_at     BIGINT NOT NULL DEFAULT 0,
+    claimed_by       TEXT NOT NULL DEFAULT '',
+    lease_expires_at BIGINT NOT NULL DEFAULT 0,
+    last_error       TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_queue_order ON event_queue (enqueued_at);
+
+CREATE INDEX IF NOT EXISTS idx_event_queue_profile ON event_queue (profile_id, enqueued_at);
+
+CREATE TABLE IF NOT EXISTS dead_letter_events (
+    queue_id    TEXT PRIMARY KEY,
+    event       JSONB NOT NULL,
+    attempts    INTEGER NOT NULL DEFAULT 0,
+    enqueued_at BIGINT NOT NULL,
+    last_error  TEXT NOT NULL DEFAULT '',
+    failed_at   BIGINT NOT NULL
+);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS profile_unmerges (
+    unmerge_id        TEXT PRIMARY KEY,
+    profile_id        TEXT NOT NULL,
+    master_profile_id TEXT NOT NULL,
+    unmerged_at       BIGINT NOT NULL,
+    definition        JSONB NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_profile_unmerges_profile ON profile_unmerges (profile_id);
+
+CREATE INDEX IF NOT EXISTS idx_profile_unmerges_master ON profile_unmerges (master_profile_id);
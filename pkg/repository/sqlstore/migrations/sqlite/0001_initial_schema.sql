@@ -0,0 +1,59 @@
+CREATE TABLE IF NOT EXISTS profiles (
+    profile_id          TEXT PRIMARY KEY,
+    origin_country      TEXT NOT NULL DEFAULT '',
+    identity_attributes TEXT,
+    traits              TEXT,
+    application_data    TEXT,
+    profile_hierarchy   TEXT,
+    parent_profile_id   TEXT NOT NULL DEFAULT '',
+    is_parent           INTEGER NOT NULL DEFAULT 0,
+    list_profile        INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_profiles_is_parent ON profiles (is_parent);
+
+CREATE INDEX IF NOT EXISTS idx_profiles_list_profile ON profiles (list_profile);
+
+CREATE TABLE IF NOT EXISTS events (
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_id        TEXT NOT NULL,
+    profile_id      TEXT NOT NULL,
+    event_type      TEXT NOT NULL DEFAULT '',
+    event_name      TEXT NOT NULL DEFAULT '',
+    application_id  TEXT NOT NULL DEFAULT '',
+    org_id          TEXT NOT NULL DEFAULT '',
+    event_timestamp INTEGER NOT NULL DEFAULT 0,
+    properties      TEXT,
+    context         TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_events_profile ON events (profile_id, event_type, event_name, event_timestamp);
+
+CREATE INDEX IF NOT EXISTS idx_events_event_id ON events (event_id);
+
+CREATE TABLE IF NOT EXISTS consents (
+    perma_id             TEXT NOT NULL,
+    app_id               TEXT NOT NULL,
+    consented_to_collect INTEGER NOT NULL DEFAULT 0,
+    consented_to_share   INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (perma_id, app_id)
+);
+
+CREATE TABLE IF NOT EXISTS enrichment_rules (
+    rule_id       TEXT PRIMARY KEY,
+    property_name TEXT NOT NULL,
+    definition    TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS unification_rules (
+    rule_id    TEXT PRIMARY KEY,
+    property   TEXT NOT NULL,
+    priority   INTEGER NOT NULL DEFAULT 0,
+    definition TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS locks (
+    lock_key   TEXT PRIMARY KEY,
+    owner      TEXT NOT NULL DEFAULT '',
+    expires_at INTEGER NOT NULL
+);
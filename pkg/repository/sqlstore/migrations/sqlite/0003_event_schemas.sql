@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS event_schemas (
+    event_schema_id TEXT PRIMARY KEY,
+    event_type      TEXT NOT NULL,
+    event_name      TEXT NOT NULL,
+    definition      TEXT NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_event_schemas_event ON event_schemas (event_type, event_name);
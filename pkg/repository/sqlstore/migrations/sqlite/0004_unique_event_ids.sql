@@ -0,0 +1,4 @@
+CREATE UNIQUE INDEX IF NOT EXISTS idx_events_org_event_id ON events (org_id, event_id) WHERE event_id <> '';
+
+-- Events stored before this migration were queued when they were stored
+ALTER TABLE events ADD COLUMN queued INTEGER NOT NULL DEFAULT 1;
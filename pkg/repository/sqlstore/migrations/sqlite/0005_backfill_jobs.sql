@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS backfill_jobs (
+    job_id     TEXT PRIMARY KEY,
+    rule_id    TEXT NOT NULL,
+    status     TEXT NOT NULL,
+    created_at INTEGER NOT NULL,
+    definition TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_backfill_jobs_rule ON backfill_jobs (rule_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_backfill_jobs_status ON backfill_jobs (status);
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS rule_versions (
+    rule_type  TEXT NOT NULL,
+    rule_id    TEXT NOT NULL,
+    version    INTEGER NOT NULL,
+    created_at INTEGER NOT NULL,
+    definition TEXT NOT NULL,
+    PRIMARY KEY (rule_type, rule_id, version)
+);
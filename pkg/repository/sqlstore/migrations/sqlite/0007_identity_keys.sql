@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS identity_keys (
+    identity_key TEXT NOT NULL,
+    profile_id   TEXT NOT NULL,
+    PRIMARY KEY (identity_key, profile_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_identity_keys_profile ON identity_keys (profile_id);
+
+-- Version of the unification rules the identity keys were last built for
+CREATE TABLE IF NOT EXISTS identity_index (
+    id      INTEGER PRIMARY KEY,
+    version TEXT NOT NULL
+);
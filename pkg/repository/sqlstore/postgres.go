@@ -0,0 +1,53 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	_ "github.com/jackc/pgx/v5/stdlib" // registers the "pgx" database/sql driver
+)
+
+// OpenPostgres connects to a PostgreSQL database using the given connection string
+func OpenPostgres(dsn string, maxOpenConns int) (*Database, error) {
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if maxOpenConns > 0 {
+		db.SetMaxOpenConns(maxOpenConns)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to connect to postgres: %w", err)
+	}
+	return &Database{DB: db, dialect: postgresDialect}, nil
+}
+
+var postgresDialect = dialect{
+	name:                 "postgres",
+	numberedPlaceholders: true,
+	lockRow:              " FOR UPDATE",
+	skipLocked:           " FOR UPDATE SKIP LOCKED",
+	jsonParam:            "CAST(? AS JSONB)",
+	migrationLock:        "SELECT pg_advisory_xact_lock(7283001)",
+	jsonValues: func(column string) string {
+		// jsonb_path_query runs in lax mode, which unwraps arrays met along the path like a document database does
+		return "SELECT elem.value #>> '{}' AS value FROM jsonb_path_query(" + column +
+			", CAST(? AS JSONPATH)) AS elem(value)"
+	},
+	jsonPath: func(keys []string) string {
+		var sb strings.Builder
+		sb.WriteString("$")
+		for _, key := range keys {
+			key = strings.ReplaceAll(key, `\`, `\\`)
+			sb.WriteString(`."` + strings.ReplaceAll(key, `"`, `\"`) + `"`)
+		}
+		sb.WriteString("[*]")
+		return sb.String()
+	},
+}
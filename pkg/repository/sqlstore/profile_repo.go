@@ -0,0 +1,343 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+	repositories "github.com/wso2/identity-customer-data-service/pkg/repository"
+)
+
+const profileColumns = "profile_id, origin_country, identity_attributes, traits, application_data, profile_hierarchy"
+
+var errProfileNotFound = errors.New("profile not found")
+
+// storedApplicationData is the stored form of models.ApplicationData, whose JSON marshaller flattens the
+// app specific data into the top level object and therefore cannot be decoded back.
+type storedApplicationData struct {
+	AppId           string                 `json:"application_id"`
+	Devices         []models.Devices       `json:"devices,omitempty"`
+	AppSpecificData map[string]interface{} `json:"app_specific_data,omitempty"`
+}
+
+// ProfileRepository keeps profiles in the `profiles` table
+type ProfileRepository struct {
+	db *Database
+}
+
+// NewProfileRepository creates a new SQL backed profile repository
+func NewProfileRepository(db *Database) *ProfileRepository {
+	return &ProfileRepository{db: db}
+}
+
+// InsertProfile saves a profile if no profile exists with the same id
+func (repo *ProfileRepository) InsertProfile(profile models.Profile) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	columns, values, err := profileRow(profile)
+	if err != nil {
+		return err
+	}
+	query := "INSERT INTO profiles (" + columns + ") VALUES (" + repo.profilePlaceholders() + ") " +
+		"ON CONFLICT (profile_id) DO NOTHING"
+	if _, err := repo.db.exec(ctx, query, values...); err != nil {
+		return fmt.Errorf("failed to insert profile: %w", err)
+	}
+	return nil
+}
+
+// InsertProfiles saves the profiles that do not exist yet in a single transaction
+func (repo *ProfileRepository) InsertProfiles(profiles []models.Profile) error {
+	if len(profiles) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	return repo.db.withTx(ctx, func(tx *sql.Tx) error {
+		var stmt *sql.Stmt
+		for _, profile := range profiles {
+			columns, values, err := profileRow(profile)
+			if err != nil {
+				return err
+			}
+			if stmt == nil {
+				stmt, err = tx.PrepareContext(ctx, repo.db.rebind("INSERT INTO profiles ("+columns+") VALUES ("+
+					repo.profilePlaceholders()+") ON CONFLICT (profile_id) DO NOTHING"))
+				if err != nil {
+					return err
+				}
+				defer stmt.Close()
+			}
+			if _, err := stmt.ExecContext(ctx, values...); err != nil {
+				return fmt.Errorf("failed to insert profile %s: %w", profile.ProfileId, err)
+			}
+		}
+		return nil
+	})
+}
+
+// FindProfileByID retrieves a profile by `profile_id`
+func (repo *ProfileRepository) FindProfileByID(profileId string) (*models.Profile, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	row := repo.db.DB.QueryRowContext(ctx, repo.db.rebind("SELECT "+profileColumns+" FROM profiles WHERE profile_id = ?"),
+		profileId)
+	profile, err := scanProfile(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil // Profile not found is not an error
+	}
+	if err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// DeleteProfile removes a profile using `profile_id`
+func (repo *ProfileRepository) DeleteProfile(profileId string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	result, err := repo.db.exec(ctx, "DELETE FROM profiles WHERE profile_id = ?", profileId)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return fmt.Errorf("profile %s not found", profileId)
+	}
+	return nil
+}
+
+func (repo *ProfileRepository) DetachChildFromParent(parentID, childID string) error {
+	err := repo.updateProfile(parentID, false, func(profile *models.Profile) {
+		repositories.RemoveChildProfile(profile, childID)
+	})
+	if errors.Is(err, errProfileNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (repo *ProfileRepository) AddOrUpdateAppContext(profileId string, newAppCtx models.ApplicationData) error {
+	err := repo.updateProfile(profileId, false, func(profile *models.Profile) {
+		repositories.ApplyAppContext(profile, newAppCtx)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update app context: %w", err)
+	}
+	return nil
+}
+
+// AddOrUpdateTraitsData replaces (PUT) the traits inside Profile
+func (repo *ProfileRepository) AddOrUpdateTraitsData(profileId string, traits map[string]interface{}) error {
+	return repo.updateProfile(profileId, true, func(profile *models.Profile) {
+		profile.Traits = traits
+	})
+}
+
+// UpsertIdentityData sets the given identity attributes of a Profile
+func (repo *ProfileRepository) UpsertIdentityData(profileId string, identityData map[string]interface{}) error {
+	return repo.updateProfile(profileId, true, func(profile *models.Profile) {
+		if profile.IdentityAttributes == nil {
+			profile.IdentityAttributes = map[string]interface{}{}
+		}
+		for k, v := range identityData {
+			profile.IdentityAttributes[k] = v
+		}
+	})
+}
+
+// GetAllProfiles retrieves all listable profiles
+func (repo *ProfileRepository) GetAllProfiles() ([]models.Profile, error) {
+	return repo.findProfiles("SELECT "+profileColumns+" FROM profiles WHERE list_profile = ?", true)
+}
+
+func (repo *ProfileRepository) GetAllProfilesWithFilter(filters []string) ([]models.Profile, error) {
+	where, args, err := repo.db.whereClause(filters, profileFilterTarget, false)
+	if err != nil {
+		return nil, err
+	}
+	return repo.findProfiles("SELECT "+profileColumns+" FROM profiles"+where, args...)
+}
+
+// GetAllMasterProfilesExceptForCurrent retrieves all master profiles excluding the current profile
+func (repo *ProfileRepository) GetAllMasterProfilesExceptForCurrent(currentProfile models.Profile) ([]models.Profile, error) {
+	return repo.findProfiles("SELECT "+profileColumns+" FROM profiles WHERE is_parent = ? AND profile_id <> ?",
+		true, currentProfile.ProfileId)
+}
+
+func (repo *ProfileRepository) UpdateParent(master models.Profile, newProfile models.Profile) error {
+	err := repo.updateProfile(newProfile.ProfileId, false, func(profile *models.Profile) {
+		repositories.ApplyParent(profile, master.ProfileId)
+	})
+	if errors.Is(err, errProfileNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (repo *ProfileRepository) AddChildProfile(parentProfile models.Profile, child models.ChildProfile) error {
+	err := repo.updateProfile(parentProfile.ProfileId, false, func(profile *models.Profile) {
+		repositories.ApplyChildProfile(profile, child)
+	})
+	if errors.Is(err, errProfileNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (repo *ProfileRepository) UpsertIdentityAttribute(profileId string, updates map[string]interface{}) error {
+	return repo.updateProfile(profileId, false, func(profile *models.Profile) {
+		repositories.ApplyIdentityAttributeUpdates(profile, updates)
+	})
+}
+
+func (repo *ProfileRepository) UpsertTrait(profileId string, updates map[string]interface{}) error {
+	return repo.updateProfile(profileId, false, func(profile *models.Profile) {
+		repositories.ApplyTraitUpdates(profile, updates)
+	})
+}
+
+func (repo *ProfileRepository) UpsertAppDatum(profileId string, appId string, updates map[string]interface{}) error {
+	return repo.updateProfile(profileId, false, func(profile *models.Profile) {
+		repositories.ApplyAppDatumUpdates(profile, appId, updates)
+	})
+}
+
+// ReplaceProfile replaces the row of the profile
+func (repo *ProfileRepository) ReplaceProfile(replacement models.Profile) error {
+	return repo.updateProfile(replacement.ProfileId, false, func(profile *models.Profile) {
+		*profile = replacement
+	})
+}
+
+// updateProfile loads the profile in a transaction, applies `mutate` and writes it back. When the profile does
+// not exist, an empty profile is created if `createIfMissing` is set and errProfileNotFound is returned otherwise.
+func (repo *ProfileRepository) updateProfile(profileId string, createIfMissing bool, mutate func(profile *models.Profile)) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	return repo.db.withTx(ctx, func(tx *sql.Tx) error {
+		row := tx.QueryRowContext(ctx, repo.db.rebind("SELECT "+profileColumns+" FROM profiles WHERE profile_id = ?"+
+			repo.db.dialect.lockRow), profileId)
+		profile, err := scanProfile(row)
+		switch {
+		case errors.Is(err, sql.ErrNoRows) && createIfMissing:
+			profile = &models.Profile{ProfileId: profileId}
+		case errors.Is(err, sql.ErrNoRows):
+			return fmt.Errorf("failed to fetch profile %s: %w", profileId, errProfileNotFound)
+		case err != nil:
+			return err
+		}
+
+		mutate(profile)
+
+		columns, values, err := profileRow(*profile)
+		if err != nil {
+			return err
+		}
+		query := "INSERT INTO profiles (" + columns + ") VALUES (" + repo.profilePlaceholders() + ") " +
+			"ON CONFLICT (profile_id) DO UPDATE SET origin_country = excluded.origin_country, " +
+			"identity_attributes = excluded.identity_attributes, traits = excluded.traits, " +
+			"application_data = excluded.application_data, profile_hierarchy = excluded.profile_hierarchy, " +
+			"parent_profile_id = excluded.parent_profile_id, is_parent = excluded.is_parent, " +
+			"list_profile = excluded.list_profile"
+		_, err = tx.ExecContext(ctx, repo.db.rebind(query), values...)
+		return err
+	})
+}
+
+func (repo *ProfileRepository) findProfiles(query string, args ...interface{}) ([]models.Profile, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	rows, err := repo.db.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var profiles []models.Profile
+	for rows.Next() {
+		profile, err := scanProfile(rows)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, *profile)
+	}
+	return profiles, rows.Err()
+}
+
+func (repo *ProfileRepository) profilePlaceholders() string {
+	jsonParam := repo.db.dialect.jsonParam
+	return "?, ?, " + jsonParam + ", " + jsonParam + ", " + jsonParam + ", " + jsonParam + ", ?, ?, ?"
+}
+
+// profileRow returns the column list and the values used to store the profile
+func profileRow(profile models.Profile) (string, []interface{}, error) {
+	apps := make([]storedApplicationData, 0, len(profile.ApplicationData))
+	for _, app := range profile.ApplicationData {
+		apps = append(apps, storedApplicationData{
+			AppId:           app.AppId,
+			Devices:         app.Devices,
+			AppSpecificData: app.AppSpecificData,
+		})
+	}
+
+	var jsonValues []interface{}
+	for _, v := range []interface{}{profile.IdentityAttributes, profile.Traits, apps, profile.ProfileHierarchy} {
+		data, err := toJSON(v)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to encode profile %s: %w", profile.ProfileId, err)
+		}
+		jsonValues = append(jsonValues, data)
+	}
+
+	hierarchy := models.ProfileHierarchy{}
+	if profile.ProfileHierarchy != nil {
+		hierarchy = *profile.ProfileHierarchy
+	}
+	values := append([]interface{}{profile.ProfileId, profile.OriginCountry}, jsonValues...)
+	values = append(values, hierarchy.ParentProfileID, hierarchy.IsParent, hierarchy.ListProfile)
+	return profileColumns + ", parent_profile_id, is_parent, list_profile", values, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanProfile(row rowScanner) (*models.Profile, error) {
+	var profile models.Profile
+	var identityAttributes, traits, applicationData, hierarchy sql.NullString
+	if err := row.Scan(&profile.ProfileId, &profile.OriginCountry, &identityAttributes, &traits, &applicationData,
+		&hierarchy); err != nil {
+		return nil, err
+	}
+
+	var apps []storedApplicationData
+	for _, column := range []struct {
+		data   sql.NullString
+		target interface{}
+	}{
+		{identityAttributes, &profile.IdentityAttributes},
+		{traits, &profile.Traits},
+		{applicationData, &apps},
+		{hierarchy, &profile.ProfileHierarchy},
+	} {
+		if err := fromJSON(column.data, column.target); err != nil {
+			return nil, fmt.Errorf("failed to decode profile %s: %w", profile.ProfileId, err)
+		}
+	}
+	for _, app := range apps {
+		profile.ApplicationData = append(profile.ApplicationData, models.ApplicationData{
+			AppId:           app.AppId,
+			Devices:         app.Devices,
+			AppSpecificData: app.AppSpecificData,
+		})
+	}
+	return &profile, nil
+}
@@ -0,0 +1,102 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+	repositories "github.com/wso2/identity-customer-data-service/pkg/repository"
+)
+
+// ProfileSchemaRepository keeps profile enrichment rules in the `enrichment_rules` table. The whole rule is
+// stored as a JSON document so that rules can be filtered on any of their fields.
+type ProfileSchemaRepository struct {
+	db *Database
+}
+
+// NewProfileSchemaRepository creates a new SQL backed enrichment rule repository
+func NewProfileSchemaRepository(db *Database) *ProfileSchemaRepository {
+	return &ProfileSchemaRepository{db: db}
+}
+
+func (repo *ProfileSchemaRepository) UpsertEnrichmentRule(rule models.ProfileEnrichmentRule) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	definition, err := toJSON(rule)
+	if err != nil {
+		return fmt.Errorf("failed to encode enrichment rule %s: %w", rule.RuleId, err)
+	}
+	_, err = repo.db.exec(ctx, "INSERT INTO enrichment_rules (rule_id, property_name, definition) VALUES (?, ?, "+
+		repo.db.dialect.jsonParam+") ON CONFLICT (rule_id) DO UPDATE SET property_name = excluded.property_name, "+
+		"definition = excluded.definition", rule.RuleId, rule.PropertyName, definition)
+	return err
+}
+
+func (repo *ProfileSchemaRepository) GetProfileEnrichmentRules() ([]models.ProfileEnrichmentRule, error) {
+	return repo.findRules("")
+}
+
+func (repo *ProfileSchemaRepository) GetEnrichmentRulesByFilter(filters []string) ([]models.ProfileEnrichmentRule, error) {
+	where, args, err := repo.db.whereClause(filters, enrichmentRuleFilterTarget, true)
+	if err != nil {
+		return nil, err
+	}
+	return repo.findRules(where, args...)
+}
+
+func (repo *ProfileSchemaRepository) GetSchemaRule(ruleId string) (models.ProfileEnrichmentRule, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	var definition sql.NullString
+	err := repo.db.DB.QueryRowContext(ctx, repo.db.rebind("SELECT definition FROM enrichment_rules WHERE rule_id = ?"),
+		ruleId).Scan(&definition)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.ProfileEnrichmentRule{}, fmt.Errorf("%w: %s", repositories.ErrEnrichmentRuleNotFound, ruleId)
+	}
+	if err != nil {
+		return models.ProfileEnrichmentRule{}, err
+	}
+
+	var rule models.ProfileEnrichmentRule
+	if err := fromJSON(definition, &rule); err != nil {
+		return models.ProfileEnrichmentRule{}, fmt.Errorf("failed to decode enrichment rule %s: %w", ruleId, err)
+	}
+	return rule, nil
+}
+
+func (repo *ProfileSchemaRepository) DeleteSchemaRule(ruleId string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	_, err := repo.db.exec(ctx, "DELETE FROM enrichment_rules WHERE rule_id = ?", ruleId)
+	return err
+}
+
+func (repo *ProfileSchemaRepository) findRules(where string, args ...interface{}) ([]models.ProfileEnrichmentRule, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	rows, err := repo.db.query(ctx, "SELECT definition FROM enrichment_rules"+where+" ORDER BY rule_id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []models.ProfileEnrichmentRule
+	for rows.Next() {
+		var definition sql.NullString
+		if err := rows.Scan(&definition); err != nil {
+			return nil, err
+		}
+		var rule models.ProfileEnrichmentRule
+		if err := fromJSON(definition, &rule); err != nil {
+			return nil, fmt.Errorf("failed to decode enrichment rule: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
@@ -0,0 +1,70 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+)
+
+// ProfileUnmergeRepository keeps the records of profiles split from their master profiles in the
+// `profile_unmerges` table
+type ProfileUnmergeRepository struct {
+	db *Database
+}
+
+// NewProfileUnmergeRepository creates a new SQL backed profile unmerge repository
+func NewProfileUnmergeRepository(db *Database) *ProfileUnmergeRepository {
+	return &ProfileUnmergeRepository{db: db}
+}
+
+func (repo *ProfileUnmergeRepository) AddProfileUnmerge(unmerge models.ProfileUnmerge) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	definition, err := toJSON(unmerge)
+	if err != nil {
+		return fmt.Errorf("failed to encode unmerge %s: %w", unmerge.UnmergeId, err)
+	}
+	_, err = repo.db.exec(ctx, "INSERT INTO profile_unmerges (unmerge_id, profile_id, master_profile_id, "+
+		"unmerged_at, definition) VALUES (?, ?, ?, ?, "+repo.db.dialect.jsonParam+")", unmerge.UnmergeId,
+		unmerge.ProfileId, unmerge.MasterProfileId, unmerge.UnmergedAt, definition)
+	return err
+}
+
+func (repo *ProfileUnmergeRepository) GetProfileUnmerges(profileIds []string) ([]models.ProfileUnmerge, error) {
+	if len(profileIds) == 0 {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	args := make([]interface{}, 0, 2*len(profileIds))
+	for _, profileId := range profileIds {
+		args = append(args, profileId)
+	}
+	args = append(args, args...)
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(profileIds)), ", ")
+	rows, err := repo.db.query(ctx, "SELECT definition FROM profile_unmerges WHERE profile_id IN ("+placeholders+
+		") OR master_profile_id IN ("+placeholders+") ORDER BY unmerged_at DESC", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var unmerges []models.ProfileUnmerge
+	for rows.Next() {
+		var definition sql.NullString
+		if err := rows.Scan(&definition); err != nil {
+			return nil, err
+		}
+		var unmerge models.ProfileUnmerge
+		if err := fromJSON(definition, &unmerge); err != nil {
+			return nil, fmt.Errorf("failed to decode profile unmerge: %w", err)
+		}
+		unmerges = append(unmerges, unmerge)
+	}
+	return unmerges, rows.Err()
+}
@@ -0,0 +1,105 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+	repositories "github.com/wso2/identity-customer-data-service/pkg/repository"
+)
+
+// RuleVersionRepository keeps the version history of rules in the `rule_versions` table, keyed by the rule type,
+// rule id and version number
+type RuleVersionRepository struct {
+	db *Database
+}
+
+// NewRuleVersionRepository creates a new SQL backed rule version repository
+func NewRuleVersionRepository(db *Database) *RuleVersionRepository {
+	return &RuleVersionRepository{db: db}
+}
+
+func (repo *RuleVersionRepository) AddRuleVersion(version models.RuleVersion) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	definition, err := toJSON(version)
+	if err != nil {
+		return fmt.Errorf("failed to encode version %d of rule %s: %w", version.Version, version.RuleId, err)
+	}
+	result, err := repo.db.exec(ctx, "INSERT INTO rule_versions (rule_type, rule_id, version, created_at, "+
+		"definition) VALUES (?, ?, ?, ?, "+repo.db.dialect.jsonParam+") ON CONFLICT DO NOTHING", version.RuleType,
+		version.RuleId, version.Version, version.CreatedAt, definition)
+	if err != nil {
+		return err
+	}
+	if inserted, err := result.RowsAffected(); err != nil {
+		return err
+	} else if inserted == 0 {
+		return fmt.Errorf("%w: version %d of %s rule %s", repositories.ErrRuleVersionExists, version.Version,
+			version.RuleType, version.RuleId)
+	}
+	return nil
+}
+
+func (repo *RuleVersionRepository) GetRuleVersions(ruleType, ruleId string) ([]models.RuleVersion, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	rows, err := repo.db.query(ctx, "SELECT definition FROM rule_versions WHERE rule_type = ? AND rule_id = ? "+
+		"ORDER BY version DESC", ruleType, ruleId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []models.RuleVersion
+	for rows.Next() {
+		version, err := scanRuleVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+	return versions, rows.Err()
+}
+
+func (repo *RuleVersionRepository) GetRuleVersion(ruleType, ruleId string, version int) (*models.RuleVersion, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	row := repo.db.DB.QueryRowContext(ctx, repo.db.rebind("SELECT definition FROM rule_versions "+
+		"WHERE rule_type = ? AND rule_id = ? AND version = ?"), ruleType, ruleId, version)
+	ruleVersion, err := scanRuleVersion(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ruleVersion, nil
+}
+
+func (repo *RuleVersionRepository) GetLatestRuleVersion(ruleType, ruleId string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	var latest int
+	err := repo.db.DB.QueryRowContext(ctx, repo.db.rebind("SELECT COALESCE(MAX(version), 0) FROM rule_versions "+
+		"WHERE rule_type = ? AND rule_id = ?"), ruleType, ruleId).Scan(&latest)
+	return latest, err
+}
+
+func scanRuleVersion(row rowScanner) (models.RuleVersion, error) {
+	var definition sql.NullString
+	if err := row.Scan(&definition); err != nil {
+		return models.RuleVersion{}, err
+	}
+	var version models.RuleVersion
+	if err := fromJSON(definition, &version); err != nil {
+		return models.RuleVersion{}, fmt.Errorf("failed to decode rule version: %w", err)
+	}
+	return version, nil
+}
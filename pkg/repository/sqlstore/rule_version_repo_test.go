@@ -0,0 +1,37 @@
+package sqlstore
+
+import (
+	"testing"
+
+	"github.com/wso2/identity-customer-data-service/pkg/constants"
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+)
+
+func TestGetLatestRuleVersion(t *testing.T) {
+	repo := NewRuleVersionRepository(openTestDatabase(t))
+	for _, version := range []models.RuleVersion{
+		{RuleType: constants.EnrichmentRuleType, RuleId: "rule-plan", Version: 1},
+		{RuleType: constants.EnrichmentRuleType, RuleId: "rule-plan", Version: 2},
+		{RuleType: constants.EnrichmentRuleType, RuleId: "rule-tier", Version: 7},
+		{RuleType: constants.UnificationRuleType, RuleId: "rule-plan", Version: 5},
+	} {
+		if err := repo.AddRuleVersion(version); err != nil {
+			t.Fatalf("failed to add rule version: %v", err)
+		}
+	}
+
+	for _, test := range []struct {
+		ruleType string
+		ruleId   string
+		want     int
+	}{
+		{ruleType: constants.EnrichmentRuleType, ruleId: "rule-plan", want: 2},
+		{ruleType: constants.UnificationRuleType, ruleId: "rule-plan", want: 5},
+		{ruleType: constants.UnificationRuleType, ruleId: "rule-tier"},
+	} {
+		if latest, err := repo.GetLatestRuleVersion(test.ruleType, test.ruleId); err != nil || latest != test.want {
+			t.Errorf("latest version of %s rule %s = (%d, %v), want %d", test.ruleType, test.ruleId, latest, err,
+				test.want)
+		}
+	}
+}
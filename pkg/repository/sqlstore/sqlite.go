@@ -0,0 +1,60 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	_ "modernc.org/sqlite" // registers the "sqlite" database/sql driver
+)
+
+// OpenSQLite opens the SQLite database at the given file path, creating it if it does not exist
+func OpenSQLite(path string) (*Database, error) {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create sqlite directory: %w", err)
+		}
+	}
+
+	// Writers take the database lock when the transaction begins, so that concurrent read-modify-write
+	// transactions wait for each other instead of failing when upgrading their lock.
+	params := url.Values{}
+	params.Add("_txlock", "immediate")
+	params.Add("_pragma", "busy_timeout(10000)")
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Add("_pragma", "case_sensitive_like(1)")
+	db, err := sql.Open("sqlite", "file:"+path+"?"+params.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+	return &Database{DB: db, dialect: sqliteDialect}, nil
+}
+
+var sqliteDialect = dialect{
+	name:      "sqlite",
+	jsonParam: "json(?)",
+	jsonValues: func(column string) string {
+		// booleans are reported as integers by json_each, so they are converted back to their JSON text
+		return "SELECT CASE elem.type WHEN 'true' THEN 'true' WHEN 'false' THEN 'false' " +
+			"ELSE CAST(elem.value AS TEXT) END AS value FROM json_each(" + column + ", ?) AS elem"
+	},
+	jsonPath: func(keys []string) string {
+		var sb strings.Builder
+		sb.WriteString("$")
+		for _, key := range keys {
+			sb.WriteString(`."` + key + `"`)
+		}
+		return sb.String()
+	},
+}
@@ -0,0 +1,22 @@
+package sqlstore
+
+import (
+	repositories "github.com/wso2/identity-customer-data-service/pkg/repository"
+)
+
+// NewStores builds the set of stores backed by the given database
+func NewStores(db *Database) repositories.Stores {
+	return repositories.Stores{
+		Profiles:         NewProfileRepository(db),
+		Events:           NewEventRepository(db),
+		Consents:         NewConsentRepository(db),
+		EnrichmentRules:  NewProfileSchemaRepository(db),
+		UnificationRules: NewUnificationRuleRepository(db),
+		EventSchemas:     NewEventSchemaRepository(db),
+		EventQueue:       NewEventQueueRepository(db),
+		BackfillJobs:     NewBackfillJobRepository(db),
+		RuleVersions:     NewRuleVersionRepository(db),
+		IdentityIndex:    NewIdentityIndexRepository(db),
+		ProfileUnmerges:  NewProfileUnmergeRepository(db),
+	}
+}
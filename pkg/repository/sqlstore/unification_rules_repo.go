@@ -0,0 +1,165 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	stdErrors "errors"
+	"time"
+
+	"github.com/wso2/identity-customer-data-service/pkg/errors"
+	"github.com/wso2/identity-customer-data-service/pkg/logger"
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+)
+
+// UnificationRuleRepository keeps unification rules in the `unification_rules` table
+type UnificationRuleRepository struct {
+	db *Database
+}
+
+// NewUnificationRuleRepository creates a new SQL backed unification rule repository
+func NewUnificationRuleRepository(db *Database) *UnificationRuleRepository {
+	return &UnificationRuleRepository{db: db}
+}
+
+// AddUnificationRule Inserts a new unification rule
+func (repo *UnificationRuleRepository) AddUnificationRule(rule models.UnificationRule) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	definition, err := toJSON(rule)
+	if err != nil {
+		return errors.NewServerError(errors.ErrWhileCreatingUnificationRules, err)
+	}
+	_, err = repo.db.exec(ctx, "INSERT INTO unification_rules (rule_id, property, priority, definition) VALUES (?, ?, ?, "+
+		repo.db.dialect.jsonParam+")", rule.RuleId, rule.Property, rule.Priority, definition)
+	if err != nil {
+		return errors.NewServerError(errors.ErrWhileCreatingUnificationRules, err)
+	}
+
+	logger.Info("Unification rule created successfully: " + rule.RuleName)
+	return nil
+}
+
+// GetUnificationRules  Retrieves all unification rules
+func (repo *UnificationRuleRepository) GetUnificationRules() ([]models.UnificationRule, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	rows, err := repo.db.query(ctx, "SELECT definition FROM unification_rules ORDER BY priority, rule_id")
+	if err != nil {
+		return nil, errors.NewServerError(errors.ErrWhileFetchingUnificationRules, err)
+	}
+	defer rows.Close()
+
+	var rules []models.UnificationRule
+	for rows.Next() {
+		rule, err := scanUnificationRule(rows)
+		if err != nil {
+			return nil, errors.NewServerError(errors.ErrWhileFetchingUnificationRules, err)
+		}
+		rules = append(rules, rule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.NewServerError(errors.ErrWhileFetchingUnificationRules, err)
+	}
+	return rules, nil
+}
+
+// GetUnificationRule retrieves a specific unification rule by rule_id. An empty rule is returned if none exists.
+func (repo *UnificationRuleRepository) GetUnificationRule(ruleId string) (models.UnificationRule, error) {
+	return repo.findRule("rule_id", ruleId)
+}
+
+// GetUnificationRuleByPropertyName retrieves a specific unification rule by property name.
+func (repo *UnificationRuleRepository) GetUnificationRuleByPropertyName(property string) (models.UnificationRule, error) {
+	return repo.findRule("property", property)
+}
+
+// PatchUnificationRule modifies specific fields
+func (repo *UnificationRuleRepository) PatchUnificationRule(ruleId string, updates map[string]interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	err := repo.db.withTx(ctx, func(tx *sql.Tx) error {
+		row := tx.QueryRowContext(ctx, repo.db.rebind("SELECT definition FROM unification_rules WHERE rule_id = ?"+
+			repo.db.dialect.lockRow), ruleId)
+		var definition sql.NullString
+		if err := row.Scan(&definition); err != nil {
+			if stdErrors.Is(err, sql.ErrNoRows) {
+				return nil
+			}
+			return err
+		}
+
+		doc := map[string]interface{}{}
+		if err := fromJSON(definition, &doc); err != nil {
+			return err
+		}
+		for field, value := range updates {
+			doc[field] = value
+		}
+		doc["updated_at"] = time.Now().UTC().Unix()
+
+		data, err := json.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		var patched models.UnificationRule
+		if err := json.Unmarshal(data, &patched); err != nil {
+			return err
+		}
+		patchedDefinition, err := toJSON(patched)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, repo.db.rebind("UPDATE unification_rules SET property = ?, priority = ?, "+
+			"definition = "+repo.db.dialect.jsonParam+" WHERE rule_id = ?"), patched.Property, patched.Priority,
+			patchedDefinition, ruleId)
+		return err
+	})
+	if err != nil {
+		return errors.NewServerError(errors.ErrWhileUpdatingUnificationRule, err)
+	}
+	logger.Info("Successfully updated unification rule for rule_id: " + ruleId)
+	return nil
+}
+
+// DeleteUnificationRule Removes a unification rule.
+func (repo *UnificationRuleRepository) DeleteUnificationRule(ruleId string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	if _, err := repo.db.exec(ctx, "DELETE FROM unification_rules WHERE rule_id = ?", ruleId); err != nil {
+		logger.Error(err, "Error while deleting unification rule for rule_id: "+ruleId)
+		return err
+	}
+	logger.Info("Successfully deleted unification rule with rule_id: " + ruleId)
+	return nil
+}
+
+func (repo *UnificationRuleRepository) findRule(column, value string) (models.UnificationRule, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	row := repo.db.DB.QueryRowContext(ctx, repo.db.rebind("SELECT definition FROM unification_rules WHERE "+column+
+		" = ? ORDER BY priority LIMIT 1"), value)
+	rule, err := scanUnificationRule(row)
+	if stdErrors.Is(err, sql.ErrNoRows) {
+		return models.UnificationRule{}, nil
+	}
+	if err != nil {
+		return models.UnificationRule{}, errors.NewServerError(errors.ErrWhileFetchingUnificationRule, err)
+	}
+	return rule, nil
+}
+
+func scanUnificationRule(row rowScanner) (models.UnificationRule, error) {
+	var definition sql.NullString
+	if err := row.Scan(&definition); err != nil {
+		return models.UnificationRule{}, err
+	}
+	var rule models.UnificationRule
+	err := fromJSON(definition, &rule)
+	return rule, err
+}
@@ -0,0 +1,195 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+)
+
+var (
+	// ErrEnrichmentRuleNotFound is returned, possibly wrapped, when looking up an enrichment rule that does not exist
+	ErrEnrichmentRuleNotFound = errors.New("enrichment rule not found")
+	// ErrRuleVersionExists is returned, possibly wrapped, when adding a version that was already recorded
+	ErrRuleVersionExists = errors.New("rule version already exists")
+)
+
+// ProfileStore defines the storage operations required for profiles
+type ProfileStore interface {
+	InsertProfile(profile models.Profile) error
+	InsertProfiles(profiles []models.Profile) error
+	FindProfileByID(profileId string) (*models.Profile, error)
+	DeleteProfile(profileId string) error
+	DetachChildFromParent(parentID, childID string) error
+	AddOrUpdateAppContext(profileId string, newAppCtx models.ApplicationData) error
+	AddOrUpdateTraitsData(profileId string, traits map[string]interface{}) error
+	UpsertIdentityData(profileId string, identityData map[string]interface{}) error
+	GetAllProfiles() ([]models.Profile, error)
+	GetAllProfilesWithFilter(filters []string) ([]models.Profile, error)
+	GetAllMasterProfilesExceptForCurrent(currentProfile models.Profile) ([]models.Profile, error)
+	UpdateParent(master models.Profile, newProfile models.Profile) error
+	AddChildProfile(parentProfile models.Profile, child models.ChildProfile) error
+	UpsertIdentityAttribute(profileId string, updates map[string]interface{}) error
+	UpsertTrait(profileId string, updates map[string]interface{}) error
+	UpsertAppDatum(profileId string, appId string, updates map[string]interface{}) error
+	// ReplaceProfile replaces the stored profile with the same id in a single write, failing if there is none
+	ReplaceProfile(profile models.Profile) error
+}
+
+// EventFilter narrows down events by exact field matches and a lower timestamp bound.
+// Empty fields are not used for matching.
+type EventFilter struct {
+	ProfileId     string
+	EventType     string
+	EventName     string
+	FromTimestamp int64
+}
+
+// EventStore defines the storage operations required for events
+type EventStore interface {
+	// AddEvent stores the event and reports whether it has to be queued for processing. It is not stored again if
+	// an event with the same event_id was already stored for the org, in which case it only has to be queued if the
+	// stored event was never marked as queued.
+	AddEvent(event models.Event) (bool, error)
+	// AddEvents stores the events, skipping replayed ones like AddEvent, and returns the events that have to be
+	// queued for processing
+	AddEvents(events []models.Event) ([]models.Event, error)
+	// MarkEventsQueued records that the stored events were queued for processing, so that replays of them are not
+	// queued again
+	MarkEventsQueued(events []models.Event) error
+	FindEvents(filters []string, fromTimestamp int64) ([]models.Event, error)
+	FindEvent(eventId string) (*models.Event, error)
+	FindEventsWithFilter(filter EventFilter) ([]models.Event, error)
+	DeleteEventsByProfileId(profileId string) error
+	DeleteEventsByAppID(profileId, appID string) error
+}
+
+// ConsentStore defines the storage operations required for consents
+type ConsentStore interface {
+	GetConsentedApps(permaID string) ([]models.Consent, error)
+	GetConsentedAppsToCollect(permaID string) ([]string, error)
+	GetConsentedAppsToShare(permaID string) ([]string, error)
+	GiveConsent(permaID, appID string, consentType string) error
+	RevokeConsent(permaID, appID string, consentType string) error
+	RevokeAllConsents(permaID string) error
+}
+
+// EnrichmentRuleStore defines the storage operations required for profile enrichment rules
+type EnrichmentRuleStore interface {
+	UpsertEnrichmentRule(rule models.ProfileEnrichmentRule) error
+	GetProfileEnrichmentRules() ([]models.ProfileEnrichmentRule, error)
+	GetEnrichmentRulesByFilter(filters []string) ([]models.ProfileEnrichmentRule, error)
+	// GetSchemaRule returns the enrichment rule with the id, or ErrEnrichmentRuleNotFound when there is none
+	GetSchemaRule(ruleId string) (models.ProfileEnrichmentRule, error)
+	DeleteSchemaRule(ruleId string) error
+}
+
+// UnificationRuleStore defines the storage operations required for unification rules
+type UnificationRuleStore interface {
+	AddUnificationRule(rule models.UnificationRule) error
+	GetUnificationRules() ([]models.UnificationRule, error)
+	GetUnificationRule(ruleId string) (models.UnificationRule, error)
+	GetUnificationRuleByPropertyName(property string) (models.UnificationRule, error)
+	PatchUnificationRule(ruleId string, updates map[string]interface{}) error
+	DeleteUnificationRule(ruleId string) error
+}
+
+// EventSchemaStore defines the storage operations required for event schemas. Lookups return nil when no schema
+// matches.
+type EventSchemaStore interface {
+	AddEventSchema(schema models.EventSchema) error
+	GetAllEventSchemas() ([]models.EventSchema, error)
+	GetById(id string) (*models.EventSchema, error)
+	GetByEvent(eventType, eventName string) (*models.EventSchema, error)
+	Patch(id string, updates map[string]interface{}) error
+	Delete(id string) error
+}
+
+// EventQueueStore defines the storage operations of the durable event processing queue. Claimed events are
+// leased to a worker and become claimable again when they are neither acknowledged nor released before the
+// lease expires, so events are not lost if a worker crashes while processing them.
+type EventQueueStore interface {
+	Enqueue(events []models.QueuedEvent) error
+	// Claim leases up to `limit` available events to the worker in queue order and counts the attempt. Events of a
+	// profile that has an event leased or waiting for a retry are not claimed, to keep the events of a profile in
+	// order.
+	Claim(workerId string, limit int, lease time.Duration) ([]models.QueuedEvent, error)
+	// Ack removes a processed event from the queue
+	Ack(event models.QueuedEvent) error
+	// Release returns a failed event to the queue to be claimed again after `availableAt` (unix milliseconds). The
+	// attempts of the event are stored as given, so that an event released without being processed can give back
+	// the attempt counted by Claim.
+	Release(event models.QueuedEvent, availableAt int64, lastError string) error
+	// DeadLetter moves an event that can no longer be retried from the queue to the dead-letter store. Events that
+	// are quarantined at ingestion are added to the dead-letter store directly.
+	DeadLetter(event models.QueuedEvent, lastError string) error
+	GetDeadLetterEvents() ([]models.DeadLetterEvent, error)
+	// Depth returns the number of events waiting in the queue, including the ones claimed but not yet acknowledged
+	Depth() (int64, error)
+}
+
+// BackfillJobStore defines the storage operations required for the jobs that backfill enrichment rules. Lookups
+// return nil when no job matches.
+type BackfillJobStore interface {
+	AddBackfillJob(job models.BackfillJob) error
+	GetBackfillJob(jobId string) (*models.BackfillJob, error)
+	// GetBackfillJobs returns the jobs of the rule, the most recently created first
+	GetBackfillJobs(ruleId string) ([]models.BackfillJob, error)
+	// GetBackfillJobsByStatus returns the jobs of all rules that are in the status
+	GetBackfillJobsByStatus(status string) ([]models.BackfillJob, error)
+	UpdateBackfillJob(job models.BackfillJob) error
+	// UpdateBackfillJobIfStatus updates the job only while it is stored with the status, and reports whether it did.
+	// It keeps a job that changed meanwhile, such as one cancelled by another instance, from being overwritten.
+	UpdateBackfillJobIfStatus(job models.BackfillJob, status string) (bool, error)
+}
+
+// RuleVersionStore defines the storage operations required for the version history of enrichment and unification
+// rules. Versions are immutable and are unique per rule type, rule id and version number.
+type RuleVersionStore interface {
+	// AddRuleVersion returns ErrRuleVersionExists when the rule already has a version with the same number
+	AddRuleVersion(version models.RuleVersion) error
+	// GetRuleVersions returns the versions of the rule, the latest first
+	GetRuleVersions(ruleType, ruleId string) ([]models.RuleVersion, error)
+	// GetRuleVersion returns nil when the rule has no such version
+	GetRuleVersion(ruleType, ruleId string, version int) (*models.RuleVersion, error)
+	// GetLatestRuleVersion returns the number of the latest version of the rule, or 0 when it has no versions
+	GetLatestRuleVersion(ruleType, ruleId string) (int, error)
+}
+
+// IdentityIndexStore defines the storage operations required for the identity index, which maps the identity keys
+// that unification rules match on to the master profiles holding them
+type IdentityIndexStore interface {
+	// SetIdentityKeys replaces the keys of the profile
+	SetIdentityKeys(profileId string, keys []string) error
+	DeleteIdentityKeys(profileId string) error
+	// FindProfileIdsByIdentityKeys returns the ids of the profiles holding any of the keys, in ascending order
+	FindProfileIdsByIdentityKeys(keys []string) ([]string, error)
+	// GetIndexVersion returns the version of the unification rules the index was last built for, empty if it was
+	// never built
+	GetIndexVersion() (string, error)
+	SetIndexVersion(version string) error
+}
+
+// ProfileUnmergeStore defines the storage operations required for the records of profiles split from their master
+// profiles
+type ProfileUnmergeStore interface {
+	AddProfileUnmerge(unmerge models.ProfileUnmerge) error
+	// GetProfileUnmerges returns the unmerges of which any of the profiles is the split profile or its master, the
+	// latest first
+	GetProfileUnmerges(profileIds []string) ([]models.ProfileUnmerge, error)
+}
+
+// Stores groups the storage backends used by the service layer
+type Stores struct {
+	Profiles         ProfileStore
+	Events           EventStore
+	Consents         ConsentStore
+	EnrichmentRules  EnrichmentRuleStore
+	UnificationRules UnificationRuleStore
+	EventSchemas     EventSchemaStore
+	EventQueue       EventQueueStore
+	BackfillJobs     BackfillJobStore
+	RuleVersions     RuleVersionStore
+	IdentityIndex    IdentityIndexStore
+	ProfileUnmerges  ProfileUnmergeStore
+}
@@ -80,29 +80,29 @@ func (repo *UnificationRuleRepository) GetUnificationRule(ruleId string) (models
 }
 
 // GetUnificationRuleByPropertyName retrieves a specific resolution rule by property name.
-func (repo *UnificationRuleRepository) GetUnificationRuleByPropertyName(ruleId string) (models.UnificationRule, error) {
+func (repo *UnificationRuleRepository) GetUnificationRuleByPropertyName(property string) (models.UnificationRule, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	filter := bson.M{"rule_id": ruleId}
+	filter := bson.M{"property": property}
 	var rule models.UnificationRule
 
 	err := repo.Collection.FindOne(ctx, filter).Decode(&rule)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			logger.Info("No resolution rule found for rule_id: " + ruleId)
+			logger.Info("No resolution rule found for property: " + property)
 			return models.UnificationRule{}, nil
 		}
-		logger.Debug("Error occurred while fetching resolution rule with rule_id: "+ruleId, err)
+		logger.Debug("Error occurred while fetching resolution rule with property: "+property, err)
 		return models.UnificationRule{}, errors.NewServerError(errors.ErrWhileFetchingUnificationRule, err)
 	}
 
-	logger.Info("Successfully fetched resolution rule for rule_id: " + ruleId)
+	logger.Info("Successfully fetched resolution rule for property: " + property)
 	return rule, nil
 }
 
 // PatchUnificationRule modifies specific fields
-func (repo *UnificationRuleRepository) PatchUnificationRule(ruleId string, updates bson.M) error {
+func (repo *UnificationRuleRepository) PatchUnificationRule(ruleId string, updates map[string]interface{}) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
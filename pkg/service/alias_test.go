@@ -0,0 +1,157 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/wso2/identity-customer-data-service/pkg/constants"
+	"github.com/wso2/identity-customer-data-service/pkg/locks"
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+)
+
+func aliasEvent(eventId, userId, previousId string) models.Event {
+	return models.Event{EventId: eventId, ProfileId: userId, OrgId: "org", EventType: "alias", EventName: "alias",
+		Properties: map[string]interface{}{constants.AliasPreviousIdProperty: previousId}}
+}
+
+// addAndProcessEvents ingests the events as the API does and processes them as the profile worker does
+func addAndProcessEvents(t *testing.T, events ...models.Event) {
+	t.Helper()
+	for _, event := range events {
+		if _, _, err := AddEvents(event); err != nil {
+			t.Fatalf("failed to add event %s: %v", event.EventId, err)
+		}
+	}
+	processQueuedEvents(t)
+}
+
+// childRuleName returns the rule that linked the child to the master, or an empty string if it is not a child
+func childRuleName(master *models.Profile, childId string) string {
+	for _, child := range master.ProfileHierarchy.ChildProfiles {
+		if child.ChildProfileId == childId {
+			return child.RuleName
+		}
+	}
+	return ""
+}
+
+func TestAliasLinksTheAnonymousProfileToTheKnownMaster(t *testing.T) {
+	for _, test := range []struct {
+		name             string
+		anonymousVisited bool
+	}{
+		{name: "anonymous profile with events", anonymousVisited: true},
+		{name: "anonymous profile not seen yet"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			setupMemoryStores(t)
+			addCopyRule(t, "plan")
+			events := []models.Event{signupEventWith("e1", map[string]interface{}{"plan": "gold"})}
+			if test.anonymousVisited {
+				visit := signupEvent("e2")
+				visit.ProfileId = "anon"
+				events = append(events, visit)
+			}
+			addAndProcessEvents(t, append(events, aliasEvent("e3", "p1", "anon"))...)
+
+			known, anonymous := findProfile(t, "p1"), findProfile(t, "anon")
+			masterId := known.ProfileHierarchy.ParentProfileID
+			if known.ProfileHierarchy.IsParent || anonymous.ProfileHierarchy.IsParent ||
+				anonymous.ProfileHierarchy.ParentProfileID != masterId {
+				t.Fatalf("profiles are not linked: known %+v, anonymous %+v", known.ProfileHierarchy,
+					anonymous.ProfileHierarchy)
+			}
+			master := findProfile(t, masterId)
+			if rule := childRuleName(master, "anon"); rule != constants.AliasRuleName {
+				t.Errorf("anonymous profile was linked by rule %q, want %q", rule, constants.AliasRuleName)
+			}
+			if master.Traits["plan"] != "gold" {
+				t.Errorf("master traits = %v, want the traits of the known profile", master.Traits)
+			}
+
+			// Later events of the anonymous profile enrich the master
+			addAndProcessEvents(t, models.Event{EventId: "e4", ProfileId: "anon", OrgId: "org", EventType: "track",
+				EventName: "signup", Properties: map[string]interface{}{"plan": "silver"}})
+			if plan := findProfile(t, masterId).Traits["plan"]; plan != "silver" {
+				t.Errorf("master traits.plan = %v after an anonymous event, want silver", plan)
+			}
+		})
+	}
+}
+
+func TestAliasJoinsAnExistingMaster(t *testing.T) {
+	masterId := setupMergedProfiles(t)
+
+	addAndProcessEvents(t, aliasEvent("alias-1", "p2", "anon"))
+	if anonymous := findProfile(t, "anon"); anonymous.ProfileHierarchy.ParentProfileID != masterId {
+		t.Fatalf("anonymous profile hierarchy = %+v, want it linked to the master of p2", anonymous.ProfileHierarchy)
+	}
+	master := findProfile(t, masterId)
+	if len(master.ProfileHierarchy.ChildProfiles) != 4 || childRuleName(master, "anon") != constants.AliasRuleName {
+		t.Errorf("master children = %+v, want p1, p2, p3 and the aliased profile", master.ProfileHierarchy.ChildProfiles)
+	}
+}
+
+func TestAliasLeavesProfilesThatCanNotBeLinked(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		previousId string
+	}{
+		{name: "the known profile itself", previousId: "p2"},
+		{name: "a profile unified with the known profile", previousId: "p1"},
+		{name: "a master profile", previousId: "master"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			masterId := setupMergedProfiles(t)
+			previousId := test.previousId
+			if previousId == "master" {
+				previousId = masterId
+			}
+			before := findProfile(t, masterId).ProfileHierarchy.ChildProfiles
+
+			if err := processEvent(aliasEvent("alias-1", "p2", previousId)); err != nil {
+				t.Fatalf("failed to process the alias event: %v", err)
+			}
+			master := findProfile(t, masterId)
+			if len(master.ProfileHierarchy.ChildProfiles) != len(before) || !master.ProfileHierarchy.IsParent {
+				t.Errorf("master hierarchy = %+v, want it unchanged", master.ProfileHierarchy)
+			}
+		})
+	}
+}
+
+func TestAliasOfAProfileUnifiedElsewhereIsIgnored(t *testing.T) {
+	masterId := setupMergedProfiles(t)
+	other := signupEvent("e1")
+	other.ProfileId = "bob"
+	addAndProcessEvents(t, other)
+
+	if err := processEvent(aliasEvent("alias-1", "bob", "p2")); err != nil {
+		t.Fatalf("failed to process the alias event: %v", err)
+	}
+	if p2 := findProfile(t, "p2"); p2.ProfileHierarchy.ParentProfileID != masterId {
+		t.Errorf("profile p2 moved to %+v, want it left with its master", p2.ProfileHierarchy)
+	}
+}
+
+func TestAliasIsRetriedWhileThePreviousProfileIsBeingUnified(t *testing.T) {
+	setupMemoryStores(t)
+	addAndProcessEvents(t, signupEvent("e1"))
+
+	lock := locks.GetDistributedLock()
+	owner, err := lock.Acquire("lock:unify:anon", 10*time.Second)
+	if err != nil || owner == "" {
+		t.Fatalf("failed to lock the anonymous profile: %v", err)
+	}
+	if err := processEvent(aliasEvent("alias-1", "p1", "anon")); err == nil {
+		t.Fatalf("alias succeeded while the anonymous profile was being unified")
+	}
+
+	_ = lock.Release("lock:unify:anon", owner)
+	if err := processEvent(aliasEvent("alias-1", "p1", "anon")); err != nil {
+		t.Fatalf("retried alias failed: %v", err)
+	}
+	if anonymous := findProfile(t, "anon"); anonymous.ProfileHierarchy.IsParent {
+		t.Errorf("anonymous profile was not linked on the retry: %+v", anonymous.ProfileHierarchy)
+	}
+}
@@ -0,0 +1,419 @@
+package service
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wso2/identity-customer-data-service/pkg/constants"
+	"github.com/wso2/identity-customer-data-service/pkg/errors"
+	"github.com/wso2/identity-customer-data-service/pkg/logger"
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+	repositories "github.com/wso2/identity-customer-data-service/pkg/repository"
+)
+
+// backfillCheckpointInterval is the number of profiles after which the progress of a backfill job is saved
+const backfillCheckpointInterval = 20
+
+var (
+	errBackfillJobCancelled = fmt.Errorf("backfill job was cancelled")
+	errBackfillJobShutdown  = fmt.Errorf("the service shut down before the backfill job completed")
+	errBackfillJobLost      = fmt.Errorf("the service stopped before the backfill job completed")
+)
+
+// backfillJobs tracks the backfill jobs running in this instance so that they can be cancelled and drained on
+// shutdown
+var backfillJobs = struct {
+	mutex   sync.Mutex
+	cancels map[string]context.CancelCauseFunc
+	running sync.WaitGroup
+}{cancels: make(map[string]context.CancelCauseFunc)}
+
+// StartBackfillJob starts a job that replays the stored events of the profiles matching the filters through the
+// enrichment rule. All profiles are backfilled when there are no filters.
+func StartBackfillJob(ruleId string, request models.BackfillJobRequest) (*models.BackfillJob, error) {
+	rule, err := stores.EnrichmentRules.GetSchemaRule(ruleId)
+	if stderrors.Is(err, repositories.ErrEnrichmentRuleNotFound) {
+		return nil, errors.NewClientError(errors.ErrEnrichmentRuleNotFound, http.StatusNotFound)
+	}
+	if err != nil {
+		return nil, errors.NewServerError(errors.ErrWhileFetchingEnrichmentRules, err)
+	}
+	if strings.ToLower(rule.Trigger.EventType) == constants.ProfileTriggerEventType {
+		return nil, errors.NewClientError(errors.ErrorMessage{
+			Code:    errors.ErrInvalidBackfillJob.Code,
+			Message: errors.ErrInvalidBackfillJob.Message,
+			Description: "Rules with profile triggers have no events to replay. They are applied when the traits " +
+				"they depend on are backfilled.",
+		}, http.StatusBadRequest)
+	}
+	return startBackfillJob(rule, request.Filters)
+}
+
+// GetBackfillJob retrieves a backfill job with its progress
+func GetBackfillJob(jobId string) (*models.BackfillJob, error) {
+	job, err := stores.BackfillJobs.GetBackfillJob(jobId)
+	if err != nil {
+		return nil, errors.NewServerError(errors.ErrWhileFetchingBackfillJobs, err)
+	}
+	if job == nil {
+		return nil, errors.NewClientError(errors.ErrBackfillJobNotFound, http.StatusNotFound)
+	}
+	return job, nil
+}
+
+// GetBackfillJobs retrieves the backfill jobs of an enrichment rule, the most recent first
+func GetBackfillJobs(ruleId string) ([]models.BackfillJob, error) {
+	jobs, err := stores.BackfillJobs.GetBackfillJobs(ruleId)
+	if err != nil {
+		return nil, errors.NewServerError(errors.ErrWhileFetchingBackfillJobs, err)
+	}
+	if jobs == nil {
+		jobs = []models.BackfillJob{}
+	}
+	return jobs, nil
+}
+
+// CancelBackfillJob stops a pending or running backfill job. The profiles that were already backfilled keep their
+// recomputed values.
+func CancelBackfillJob(jobId string) (*models.BackfillJob, error) {
+	job, err := GetBackfillJob(jobId)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != constants.BackfillJobPending && job.Status != constants.BackfillJobRunning {
+		return nil, errors.NewClientError(errors.ErrorMessage{
+			Code:        errors.ErrInvalidBackfillJob.Code,
+			Message:     errors.ErrInvalidBackfillJob.Message,
+			Description: fmt.Sprintf("Backfill job is already %s.", job.Status),
+		}, http.StatusConflict)
+	}
+
+	// The job is marked first so that an instance running it stops at its next checkpoint
+	status := job.Status
+	job.Status = constants.BackfillJobCancelled
+	job.FinishedAt = time.Now().UTC().Unix()
+	cancelled, err := stores.BackfillJobs.UpdateBackfillJobIfStatus(*job, status)
+	if err != nil {
+		return nil, errors.NewServerError(errors.ErrWhileUpdatingBackfillJob, err)
+	}
+	if !cancelled {
+		return nil, errors.NewClientError(errors.ErrorMessage{
+			Code:        errors.ErrInvalidBackfillJob.Code,
+			Message:     errors.ErrInvalidBackfillJob.Message,
+			Description: "Backfill job finished while it was being cancelled.",
+		}, http.StatusConflict)
+	}
+
+	backfillJobs.mutex.Lock()
+	if cancel, ok := backfillJobs.cancels[jobId]; ok {
+		cancel(errBackfillJobCancelled)
+	}
+	backfillJobs.mutex.Unlock()
+	return job, nil
+}
+
+// StopBackfillJobs cancels the backfill jobs running in this instance and waits until they record how far they
+// got. The interrupted jobs are marked as failed.
+func StopBackfillJobs(ctx context.Context) error {
+	backfillJobs.mutex.Lock()
+	for _, cancel := range backfillJobs.cancels {
+		cancel(errBackfillJobShutdown)
+	}
+	backfillJobs.mutex.Unlock()
+
+	stopped := make(chan struct{})
+	go func() {
+		backfillJobs.running.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("backfill jobs did not stop in time: %v", ctx.Err())
+	}
+}
+
+// FailInterruptedBackfillJobs marks the jobs left pending or running by an earlier run of the service as failed. The
+// progress of a job is only kept in memory by the instance running it, so such a job never completes.
+func FailInterruptedBackfillJobs() {
+	for _, status := range []string{constants.BackfillJobPending, constants.BackfillJobRunning} {
+		jobs, err := stores.BackfillJobs.GetBackfillJobsByStatus(status)
+		if err != nil {
+			logger.Error(err, fmt.Sprintf("Failed to fetch the %s backfill jobs", status))
+			continue
+		}
+		for _, job := range jobs {
+			job.Status = constants.BackfillJobFailed
+			job.FinishedAt = time.Now().UTC().Unix()
+			job.Errors = append(job.Errors, models.BackfillJobError{Error: errBackfillJobLost.Error()})
+			if _, err := stores.BackfillJobs.UpdateBackfillJobIfStatus(job, status); err != nil {
+				logger.Error(err, fmt.Sprintf("Failed to mark interrupted backfill job %s as failed", job.JobId))
+				continue
+			}
+			logger.Info(fmt.Sprintf("Backfill job %s of enrichment rule %s was interrupted: %d of %d profiles "+
+				"processed", job.JobId, job.RuleId, job.ProcessedProfiles, job.TotalProfiles))
+		}
+	}
+}
+
+// backfillEnrichmentRule starts a job that recomputes the property of a new or changed rule for the existing
+// profiles. Jobs still backfilling an earlier version of the rule are cancelled. Rules with profile triggers are
+// applied as the traits they depend on change and are not backfilled.
+func backfillEnrichmentRule(rule models.ProfileEnrichmentRule) {
+	cancelBackfillJobsOfRule(rule.RuleId)
+	if strings.ToLower(rule.Trigger.EventType) == constants.ProfileTriggerEventType {
+		return
+	}
+	if _, err := startBackfillJob(rule, nil); err != nil {
+		logger.Error(err, fmt.Sprintf("Failed to start backfilling enrichment rule %s", rule.RuleId))
+	}
+}
+
+// cancelBackfillJobsOfRule cancels the pending and running backfill jobs of the rule
+func cancelBackfillJobsOfRule(ruleId string) {
+	jobs, err := stores.BackfillJobs.GetBackfillJobs(ruleId)
+	if err != nil {
+		logger.Error(err, fmt.Sprintf("Failed to fetch the backfill jobs of enrichment rule %s", ruleId))
+		return
+	}
+	for _, job := range jobs {
+		if job.Status != constants.BackfillJobPending && job.Status != constants.BackfillJobRunning {
+			continue
+		}
+		if _, err := CancelBackfillJob(job.JobId); err != nil {
+			logger.Error(err, fmt.Sprintf("Failed to cancel backfill job %s", job.JobId))
+		}
+	}
+}
+
+func startBackfillJob(rule models.ProfileEnrichmentRule, filters []string) (*models.BackfillJob, error) {
+	job := models.BackfillJob{
+		JobId:     uuid.New().String(),
+		RuleId:    rule.RuleId,
+		Filters:   filters,
+		Status:    constants.BackfillJobPending,
+		CreatedAt: time.Now().UTC().Unix(),
+	}
+	if err := stores.BackfillJobs.AddBackfillJob(job); err != nil {
+		return nil, errors.NewServerError(errors.ErrWhileStartingBackfillJob, err)
+	}
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	backfillJobs.mutex.Lock()
+	backfillJobs.cancels[job.JobId] = cancel
+	backfillJobs.running.Add(1)
+	backfillJobs.mutex.Unlock()
+
+	go func() {
+		defer func() {
+			backfillJobs.mutex.Lock()
+			delete(backfillJobs.cancels, job.JobId)
+			backfillJobs.mutex.Unlock()
+			cancel(nil)
+			backfillJobs.running.Done()
+		}()
+		runBackfillJob(ctx, job, rule)
+	}()
+	return &job, nil
+}
+
+// runBackfillJob backfills the rule for the profiles of the job one at a time, saving the progress periodically
+func runBackfillJob(ctx context.Context, job models.BackfillJob, rule models.ProfileEnrichmentRule) {
+	job.Status = constants.BackfillJobRunning
+	job.StartedAt = time.Now().UTC().Unix()
+	if !saveBackfillProgress(job, constants.BackfillJobPending) {
+		finishBackfillJob(job, constants.BackfillJobCancelled)
+		return
+	}
+
+	profileIds, err := backfillProfileIds(job.Filters)
+	if err != nil {
+		job.Errors = append(job.Errors, models.BackfillJobError{Error: err.Error()})
+		finishBackfillJob(job, constants.BackfillJobFailed)
+		return
+	}
+	job.TotalProfiles = len(profileIds)
+	if !saveBackfillProgress(job, constants.BackfillJobRunning) {
+		finishBackfillJob(job, constants.BackfillJobCancelled)
+		return
+	}
+
+	rules, err := GetEnrichmentRules()
+	if err != nil {
+		job.Errors = append(job.Errors, models.BackfillJobError{
+			Error: fmt.Sprintf("failed to fetch the enrichment rules: %v", err)})
+		finishBackfillJob(job, constants.BackfillJobFailed)
+		return
+	}
+	for _, profileId := range profileIds {
+		if ctx.Err() != nil {
+			if context.Cause(ctx) == errBackfillJobShutdown {
+				job.Errors = append(job.Errors, models.BackfillJobError{Error: errBackfillJobShutdown.Error()})
+				finishBackfillJob(job, constants.BackfillJobFailed)
+			} else {
+				finishBackfillJob(job, constants.BackfillJobCancelled)
+			}
+			return
+		}
+
+		changed, err := backfillProfile(rule, rules, profileId)
+		job.ProcessedProfiles++
+		if err != nil {
+			job.FailedProfiles++
+			if len(job.Errors) < constants.MaxBackfillJobErrors {
+				job.Errors = append(job.Errors, models.BackfillJobError{ProfileId: profileId, Error: err.Error()})
+			}
+		} else if changed {
+			job.UpdatedProfiles++
+		}
+
+		if job.ProcessedProfiles%backfillCheckpointInterval == 0 &&
+			!saveBackfillProgress(job, constants.BackfillJobRunning) {
+			finishBackfillJob(job, constants.BackfillJobCancelled)
+			return
+		}
+	}
+	finishBackfillJob(job, constants.BackfillJobCompleted)
+}
+
+// backfillProfileIds lists the ids of the profiles matching the filters, or of all profiles without filters
+func backfillProfileIds(filters []string) ([]string, error) {
+	var profiles []models.Profile
+	var err error
+	if len(filters) > 0 {
+		profiles, err = GetAllProfilesWithFilter(filters)
+	} else {
+		profiles, err = GetAllProfiles()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	profileIds := make([]string, 0, len(profiles))
+	for _, profile := range profiles {
+		profileIds = append(profileIds, profile.ProfileId)
+	}
+	return profileIds, nil
+}
+
+// backfillProfile replays the stored events of the profile that trigger the rule, writing to its master profile
+// as the worker does, and reports whether any trait or identity attribute changed. The rules with profile triggers
+// then see the changed values. Values that fail to be computed or written fail the profile.
+func backfillProfile(rule models.ProfileEnrichmentRule, rules []models.ProfileEnrichmentRule,
+	profileId string) (bool, error) {
+	master, err := findMasterProfile(profileId)
+	if err != nil {
+		return false, err
+	}
+	if master == nil {
+		return false, nil // deleted since the job started
+	}
+
+	events, err := findTriggeringEvents(profileId, rule.Trigger)
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch the events of profile %s: %v", profileId, err)
+	}
+	if len(events) == 0 {
+		return false, nil
+	}
+
+	// Counts, aggregates and decay scores are computed over all the matching events, replaying the latest one is
+	// enough
+	if computesOverEvents(rule) {
+		events = events[len(events)-1:]
+	}
+
+	// The writes are serialized with the worker enriching the same master
+	unlock, err := lockProfile(master.ProfileId, 5*time.Second)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+	if master, err = stores.Profiles.FindProfileByID(master.ProfileId); err != nil {
+		return false, fmt.Errorf("failed to fetch profile %s: %v", profileId, err)
+	} else if master == nil {
+		return false, nil // merged or deleted since it was found
+	}
+
+	var failures []string
+	updated := map[string]interface{}{}
+	for _, event := range events {
+		ruleUpdates, err := applyEnrichmentRules(event, master, []models.ProfileEnrichmentRule{rule})
+		if err != nil {
+			failures = append(failures, err.Error())
+		}
+		for fieldPath, value := range ruleUpdates {
+			updated[fieldPath] = value
+		}
+	}
+
+	profileEvents := traitUpdatedEvents(events[len(events)-1], master, updated)
+	for _, profileEvent := range profileEvents {
+		if _, err := applyEnrichmentRules(profileEvent, master, rules); err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+	if len(failures) > 0 {
+		return len(profileEvents) > 0, fmt.Errorf("%s", strings.Join(failures, "; "))
+	}
+	return len(profileEvents) > 0, nil
+}
+
+// findTriggeringEvents finds the events of the profile that trigger a rule with the trigger, in the order they
+// occurred
+func findTriggeringEvents(profileId string, trigger models.RuleTrigger) ([]models.Event, error) {
+	events, err := stores.Events.FindEventsWithFilter(repositories.EventFilter{ProfileId: profileId})
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []models.Event
+	for _, event := range events {
+		if triggerMatchesEvent(trigger, event) && EvaluateConditions(event, trigger.Conditions) {
+			matched = append(matched, event)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		return matched[i].EventTimestamp < matched[j].EventTimestamp
+	})
+	return matched, nil
+}
+
+// saveBackfillProgress saves the progress of a job that is still stored with the status. It reports false when the
+// job was cancelled meanwhile, possibly by another instance, in which case the job has to stop.
+func saveBackfillProgress(job models.BackfillJob, status string) bool {
+	saved, err := stores.BackfillJobs.UpdateBackfillJobIfStatus(job, status)
+	if err != nil {
+		logger.Error(err, fmt.Sprintf("Failed to save the progress of backfill job %s", job.JobId))
+		return true
+	}
+	return saved
+}
+
+// finishBackfillJob records the final status of a job unless it is no longer running, as a cancelled job already
+// has its final status
+func finishBackfillJob(job models.BackfillJob, status string) {
+	job.Status = status
+	job.FinishedAt = time.Now().UTC().Unix()
+	finished, err := stores.BackfillJobs.UpdateBackfillJobIfStatus(job, constants.BackfillJobRunning)
+	if err != nil {
+		logger.Error(err, fmt.Sprintf("Failed to save the status of backfill job %s", job.JobId))
+		return
+	}
+	if !finished {
+		logger.Info(fmt.Sprintf("Backfill job %s of enrichment rule %s stopped after it was cancelled: %d of %d "+
+			"profiles processed", job.JobId, job.RuleId, job.ProcessedProfiles, job.TotalProfiles))
+		return
+	}
+	logger.Info(fmt.Sprintf("Backfill job %s of enrichment rule %s %s: %d of %d profiles processed, %d updated, "+
+		"%d failed", job.JobId, job.RuleId, status, job.ProcessedProfiles, job.TotalProfiles, job.UpdatedProfiles,
+		job.FailedProfiles))
+}
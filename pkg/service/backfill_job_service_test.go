@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/wso2/identity-customer-data-service/pkg/constants"
+	cdserrors "github.com/wso2/identity-customer-data-service/pkg/errors"
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+	repositories "github.com/wso2/identity-customer-data-service/pkg/repository"
+)
+
+// failingTraitStore fails to write traits
+type failingTraitStore struct {
+	repositories.ProfileStore
+}
+
+func (store failingTraitStore) UpsertTrait(profileId string, update map[string]interface{}) error {
+	return errors.New("write failed")
+}
+
+// runBackfill runs a backfill job of the rule to completion and returns the job as it was saved
+func runBackfill(t *testing.T, ruleId string) models.BackfillJob {
+	t.Helper()
+	rule, err := stores.EnrichmentRules.GetSchemaRule(ruleId)
+	if err != nil {
+		t.Fatalf("failed to fetch rule %s: %v", ruleId, err)
+	}
+	job := models.BackfillJob{JobId: "job-" + ruleId, RuleId: ruleId, Status: constants.BackfillJobPending}
+	if err := stores.BackfillJobs.AddBackfillJob(job); err != nil {
+		t.Fatalf("failed to add backfill job: %v", err)
+	}
+	runBackfillJob(context.Background(), job, rule)
+
+	saved, err := stores.BackfillJobs.GetBackfillJob(job.JobId)
+	if err != nil || saved == nil {
+		t.Fatalf("failed to fetch backfill job: %v", err)
+	}
+	return *saved
+}
+
+func TestBackfillCountsProfilesThatFailToBeWritten(t *testing.T) {
+	setupMemoryStores(t)
+	enrichWithEvent(t, "p1", map[string]interface{}{"plan": "gold"})
+	addCopyRule(t, "plan")
+	stores.Profiles = failingTraitStore{stores.Profiles}
+
+	job := runBackfill(t, "rule-plan")
+	if job.Status != constants.BackfillJobCompleted || job.ProcessedProfiles != 1 {
+		t.Fatalf("job %s processed %d profiles, want a completed job of 1 profile", job.Status,
+			job.ProcessedProfiles)
+	}
+	if job.FailedProfiles != 1 || job.UpdatedProfiles != 0 {
+		t.Errorf("failed %d and updated %d profiles, want 1 failed", job.FailedProfiles, job.UpdatedProfiles)
+	}
+	if len(job.Errors) != 1 || job.Errors[0].ProfileId != "p1" || !strings.Contains(job.Errors[0].Error,
+		"write failed") {
+		t.Errorf("job errors = %+v, want the write failure of p1", job.Errors)
+	}
+}
+
+func TestBackfillUpdatesExistingProfiles(t *testing.T) {
+	setupMemoryStores(t)
+	enrichWithEvent(t, "p1", map[string]interface{}{"plan": "gold"})
+	addCopyRule(t, "plan")
+
+	job := runBackfill(t, "rule-plan")
+	if job.Status != constants.BackfillJobCompleted || job.UpdatedProfiles != 1 || job.FailedProfiles != 0 {
+		t.Fatalf("job = %+v, want a completed job that updated p1", job)
+	}
+	if got := findProfile(t, "p1").Traits["plan"]; got != "gold" {
+		t.Errorf("traits.plan = %v after the backfill, want gold", got)
+	}
+}
+
+func TestStartBackfillJobOfMissingRule(t *testing.T) {
+	setupMemoryStores(t)
+
+	_, err := StartBackfillJob("missing", models.BackfillJobRequest{})
+	var clientError *cdserrors.ClientError
+	if !errors.As(err, &clientError) || clientError.StatusCode != http.StatusNotFound {
+		t.Errorf("starting a job of a missing rule returned %v, want a not found error", err)
+	}
+}
+
+// staleBackfillJobStore returns the jobs as they were before they finished
+type staleBackfillJobStore struct {
+	repositories.BackfillJobStore
+}
+
+func (store staleBackfillJobStore) GetBackfillJob(jobId string) (*models.BackfillJob, error) {
+	job, err := store.BackfillJobStore.GetBackfillJob(jobId)
+	if job != nil {
+		job.Status = constants.BackfillJobRunning
+	}
+	return job, err
+}
+
+func addBackfillJob(t *testing.T, jobId string, status string) models.BackfillJob {
+	t.Helper()
+	job := models.BackfillJob{JobId: jobId, RuleId: "rule-plan", Status: status, ProcessedProfiles: 3,
+		TotalProfiles: 5}
+	if err := stores.BackfillJobs.AddBackfillJob(job); err != nil {
+		t.Fatalf("failed to add backfill job: %v", err)
+	}
+	return job
+}
+
+func backfillJobStatus(t *testing.T, jobId string) string {
+	t.Helper()
+	job, err := stores.BackfillJobs.GetBackfillJob(jobId)
+	if err != nil || job == nil {
+		t.Fatalf("failed to fetch backfill job %s: %v", jobId, err)
+	}
+	return job.Status
+}
+
+func TestBackfillJobCancelledWhileFinishingStaysCancelled(t *testing.T) {
+	for _, status := range []string{constants.BackfillJobCompleted, constants.BackfillJobFailed} {
+		t.Run(status, func(t *testing.T) {
+			setupMemoryStores(t)
+			job := addBackfillJob(t, "job-1", constants.BackfillJobRunning)
+
+			if _, err := CancelBackfillJob(job.JobId); err != nil {
+				t.Fatalf("failed to cancel the job: %v", err)
+			}
+			finishBackfillJob(job, status)
+			if got := backfillJobStatus(t, job.JobId); got != constants.BackfillJobCancelled {
+				t.Errorf("job status = %s after it finished, want it left cancelled", got)
+			}
+		})
+	}
+}
+
+func TestBackfillJobCancelledBeforeItStartsIsNotRun(t *testing.T) {
+	setupMemoryStores(t)
+	enrichWithEvent(t, "p1", map[string]interface{}{"plan": "gold"})
+	addCopyRule(t, "plan")
+	job := addBackfillJob(t, "job-1", constants.BackfillJobPending)
+	if _, err := CancelBackfillJob(job.JobId); err != nil {
+		t.Fatalf("failed to cancel the job: %v", err)
+	}
+
+	rule, err := stores.EnrichmentRules.GetSchemaRule("rule-plan")
+	if err != nil {
+		t.Fatalf("failed to fetch the rule: %v", err)
+	}
+	runBackfillJob(context.Background(), job, rule)
+	saved, err := stores.BackfillJobs.GetBackfillJob(job.JobId)
+	if err != nil || saved == nil {
+		t.Fatalf("failed to fetch backfill job: %v", err)
+	}
+	if saved.Status != constants.BackfillJobCancelled || saved.StartedAt != 0 {
+		t.Errorf("job = %+v, want it left cancelled without starting", saved)
+	}
+}
+
+func TestCancelBackfillJobThatFinishedMeanwhile(t *testing.T) {
+	setupMemoryStores(t)
+	job := addBackfillJob(t, "job-1", constants.BackfillJobCompleted)
+	jobStore := stores.BackfillJobs
+	stores.BackfillJobs = staleBackfillJobStore{jobStore}
+
+	_, err := CancelBackfillJob(job.JobId)
+	var clientError *cdserrors.ClientError
+	if !errors.As(err, &clientError) || clientError.StatusCode != http.StatusConflict {
+		t.Errorf("cancelling a finished job returned %v, want a conflict", err)
+	}
+	stores.BackfillJobs = jobStore
+	if got := backfillJobStatus(t, job.JobId); got != constants.BackfillJobCompleted {
+		t.Errorf("job status = %s, want it left completed", got)
+	}
+}
+
+func TestFailInterruptedBackfillJobs(t *testing.T) {
+	setupMemoryStores(t)
+	for _, status := range []string{constants.BackfillJobPending, constants.BackfillJobRunning,
+		constants.BackfillJobCompleted, constants.BackfillJobCancelled} {
+		addBackfillJob(t, status, status)
+	}
+
+	FailInterruptedBackfillJobs()
+	for jobId, want := range map[string]string{
+		constants.BackfillJobPending:   constants.BackfillJobFailed,
+		constants.BackfillJobRunning:   constants.BackfillJobFailed,
+		constants.BackfillJobCompleted: constants.BackfillJobCompleted,
+		constants.BackfillJobCancelled: constants.BackfillJobCancelled,
+	} {
+		job, err := stores.BackfillJobs.GetBackfillJob(jobId)
+		if err != nil || job == nil {
+			t.Fatalf("failed to fetch backfill job %s: %v", jobId, err)
+		}
+		if job.Status != want {
+			t.Errorf("%s job is %s, want %s", jobId, job.Status, want)
+		}
+		if interrupted := want == constants.BackfillJobFailed; interrupted != (len(job.Errors) == 1) ||
+			interrupted != (job.FinishedAt != 0) || job.ProcessedProfiles != 3 {
+			t.Errorf("%s job = %+v, want the progress kept and interrupted jobs finished with an error", jobId, job)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wso2/identity-customer-data-service/pkg/errors"
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+	repositories "github.com/wso2/identity-customer-data-service/pkg/repository"
+)
+
+// PreviewEnrichmentRule evaluates a rule against a sample event, or against the latest event of a profile that
+// triggers the rule, and returns the value it would write along with the trait it would result in. Nothing is
+// persisted.
+func PreviewEnrichmentRule(request models.EnrichmentRulePreviewRequest) (*models.EnrichmentRulePreview, error) {
+	rule := request.Rule
+	if err, isValid := validateEnrichmentRule(rule); !isValid {
+		return nil, err
+	}
+	if request.Event == nil && request.ProfileId == "" {
+		return nil, errors.NewClientError(errors.ErrorMessage{
+			Code:        errors.ErrBadRequest.Code,
+			Message:     errors.ErrBadRequest.Message,
+			Description: "Either 'event' or 'profile_id' must be provided.",
+		}, http.StatusBadRequest)
+	}
+
+	profileId := request.ProfileId
+	if profileId == "" {
+		profileId = request.Event.ProfileId
+	}
+	var profile *models.Profile
+	if profileId != "" {
+		var err error
+		if profile, err = findMasterProfile(profileId); err != nil {
+			return nil, errors.NewServerError(errors.ErrWhileFetchingProfile, err)
+		}
+	}
+	if profile == nil && request.Event == nil {
+		return nil, errors.NewClientError(errors.ErrProfileNotFound, http.StatusNotFound)
+	}
+
+	preview := &models.EnrichmentRulePreview{PropertyName: rule.PropertyName}
+	var event models.Event
+	if request.Event != nil {
+		event = *request.Event
+		event.EventType = strings.ToLower(event.EventType)
+		event.EventName = strings.ToLower(event.EventName)
+		if event.ProfileId == "" {
+			event.ProfileId = profileId
+		}
+		if event.EventTimestamp == 0 {
+			event.EventTimestamp = int(time.Now().UTC().Unix())
+		}
+	} else {
+		latest, err := findLatestTriggeringEvent(profileId, rule.Trigger)
+		if err != nil {
+			return nil, errors.NewServerError(errors.ErrWhileFetchingEvents, err)
+		}
+		if latest == nil {
+			return preview, nil
+		}
+		event = *latest
+	}
+	preview.Event = &event
+
+	preview.Matched = triggerMatchesEvent(rule.Trigger, event) && EvaluateConditions(event, rule.Trigger.Conditions)
+	if !preview.Matched {
+		return preview, nil
+	}
+
+	value, err := computeRuleValue(rule, event, func() ([]models.Event, error) {
+		events, err := findEventsMatchingRule(event.ProfileId, rule.Trigger, rule.TimeRange)
+		if err != nil || request.Event == nil {
+			return events, err
+		}
+		// The sample event counts as if it had been stored
+		if int64(event.EventTimestamp) >= ruleWindowStart(rule.TimeRange) && !containsEvent(events, event.EventId) {
+			events = append(events, event)
+			sort.SliceStable(events, func(i, j int) bool {
+				return events[i].EventTimestamp < events[j].EventTimestamp
+			})
+		}
+		return events, nil
+	})
+	if err != nil {
+		preview.Error = err.Error()
+		return preview, nil
+	}
+	preview.Value = value
+	if value == nil {
+		return preview, nil
+	}
+
+	preview.ExistingValue, preview.MergedValue, err = previewMerge(profile, rule.PropertyName, event.AppId, value)
+	if err != nil {
+		preview.Error = err.Error()
+	}
+	return preview, nil
+}
+
+// findLatestTriggeringEvent finds the latest event of the profile that triggers a rule with the trigger
+func findLatestTriggeringEvent(profileId string, trigger models.RuleTrigger) (*models.Event, error) {
+	events, err := findTriggeringEvents(profileId, trigger)
+	if err != nil || len(events) == 0 {
+		return nil, err
+	}
+	return &events[len(events)-1], nil
+}
+
+func containsEvent(events []models.Event, eventId string) bool {
+	if eventId == "" {
+		return false
+	}
+	for _, event := range events {
+		if event.EventId == eventId {
+			return true
+		}
+	}
+	return false
+}
+
+// previewMerge merges the value into a copy of the profile the way the stores do and returns the existing and the
+// merged value of the property
+func previewMerge(profile *models.Profile, propertyName string, appId string, value interface{}) (interface{},
+	interface{}, error) {
+
+	preview := models.Profile{}
+	if profile != nil {
+		preview.Traits = copyMap(profile.Traits)
+		preview.IdentityAttributes = copyMap(profile.IdentityAttributes)
+		for _, app := range profile.ApplicationData {
+			app.AppSpecificData = copyMap(app.AppSpecificData)
+			preview.ApplicationData = append(preview.ApplicationData, app)
+		}
+	}
+
+	namespace, name, _ := strings.Cut(propertyName, ".")
+	update := map[string]interface{}{namespace + "." + name: value}
+	switch namespace {
+	case "traits":
+		existing := preview.Traits[name]
+		repositories.ApplyTraitUpdates(&preview, update)
+		return existing, preview.Traits[name], nil
+	case "identity_attributes":
+		existing := preview.IdentityAttributes[name]
+		repositories.ApplyIdentityAttributeUpdates(&preview, update)
+		return existing, preview.IdentityAttributes[name], nil
+	case "application_data":
+		existing := appDatum(preview, appId, name)
+		repositories.ApplyAppDatumUpdates(&preview, appId, update)
+		return existing, appDatum(preview, appId, name), nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported trait namespace: %s", namespace)
+	}
+}
+
+func appDatum(profile models.Profile, appId string, name string) interface{} {
+	for _, app := range profile.ApplicationData {
+		if app.AppId == appId {
+			return app.AppSpecificData[name]
+		}
+	}
+	return nil
+}
+
+func copyMap(source map[string]interface{}) map[string]interface{} {
+	if source == nil {
+		return nil
+	}
+	copied := make(map[string]interface{}, len(source))
+	for key, value := range source {
+		copied[key] = value
+	}
+	return copied
+}
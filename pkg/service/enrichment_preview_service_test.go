@@ -0,0 +1,196 @@
+package service
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/wso2/identity-customer-data-service/pkg/errors"
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+)
+
+func planRule() models.ProfileEnrichmentRule {
+	return models.ProfileEnrichmentRule{
+		RuleId:        "rule-plan",
+		PropertyName:  "traits.plan",
+		PropertyType:  "computed",
+		Computation:   "copy",
+		SourceFields:  []string{"properties.plan"},
+		ValueType:     "string",
+		MergeStrategy: "overwrite",
+		Trigger: models.RuleTrigger{EventType: "track", EventName: "signup", Conditions: []models.RuleCondition{
+			{Field: "properties.plan", Operator: "exists"},
+		}},
+	}
+}
+
+func totalRule() models.ProfileEnrichmentRule {
+	return models.ProfileEnrichmentRule{
+		RuleId:        "rule-total",
+		PropertyName:  "traits.total",
+		PropertyType:  "computed",
+		Computation:   "sum",
+		SourceFields:  []string{"properties.total"},
+		MergeStrategy: "overwrite",
+		Trigger:       models.RuleTrigger{EventType: "track", EventName: "signup"},
+	}
+}
+
+func TestPreviewEnrichmentRule(t *testing.T) {
+	now := int(time.Now().Unix())
+	sample := func(eventId, profileId string, properties map[string]interface{}) *models.Event {
+		return &models.Event{EventId: eventId, ProfileId: profileId, OrgId: "org", EventType: "Track",
+			EventName: "Signup", EventTimestamp: now, Properties: properties}
+	}
+
+	for _, test := range []struct {
+		name          string
+		request       models.EnrichmentRulePreviewRequest
+		matched       bool
+		value         interface{}
+		existingValue interface{}
+		mergedValue   interface{}
+		eventId       string
+	}{
+		{
+			name: "sample event of a profile",
+			request: models.EnrichmentRulePreviewRequest{Rule: planRule(),
+				Event: sample("sample", "p1", map[string]interface{}{"plan": "silver"})},
+			matched: true, value: "silver", existingValue: "gold", mergedValue: "silver", eventId: "sample",
+		},
+		{
+			name: "sample event of an unknown profile",
+			request: models.EnrichmentRulePreviewRequest{Rule: planRule(),
+				Event: sample("sample", "anon", map[string]interface{}{"plan": "silver"})},
+			matched: true, value: "silver", mergedValue: "silver", eventId: "sample",
+		},
+		{
+			name: "sample event not matching the conditions",
+			request: models.EnrichmentRulePreviewRequest{Rule: planRule(),
+				Event: sample("sample", "p1", map[string]interface{}{"total": 5.0})},
+			eventId: "sample",
+		},
+		{
+			name:    "latest triggering event of a profile",
+			request: models.EnrichmentRulePreviewRequest{Rule: planRule(), ProfileId: "p1"},
+			matched: true, value: "bronze", existingValue: "gold", mergedValue: "bronze", eventId: "unenriched",
+		},
+		{
+			name:    "profile without a triggering event",
+			request: models.EnrichmentRulePreviewRequest{Rule: planRule(), ProfileId: "p2"},
+		},
+		{
+			name: "aggregate counting the sample event",
+			request: models.EnrichmentRulePreviewRequest{Rule: totalRule(),
+				Event: sample("sample", "p1", map[string]interface{}{"total": 5.0})},
+			matched: true, value: 35.0, existingValue: 10.0, mergedValue: 35.0, eventId: "sample",
+		},
+		{
+			name: "aggregate counting a stored sample event once",
+			request: models.EnrichmentRulePreviewRequest{Rule: totalRule(),
+				Event: sample("unenriched", "p1", map[string]interface{}{"total": 20.0})},
+			matched: true, value: 30.0, existingValue: 10.0, mergedValue: 30.0, eventId: "unenriched",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			setupMemoryStores(t)
+			addEnrichmentRule(t, planRule())
+			addEnrichmentRule(t, totalRule())
+			enrichWithEvent(t, "p1", map[string]interface{}{"plan": "gold", "total": 10.0})
+			if _, err := stores.Events.AddEvent(models.Event{EventId: "unenriched", ProfileId: "p1", OrgId: "org",
+				EventType: "track", EventName: "signup", EventTimestamp: now + 3600,
+				Properties: map[string]interface{}{"plan": "bronze", "total": 20.0}}); err != nil {
+				t.Fatalf("failed to store event: %v", err)
+			}
+			if _, err := CreateOrUpdateProfile(models.Event{ProfileId: "p2", OrgId: "org", EventType: "track",
+				EventName: "login", EventTimestamp: now}); err != nil {
+				t.Fatalf("failed to create profile p2: %v", err)
+			}
+			profile := findProfile(t, "p1")
+			rules, err := stores.EnrichmentRules.GetProfileEnrichmentRules()
+			if err != nil {
+				t.Fatalf("failed to fetch the enrichment rules: %v", err)
+			}
+
+			preview, err := PreviewEnrichmentRule(test.request)
+			if err != nil {
+				t.Fatalf("failed to preview the rule: %v", err)
+			}
+			if preview.Matched != test.matched || preview.PropertyName != test.request.Rule.PropertyName {
+				t.Errorf("preview matched %v the property %q, want %v for %q", preview.Matched,
+					preview.PropertyName, test.matched, test.request.Rule.PropertyName)
+			}
+			if fmt.Sprintf("%#v", preview.Value) != fmt.Sprintf("%#v", test.value) ||
+				fmt.Sprintf("%#v", preview.ExistingValue) != fmt.Sprintf("%#v", test.existingValue) ||
+				fmt.Sprintf("%#v", preview.MergedValue) != fmt.Sprintf("%#v", test.mergedValue) {
+				t.Errorf("preview value %#v, existing %#v and merged %#v, want %#v, %#v and %#v", preview.Value,
+					preview.ExistingValue, preview.MergedValue, test.value, test.existingValue, test.mergedValue)
+			}
+			if eventId := ""; preview.Event != nil {
+				eventId = preview.Event.EventId
+				if eventId != test.eventId {
+					t.Errorf("preview evaluated event %q, want %q", eventId, test.eventId)
+				}
+			} else if test.eventId != "" {
+				t.Errorf("preview evaluated no event, want %q", test.eventId)
+			}
+			if preview.Error != "" {
+				t.Errorf("preview error = %s", preview.Error)
+			}
+
+			// Nothing of the preview is persisted
+			if after := findProfile(t, "p1"); !reflect.DeepEqual(after, profile) {
+				t.Errorf("profile changed by the preview:\n%+v\nwant %+v", after, profile)
+			}
+			if anon, err := stores.Profiles.FindProfileByID("anon"); err != nil || anon != nil {
+				t.Errorf("preview created a profile %+v (%v)", anon, err)
+			}
+			if event, _ := stores.Events.FindEvent("sample"); event != nil {
+				t.Errorf("preview stored the sample event %+v", event)
+			}
+			if depth := queueDepth(t); depth != 0 {
+				t.Errorf("preview queued %d events", depth)
+			}
+			if after, err := stores.EnrichmentRules.GetProfileEnrichmentRules(); err != nil ||
+				!reflect.DeepEqual(after, rules) {
+				t.Errorf("enrichment rules changed by the preview: %+v (%v)", after, err)
+			}
+			if jobs, err := stores.BackfillJobs.GetBackfillJobs(test.request.Rule.RuleId); err != nil ||
+				len(jobs) != 0 {
+				t.Errorf("preview started the backfill jobs %+v (%v)", jobs, err)
+			}
+		})
+	}
+}
+
+func TestPreviewEnrichmentRuleRejectsInvalidRequests(t *testing.T) {
+	invalidRule := planRule()
+	invalidRule.Computation = "median"
+
+	for _, test := range []struct {
+		name    string
+		request models.EnrichmentRulePreviewRequest
+		code    string
+	}{
+		{name: "neither an event nor a profile", request: models.EnrichmentRulePreviewRequest{Rule: planRule()},
+			code: errors.ErrBadRequest.Code},
+		{name: "unknown profile", request: models.EnrichmentRulePreviewRequest{Rule: planRule(), ProfileId: "p9"},
+			code: errors.ErrProfileNotFound.Code},
+		{name: "invalid rule", request: models.EnrichmentRulePreviewRequest{Rule: invalidRule, ProfileId: "p1"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			setupMemoryStores(t)
+			enrichWithEvent(t, "p1", map[string]interface{}{"plan": "gold"})
+
+			preview, err := PreviewEnrichmentRule(test.request)
+			if err == nil {
+				t.Fatalf("preview = %+v, want the request rejected", preview)
+			}
+			code := clientErrorCode(err)
+			if code == "" || (test.code != "" && code != test.code) {
+				t.Errorf("error = %v, want a client error %s", err, test.code)
+			}
+		})
+	}
+}
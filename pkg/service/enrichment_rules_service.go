@@ -5,18 +5,15 @@ import (
 	"github.com/google/uuid"
 	"github.com/wso2/identity-customer-data-service/pkg/constants"
 	"github.com/wso2/identity-customer-data-service/pkg/errors"
-	"github.com/wso2/identity-customer-data-service/pkg/locks"
 	"github.com/wso2/identity-customer-data-service/pkg/models"
-	"github.com/wso2/identity-customer-data-service/pkg/repository"
 	"net/http"
 	"strings"
 	"time"
 )
 
-func AddEnrichmentRule(rule models.ProfileEnrichmentRule) error {
+func AddEnrichmentRule(rule models.ProfileEnrichmentRule, author string) error {
 
-	mongoDB := locks.GetMongoDBInstance()
-	schemaRepo := repositories.NewProfileSchemaRepository(mongoDB.Database, constants.ProfileSchemaCollection)
+	schemaRepo := stores.EnrichmentRules
 
 	rule.RuleId = uuid.New().String()
 
@@ -28,42 +25,75 @@ func AddEnrichmentRule(rule models.ProfileEnrichmentRule) error {
 	rule.CreatedAt = time.Now().UTC().Unix()
 	rule.UpdatedAt = time.Now().UTC().Unix()
 
-	return schemaRepo.UpsertEnrichmentRule(rule)
+	if err := schemaRepo.UpsertEnrichmentRule(rule); err != nil {
+		return err
+	}
+	recordRuleVersion(constants.EnrichmentRuleType, rule.RuleId, constants.RuleCreated, author, nil, rule, 0)
+	backfillEnrichmentRule(rule)
+	return nil
 }
 
 func GetEnrichmentRules() ([]models.ProfileEnrichmentRule, error) {
-	mongoDB := locks.GetMongoDBInstance()
-	schemaRepo := repositories.NewProfileSchemaRepository(mongoDB.Database, constants.ProfileSchemaCollection)
+	schemaRepo := stores.EnrichmentRules
 	return schemaRepo.GetProfileEnrichmentRules()
 }
 
 func GetEnrichmentRulesByFilter(filters []string) ([]models.ProfileEnrichmentRule, error) {
-	mongoDB := locks.GetMongoDBInstance()
-	schemaRepo := repositories.NewProfileSchemaRepository(mongoDB.Database, constants.ProfileSchemaCollection)
+	schemaRepo := stores.EnrichmentRules
 	return schemaRepo.GetEnrichmentRulesByFilter(filters)
 }
 
 func GetEnrichmentRule(ruleId string) (models.ProfileEnrichmentRule, error) {
-	mongoDB := locks.GetMongoDBInstance()
-	schemaRepo := repositories.NewProfileSchemaRepository(mongoDB.Database, constants.ProfileSchemaCollection)
+	schemaRepo := stores.EnrichmentRules
 	return schemaRepo.GetSchemaRule(ruleId)
 }
 
-func PutEnrichmentRule(rule models.ProfileEnrichmentRule) error {
-	mongoDB := locks.GetMongoDBInstance()
-	schemaRepo := repositories.NewProfileSchemaRepository(mongoDB.Database, constants.ProfileSchemaCollection)
+func PutEnrichmentRule(rule models.ProfileEnrichmentRule, author string) error {
+	schemaRepo := stores.EnrichmentRules
 
 	err, isValid := validateEnrichmentRule(rule)
 	if !isValid {
 		return err
 	}
-	return schemaRepo.UpsertEnrichmentRule(rule)
+
+	unlock, err := lockRule(constants.EnrichmentRuleType, rule.RuleId)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	previous, exists := findEnrichmentRule(rule.RuleId)
+	rule.UpdatedAt = time.Now().UTC().Unix()
+	rule.CreatedAt = rule.UpdatedAt
+	if exists {
+		rule.CreatedAt = previous.CreatedAt
+	}
+	if err := schemaRepo.UpsertEnrichmentRule(rule); err != nil {
+		return err
+	}
+	recordRuleVersion(constants.EnrichmentRuleType, rule.RuleId, ruleChange(exists), author,
+		ruleSnapshot(previous, exists), rule, 0)
+	backfillEnrichmentRule(rule)
+	return nil
 }
 
-func DeleteEnrichmentRule(ruleId string) error {
-	mongoDB := locks.GetMongoDBInstance()
-	schemaRepo := repositories.NewProfileSchemaRepository(mongoDB.Database, constants.ProfileSchemaCollection)
-	return schemaRepo.DeleteSchemaRule(ruleId)
+func DeleteEnrichmentRule(ruleId string, author string) error {
+	schemaRepo := stores.EnrichmentRules
+	unlock, err := lockRule(constants.EnrichmentRuleType, ruleId)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	cancelBackfillJobsOfRule(ruleId)
+
+	previous, exists := findEnrichmentRule(ruleId)
+	if err := schemaRepo.DeleteSchemaRule(ruleId); err != nil {
+		return err
+	}
+	if !exists {
+		return nil
+	}
+	recordRuleVersion(constants.EnrichmentRuleType, ruleId, constants.RuleDeleted, author, previous, nil, 0)
+	return nil
 }
 
 // validateEnrichmentRule validates the enrichment rule.
@@ -109,6 +139,14 @@ func validateEnrichmentRule(rule models.ProfileEnrichmentRule) (error, bool) {
 		return clientError, false
 	}
 
+	if rule.PropertyType == "computed" && !constants.AllowedComputations[strings.ToLower(rule.Computation)] {
+		return errors.NewClientError(errors.ErrorMessage{
+			Code:        errors.ErrComputationValidation.Code,
+			Message:     errors.ErrComputationValidation.Message,
+			Description: fmt.Sprintf("Computation '%s' is not supported.", rule.Computation),
+		}, http.StatusBadRequest), false
+	}
+
 	if rule.Computation == "copy" && len(rule.SourceFields) != 1 {
 		return errors.NewClientError(errors.ErrorMessage{
 			Code:        errors.ErrSourceFieldValidation.Code,
@@ -117,33 +155,82 @@ func validateEnrichmentRule(rule models.ProfileEnrichmentRule) (error, bool) {
 		}, http.StatusBadRequest), false
 	}
 
-	//  Validate Trigger
-	if rule.Trigger.EventType == "" || rule.Trigger.EventName == "" {
+	if strings.ToLower(rule.Computation) == "expression" {
+		if strings.TrimSpace(rule.Expression) == "" {
+			return errors.NewClientError(errors.ErrorMessage{
+				Code:        errors.ErrInvalidExpression.Code,
+				Message:     errors.ErrInvalidExpression.Message,
+				Description: "For expression computation, 'expression' must be provided.",
+			}, http.StatusBadRequest), false
+		}
+		if _, err := CompileExpression(rule.Expression); err != nil {
+			return errors.NewClientError(errors.ErrorMessage{
+				Code:        errors.ErrInvalidExpression.Code,
+				Message:     errors.ErrInvalidExpression.Message,
+				Description: err.Error(),
+			}, http.StatusBadRequest), false
+		}
+	}
+
+	for _, field := range rule.SourceFields {
+		if _, err := parseFieldPath(field); err != nil {
+			return errors.NewClientError(errors.ErrorMessage{
+				Code:        errors.ErrSourceFieldValidation.Code,
+				Message:     errors.ErrSourceFieldValidation.Message,
+				Description: err.Error(),
+			}, http.StatusBadRequest), false
+		}
+	}
+
+	//  Aggregate computations aggregate a single source field
+	if constants.AggregateComputations[strings.ToLower(rule.Computation)] && len(rule.SourceFields) != 1 {
 		return errors.NewClientError(errors.ErrorMessage{
-			Code:        errors.ErrTriggerValidation.Code,
-			Message:     errors.ErrTriggerValidation.Message,
-			Description: "Both 'event_type' and 'event_name' must be provided inside trigger",
+			Code:        errors.ErrSourceFieldValidation.Code,
+			Message:     errors.ErrSourceFieldValidation.Message,
+			Description: fmt.Sprintf("For %s computation, exactly one source field must be provided.", rule.Computation),
 		}, http.StatusBadRequest), false
 	}
 
-	//  Validate Trigger Conditions
-	for _, cond := range rule.Trigger.Conditions {
-		if cond.Field == "" || cond.Operator == "" {
+	//  Decay scores weigh the events by at most one source field and need a half life
+	if strings.ToLower(rule.Computation) == "decay" {
+		if len(rule.SourceFields) > 1 {
 			return errors.NewClientError(errors.ErrorMessage{
-				Code:        errors.ErrTriggerValidation.Code,
-				Message:     errors.ErrTriggerValidation.Message,
-				Description: "Each condition must have a field and operator defined.",
+				Code:        errors.ErrSourceFieldValidation.Code,
+				Message:     errors.ErrSourceFieldValidation.Message,
+				Description: "For decay computation, at most one source field can be provided as the weight.",
 			}, http.StatusBadRequest), false
 		}
-		if !constants.AllowedConditionOperators[strings.ToLower(cond.Operator)] {
+		if _, err := parseTimeWindow(rule.HalfLife); err != nil {
 			return errors.NewClientError(errors.ErrorMessage{
-				Code:        errors.ErrConditionOpValidation.Code,
-				Message:     errors.ErrConditionOpValidation.Message,
-				Description: fmt.Sprintf("Operator '%s' is not supported.", cond.Operator),
+				Code:        errors.ErrInvalidTimeWindow.Code,
+				Message:     errors.ErrInvalidTimeWindow.Message,
+				Description: fmt.Sprintf("For decay computation, a valid half_life must be provided: %v", err),
 			}, http.StatusBadRequest), false
 		}
 	}
 
+	if rule.TimeRange != "" {
+		if _, err := parseRuleTimeRange(rule.TimeRange); err != nil {
+			return errors.NewClientError(errors.ErrorMessage{
+				Code:        errors.ErrInvalidTimeWindow.Code,
+				Message:     errors.ErrInvalidTimeWindow.Message,
+				Description: fmt.Sprintf("Invalid time_range: %v", err),
+			}, http.StatusBadRequest), false
+		}
+	}
+
+	//  Validate Trigger
+	if err := validateTrigger(rule.Trigger); err != nil {
+		return err, false
+	}
+
+	//  Validate Trigger Conditions
+	for _, cond := range rule.Trigger.Conditions {
+		if err := validateCondition(cond, 1); err != nil {
+			return err, false
+		}
+	}
+
 	// Validate Merge Strategy
 	if rule.MergeStrategy != "" && !constants.AllowedMergeStrategies[strings.ToLower(rule.MergeStrategy)] {
 		return errors.NewClientError(errors.ErrorMessage{
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wso2/identity-customer-data-service/config"
+	"github.com/wso2/identity-customer-data-service/pkg/logger"
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+)
+
+var eventQueueConfig = withEventQueueDefaults(config.EventQueueConfig{})
+
+// withEventQueueDefaults fills in the queue settings that are not configured
+func withEventQueueDefaults(queueConfig config.EventQueueConfig) config.EventQueueConfig {
+	if queueConfig.Concurrency <= 0 {
+		queueConfig.Concurrency = 4
+	}
+	if queueConfig.MaxAttempts <= 0 {
+		queueConfig.MaxAttempts = 5
+	}
+	if queueConfig.PollIntervalMs <= 0 {
+		queueConfig.PollIntervalMs = 500
+	}
+	if queueConfig.BatchSize <= 0 {
+		queueConfig.BatchSize = 50
+	}
+	if queueConfig.LeaseSeconds <= 0 {
+		queueConfig.LeaseSeconds = 60
+	}
+	if queueConfig.RetryBackoffMs <= 0 {
+		queueConfig.RetryBackoffMs = 1000
+	}
+	if queueConfig.MaxRetryBackoffMs <= 0 {
+		queueConfig.MaxRetryBackoffMs = 5 * 60 * 1000
+	}
+	return queueConfig
+}
+
+// EnqueueEventForProcessing adds the event to the durable queue for enrichment and unification
+func EnqueueEventForProcessing(event models.Event) error {
+	return EnqueueEventsForProcessing([]models.Event{event})
+}
+
+// EnqueueEventsForProcessing adds the events to the durable queue, preserving their order
+func EnqueueEventsForProcessing(events []models.Event) error {
+	now := time.Now()
+	queued := make([]models.QueuedEvent, 0, len(events))
+	for i, event := range events {
+		queued = append(queued, models.QueuedEvent{
+			QueueId:     uuid.New().String(),
+			Event:       event,
+			EnqueuedAt:  now.UnixNano() + int64(i),
+			AvailableAt: now.UnixMilli(),
+		})
+	}
+	if err := stores.EventQueue.Enqueue(queued); err != nil {
+		return fmt.Errorf("failed to enqueue events for processing: %v", err)
+	}
+	return nil
+}
+
+// handleQueuedEvent processes a claimed event and acknowledges it on success. Failed events are retried with
+// exponential backoff until they run out of attempts, after which they are moved to the dead-letter store. The
+// processing error, if any, is returned.
+func handleQueuedEvent(queuedEvent models.QueuedEvent, process func(event models.Event) error) error {
+	queue := stores.EventQueue
+
+	err := process(queuedEvent.Event)
+	if err == nil {
+		if err := queue.Ack(queuedEvent); err != nil {
+			logger.Error(err, fmt.Sprintf("Failed to acknowledge event %s", queuedEvent.Event.EventId))
+		}
+		return nil
+	}
+
+	if queuedEvent.Attempts >= eventQueueConfig.MaxAttempts {
+		logger.Error(err, fmt.Sprintf("Moving event %s to the dead-letter store after %d attempts",
+			queuedEvent.Event.EventId, queuedEvent.Attempts))
+		if err := queue.DeadLetter(queuedEvent, err.Error()); err != nil {
+			logger.Error(err, fmt.Sprintf("Failed to dead-letter event %s", queuedEvent.Event.EventId))
+		}
+		return err
+	}
+
+	backoff := retryBackoff(queuedEvent.Attempts)
+	logger.Error(err, fmt.Sprintf("Failed to process event %s on attempt %d, retrying in %s",
+		queuedEvent.Event.EventId, queuedEvent.Attempts, backoff))
+	if err := queue.Release(queuedEvent, time.Now().Add(backoff).UnixMilli(), err.Error()); err != nil {
+		logger.Error(err, fmt.Sprintf("Failed to release event %s", queuedEvent.Event.EventId))
+	}
+	return err
+}
+
+// retryBackoff returns the delay before retrying an event that failed `attempts` times. The delay doubles on each
+// attempt up to the configured maximum.
+func retryBackoff(attempts int) time.Duration {
+	backoff := time.Duration(eventQueueConfig.RetryBackoffMs) * time.Millisecond
+	maxBackoff := time.Duration(eventQueueConfig.MaxRetryBackoffMs) * time.Millisecond
+	for i := 1; i < attempts && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	return backoff
+}
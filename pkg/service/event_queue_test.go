@@ -0,0 +1,225 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wso2/identity-customer-data-service/config"
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+)
+
+// setEventQueueConfig overrides the queue settings for the duration of the test
+func setEventQueueConfig(t *testing.T, queueConfig config.EventQueueConfig) {
+	t.Helper()
+	previous := eventQueueConfig
+	eventQueueConfig = withEventQueueDefaults(queueConfig)
+	t.Cleanup(func() { eventQueueConfig = previous })
+}
+
+func enqueueEvents(t *testing.T, events ...models.Event) {
+	t.Helper()
+	if err := EnqueueEventsForProcessing(events); err != nil {
+		t.Fatalf("failed to enqueue events: %v", err)
+	}
+}
+
+func claimEvents(t *testing.T) []models.QueuedEvent {
+	t.Helper()
+	claimed, err := stores.EventQueue.Claim("worker", 10, time.Minute)
+	if err != nil {
+		t.Fatalf("failed to claim events: %v", err)
+	}
+	return claimed
+}
+
+// processQueuedEvents processes the queued events with the profile worker's processor until the queue has no events
+// left to claim
+func processQueuedEvents(t *testing.T) {
+	t.Helper()
+	for claimed := claimEvents(t); len(claimed) > 0; claimed = claimEvents(t) {
+		for _, queuedEvent := range claimed {
+			if err := handleQueuedEvent(queuedEvent, processEvent); err != nil {
+				t.Fatalf("failed to process event %s: %v", queuedEvent.Event.EventId, err)
+			}
+		}
+	}
+}
+
+// recordingProcessor records the ids of the events it processes and fails the ones in `failing`
+type recordingProcessor struct {
+	mutex     sync.Mutex
+	processed []string
+	failing   map[string]bool
+}
+
+func (p *recordingProcessor) process(event models.Event) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.processed = append(p.processed, event.EventId)
+	if p.failing[event.EventId] {
+		return errors.New("processing failed")
+	}
+	return nil
+}
+
+func (p *recordingProcessor) processedEvents() []string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return append([]string(nil), p.processed...)
+}
+
+func TestFailedEventHoldsBackLaterEventsOfTheProfile(t *testing.T) {
+	setupMemoryStores(t)
+	setEventQueueConfig(t, config.EventQueueConfig{RetryBackoffMs: 50})
+
+	enqueueEvents(t,
+		models.Event{EventId: "a1", ProfileId: "a"},
+		models.Event{EventId: "a2", ProfileId: "a"},
+		models.Event{EventId: "b1", ProfileId: "b"},
+	)
+	processor := &recordingProcessor{failing: map[string]bool{"a1": true}}
+	pool := newWorkerPool(2, 10, processor.process)
+
+	pool.dispatch(claimEvents(t))
+	pool.drain()
+	if got := processor.processedEvents(); len(got) != 2 || slices.Contains(got, "a2") {
+		t.Fatalf("processed %v, want a1 and b1 only", got)
+	}
+
+	// The retry of a1 is pending, so a2 must not be claimed ahead of it
+	if claimed := claimEvents(t); len(claimed) != 0 {
+		t.Fatalf("claimed %d events while the retry of a1 is pending", len(claimed))
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	processor.failing = nil
+	for _, want := range []struct {
+		eventId  string
+		attempts int
+	}{{eventId: "a1", attempts: 2}, {eventId: "a2", attempts: 1}} {
+		claimed := claimEvents(t)
+		if len(claimed) != 1 || claimed[0].Event.EventId != want.eventId || claimed[0].Attempts != want.attempts {
+			t.Fatalf("claimed %+v, want %s on attempt %d", claimed, want.eventId, want.attempts)
+		}
+		pool.dispatch(claimed)
+		pool.drain()
+	}
+
+	if depth, _ := stores.EventQueue.Depth(); depth != 0 {
+		t.Errorf("queue depth = %d after processing, want 0", depth)
+	}
+}
+
+func TestClaimLeasesOneEventOfAProfileAtATime(t *testing.T) {
+	setupMemoryStores(t)
+	enqueueEvents(t,
+		models.Event{EventId: "a1", ProfileId: "a"},
+		models.Event{EventId: "a2", ProfileId: "a"},
+		models.Event{EventId: "b1", ProfileId: "b"},
+	)
+
+	first, err := stores.EventQueue.Claim("worker-1", 10, time.Minute)
+	if err != nil || len(first) != 2 || first[0].Event.EventId != "a1" || first[1].Event.EventId != "b1" {
+		t.Fatalf("first worker claimed %+v, %v, want a1 and b1", first, err)
+	}
+	// a2 waits for a1 whichever worker claims it
+	if second, _ := stores.EventQueue.Claim("worker-2", 10, time.Minute); len(second) != 0 {
+		t.Fatalf("second worker claimed %+v while a1 is leased", second)
+	}
+	if err := stores.EventQueue.Ack(first[0]); err != nil {
+		t.Fatalf("failed to acknowledge a1: %v", err)
+	}
+	second, _ := stores.EventQueue.Claim("worker-2", 10, time.Minute)
+	if len(second) != 1 || second[0].Event.EventId != "a2" {
+		t.Fatalf("second worker claimed %+v after a1 was acknowledged, want a2", second)
+	}
+}
+
+func TestSlowEventDoesNotHoldBackOtherPartitions(t *testing.T) {
+	setupMemoryStores(t)
+	enqueueEvents(t, models.Event{EventId: "a1", ProfileId: "a"}, models.Event{EventId: "b1", ProfileId: "b"})
+
+	release := make(chan struct{})
+	processed := make(chan string, 10)
+	pool := newWorkerPool(2, 10, func(event models.Event) error {
+		if event.EventId == "a1" {
+			<-release
+		}
+		processed <- event.EventId
+		return nil
+	})
+	if pool.partitionOf("a") == pool.partitionOf("b") {
+		t.Fatalf("profiles a and b share a partition")
+	}
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		pool.run("worker", 10, time.Minute, 10*time.Millisecond, stop)
+	}()
+
+	waitFor := func(eventId string) {
+		t.Helper()
+		select {
+		case got := <-processed:
+			if got != eventId {
+				t.Fatalf("processed %s, want %s", got, eventId)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%s was not processed", eventId)
+		}
+	}
+	waitFor("b1")
+	// b2 is claimed and processed while a1, claimed along with b1, is still in progress
+	enqueueEvents(t, models.Event{EventId: "b2", ProfileId: "b"})
+	waitFor("b2")
+
+	close(release)
+	waitFor("a1")
+	close(stop)
+	<-stopped
+	if depth, _ := stores.EventQueue.Depth(); depth != 0 {
+		t.Errorf("queue depth = %d after the pool stopped, want 0", depth)
+	}
+}
+
+func TestEventIsDeadLetteredAfterMaxAttempts(t *testing.T) {
+	setupMemoryStores(t)
+	setEventQueueConfig(t, config.EventQueueConfig{MaxAttempts: 3, RetryBackoffMs: 1, MaxRetryBackoffMs: 1})
+
+	enqueueEvents(t, models.Event{EventId: "a1", ProfileId: "a"}, models.Event{EventId: "a2", ProfileId: "a"})
+	processor := &recordingProcessor{failing: map[string]bool{"a1": true}}
+
+	for i := 0; i < eventQueueConfig.MaxAttempts; i++ {
+		time.Sleep(5 * time.Millisecond)
+		claimed := claimEvents(t)
+		if len(claimed) != 1 || claimed[0].Event.EventId != "a1" {
+			t.Fatalf("attempt %d claimed %v, want a1 only", i+1, claimed)
+		}
+		if err := handleQueuedEvent(claimed[0], processor.process); err == nil {
+			t.Fatalf("attempt %d of a1 succeeded", i+1)
+		}
+	}
+
+	deadLetters, err := stores.EventQueue.GetDeadLetterEvents()
+	if err != nil {
+		t.Fatalf("failed to fetch dead-letter events: %v", err)
+	}
+	if len(deadLetters) != 1 || deadLetters[0].Event.EventId != "a1" || deadLetters[0].Attempts != 3 {
+		t.Fatalf("dead letters = %+v, want a1 after 3 attempts", deadLetters)
+	}
+	if deadLetters[0].LastError != "processing failed" {
+		t.Errorf("last error = %q, want the processing error", deadLetters[0].LastError)
+	}
+
+	// Once a1 is dead-lettered, the events behind it are claimed again with their attempts intact
+	claimed := claimEvents(t)
+	if len(claimed) != 1 || claimed[0].Event.EventId != "a2" || claimed[0].Attempts != 1 {
+		t.Fatalf("claimed %+v after a1 was dead-lettered, want a2 on its first attempt", claimed)
+	}
+}
@@ -0,0 +1,322 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wso2/identity-customer-data-service/pkg/constants"
+	"github.com/wso2/identity-customer-data-service/pkg/errors"
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+)
+
+var eventSchemaEnforcement = constants.EventSchemaEnforcementReject
+
+// patchableEventSchemaFields are the fields of an event schema that can be updated
+var patchableEventSchemaFields = map[string]bool{
+	"event_type": true,
+	"event_name": true,
+	"properties": true,
+}
+
+// SetEventSchemaEnforcement sets how events that do not conform to their schema are handled
+func SetEventSchemaEnforcement(enforcement string) {
+	if strings.ToLower(enforcement) == constants.EventSchemaEnforcementQuarantine {
+		eventSchemaEnforcement = constants.EventSchemaEnforcementQuarantine
+	} else {
+		eventSchemaEnforcement = constants.EventSchemaEnforcementReject
+	}
+}
+
+// AddEventSchema validates and stores a new event schema. There can be one schema per event type and name.
+func AddEventSchema(schema models.EventSchema) (models.EventSchema, error) {
+	schema.EventSchemaId = uuid.New().String()
+	schema.EventType = strings.ToLower(schema.EventType)
+	schema.EventName = strings.ToLower(schema.EventName)
+
+	if err := validateEventSchema(schema); err != nil {
+		return models.EventSchema{}, err
+	}
+	if err := ensureEventSchemaIsUnique(schema); err != nil {
+		return models.EventSchema{}, err
+	}
+	if err := stores.EventSchemas.AddEventSchema(schema); err != nil {
+		return models.EventSchema{}, errors.NewServerError(errors.ErrWhileAddingEventSchema, err)
+	}
+	return schema, nil
+}
+
+// GetEventSchemas retrieves all event schemas
+func GetEventSchemas() ([]models.EventSchema, error) {
+	schemas, err := stores.EventSchemas.GetAllEventSchemas()
+	if err != nil {
+		return nil, errors.NewServerError(errors.ErrWhileFetchingEventSchemas, err)
+	}
+	return schemas, nil
+}
+
+// GetEventSchema retrieves a specific event schema
+func GetEventSchema(schemaId string) (models.EventSchema, error) {
+	schema, err := stores.EventSchemas.GetById(schemaId)
+	if err != nil {
+		return models.EventSchema{}, errors.NewServerError(errors.ErrWhileFetchingEventSchemas, err)
+	}
+	if schema == nil {
+		return models.EventSchema{}, errors.NewClientError(errors.ErrEventSchemaNotFound, http.StatusNotFound)
+	}
+	return *schema, nil
+}
+
+// PatchEventSchema updates the event type, event name or properties of an event schema
+func PatchEventSchema(schemaId string, updates map[string]interface{}) (models.EventSchema, error) {
+	for field := range updates {
+		if !patchableEventSchemaFields[field] {
+			return models.EventSchema{}, errors.NewClientError(errors.ErrorMessage{
+				Code:        errors.ErrInvalidEventSchema.Code,
+				Message:     errors.ErrInvalidEventSchema.Message,
+				Description: "Only event_type, event_name and properties can be updated.",
+			}, http.StatusBadRequest)
+		}
+	}
+
+	existing, err := GetEventSchema(schemaId)
+	if err != nil {
+		return models.EventSchema{}, err
+	}
+
+	// Apply the updates on the existing schema so that the result is validated as a whole
+	patched := existing
+	data, err := json.Marshal(updates)
+	if err != nil {
+		return models.EventSchema{}, errors.NewServerError(errors.ErrWhileUpdatingEventSchema, err)
+	}
+	if err := json.Unmarshal(data, &patched); err != nil {
+		return models.EventSchema{}, errors.NewClientError(errors.ErrorMessage{
+			Code:        errors.ErrBadRequest.Code,
+			Message:     errors.ErrBadRequest.Message,
+			Description: err.Error(),
+		}, http.StatusBadRequest)
+	}
+	patched.EventSchemaId = schemaId
+	patched.EventType = strings.ToLower(patched.EventType)
+	patched.EventName = strings.ToLower(patched.EventName)
+
+	if err := validateEventSchema(patched); err != nil {
+		return models.EventSchema{}, err
+	}
+	if patched.EventType != existing.EventType || patched.EventName != existing.EventName {
+		if err := ensureEventSchemaIsUnique(patched); err != nil {
+			return models.EventSchema{}, err
+		}
+	}
+
+	err = stores.EventSchemas.Patch(schemaId, map[string]interface{}{
+		"event_type": patched.EventType,
+		"event_name": patched.EventName,
+		"properties": patched.Properties,
+	})
+	if err != nil {
+		return models.EventSchema{}, errors.NewServerError(errors.ErrWhileUpdatingEventSchema, err)
+	}
+	return patched, nil
+}
+
+// DeleteEventSchema removes an event schema. Events of its type and name are no longer validated afterwards.
+func DeleteEventSchema(schemaId string) error {
+	if _, err := GetEventSchema(schemaId); err != nil {
+		return err
+	}
+	if err := stores.EventSchemas.Delete(schemaId); err != nil {
+		return errors.NewServerError(errors.ErrWhileDeletingEventSchema, err)
+	}
+	return nil
+}
+
+// validateEventSchema checks that the schema identifies a supported event and declares typed properties
+func validateEventSchema(schema models.EventSchema) error {
+	if schema.EventType == "" || schema.EventName == "" {
+		return errors.NewClientError(errors.ErrorMessage{
+			Code:        errors.ErrInvalidEventSchema.Code,
+			Message:     errors.ErrInvalidEventSchema.Message,
+			Description: "Both 'event_type' and 'event_name' must be provided.",
+		}, http.StatusBadRequest)
+	}
+	if !constants.AllowedEventTypes[schema.EventType] {
+		return errors.NewClientError(errors.ErrorMessage{
+			Code:        errors.ErrInvalidEventSchema.Code,
+			Message:     errors.ErrInvalidEventSchema.Message,
+			Description: fmt.Sprintf("Event type '%s' is not supported.", schema.EventType),
+		}, http.StatusBadRequest)
+	}
+	if len(schema.Properties) == 0 {
+		return errors.NewClientError(errors.ErrNoEventProps, http.StatusBadRequest)
+	}
+
+	seen := map[string]bool{}
+	for _, property := range schema.Properties {
+		if property.PropertyName == "" || property.PropertyType == "" {
+			return errors.NewClientError(errors.ErrorMessage{
+				Code:        errors.ErrNoEventPropValue.Code,
+				Message:     errors.ErrNoEventPropValue.Message,
+				Description: fmt.Sprintf(errors.ErrNoEventPropValue.Description, property.PropertyName),
+			}, http.StatusBadRequest)
+		}
+		if _, err := parseFieldPath(property.PropertyName); err != nil {
+			return errors.NewClientError(errors.ErrorMessage{
+				Code:        errors.ErrInvalidEventSchema.Code,
+				Message:     errors.ErrInvalidEventSchema.Message,
+				Description: err.Error(),
+			}, http.StatusBadRequest)
+		}
+		if !isAllowedPropertyType(property.PropertyType) {
+			return errors.NewClientError(errors.ErrImproperProperty, http.StatusBadRequest)
+		}
+		if seen[property.PropertyName] {
+			return errors.NewClientError(errors.ErrorMessage{
+				Code:        errors.ErrInvalidEventSchema.Code,
+				Message:     errors.ErrInvalidEventSchema.Message,
+				Description: fmt.Sprintf("Property '%s' is declared more than once.", property.PropertyName),
+			}, http.StatusBadRequest)
+		}
+		seen[property.PropertyName] = true
+	}
+	return nil
+}
+
+// ensureEventSchemaIsUnique checks that no other schema is defined for the event type and name of the schema
+func ensureEventSchemaIsUnique(schema models.EventSchema) error {
+	existing, err := stores.EventSchemas.GetByEvent(schema.EventType, schema.EventName)
+	if err != nil {
+		return errors.NewServerError(errors.ErrWhileFetchingEventSchemas, err)
+	}
+	if existing != nil && existing.EventSchemaId != schema.EventSchemaId {
+		return errors.NewClientError(errors.ErrorMessage{
+			Code:        errors.ErrEventSchemaAlreadyExists.Code,
+			Message:     errors.ErrEventSchemaAlreadyExists.Message,
+			Description: fmt.Sprintf(errors.ErrEventSchemaAlreadyExists.Description, schema.EventType, schema.EventName),
+		}, http.StatusConflict)
+	}
+	return nil
+}
+
+func isAllowedPropertyType(propertyType string) bool {
+	for allowed := range constants.AllowedPropertyTypes {
+		if strings.EqualFold(allowed, propertyType) {
+			return true
+		}
+	}
+	return false
+}
+
+// eventSchemaValidator checks events against the schema of their event type and name. Schemas are looked up once
+// per event type and name.
+type eventSchemaValidator struct {
+	schemas map[string]*models.EventSchema
+}
+
+func newEventSchemaValidator() *eventSchemaValidator {
+	return &eventSchemaValidator{schemas: map[string]*models.EventSchema{}}
+}
+
+// violation describes why the event does not conform to its schema. An empty string is returned if it conforms or
+// if no schema is defined for the event.
+func (validator *eventSchemaValidator) violation(event models.Event) (string, error) {
+	key := event.EventType + ":" + event.EventName
+	schema, cached := validator.schemas[key]
+	if !cached {
+		var err error
+		schema, err = stores.EventSchemas.GetByEvent(event.EventType, event.EventName)
+		if err != nil {
+			return "", errors.NewServerError(errors.ErrWhileFetchingEventSchemas, err)
+		}
+		validator.schemas[key] = schema
+	}
+	if schema == nil {
+		return "", nil
+	}
+
+	var violations []string
+	for _, property := range schema.Properties {
+		value := FieldPathValue(event.Properties, property.PropertyName)
+		if value == nil {
+			if property.Required {
+				violations = append(violations, fmt.Sprintf("property '%s' is required", property.PropertyName))
+			}
+			continue
+		}
+		if !conformsToPropertyType(value, property.PropertyType) {
+			violations = append(violations, fmt.Sprintf("property '%s' must be of type %s", property.PropertyName,
+				property.PropertyType))
+		}
+	}
+	return strings.Join(violations, "; "), nil
+}
+
+// conformsToPropertyType checks a decoded JSON value against an event schema property type
+func conformsToPropertyType(value interface{}, propertyType string) bool {
+	switch strings.ToLower(propertyType) {
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "int":
+		return isInteger(value)
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "date":
+		if str, ok := value.(string); ok {
+			for _, layout := range []string{time.RFC3339, time.DateOnly} {
+				if _, err := time.Parse(layout, str); err == nil {
+					return true
+				}
+			}
+			return false
+		}
+		// Unix timestamps are accepted as dates as well
+		return isInteger(value)
+	case "arrayofstring", "arrayofint":
+		items, ok := value.([]interface{})
+		if !ok {
+			return false
+		}
+		itemType := strings.TrimPrefix(strings.ToLower(propertyType), "arrayof")
+		for _, item := range items {
+			if !conformsToPropertyType(item, itemType) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+func isInteger(value interface{}) bool {
+	switch v := value.(type) {
+	case int, int32, int64:
+		return true
+	case float64:
+		return v == math.Trunc(v)
+	default:
+		return false
+	}
+}
+
+// quarantineEvent sets an event that does not conform to its schema aside in the dead-letter store without
+// storing or processing it
+func quarantineEvent(event models.Event, violation string) error {
+	quarantined := models.QueuedEvent{
+		QueueId:    uuid.New().String(),
+		Event:      event,
+		EnqueuedAt: time.Now().UnixNano(),
+	}
+	reason := fmt.Sprintf("%s: %s", errors.ErrEventSchemaViolation.Code, violation)
+	if err := stores.EventQueue.DeadLetter(quarantined, reason); err != nil {
+		return errors.NewServerError(errors.ErrWhileQuarantiningEvents, err)
+	}
+	return nil
+}
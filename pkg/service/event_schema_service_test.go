@@ -0,0 +1,304 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/wso2/identity-customer-data-service/pkg/constants"
+	"github.com/wso2/identity-customer-data-service/pkg/errors"
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+	repositories "github.com/wso2/identity-customer-data-service/pkg/repository"
+)
+
+// failingDeadLetterQueue fails to set events aside in the dead-letter store
+type failingDeadLetterQueue struct {
+	repositories.EventQueueStore
+}
+
+func (queue *failingDeadLetterQueue) DeadLetter(models.QueuedEvent, string) error {
+	return fmt.Errorf("dead-letter store unavailable")
+}
+
+// setEventSchemaEnforcement overrides the schema enforcement for the duration of the test
+func setEventSchemaEnforcement(t *testing.T, enforcement string) {
+	t.Helper()
+	previous := eventSchemaEnforcement
+	SetEventSchemaEnforcement(enforcement)
+	t.Cleanup(func() { eventSchemaEnforcement = previous })
+}
+
+// clientErrorCode returns the code of a client error, or an empty string for any other error
+func clientErrorCode(err error) string {
+	if clientError, ok := err.(*errors.ClientError); ok {
+		return clientError.Code
+	}
+	return ""
+}
+
+// addSignupSchema adds a schema for signup events with a required string email and an optional int age
+func addSignupSchema(t *testing.T) models.EventSchema {
+	t.Helper()
+	schema, err := AddEventSchema(models.EventSchema{
+		EventType: "Track",
+		EventName: "Signup",
+		Properties: []models.EventProperty{
+			{PropertyName: "email", PropertyType: "string", Required: true},
+			{PropertyName: "age", PropertyType: "int"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to add the signup schema: %v", err)
+	}
+	return schema
+}
+
+func signupEventWith(eventId string, properties map[string]interface{}) models.Event {
+	event := signupEvent(eventId)
+	event.Properties = properties
+	return event
+}
+
+func TestAddEventSchemaValidatesTheSchema(t *testing.T) {
+	property := func(name, propertyType string) []models.EventProperty {
+		return []models.EventProperty{{PropertyName: name, PropertyType: propertyType}}
+	}
+	for _, test := range []struct {
+		name   string
+		schema models.EventSchema
+		code   string
+	}{
+		{name: "no event name", schema: models.EventSchema{EventType: "track", Properties: property("email", "string")},
+			code: errors.ErrInvalidEventSchema.Code},
+		{name: "unsupported event type", schema: models.EventSchema{EventType: "click", EventName: "signup",
+			Properties: property("email", "string")}, code: errors.ErrInvalidEventSchema.Code},
+		{name: "no properties", schema: models.EventSchema{EventType: "track", EventName: "signup"},
+			code: errors.ErrNoEventProps.Code},
+		{name: "untyped property", schema: models.EventSchema{EventType: "track", EventName: "signup",
+			Properties: property("email", "")}, code: errors.ErrNoEventPropValue.Code},
+		{name: "invalid property path", schema: models.EventSchema{EventType: "track", EventName: "signup",
+			Properties: property("items[", "string")}, code: errors.ErrInvalidEventSchema.Code},
+		{name: "unsupported property type", schema: models.EventSchema{EventType: "track", EventName: "signup",
+			Properties: property("email", "uuid")}, code: errors.ErrImproperProperty.Code},
+		{name: "repeated property", schema: models.EventSchema{EventType: "track", EventName: "signup",
+			Properties: append(property("email", "string"), property("email", "int")...)},
+			code: errors.ErrInvalidEventSchema.Code},
+		{name: "second schema of an event", schema: models.EventSchema{EventType: "TRACK", EventName: "signup",
+			Properties: property("plan", "string")}, code: errors.ErrEventSchemaAlreadyExists.Code},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			setupMemoryStores(t)
+			addSignupSchema(t)
+			if _, err := AddEventSchema(test.schema); clientErrorCode(err) != test.code {
+				t.Errorf("error = %v, want code %s", err, test.code)
+			}
+		})
+	}
+}
+
+func TestPatchEventSchema(t *testing.T) {
+	setupMemoryStores(t)
+	signup := addSignupSchema(t)
+	login, err := AddEventSchema(models.EventSchema{EventType: "track", EventName: "login",
+		Properties: []models.EventProperty{{PropertyName: "method", PropertyType: "string"}}})
+	if err != nil {
+		t.Fatalf("failed to add the login schema: %v", err)
+	}
+
+	for _, test := range []struct {
+		name     string
+		schemaId string
+		updates  map[string]interface{}
+		code     string
+	}{
+		{name: "id", schemaId: login.EventSchemaId, updates: map[string]interface{}{"event_schema_id": "other"},
+			code: errors.ErrInvalidEventSchema.Code},
+		{name: "event of another schema", schemaId: login.EventSchemaId,
+			updates: map[string]interface{}{"event_name": "SIGNUP"}, code: errors.ErrEventSchemaAlreadyExists.Code},
+		{name: "no properties", schemaId: login.EventSchemaId, updates: map[string]interface{}{"properties": nil},
+			code: errors.ErrNoEventProps.Code},
+		{name: "missing schema", schemaId: "missing", updates: map[string]interface{}{"event_name": "logout"},
+			code: errors.ErrEventSchemaNotFound.Code},
+	} {
+		if _, err := PatchEventSchema(test.schemaId, test.updates); clientErrorCode(err) != test.code {
+			t.Errorf("patching the %s = %v, want code %s", test.name, err, test.code)
+		}
+	}
+
+	patched, err := PatchEventSchema(signup.EventSchemaId, map[string]interface{}{
+		"properties": []map[string]interface{}{{"property_name": "plan", "property_type": "string", "required": true}},
+	})
+	if err != nil {
+		t.Fatalf("failed to patch the properties: %v", err)
+	}
+	stored, err := GetEventSchema(signup.EventSchemaId)
+	if err != nil || len(stored.Properties) != 1 || stored.Properties[0].PropertyName != "plan" ||
+		stored.EventName != patched.EventName {
+		t.Errorf("stored schema = %+v, %v, want the patched properties", stored, err)
+	}
+}
+
+func TestEventsAreValidatedAgainstTheirSchema(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		properties []models.EventProperty
+		values     map[string]interface{}
+		violation  string
+	}{
+		{name: "string", properties: []models.EventProperty{{PropertyName: "email", PropertyType: "string"}},
+			values: map[string]interface{}{"email": "ann@example.com"}},
+		{name: "not a string", properties: []models.EventProperty{{PropertyName: "email", PropertyType: "string"}},
+			values: map[string]interface{}{"email": 1.0}, violation: "property 'email' must be of type string"},
+		{name: "int", properties: []models.EventProperty{{PropertyName: "age", PropertyType: "int"}},
+			values: map[string]interface{}{"age": 42.0}},
+		{name: "fraction for int", properties: []models.EventProperty{{PropertyName: "age", PropertyType: "int"}},
+			values: map[string]interface{}{"age": 4.2}, violation: "property 'age' must be of type int"},
+		{name: "boolean", properties: []models.EventProperty{{PropertyName: "vip", PropertyType: "boolean"}},
+			values: map[string]interface{}{"vip": true}},
+		{name: "date", properties: []models.EventProperty{{PropertyName: "born", PropertyType: "date"}},
+			values: map[string]interface{}{"born": "1990-04-01"}},
+		{name: "timestamp date", properties: []models.EventProperty{{PropertyName: "born", PropertyType: "date"}},
+			values: map[string]interface{}{"born": 638928000.0}},
+		{name: "invalid date", properties: []models.EventProperty{{PropertyName: "born", PropertyType: "date"}},
+			values: map[string]interface{}{"born": "01/04/1990"}, violation: "property 'born' must be of type date"},
+		{name: "array of strings", properties: []models.EventProperty{{PropertyName: "tags",
+			PropertyType: "arrayOfString"}}, values: map[string]interface{}{"tags": []interface{}{"a", "b"}}},
+		{name: "mixed array", properties: []models.EventProperty{{PropertyName: "ids", PropertyType: "arrayOfInt"}},
+			values:    map[string]interface{}{"ids": []interface{}{1.0, "2"}},
+			violation: "property 'ids' must be of type arrayOfInt"},
+		{name: "nested property", properties: []models.EventProperty{{PropertyName: "address.city",
+			PropertyType: "string", Required: true}},
+			values: map[string]interface{}{"address": map[string]interface{}{"city": "Colombo"}}},
+		{name: "missing required", properties: []models.EventProperty{{PropertyName: "email", PropertyType: "string",
+			Required: true}}, violation: "property 'email' is required"},
+		{name: "missing optional", properties: []models.EventProperty{{PropertyName: "email",
+			PropertyType: "string"}}},
+		{name: "every violation", properties: []models.EventProperty{
+			{PropertyName: "email", PropertyType: "string", Required: true},
+			{PropertyName: "age", PropertyType: "int"},
+		}, values: map[string]interface{}{"age": "old"},
+			violation: "property 'email' is required; property 'age' must be of type int"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			setupMemoryStores(t)
+			setEventSchemaEnforcement(t, constants.EventSchemaEnforcementReject)
+			if _, err := AddEventSchema(models.EventSchema{EventType: "track", EventName: "signup",
+				Properties: test.properties}); err != nil {
+				t.Fatalf("failed to add the schema: %v", err)
+			}
+
+			_, status, err := AddEvents(signupEventWith("e1", test.values))
+			if test.violation == "" {
+				if err != nil || status != eventAccepted {
+					t.Fatalf("conforming event = (%s, %v), want it accepted", status, err)
+				}
+				return
+			}
+			clientError, ok := err.(*errors.ClientError)
+			if !ok || clientError.Code != errors.ErrEventSchemaViolation.Code ||
+				clientError.StatusCode != http.StatusBadRequest || clientError.Description != test.violation {
+				t.Fatalf("non-conforming event error = %+v, want a bad request describing %q", err, test.violation)
+			}
+			events, _ := stores.Events.FindEventsWithFilter(repositories.EventFilter{ProfileId: "p1"})
+			if len(events) != 0 {
+				t.Errorf("rejected event was stored")
+			}
+		})
+	}
+}
+
+func TestEventsWithoutASchemaAreNotValidated(t *testing.T) {
+	setupMemoryStores(t)
+	setEventSchemaEnforcement(t, constants.EventSchemaEnforcementReject)
+	addSignupSchema(t)
+
+	event := signupEvent("e1")
+	event.EventName = "login"
+	if _, status, err := AddEvents(event); err != nil || status != eventAccepted {
+		t.Errorf("event without a schema = (%s, %v), want it accepted", status, err)
+	}
+}
+
+func TestNonConformingEventsAreQuarantined(t *testing.T) {
+	setupMemoryStores(t)
+	setEventSchemaEnforcement(t, "QUARANTINE")
+	addSignupSchema(t)
+
+	if eventId, status, err := AddEvents(signupEventWith("e1", nil)); err != nil || eventId != "e1" ||
+		status != eventQuarantined {
+		t.Fatalf("non-conforming event = (%s, %s, %v), want e1 quarantined", eventId, status, err)
+	}
+
+	var rawEvents []json.RawMessage
+	for _, event := range []models.Event{
+		signupEventWith("e2", map[string]interface{}{"email": "ann@example.com"}),
+		signupEventWith("e3", map[string]interface{}{"email": "ann@example.com", "age": "old"}),
+		{EventId: "e4", ProfileId: "p1", EventType: "click", EventName: "signup"},
+	} {
+		data, _ := json.Marshal(event)
+		rawEvents = append(rawEvents, data)
+	}
+	response, err := AddEventBatch(rawEvents)
+	if err != nil {
+		t.Fatalf("failed to add the batch: %v", err)
+	}
+	if response.Accepted != 1 || response.Quarantined != 1 || response.Rejected != 1 {
+		t.Errorf("batch accepted %d, quarantined %d and rejected %d, want one of each", response.Accepted,
+			response.Quarantined, response.Rejected)
+	}
+	if result := response.Results[1]; result.Status != eventQuarantined ||
+		result.ErrorCode != errors.ErrEventSchemaViolation.Code {
+		t.Errorf("quarantined result = %+v, want the schema violation reported", result)
+	}
+
+	deadLetters, err := stores.EventQueue.GetDeadLetterEvents()
+	if err != nil {
+		t.Fatalf("failed to fetch the dead-letter events: %v", err)
+	}
+	if len(deadLetters) != 2 || deadLetters[0].Event.EventId != "e1" || deadLetters[1].Event.EventId != "e3" {
+		t.Fatalf("dead letters = %+v, want e1 and e3", deadLetters)
+	}
+	if !strings.HasPrefix(deadLetters[0].LastError, errors.ErrEventSchemaViolation.Code+": ") {
+		t.Errorf("quarantine reason = %q, want the schema violation", deadLetters[0].LastError)
+	}
+	// Only the conforming event is stored and processed
+	if depth := queueDepth(t); depth != 1 {
+		t.Errorf("queue depth = %d, want only e2 queued", depth)
+	}
+}
+
+func TestFailedQuarantineFailsTheEvent(t *testing.T) {
+	setupMemoryStores(t)
+	setEventSchemaEnforcement(t, constants.EventSchemaEnforcementQuarantine)
+	addSignupSchema(t)
+	stores.EventQueue = &failingDeadLetterQueue{EventQueueStore: stores.EventQueue}
+
+	_, _, err := AddEvents(signupEventWith("e1", nil))
+	if serverError, ok := err.(*errors.ServerError); !ok || serverError.Code != errors.ErrWhileQuarantiningEvents.Code {
+		t.Errorf("error = %v, want a quarantine failure", err)
+	}
+	if depth := queueDepth(t); depth != 0 {
+		t.Errorf("queue depth = %d, want the event neither quarantined nor queued", depth)
+	}
+}
+
+func TestDeletedSchemaNoLongerValidatesEvents(t *testing.T) {
+	setupMemoryStores(t)
+	setEventSchemaEnforcement(t, constants.EventSchemaEnforcementReject)
+	schema := addSignupSchema(t)
+
+	if _, _, err := AddEvents(signupEventWith("e1", nil)); err == nil {
+		t.Fatalf("event without the required email was accepted")
+	}
+	if err := DeleteEventSchema(schema.EventSchemaId); err != nil {
+		t.Fatalf("failed to delete the schema: %v", err)
+	}
+	if _, status, err := AddEvents(signupEventWith("e1", nil)); err != nil || status != eventAccepted {
+		t.Errorf("event after the schema was deleted = (%s, %v), want it accepted", status, err)
+	}
+	if err := DeleteEventSchema(schema.EventSchemaId); clientErrorCode(err) != errors.ErrEventSchemaNotFound.Code {
+		t.Errorf("deleting the schema again = %v, want not found", err)
+	}
+}
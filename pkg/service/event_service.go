@@ -1,105 +1,485 @@
 package service
 
 import (
+	"encoding/json"
 	"fmt"
+	"github.com/google/uuid"
 	"github.com/wso2/identity-customer-data-service/pkg/constants"
-	"github.com/wso2/identity-customer-data-service/pkg/locks"
+	"github.com/wso2/identity-customer-data-service/pkg/errors"
+	"github.com/wso2/identity-customer-data-service/pkg/logger"
 	"github.com/wso2/identity-customer-data-service/pkg/models"
 	repositories "github.com/wso2/identity-customer-data-service/pkg/repository"
-	"go.mongodb.org/mongo-driver/bson"
 	"log"
+	"math"
+	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 )
 
-// AddEvents stores a single event in MongoDB
-func AddEvents(event models.Event) error {
+const (
+	eventAccepted    = "accepted"
+	eventRejected    = "rejected"
+	eventQuarantined = "quarantined"
+	eventDuplicate   = "duplicate"
+)
+
+// AddEvents stores a single event and returns its event_id, which is generated if the client did not provide one,
+// along with the status of the event as reported for the events of a batch. An event replayed with an event_id that
+// was already stored for the org is reported as a duplicate without being stored or processed again, and an event
+// violating its schema is reported as quarantined when the schema enforcement quarantines events.
+func AddEvents(event models.Event) (string, string, error) {
+
+	if err := prepareEvent(&event); err != nil {
+		return "", "", errors.NewClientError(errors.ErrorMessage{
+			Code:        errors.ErrInvalidEvent.Code,
+			Message:     errors.ErrInvalidEvent.Message,
+			Description: err.Error(),
+		}, http.StatusBadRequest)
+	}
+
+	// Step 1: Validate the event against the schema of its type and name
+	violation, err := newEventSchemaValidator().violation(event)
+	if err != nil {
+		return "", "", err
+	}
+	if violation != "" {
+		if eventSchemaEnforcement == constants.EventSchemaEnforcementQuarantine {
+			if err := quarantineEvent(event, violation); err != nil {
+				return "", "", err
+			}
+			return event.EventId, eventQuarantined, nil
+		}
+		return "", "", errors.NewClientError(errors.ErrorMessage{
+			Code:        errors.ErrEventSchemaViolation.Code,
+			Message:     errors.ErrEventSchemaViolation.Message,
+			Description: violation,
+		}, http.StatusBadRequest)
+	}
 
-	// Step 1: Ensure profile exists (with lock protection)
-	_, err := CreateOrUpdateProfile(event)
+	// Step 2: Ensure profile exists (with lock protection)
+	_, err = CreateOrUpdateProfile(event)
 	if err != nil {
-		return fmt.Errorf("failed to create or fetch profile: %v", err)
+		return "", "", fmt.Errorf("failed to create or fetch profile: %v", err)
+	}
+
+	// Step 3: Store the event unless it is a replay. A replay of an event that was stored but could not be queued
+	// is queued now.
+	eventRepo := stores.Events
+	added, err := eventRepo.AddEvent(event)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to store event: %v", err)
+	}
+	if !added {
+		logger.Debug("Skipping replayed event " + event.EventId)
+		return event.EventId, eventDuplicate, nil
+	}
+
+	// Step 4: Enqueue the event for enrichment/unification (async)
+	if err := enqueueStoredEvents([]models.Event{event}); err != nil {
+		return "", "", err
+	}
+
+	return event.EventId, eventAccepted, nil
+}
+
+// enqueueStoredEvents queues the stored events for processing and marks them as queued. Events that fail to be
+// queued stay unmarked, so that a replay of them by the client queues them again.
+func enqueueStoredEvents(events []models.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	if err := EnqueueEventsForProcessing(events); err != nil {
+		return errors.NewServerError(errors.ErrWhileQueueingEvents, err)
+	}
+	// The events are queued at this point, so failing to mark them only risks processing a replay twice
+	if err := stores.Events.MarkEventsQueued(events); err != nil {
+		logger.Error(err, "Failed to mark the stored events as queued")
+	}
+	return nil
+}
+
+// AddEventBatch validates and stores a batch of events. Invalid events are rejected (or quarantined, when they only
+// violate their schema) individually while the valid ones are stored in bulk, so that a single bad event does not
+// fail the whole batch.
+func AddEventBatch(rawEvents []json.RawMessage) (*models.EventBatchResponse, error) {
+	return addEventBatch(rawEvents, func(rawEvent json.RawMessage) (models.Event, error) {
+		var event models.Event
+		err := json.Unmarshal(rawEvent, &event)
+		return event, err
+	})
+}
+
+// addEventBatch ingests a batch of raw events that are turned into events by `decode`. Events that cannot be decoded
+// are rejected with the code of the returned client error, or as a bad request for any other error.
+func addEventBatch(rawEvents []json.RawMessage, decode func(rawEvent json.RawMessage) (models.Event, error)) (
+	*models.EventBatchResponse, error) {
+
+	if len(rawEvents) == 0 {
+		return nil, errors.NewClientError(errors.ErrEmptyEventBatch, http.StatusBadRequest)
+	}
+	if len(rawEvents) > constants.MaxEventBatchSize {
+		return nil, errors.NewClientError(errors.ErrorMessage{
+			Code:        errors.ErrEventBatchTooLarge.Code,
+			Message:     errors.ErrEventBatchTooLarge.Message,
+			Description: fmt.Sprintf(errors.ErrEventBatchTooLarge.Description, constants.MaxEventBatchSize),
+		}, http.StatusRequestEntityTooLarge)
+	}
+
+	response := &models.EventBatchResponse{Results: make([]models.EventBatchResult, len(rawEvents))}
+	reject := func(index int, code, message string) {
+		response.Results[index].Status = eventRejected
+		response.Results[index].ErrorCode = code
+		response.Results[index].ErrorMessage = message
+	}
+
+	// Step 1: Decode and validate each event on its own
+	var events []models.Event
+	var indexes []int
+	schemaValidator := newEventSchemaValidator()
+	for i, rawEvent := range rawEvents {
+		response.Results[i].Index = i
+		event, err := decode(rawEvent)
+		if clientError, ok := err.(*errors.ClientError); ok {
+			reject(i, clientError.Code, clientError.Description)
+			continue
+		} else if err != nil {
+			reject(i, errors.ErrBadRequest.Code, err.Error())
+			continue
+		}
+		err = prepareEvent(&event)
+		response.Results[i].EventId = event.EventId
+		if err != nil {
+			reject(i, errors.ErrInvalidEvent.Code, err.Error())
+			continue
+		}
+		violation, err := schemaValidator.violation(event)
+		if err != nil {
+			return nil, err
+		}
+		if violation != "" {
+			reject(i, errors.ErrEventSchemaViolation.Code, violation)
+			if eventSchemaEnforcement == constants.EventSchemaEnforcementQuarantine {
+				if err := quarantineEvent(event, violation); err != nil {
+					return nil, err
+				}
+				response.Results[i].Status = eventQuarantined
+			}
+			continue
+		}
+		events = append(events, event)
+		indexes = append(indexes, i)
+	}
+
+	if len(events) > 0 {
+		// Step 2: Create the profiles that do not exist yet in bulk
+		if err := ensureProfilesExist(events); err != nil {
+			return nil, errors.NewServerError(errors.ErrWhileCreatingProfiles, err)
+		}
+
+		// Step 3: Store the events in bulk, skipping the replayed ones that were already queued
+		added, err := stores.Events.AddEvents(events)
+		if err != nil {
+			return nil, errors.NewServerError(errors.ErrWhileAddingEvent, err)
+		}
+
+		// Step 4: Enqueue the stored events for enrichment/unification (async)
+		if err := enqueueStoredEvents(added); err != nil {
+			return nil, err
+		}
+
+		// The stored events are returned in order, so the events in between them were replays
+		next := 0
+		for i, event := range events {
+			status := eventDuplicate
+			if next < len(added) && added[next].OrgId == event.OrgId && added[next].EventId == event.EventId {
+				status = eventAccepted
+				next++
+			}
+			response.Results[indexes[i]].Status = status
+		}
 	}
 
-	// Step 2: Store the event
-	mongoDB := locks.GetMongoDBInstance()
-	eventRepo := repositories.NewEventRepository(mongoDB.Database, constants.EventCollection)
+	for _, result := range response.Results {
+		switch result.Status {
+		case eventAccepted:
+			response.Accepted++
+		case eventQuarantined:
+			response.Quarantined++
+		case eventDuplicate:
+			response.Duplicates++
+		default:
+			response.Rejected++
+		}
+	}
+	return response, nil
+}
+
+// prepareEvent fills in the defaults of an ingested event and validates it, the same way for every ingestion
+// endpoint. The event_id is generated before validating so that rejected events of a batch can be reported by it.
+func prepareEvent(event *models.Event) error {
+	if event.EventId == "" {
+		event.EventId = uuid.New().String()
+	}
 	event.EventType = strings.ToLower(event.EventType)
 	event.EventName = strings.ToLower(event.EventName)
-	if err := eventRepo.AddEvent(event); err != nil {
-
-		return fmt.Errorf("failed to store event: %v", err)
+	if err := validateEvent(*event); err != nil {
+		return err
 	}
+	if event.EventTimestamp == 0 {
+		event.EventTimestamp = int(time.Now().UTC().Unix())
+	}
+	return nil
+}
 
-	// Step 3: Enqueue the event for enrichment/unification (async)
-	EnqueueEventForProcessing(event)
+// validateEvent checks that the event carries the fields required to attribute and process it
+func validateEvent(event models.Event) error {
+	if event.ProfileId == "" {
+		return fmt.Errorf("profile_id is required")
+	}
+	if event.EventType == "" {
+		return fmt.Errorf("event_type is required")
+	}
+	if !constants.AllowedEventTypes[event.EventType] {
+		return fmt.Errorf("unsupported event_type: %s", event.EventType)
+	}
+	if event.EventName == "" {
+		return fmt.Errorf("event_name is required")
+	}
+	if event.EventTimestamp < 0 {
+		return fmt.Errorf("event_timestamp must not be negative")
+	}
+	return validateAliasEvent(event)
+}
 
+// validateAliasEvent checks that an alias event names the previous id of its profile
+func validateAliasEvent(event models.Event) error {
+	if event.EventType != constants.AliasEventType {
+		return nil
+	}
+	previousId, _ := event.Properties[constants.AliasPreviousIdProperty].(string)
+	if previousId == "" {
+		return fmt.Errorf("properties.%s is required for alias events", constants.AliasPreviousIdProperty)
+	}
+	if previousId == event.ProfileId {
+		return fmt.Errorf("properties.%s must differ from profile_id", constants.AliasPreviousIdProperty)
+	}
 	return nil
 }
 
-// GetEvents retrieves all events
-func GetEvents(filters []string, timeFilter bson.M) ([]models.Event, error) {
-	mongoDB := locks.GetMongoDBInstance()
-	eventRepo := repositories.NewEventRepository(mongoDB.Database, constants.EventCollection)
-	return eventRepo.FindEvents(filters, timeFilter)
+// ensureProfilesExist creates a listable master profile for each profile id of the events that has none yet
+func ensureProfilesExist(events []models.Event) error {
+	profileRepo := stores.Profiles
+
+	seen := map[string]bool{}
+	var missing []models.Profile
+	for _, event := range events {
+		if seen[event.ProfileId] {
+			continue
+		}
+		seen[event.ProfileId] = true
+
+		profile, err := profileRepo.FindProfileByID(event.ProfileId)
+		if err != nil {
+			return fmt.Errorf("failed to fetch profile %s: %v", event.ProfileId, err)
+		}
+		if profile == nil {
+			missing = append(missing, models.Profile{
+				ProfileId: event.ProfileId,
+				ProfileHierarchy: &models.ProfileHierarchy{
+					IsParent:    true,
+					ListProfile: true,
+				},
+			})
+		}
+	}
+	return profileRepo.InsertProfiles(missing)
+}
+
+// GetEvents retrieves all events matching the filters that occurred on or after `fromTimestamp`
+func GetEvents(filters []string, fromTimestamp int64) ([]models.Event, error) {
+	return stores.Events.FindEvents(filters, fromTimestamp)
 }
 
 func GetEvent(eventId string) (*models.Event, error) {
-	mongoDB := locks.GetMongoDBInstance()
-	eventRepo := repositories.NewEventRepository(mongoDB.Database, constants.EventCollection)
-	return eventRepo.FindEvent(eventId)
+	return stores.Events.FindEvent(eventId)
 }
 
 // CountEventsMatchingRule retrieves count of events that has occured in a timerange
 func CountEventsMatchingRule(profileId string, trigger models.RuleTrigger, timeRange string) (int, error) {
-
-	eventRepo := repositories.NewEventRepository(locks.GetMongoDBInstance().Database, constants.EventCollection)
-	durationInSec, err := strconv.Atoi(timeRange) // parse string to int
+	events, err := findEventsMatchingRule(profileId, trigger, timeRange)
 	if err != nil {
-		log.Printf("Invalid time range format: %v", err)
-		//return
+		return 0, fmt.Errorf("failed to fetch events for counting: %v", err)
 	}
+	return len(events), nil
+}
 
-	currentTime := time.Now().UTC().Unix()          // current time in seconds
-	startTime := currentTime - int64(durationInSec) // assuming value is in minutes
-	log.Println("efef", trigger.EventType)
-	log.Println("efef", trigger.EventName)
+// findEventsMatchingRule retrieves the events of the profile that match the trigger in a timerange
+func findEventsMatchingRule(profileId string, trigger models.RuleTrigger, timeRange string) ([]models.Event, error) {
 
-	filter := bson.M{
-		"profile_id": profileId,
-		"event_type": strings.ToLower(trigger.EventType),
-		"event_name": strings.ToLower(trigger.EventName),
-		"event_timestamp": bson.M{
-			"$gte": startTime,
-		},
+	eventRepo := stores.Events
+
+	// Wildcards and lists of event names are matched after fetching
+	filter := repositories.EventFilter{
+		ProfileId:     profileId,
+		EventType:     strings.ToLower(trigger.EventType),
+		EventName:     strings.ToLower(trigger.EventName),
+		FromTimestamp: ruleWindowStart(timeRange),
+	}
+	if filter.EventType == constants.WildcardTrigger {
+		filter.EventType = ""
+	}
+	if filter.EventName == constants.WildcardTrigger || len(trigger.EventNames) > 0 {
+		filter.EventName = ""
 	}
 
 	// Fetch matching events
 	events, err := eventRepo.FindEventsWithFilter(filter)
 	if err != nil {
-		return 0, fmt.Errorf("failed to fetch events for counting: %v", err)
+		return nil, err
+	}
+	var matched []models.Event
+	for _, event := range events {
+		if triggerMatchesEvent(trigger, event) && EvaluateConditions(event, trigger.Conditions) {
+			matched = append(matched, event)
+		}
+	}
+	// Stores do not guarantee an order, first and last are by the time the events occurred
+	sort.SliceStable(matched, func(i, j int) bool {
+		return matched[i].EventTimestamp < matched[j].EventTimestamp
+	})
+	return matched, nil
+}
+
+// ruleWindowStart returns the Unix time from which events count towards a rule with the given time range. Rules
+// without a time range count all the events.
+func ruleWindowStart(timeRange string) int64 {
+	if timeRange == "" {
+		return 0
+	}
+	window, err := parseRuleTimeRange(timeRange)
+	if err != nil {
+		log.Printf("Invalid time range format: %v", err)
+	}
+	currentTime := time.Now().UTC().Unix() // current time in seconds
+	return currentTime - int64(window.Seconds())
+}
+
+// parseRuleTimeRange parses the time range of a rule, given in seconds or as a window such as "30d" or "12h"
+func parseRuleTimeRange(timeRange string) (time.Duration, error) {
+	if durationInSec, err := strconv.Atoi(timeRange); err == nil {
+		if durationInSec <= 0 {
+			return 0, fmt.Errorf("time range must be positive: %s", timeRange)
+		}
+		return time.Duration(durationInSec) * time.Second, nil
+	}
+	return parseTimeWindow(timeRange)
+}
+
+// aggregateEventValues applies an aggregate computation to the values of a field of the events. Numeric aggregates
+// skip values that are not numbers and distinct flattens array values.
+func aggregateEventValues(events []models.Event, computation string, field string) interface{} {
+	var values []interface{}
+	for _, event := range events {
+		if value := GetFieldFromEvent(event, field); value != nil {
+			values = append(values, value)
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	switch computation {
+	case "first":
+		return values[0]
+	case "last":
+		return values[len(values)-1]
+	case "distinct":
+		var distinct []interface{}
+		seen := map[string]bool{}
+		for _, value := range values {
+			items, isList := value.([]interface{})
+			if !isList {
+				items = []interface{}{value}
+			}
+			for _, item := range items {
+				key := fmt.Sprintf("%v", item)
+				if item == nil || seen[key] {
+					continue
+				}
+				seen[key] = true
+				distinct = append(distinct, item)
+			}
+		}
+		return distinct
+	}
+
+	var numbers []float64
+	for _, value := range values {
+		if number, err := toFloat(value); err == nil {
+			numbers = append(numbers, number)
+		}
+	}
+	if len(numbers) == 0 {
+		return nil
+	}
+	result := numbers[0]
+	switch computation {
+	case "sum", "avg":
+		for _, number := range numbers[1:] {
+			result += number
+		}
+		if computation == "avg" {
+			result = result / float64(len(numbers))
+		}
+	case "min":
+		for _, number := range numbers[1:] {
+			result = math.Min(result, number)
+		}
+	case "max":
+		for _, number := range numbers[1:] {
+			result = math.Max(result, number)
+		}
+	default:
+		return nil
 	}
-	count := 0
+	return result
+}
+
+// decayScore sums the weights of the events, each halved for every half life that passed since the event occurred.
+// Without a weight field every event weighs one, and events whose weight is not a number are skipped. The score is
+// rounded to four decimals.
+func decayScore(events []models.Event, weightField string, halfLife time.Duration, now time.Time) float64 {
+	score := 0.0
 	for _, event := range events {
-		log.Printf("Evaluating event: %v", event)
-		if EvaluateConditions(event, trigger.Conditions) {
-			log.Printf("incrementing")
-			count++
+		weight := 1.0
+		if weightField != "" {
+			number, err := toFloat(GetFieldFromEvent(event, weightField))
+			if err != nil {
+				continue
+			}
+			weight = number
+		}
+		age := now.Sub(time.Unix(int64(event.EventTimestamp), 0))
+		if age < 0 {
+			age = 0
 		}
+		score += weight * math.Exp2(-age.Seconds()/halfLife.Seconds())
 	}
-	return count, nil
+	return math.Round(score*10000) / 10000
 }
 
+// EvaluateConditions checks that the event satisfies all the conditions of a trigger
 func EvaluateConditions(event models.Event, triggerConditions []models.RuleCondition) bool {
 	for _, cond := range triggerConditions {
-		fieldVal := GetFieldFromEvent(event, cond.Field)
-		if !EvaluateCondition(fieldVal, cond.Operator, cond.Value) {
+		if !evaluateConditionTree(event, cond) {
 			return false
 		}
 	}
 	return true
 }
+
 func EvaluateCondition(actual interface{}, operator string, expected string) bool {
 	switch strings.ToLower(operator) {
 	case "equals":
@@ -138,6 +518,34 @@ func EvaluateCondition(actual interface{}, operator string, expected string) boo
 	case "less_than_equals":
 		return compareNumeric(actual, expected, "<=")
 
+	case "in":
+		return containsConditionValue(actual, expected)
+
+	case "not_in":
+		return actual != nil && !containsConditionValue(actual, expected)
+
+	case "regex":
+		pattern, err := compileConditionRegex(expected)
+		if err != nil || actual == nil {
+			return false
+		}
+		return pattern.MatchString(fmt.Sprintf("%v", actual))
+
+	case "starts_with":
+		if str, ok := actual.(string); ok {
+			return strings.HasPrefix(str, expected)
+		}
+		return false
+
+	case "ends_with":
+		if str, ok := actual.(string); ok {
+			return strings.HasSuffix(str, expected)
+		}
+		return false
+
+	case "before", "after", "within_last":
+		return compareDate(actual, expected, strings.ToLower(operator))
+
 	default:
 		return false
 	}
@@ -164,15 +572,23 @@ func compareNumeric(actual interface{}, expected string, op string) bool {
 	}
 }
 
+// GetFieldFromEvent resolves a field path of the event, e.g. `context.device_type`, `application_id` or
+// `properties.cart.items[0].sku`. Paths that do not start with a field of the event are resolved in its properties.
 func GetFieldFromEvent(event models.Event, field string) interface{} {
-	if event.Properties == nil {
+	steps, err := parseFieldPath(field)
+	if err != nil {
 		return nil
 	}
 
-	if val, ok := event.Properties[field]; ok {
-		return val
+	var root interface{} = event.Properties
+	if eventFields[steps[0].key] {
+		root = eventDocument(event)
 	}
-	return nil
+	values := lookupFieldPath(root, steps, false)
+	if len(values) == 0 {
+		return nil
+	}
+	return values[0]
 }
 
 func toFloat(v interface{}) (float64, error) {
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+	repositories "github.com/wso2/identity-customer-data-service/pkg/repository"
+)
+
+// failingEventQueue fails to enqueue events until `failures` runs out
+type failingEventQueue struct {
+	repositories.EventQueueStore
+	failures int
+}
+
+func (queue *failingEventQueue) Enqueue(events []models.QueuedEvent) error {
+	if queue.failures > 0 {
+		queue.failures--
+		return errors.New("queue unavailable")
+	}
+	return queue.EventQueueStore.Enqueue(events)
+}
+
+func queueDepth(t *testing.T) int64 {
+	t.Helper()
+	depth, err := stores.EventQueue.Depth()
+	if err != nil {
+		t.Fatalf("failed to fetch the queue depth: %v", err)
+	}
+	return depth
+}
+
+func signupEvent(eventId string) models.Event {
+	return models.Event{EventId: eventId, ProfileId: "p1", OrgId: "org", EventType: "track", EventName: "signup"}
+}
+
+func TestReplayedEventIsNotProcessedAgain(t *testing.T) {
+	setupMemoryStores(t)
+
+	for i, want := range []string{eventAccepted, eventDuplicate} {
+		eventId, status, err := AddEvents(signupEvent("e1"))
+		if err != nil {
+			t.Fatalf("attempt %d failed: %v", i+1, err)
+		}
+		if eventId != "e1" || status != want {
+			t.Errorf("attempt %d = (%s, %s), want (e1, %s)", i+1, eventId, status, want)
+		}
+	}
+	if depth := queueDepth(t); depth != 1 {
+		t.Errorf("queue depth = %d, want the event queued once", depth)
+	}
+}
+
+func TestReplayQueuesAnEventThatFailedToBeQueued(t *testing.T) {
+	setupMemoryStores(t)
+	stores.EventQueue = &failingEventQueue{EventQueueStore: stores.EventQueue, failures: 1}
+
+	if _, _, err := AddEvents(signupEvent("e1")); err == nil {
+		t.Fatalf("adding the event succeeded although it could not be queued")
+	}
+	if depth := queueDepth(t); depth != 0 {
+		t.Fatalf("queue depth = %d after the failure, want 0", depth)
+	}
+
+	// The client retries the event, which was stored by the failed attempt
+	if _, status, err := AddEvents(signupEvent("e1")); err != nil || status != eventAccepted {
+		t.Fatalf("retry = (%s, %v), want the event accepted", status, err)
+	}
+	if _, status, err := AddEvents(signupEvent("e1")); err != nil || status != eventDuplicate {
+		t.Fatalf("second retry = (%s, %v), want a duplicate", status, err)
+	}
+	if depth := queueDepth(t); depth != 1 {
+		t.Errorf("queue depth = %d, want the event queued once", depth)
+	}
+}
+
+func TestEventBatchReportsDuplicates(t *testing.T) {
+	setupMemoryStores(t)
+	stores.EventQueue = &failingEventQueue{EventQueueStore: stores.EventQueue, failures: 1}
+
+	rawEvents := func(eventIds ...string) []json.RawMessage {
+		var events []json.RawMessage
+		for _, eventId := range eventIds {
+			data, _ := json.Marshal(signupEvent(eventId))
+			events = append(events, data)
+		}
+		return events
+	}
+
+	if _, err := AddEventBatch(rawEvents("e1", "e2")); err == nil {
+		t.Fatalf("adding the batch succeeded although it could not be queued")
+	}
+
+	response, err := AddEventBatch(rawEvents("e1", "e2", "e2", "e3"))
+	if err != nil {
+		t.Fatalf("failed to add the batch: %v", err)
+	}
+	want := []string{eventAccepted, eventAccepted, eventDuplicate, eventAccepted}
+	for i, result := range response.Results {
+		if result.Status != want[i] {
+			t.Errorf("event %d (%s) status = %s, want %s", i, result.EventId, result.Status, want[i])
+		}
+	}
+	if response.Accepted != 3 || response.Duplicates != 1 {
+		t.Errorf("accepted %d and duplicates %d, want 3 and 1", response.Accepted, response.Duplicates)
+	}
+
+	response, err = AddEventBatch(rawEvents("e1", "e3"))
+	if err != nil {
+		t.Fatalf("failed to add the replayed batch: %v", err)
+	}
+	if response.Duplicates != 2 {
+		t.Errorf("duplicates = %d for a replayed batch, want 2", response.Duplicates)
+	}
+	if depth := queueDepth(t); depth != 3 {
+		t.Errorf("queue depth = %d, want each event queued once", depth)
+	}
+}
+
+func TestSingleEventsAndBatchesAreValidatedAlike(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		event models.Event
+		valid bool
+	}{
+		{name: "valid", event: signupEvent("e1"), valid: true},
+		{name: "type case", event: models.Event{EventId: "e1", ProfileId: "p1", EventType: "TRACK", EventName: "Signup"},
+			valid: true},
+		{name: "no profile", event: models.Event{EventId: "e1", EventType: "track", EventName: "signup"}},
+		{name: "no type", event: models.Event{EventId: "e1", ProfileId: "p1", EventName: "signup"}},
+		{name: "unsupported type", event: models.Event{EventId: "e1", ProfileId: "p1", EventType: "click",
+			EventName: "signup"}},
+		{name: "no name", event: models.Event{EventId: "e1", ProfileId: "p1", EventType: "track"}},
+		{name: "negative timestamp", event: models.Event{EventId: "e1", ProfileId: "p1", EventType: "track",
+			EventName: "signup", EventTimestamp: -1}},
+		{name: "alias without previous id", event: models.Event{EventId: "e1", ProfileId: "p1", EventType: "alias",
+			EventName: "alias"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			setupMemoryStores(t)
+			_, _, err := AddEvents(test.event)
+			if (err == nil) != test.valid {
+				t.Errorf("single event error = %v, want valid %v", err, test.valid)
+			}
+
+			setupMemoryStores(t)
+			data, _ := json.Marshal(test.event)
+			response, err := AddEventBatch([]json.RawMessage{data})
+			if err != nil {
+				t.Fatalf("failed to add the batch: %v", err)
+			}
+			if accepted := response.Results[0].Status == eventAccepted; accepted != test.valid {
+				t.Errorf("batch event status = %s (%s), want valid %v", response.Results[0].Status,
+					response.Results[0].ErrorMessage, test.valid)
+			}
+		})
+	}
+}
+
+func TestSingleEventsGetATimestamp(t *testing.T) {
+	setupMemoryStores(t)
+	if _, _, err := AddEvents(signupEvent("e1")); err != nil {
+		t.Fatalf("failed to add the event: %v", err)
+	}
+	events, err := stores.Events.FindEventsWithFilter(repositories.EventFilter{ProfileId: "p1"})
+	if err != nil || len(events) != 1 {
+		t.Fatalf("stored events = %v, %v, want the event", events, err)
+	}
+	if events[0].EventTimestamp <= 0 {
+		t.Errorf("event_timestamp = %d, want the time the event was received", events[0].EventTimestamp)
+	}
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+)
+
+// expressionMaxNodes bounds the size of an expression so that evaluating it stays cheap
+const expressionMaxNodes = 1000
+
+// expressionCacheSize bounds the number of compiled expressions kept in memory, since expressions that are only
+// validated or previewed are compiled as well
+const expressionCacheSize = 1024
+
+// compiledExpressions caches the programs of the expressions of enrichment rules by their source
+var compiledExpressions = &expressionCache{programs: map[string]*vm.Program{}}
+
+// expressionCache keeps up to expressionCacheSize programs, evicting the ones compiled first when it is full
+type expressionCache struct {
+	mutex    sync.Mutex
+	programs map[string]*vm.Program
+	order    []string
+}
+
+func (cache *expressionCache) load(expression string) (*vm.Program, bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	program, ok := cache.programs[expression]
+	return program, ok
+}
+
+func (cache *expressionCache) store(expression string, program *vm.Program) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if _, ok := cache.programs[expression]; ok {
+		return
+	}
+	for len(cache.order) >= expressionCacheSize {
+		delete(cache.programs, cache.order[0])
+		cache.order = cache.order[1:]
+	}
+	cache.programs[expression] = program
+	cache.order = append(cache.order, expression)
+}
+
+// expressionOptions sandbox expressions to the fields of the event. Expressions can not reach anything else than
+// the environment and the builtin functions, of which the ones that could allocate unbounded memory are disabled.
+func expressionOptions() []expr.Option {
+	return []expr.Option{
+		expr.Env(expressionEnv(models.Event{})),
+		expr.MaxNodes(expressionMaxNodes),
+		expr.DisableBuiltin("repeat"),
+		expr.Function("fromUnix", func(params ...any) (any, error) {
+			seconds, err := toFloat(params[0])
+			if err != nil {
+				return nil, fmt.Errorf("fromUnix expects a number of seconds: %v", params[0])
+			}
+			return time.Unix(int64(seconds), 0).UTC(), nil
+		}, new(func(any) time.Time)),
+	}
+}
+
+// expressionEnv exposes the event to expressions, e.g. `properties.price * properties.qty`
+func expressionEnv(event models.Event) map[string]interface{} {
+	properties := event.Properties
+	if properties == nil {
+		properties = map[string]interface{}{}
+	}
+	eventContext := event.Context
+	if eventContext == nil {
+		eventContext = map[string]interface{}{}
+	}
+	return map[string]interface{}{
+		"profile_id":      event.ProfileId,
+		"event_type":      event.EventType,
+		"event_name":      event.EventName,
+		"event_id":        event.EventId,
+		"application_id":  event.AppId,
+		"event_timestamp": event.EventTimestamp,
+		"properties":      properties,
+		"context":         eventContext,
+	}
+}
+
+// CompileExpression parses and type checks an expression of an enrichment rule
+func CompileExpression(expression string) (*vm.Program, error) {
+	if program, ok := compiledExpressions.load(expression); ok {
+		return program, nil
+	}
+	program, err := expr.Compile(expression, expressionOptions()...)
+	if err != nil {
+		return nil, err
+	}
+	compiledExpressions.store(expression, program)
+	return program, nil
+}
+
+// EvaluateExpression evaluates an expression of an enrichment rule against an event. Dates are returned as Unix
+// timestamps and durations as seconds, like event timestamps.
+func EvaluateExpression(expression string, event models.Event) (interface{}, error) {
+	program, err := CompileExpression(expression)
+	if err != nil {
+		return nil, err
+	}
+	value, err := expr.Run(program, expressionEnv(event))
+	if err != nil {
+		return nil, err
+	}
+
+	switch v := value.(type) {
+	case time.Time:
+		return v.Unix(), nil
+	case time.Duration:
+		return v.Seconds(), nil
+	default:
+		return value, nil
+	}
+}
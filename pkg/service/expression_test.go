@@ -0,0 +1,39 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+)
+
+func TestFromUnixChecksItsArguments(t *testing.T) {
+	for _, expression := range []string{"fromUnix()", "fromUnix(1, 2)"} {
+		if _, err := CompileExpression(expression); err == nil {
+			t.Errorf("%s compiled, want an arity error", expression)
+		}
+	}
+
+	event := models.Event{Properties: map[string]interface{}{"signed_up_at": 1700000000}}
+	value, err := EvaluateExpression("fromUnix(properties.signed_up_at)", event)
+	if err != nil {
+		t.Fatalf("failed to evaluate fromUnix: %v", err)
+	}
+	if value != int64(1700000000) {
+		t.Errorf("fromUnix(1700000000) = %v, want the same timestamp", value)
+	}
+}
+
+func TestCompiledExpressionsAreBounded(t *testing.T) {
+	for i := 0; i < expressionCacheSize+10; i++ {
+		if _, err := CompileExpression(fmt.Sprintf("properties.qty * %d", i)); err != nil {
+			t.Fatalf("failed to compile expression %d: %v", i, err)
+		}
+	}
+	if size := len(compiledExpressions.programs); size > expressionCacheSize {
+		t.Errorf("cache holds %d programs, want at most %d", size, expressionCacheSize)
+	}
+	if _, ok := compiledExpressions.load("properties.qty * 0"); ok {
+		t.Errorf("the expression compiled first was not evicted")
+	}
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// pathStep is a step of a field path, either a key of an object or an index of a list
+type pathStep struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// parseFieldPath parses a dotted path with list indexes, e.g. `properties.cart.items[0].sku`
+func parseFieldPath(path string) ([]pathStep, error) {
+	if path == "" {
+		return nil, fmt.Errorf("field path is empty")
+	}
+
+	var steps []pathStep
+	for _, segment := range strings.Split(path, ".") {
+		key := segment
+		if bracket := strings.IndexByte(segment, '['); bracket >= 0 {
+			key = segment[:bracket]
+		}
+		if key == "" {
+			return nil, fmt.Errorf("field path '%s' has an empty key", path)
+		}
+		if strings.IndexByte(key, ']') >= 0 {
+			return nil, fmt.Errorf("field path '%s' has a malformed index", path)
+		}
+		steps = append(steps, pathStep{key: key})
+
+		rest := segment[len(key):]
+		for rest != "" {
+			end := strings.IndexByte(rest, ']')
+			if rest[0] != '[' || end < 0 {
+				return nil, fmt.Errorf("field path '%s' has a malformed index", path)
+			}
+			index, err := strconv.Atoi(rest[1:end])
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("field path '%s' has an invalid index '%s'", path, rest[1:end])
+			}
+			steps = append(steps, pathStep{index: index, isIndex: true})
+			rest = rest[end+1:]
+		}
+	}
+	return steps, nil
+}
+
+// lookupFieldPath returns the values at the path. With fanOut, keys applied to a list are looked up in each of its
+// items, so that `devices.device_id` yields the id of every device.
+func lookupFieldPath(value interface{}, steps []pathStep, fanOut bool) []interface{} {
+	if len(steps) == 0 {
+		return []interface{}{value}
+	}
+
+	step := steps[0]
+	if list, ok := asList(value); ok {
+		if step.isIndex {
+			if step.index >= len(list) {
+				return nil
+			}
+			return lookupFieldPath(list[step.index], steps[1:], fanOut)
+		}
+		if !fanOut {
+			return nil
+		}
+		var results []interface{}
+		for _, item := range list {
+			results = append(results, lookupFieldPath(item, steps, fanOut)...)
+		}
+		return results
+	}
+	if object, ok := asObject(value); ok && !step.isIndex {
+		child, exists := object[step.key]
+		if !exists {
+			return nil
+		}
+		return lookupFieldPath(child, steps[1:], fanOut)
+	}
+	return nil
+}
+
+// FieldPathValue returns the value at a path of the data, or nil if there is none
+func FieldPathValue(data interface{}, path string) interface{} {
+	steps, err := parseFieldPath(path)
+	if err != nil {
+		return nil
+	}
+	values := lookupFieldPath(data, steps, false)
+	if len(values) == 0 {
+		return nil
+	}
+	return values[0]
+}
+
+// eventFields are the fields of an event that paths can start with. Other paths are resolved in the properties.
+var eventFields = map[string]bool{
+	"profile_id":      true,
+	"event_type":      true,
+	"event_name":      true,
+	"event_id":        true,
+	"application_id":  true,
+	"org_id":          true,
+	"event_timestamp": true,
+	"properties":      true,
+	"context":         true,
+}
+
+func eventDocument(event models.Event) map[string]interface{} {
+	return map[string]interface{}{
+		"profile_id":      event.ProfileId,
+		"event_type":      event.EventType,
+		"event_name":      event.EventName,
+		"event_id":        event.EventId,
+		"application_id":  event.AppId,
+		"org_id":          event.OrgId,
+		"event_timestamp": event.EventTimestamp,
+		"properties":      event.Properties,
+		"context":         event.Context,
+	}
+}
+
+func asObject(value interface{}) (map[string]interface{}, bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return v, true
+	case primitive.M:
+		return v, true
+	case primitive.D:
+		object := make(map[string]interface{}, len(v))
+		for _, element := range v {
+			object[element.Key] = element.Value
+		}
+		return object, true
+	}
+	return nil, false
+}
+
+func asList(value interface{}) ([]interface{}, bool) {
+	switch v := value.(type) {
+	case []interface{}:
+		return v, true
+	case primitive.A:
+		return v, true
+	case nil, string, []byte:
+		return nil, false
+	}
+	// Typed lists such as []string set by the service itself
+	reflected := reflect.ValueOf(value)
+	if reflected.Kind() != reflect.Slice {
+		return nil, false
+	}
+	list := make([]interface{}, reflected.Len())
+	for i := range list {
+		list[i] = reflected.Index(i).Interface()
+	}
+	return list, true
+}
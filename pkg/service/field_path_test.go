@@ -0,0 +1,156 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+)
+
+func TestParseFieldPathRejectsMalformedPaths(t *testing.T) {
+	for _, path := range []string{"", "cart..sku", ".sku", "items[", "items]", "items[x]", "items[-1]", "[0]",
+		"items[0]sku"} {
+		if _, err := parseFieldPath(path); err == nil {
+			t.Errorf("path %q was parsed, want an error", path)
+		}
+	}
+}
+
+func TestGetFieldFromEventResolvesPaths(t *testing.T) {
+	event := models.Event{
+		ProfileId:      "p1",
+		EventType:      "track",
+		EventName:      "checkout",
+		AppId:          "web",
+		EventTimestamp: 1709287200,
+		Properties: map[string]interface{}{
+			"plan": "gold",
+			"cart": map[string]interface{}{
+				"items": []interface{}{
+					map[string]interface{}{"sku": "A-1", "tags": []string{"new", "sale"}},
+					map[string]interface{}{"sku": "B-2"},
+				},
+			},
+			// A property named like an event field is reached through the properties namespace
+			"context": "checkout page",
+		},
+		Context: map[string]interface{}{"device_type": "mobile", "screen": map[string]interface{}{"width": 390.0}},
+	}
+
+	for _, test := range []struct {
+		path string
+		want interface{}
+	}{
+		{path: "plan", want: "gold"},
+		{path: "properties.plan", want: "gold"},
+		{path: "properties.cart.items[0].sku", want: "A-1"},
+		{path: "cart.items[1].sku", want: "B-2"},
+		{path: "properties.cart.items[0].tags[1]", want: "sale"},
+		{path: "properties.cart.items[2].sku"},
+		{path: "properties.cart.items.sku"},
+		{path: "properties.plan[0]"},
+		{path: "properties.context", want: "checkout page"},
+		{path: "context.device_type", want: "mobile"},
+		{path: "context.screen.width", want: 390.0},
+		{path: "context.os"},
+		{path: "application_id", want: "web"},
+		{path: "event_name", want: "checkout"},
+		{path: "event_timestamp", want: 1709287200},
+		{path: "properties.cart.items["},
+	} {
+		t.Run(test.path, func(t *testing.T) {
+			if got := GetFieldFromEvent(event, test.path); fmt.Sprintf("%#v", got) != fmt.Sprintf("%#v", test.want) {
+				t.Errorf("value = %#v, want %#v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestGetNestedJSONFieldCollectsTheValuesOfLists(t *testing.T) {
+	profile := []byte(`{"application_data": [
+		{"application_id": "web", "devices": [{"device_id": "d1"}, {"device_id": "d2"}]},
+		{"application_id": "ios", "devices": [{"device_id": "d3"}]}
+	], "identity_attributes": {"emails": ["ann@example.com", "ann@example.org"]}}`)
+
+	for _, test := range []struct {
+		path string
+		want []interface{}
+	}{
+		{path: "application_data.devices.device_id", want: []interface{}{"d1", "d2", "d3"}},
+		{path: "application_data[1].devices[0].device_id", want: []interface{}{"d3"}},
+		{path: "identity_attributes.emails", want: []interface{}{"ann@example.com", "ann@example.org"}},
+		{path: "identity_attributes.emails[1]", want: []interface{}{"ann@example.org"}},
+		{path: "identity_attributes.phone"},
+	} {
+		t.Run(test.path, func(t *testing.T) {
+			got := extractFieldFromJSON(profile, test.path)
+			if fmt.Sprintf("%v", got) != fmt.Sprintf("%v", test.want) {
+				t.Errorf("values = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestEnrichmentRulesUseNestedAndContextPaths(t *testing.T) {
+	setupMemoryStores(t)
+	addEnrichmentRule(t, models.ProfileEnrichmentRule{
+		RuleId:       "rule-first-sku",
+		PropertyName: "traits.first_sku",
+		PropertyType: "computed",
+		Computation:  "copy",
+		SourceFields: []string{"properties.cart.items[0].sku"},
+		Trigger: models.RuleTrigger{EventType: "track", EventName: "checkout", Conditions: []models.RuleCondition{
+			{Field: "context.device_type", Operator: "equals", Value: "mobile"},
+			{Field: "application_id", Operator: "equals", Value: "ios"},
+		}},
+	})
+
+	checkout := func(eventId, appId, deviceType, sku string) models.Event {
+		return models.Event{EventId: eventId, ProfileId: "p1", OrgId: "org", EventType: "track", EventName: "checkout",
+			AppId: appId, Context: map[string]interface{}{"device_type": deviceType},
+			Properties: map[string]interface{}{"cart": map[string]interface{}{
+				"items": []interface{}{map[string]interface{}{"sku": sku}}}}}
+	}
+	storeAndEnrich(t, checkout("e1", "ios", "mobile", "A-1"))
+	storeAndEnrich(t, checkout("e2", "web", "mobile", "B-2"))
+	storeAndEnrich(t, checkout("e3", "ios", "tablet", "C-3"))
+
+	if sku := findProfile(t, "p1").Traits["first_sku"]; sku != "A-1" {
+		t.Errorf("traits.first_sku = %v, want the sku of the only checkout on an ios phone", sku)
+	}
+}
+
+func TestUnificationRulesMatchNestedPaths(t *testing.T) {
+	setupMemoryStores(t)
+	addEnrichmentRule(t, models.ProfileEnrichmentRule{
+		RuleId:       "rule-emails",
+		PropertyName: "identity_attributes.emails",
+		PropertyType: "computed",
+		Computation:  "copy",
+		SourceFields: []string{"properties.account.emails"},
+		ValueType:    "arrayOfString",
+	})
+	rule := models.UnificationRule{RuleId: "rule-emails", RuleName: "emails", Property: "identity_attributes.emails",
+		Priority: 1, IsActive: true}
+	if err := stores.UnificationRules.AddUnificationRule(rule); err != nil {
+		t.Fatalf("failed to add unification rule: %v", err)
+	}
+
+	emails := func(addresses ...interface{}) map[string]interface{} {
+		return map[string]interface{}{"account": map[string]interface{}{"emails": addresses}}
+	}
+	enrichWithEvent(t, "p1", emails("ann@example.com", "ann@work.example"))
+	unify(t, "p1")
+	enrichWithEvent(t, "p2", emails("bob@example.com"))
+	unify(t, "p2")
+	enrichWithEvent(t, "p3", emails("ann@work.example"))
+	unify(t, "p3")
+
+	p1, p2, p3 := findProfile(t, "p1"), findProfile(t, "p2"), findProfile(t, "p3")
+	if p1.ProfileHierarchy.IsParent || p1.ProfileHierarchy.ParentProfileID != p3.ProfileHierarchy.ParentProfileID {
+		t.Errorf("profiles sharing an email were not unified: %+v, %+v", p1.ProfileHierarchy, p3.ProfileHierarchy)
+	}
+	if !p2.ProfileHierarchy.IsParent {
+		t.Errorf("profile without a shared email was unified: %+v", p2.ProfileHierarchy)
+	}
+}
@@ -0,0 +1,330 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/wso2/identity-customer-data-service/pkg/logger"
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+	repositories "github.com/wso2/identity-customer-data-service/pkg/repository"
+)
+
+// The identity index maps the identity keys of master profiles to their ids so that unification looks up the
+// profiles sharing a key with the new profile instead of comparing it with every master profile. An identity key
+// is the id of a unification rule, one of its properties and a normalized value of the property.
+
+var identityIndexRebuild struct {
+	mutex   sync.Mutex
+	running bool
+	pending bool
+}
+
+// indexedProfileStore keeps the identity index up to date with the writes that add, remove or relink profiles. The
+// values written while enriching or merging a profile are indexed once, when the pass writing them completes.
+type indexedProfileStore struct {
+	repositories.ProfileStore
+}
+
+func (s *indexedProfileStore) InsertProfile(profile models.Profile) error {
+	err := s.ProfileStore.InsertProfile(profile)
+	reindexProfiles(profile.ProfileId)
+	return err
+}
+
+func (s *indexedProfileStore) InsertProfiles(profiles []models.Profile) error {
+	err := s.ProfileStore.InsertProfiles(profiles)
+	profileIds := make([]string, 0, len(profiles))
+	for _, profile := range profiles {
+		profileIds = append(profileIds, profile.ProfileId)
+	}
+	reindexProfiles(profileIds...)
+	return err
+}
+
+func (s *indexedProfileStore) DeleteProfile(profileId string) error {
+	err := s.ProfileStore.DeleteProfile(profileId)
+	reindexProfiles(profileId)
+	return err
+}
+
+func (s *indexedProfileStore) DetachChildFromParent(parentID, childID string) error {
+	err := s.ProfileStore.DetachChildFromParent(parentID, childID)
+	reindexProfiles(parentID, childID)
+	return err
+}
+
+func (s *indexedProfileStore) UpdateParent(master models.Profile, newProfile models.Profile) error {
+	err := s.ProfileStore.UpdateParent(master, newProfile)
+	reindexProfiles(newProfile.ProfileId)
+	return err
+}
+
+func (s *indexedProfileStore) ReplaceProfile(profile models.Profile) error {
+	err := s.ProfileStore.ReplaceProfile(profile)
+	reindexProfiles(profile.ProfileId)
+	return err
+}
+
+// reindexProfiles replaces the identity keys of the profiles with their current keys. Failures are logged rather
+// than failing the write, the next write or rebuild of the index corrects the keys.
+func reindexProfiles(profileIds ...string) {
+	rules, err := GetUnificationRules()
+	if err != nil {
+		logger.Error(err, "Failed to fetch the unification rules to update the identity index")
+		return
+	}
+	for _, profileId := range profileIds {
+		profile, err := stores.Profiles.FindProfileByID(profileId)
+		if err != nil {
+			logger.Error(err, fmt.Sprintf("Failed to fetch profile %s to update the identity index", profileId))
+			continue
+		}
+		if profile == nil {
+			err = stores.IdentityIndex.DeleteIdentityKeys(profileId)
+		} else {
+			err = indexProfile(*profile, rules)
+		}
+		if err != nil {
+			logger.Error(err, fmt.Sprintf("Failed to update the identity keys of profile %s", profileId))
+		}
+	}
+}
+
+// indexProfile sets the identity keys of a master profile. Child profiles are not indexed, their values are
+// unified through their master.
+func indexProfile(profile models.Profile, rules []models.UnificationRule) error {
+	if profile.ProfileHierarchy != nil && !profile.ProfileHierarchy.IsParent {
+		return stores.IdentityIndex.DeleteIdentityKeys(profile.ProfileId)
+	}
+	profileJSON, err := json.Marshal(profile)
+	if err != nil {
+		return err
+	}
+	var keys []string
+	for _, rule := range rules {
+		keys = append(keys, identityKeys(profileJSON, rule)...)
+	}
+	return stores.IdentityIndex.SetIdentityKeys(profile.ProfileId, keys)
+}
+
+// identityKeys lists the keys of the profile for the rule. Probabilistic rules key the values of their exact
+// attributes as they are and of their soundex attributes by their Soundex code, the values the comparators score as
+// a match. Rules with a levenshtein or jaro_winkler attribute have no keys, as similar values share no key, and are
+// matched against every master profile instead.
+func identityKeys(profileJSON []byte, rule models.UnificationRule) []string {
+	if scansAllProfiles(rule) {
+		return nil
+	}
+	var keys []string
+	if isProbabilisticRule(rule) {
+		for _, attribute := range rule.Attributes {
+			soundexKeyed := strings.ToLower(attribute.Comparator) == "soundex"
+			values := normalizeRuleValues(extractFieldFromJSON(profileJSON, attribute.Property), rule.Normalizers)
+			for _, value := range values {
+				keyValue := value.(string)
+				if soundexKeyed {
+					// Values without a code never match
+					if keyValue = soundex(keyValue); keyValue == "" {
+						continue
+					}
+				}
+				keys = append(keys, identityKey(rule, attribute.Property, keyValue))
+			}
+		}
+		return keys
+	}
+
+	for _, property := range unificationRuleProperties(rule) {
+		for _, value := range normalizeRuleValues(extractFieldFromJSON(profileJSON, property), rule.Normalizers) {
+			keys = append(keys, identityKey(rule, property, value.(string)))
+		}
+	}
+	return keys
+}
+
+// scansAllProfiles tells if the rule compares an attribute by similarity, for which the identity index cannot look up
+// the candidates
+func scansAllProfiles(rule models.UnificationRule) bool {
+	if !isProbabilisticRule(rule) {
+		return false
+	}
+	for _, attribute := range rule.Attributes {
+		switch strings.ToLower(attribute.Comparator) {
+		case "", "exact", "soundex":
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+func identityKey(rule models.UnificationRule, property string, value string) string {
+	return rule.RuleId + "|" + property + "|" + value
+}
+
+// findUnificationCandidates looks up the master profiles that share an identity key of the rule with the profile,
+// or lists all master profiles for rules without keys or while the index is not built for the current rules, other
+// than the profile and its own master. The candidates are then matched against the rule.
+func findUnificationCandidates(profile models.Profile, profileJSON []byte, rule models.UnificationRule,
+	indexed bool) ([]models.Profile, error) {
+	isOwnMaster := func(profileId string) bool {
+		return profile.ProfileHierarchy != nil && profileId == profile.ProfileHierarchy.ParentProfileID
+	}
+
+	if !indexed || scansAllProfiles(rule) {
+		masters, err := stores.Profiles.GetAllMasterProfilesExceptForCurrent(profile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch the master profiles: %v", err)
+		}
+		var candidates []models.Profile
+		for _, master := range masters {
+			if !isOwnMaster(master.ProfileId) {
+				candidates = append(candidates, master)
+			}
+		}
+		return candidates, nil
+	}
+
+	keys := identityKeys(profileJSON, rule)
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	profileIds, err := stores.IdentityIndex.FindProfileIdsByIdentityKeys(keys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up the identity index: %v", err)
+	}
+
+	var candidates []models.Profile
+	for _, profileId := range profileIds {
+		if profileId == profile.ProfileId || isOwnMaster(profileId) {
+			continue
+		}
+		candidate, err := stores.Profiles.FindProfileByID(profileId)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch profile %s: %v", profileId, err)
+		}
+		// Keys of profiles that were merged or deleted since are left to be corrected
+		if candidate == nil || candidate.ProfileHierarchy == nil || !candidate.ProfileHierarchy.IsParent {
+			continue
+		}
+		candidates = append(candidates, *candidate)
+	}
+	return candidates, nil
+}
+
+// isIdentityIndexBuilt tells if the identity index holds the keys of all master profiles for the rules. A rebuild
+// only marks the index as built once every master profile is indexed, until then unification scans the profiles.
+func isIdentityIndexBuilt(rules []models.UnificationRule) (bool, error) {
+	indexedVersion, err := stores.IdentityIndex.GetIndexVersion()
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch the version of the identity index: %v", err)
+	}
+	return indexedVersion == identityIndexVersion(rules), nil
+}
+
+// RebuildIdentityIndex recomputes the identity keys of all master profiles in the background if the unification rules
+// changed since the index was last built. A rebuild requested while one is running runs once the current one
+// completes.
+func RebuildIdentityIndex() {
+	identityIndexRebuild.mutex.Lock()
+	defer identityIndexRebuild.mutex.Unlock()
+	if identityIndexRebuild.running {
+		identityIndexRebuild.pending = true
+		return
+	}
+	identityIndexRebuild.running = true
+
+	go func() {
+		for {
+			if err := reindexAllProfiles(); err != nil {
+				logger.Error(err, "Failed to rebuild the identity index")
+			}
+			identityIndexRebuild.mutex.Lock()
+			if !identityIndexRebuild.pending {
+				identityIndexRebuild.running = false
+				identityIndexRebuild.mutex.Unlock()
+				return
+			}
+			identityIndexRebuild.pending = false
+			identityIndexRebuild.mutex.Unlock()
+		}
+	}()
+}
+
+func reindexAllProfiles() error {
+	rules, err := GetUnificationRules()
+	if err != nil {
+		return fmt.Errorf("failed to fetch the unification rules: %v", err)
+	}
+	version := identityIndexVersion(rules)
+	indexedVersion, err := stores.IdentityIndex.GetIndexVersion()
+	if err != nil {
+		return fmt.Errorf("failed to fetch the version of the identity index: %v", err)
+	}
+	if indexedVersion == version {
+		logger.Debug("The identity index is up to date with the unification rules")
+		return nil
+	}
+
+	profiles, err := findAllMasterProfiles()
+	if err != nil {
+		return fmt.Errorf("failed to fetch the master profiles: %v", err)
+	}
+	failed := 0
+	for _, profile := range profiles {
+		if err := indexProfile(profile, rules); err != nil {
+			logger.Error(err, fmt.Sprintf("Failed to update the identity keys of profile %s", profile.ProfileId))
+			failed++
+		}
+	}
+	// The version is left as it was so that the next rebuild indexes the profiles again
+	if failed > 0 {
+		return fmt.Errorf("failed to update the identity keys of %d of %d master profiles", failed, len(profiles))
+	}
+	if err := stores.IdentityIndex.SetIndexVersion(version); err != nil {
+		return fmt.Errorf("failed to store the version of the identity index: %v", err)
+	}
+	logger.Info(fmt.Sprintf("Rebuilt the identity index of %d master profiles", len(profiles)))
+	return nil
+}
+
+// identityKeyFormat changes whenever the keys derived from the same rules change, so that indexes built before are
+// rebuilt
+const identityKeyFormat = 2
+
+// identityIndexVersion digests the fields of the rules that the identity keys derive from
+func identityIndexVersion(rules []models.UnificationRule) string {
+	type keyedAttribute struct {
+		Property   string
+		Comparator string
+	}
+	type keyedRule struct {
+		RuleId      string
+		Mode        string
+		Properties  []string
+		Normalizers []string
+		Attributes  []keyedAttribute
+	}
+	keyed := make([]keyedRule, 0, len(rules))
+	for _, rule := range rules {
+		entry := keyedRule{
+			RuleId:      rule.RuleId,
+			Mode:        strings.ToLower(rule.Mode),
+			Properties:  unificationRuleProperties(rule),
+			Normalizers: rule.Normalizers,
+		}
+		for _, attribute := range rule.Attributes {
+			entry.Attributes = append(entry.Attributes,
+				keyedAttribute{Property: attribute.Property, Comparator: strings.ToLower(attribute.Comparator)})
+		}
+		keyed = append(keyed, entry)
+	}
+	sort.Slice(keyed, func(i, j int) bool { return keyed[i].RuleId < keyed[j].RuleId })
+
+	digest, _ := json.Marshal(keyed)
+	return fmt.Sprintf("%d-%x", identityKeyFormat, sha256.Sum256(digest))
+}
@@ -0,0 +1,263 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+	repositories "github.com/wso2/identity-customer-data-service/pkg/repository"
+)
+
+// countingIdentityIndex counts the identity keys written per profile and fails to read the index version when
+// versionErr is set
+type countingIdentityIndex struct {
+	repositories.IdentityIndexStore
+	writes     map[string]int
+	versionErr error
+}
+
+func (store *countingIdentityIndex) SetIdentityKeys(profileId string, keys []string) error {
+	store.writes[profileId]++
+	return store.IdentityIndexStore.SetIdentityKeys(profileId, keys)
+}
+
+func (store *countingIdentityIndex) GetIndexVersion() (string, error) {
+	if store.versionErr != nil {
+		return "", store.versionErr
+	}
+	return store.IdentityIndexStore.GetIndexVersion()
+}
+
+func countIdentityKeyWrites(t *testing.T) *countingIdentityIndex {
+	t.Helper()
+	index := &countingIdentityIndex{IdentityIndexStore: stores.IdentityIndex, writes: map[string]int{}}
+	stores.IdentityIndex = index
+	return index
+}
+
+func isIndexedBy(t *testing.T, profileId string, key string) bool {
+	t.Helper()
+	profileIds, err := stores.IdentityIndex.FindProfileIdsByIdentityKeys([]string{key})
+	if err != nil {
+		t.Fatalf("failed to look up the identity index: %v", err)
+	}
+	return len(profileIds) == 1 && profileIds[0] == profileId
+}
+
+func addFirstNameUnificationRule(t *testing.T, comparator string) {
+	t.Helper()
+	rule := models.UnificationRule{
+		RuleId:     "rule-first-name",
+		RuleName:   "first name",
+		Mode:       "probabilistic",
+		Attributes: []models.MatchAttribute{{Property: "traits.first_name", Comparator: comparator}},
+		Threshold:  0.85,
+		Priority:   1,
+		IsActive:   true,
+	}
+	if err := stores.UnificationRules.AddUnificationRule(rule); err != nil {
+		t.Fatalf("failed to add unification rule: %v", err)
+	}
+}
+
+func TestUnifyProfilesMatchesSimilarValuesOfProbabilisticRules(t *testing.T) {
+	for _, test := range []struct {
+		comparator string
+		first      string
+		second     string
+	}{
+		{comparator: "jaro_winkler", first: "Catherine", second: "Katherine"},
+		{comparator: "levenshtein", first: "Catherine", second: "Katherine"},
+		{comparator: "soundex", first: "Robert", second: "Rupert"},
+	} {
+		t.Run(test.comparator, func(t *testing.T) {
+			setupMemoryStores(t)
+			addCopyRule(t, "first_name")
+			addFirstNameUnificationRule(t, test.comparator)
+
+			enrichWithEvent(t, "p1", map[string]interface{}{"first_name": test.first})
+			unify(t, "p1")
+			enrichWithEvent(t, "p2", map[string]interface{}{"first_name": test.second})
+			unify(t, "p2")
+
+			p1, p2 := findProfile(t, "p1"), findProfile(t, "p2")
+			if p1.ProfileHierarchy.IsParent || p2.ProfileHierarchy.IsParent ||
+				p1.ProfileHierarchy.ParentProfileID != p2.ProfileHierarchy.ParentProfileID {
+				t.Fatalf("profiles named %s and %s were not unified: %+v, %+v", test.first, test.second,
+					p1.ProfileHierarchy, p2.ProfileHierarchy)
+			}
+		})
+	}
+}
+
+func TestRebuildIdentityIndexOnlyWhenRulesChange(t *testing.T) {
+	setupMemoryStores(t)
+	addCopyRule(t, "email")
+	addEmailUnificationRule(t)
+	enrichWithEvent(t, "p1", map[string]interface{}{"email": "ann@example.com"})
+
+	emailKey := identityKey(models.UnificationRule{RuleId: "rule-email"}, "traits.email", "ann@example.com")
+	indexed := func() bool {
+		t.Helper()
+		return isIndexedBy(t, "p1", emailKey)
+	}
+
+	if err := reindexAllProfiles(); err != nil {
+		t.Fatalf("failed to rebuild the identity index: %v", err)
+	}
+	if !indexed() {
+		t.Fatalf("profile p1 is not indexed by its email after a rebuild")
+	}
+
+	// Unchanged rules leave the index as it is
+	if err := stores.IdentityIndex.DeleteIdentityKeys("p1"); err != nil {
+		t.Fatalf("failed to delete the identity keys: %v", err)
+	}
+	if err := reindexAllProfiles(); err != nil {
+		t.Fatalf("failed to rebuild the identity index: %v", err)
+	}
+	if indexed() {
+		t.Fatalf("the identity index was rebuilt although the rules did not change")
+	}
+
+	addFirstNameUnificationRule(t, "exact")
+	if err := reindexAllProfiles(); err != nil {
+		t.Fatalf("failed to rebuild the identity index: %v", err)
+	}
+	if !indexed() {
+		t.Fatalf("the identity index was not rebuilt after a rule was added")
+	}
+}
+
+func TestIdentityKeysAreWrittenOncePerEnrichment(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		event      models.Event
+		wantEmail  string
+		wantPlan   string
+		identified bool
+	}{
+		{name: "event triggering several rules", wantEmail: "ann@example.com", wantPlan: "gold",
+			event: models.Event{EventType: "track", EventName: "signup",
+				Properties: map[string]interface{}{"email": "ann@example.com", "plan": "gold"},
+				Context:    map[string]interface{}{"device_id": "d1"}}},
+		{name: "identify event", wantEmail: "ann@example.com", identified: true,
+			event: models.Event{EventType: "identify", EventName: "identify",
+				Properties: map[string]interface{}{"email": "ann@example.com", "city": "Colombo"}}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			setupMemoryStores(t)
+			addCopyRule(t, "email")
+			addCopyRule(t, "plan")
+			addEmailUnificationRule(t)
+			event := test.event
+			event.EventId, event.ProfileId, event.OrgId, event.AppId = "e1", "p1", "org", "app"
+			if _, err := CreateOrUpdateProfile(event); err != nil {
+				t.Fatalf("failed to create profile p1: %v", err)
+			}
+			if _, err := stores.Events.AddEvent(event); err != nil {
+				t.Fatalf("failed to store event: %v", err)
+			}
+
+			index := countIdentityKeyWrites(t)
+			if err := EnrichProfile(event); err != nil {
+				t.Fatalf("failed to enrich profile p1: %v", err)
+			}
+			if index.writes["p1"] != 1 {
+				t.Errorf("identity keys of p1 written %d times, want once", index.writes["p1"])
+			}
+
+			profile := findProfile(t, "p1")
+			email := profile.Traits["email"]
+			if test.identified {
+				email = profile.IdentityAttributes["email"]
+			}
+			if email != test.wantEmail || (test.wantPlan != "" && profile.Traits["plan"] != test.wantPlan) {
+				t.Errorf("profile = %+v, %+v, want the values of the event", profile.Traits, profile.IdentityAttributes)
+			}
+		})
+	}
+}
+
+func TestIdentityKeysAreWrittenOncePerMerge(t *testing.T) {
+	setupMemoryStores(t)
+	addCopyRule(t, "email")
+	addCopyRule(t, "plan")
+	addEmailUnificationRule(t)
+	enrichWithEvent(t, "p1", map[string]interface{}{"email": "ann@example.com", "plan": "gold"})
+	enrichWithEvent(t, "p2", map[string]interface{}{"email": "ann@example.com"})
+	unify(t, "p1")
+
+	index := countIdentityKeyWrites(t)
+	unify(t, "p2")
+	masterId := findProfile(t, "p2").ProfileHierarchy.ParentProfileID
+	if masterId == "" || masterId == "p1" {
+		t.Fatalf("profile p2 was not unified with p1: %+v", findProfile(t, "p2").ProfileHierarchy)
+	}
+	if index.writes[masterId] > 2 {
+		t.Errorf("identity keys of the master written %d times, want at most twice", index.writes[masterId])
+	}
+	emailKey := identityKey(models.UnificationRule{RuleId: "rule-email"}, "traits.email", "ann@example.com")
+	if !isIndexedBy(t, masterId, emailKey) {
+		t.Errorf("the email is not indexed by the master profile only")
+	}
+}
+
+func TestUnifyProfilesScansProfilesUntilTheIdentityIndexIsBuilt(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		version func(rules []models.UnificationRule) string
+		unified bool
+	}{
+		// A built index is trusted, so the keys a partial rebuild left out are not found
+		{name: "index built for the rules", version: identityIndexVersion},
+		{name: "index built for other rules", unified: true,
+			version: func([]models.UnificationRule) string { return "1-stale" }},
+		{name: "index never built", unified: true,
+			version: func([]models.UnificationRule) string { return "" }},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			setupMemoryStores(t)
+			addCopyRule(t, "email")
+			addEmailUnificationRule(t)
+			enrichWithEvent(t, "p1", map[string]interface{}{"email": "ann@example.com"})
+
+			rules, err := GetUnificationRules()
+			if err != nil {
+				t.Fatalf("failed to fetch the unification rules: %v", err)
+			}
+			if err := stores.IdentityIndex.SetIndexVersion(test.version(rules)); err != nil {
+				t.Fatalf("failed to set the version of the identity index: %v", err)
+			}
+			if err := stores.IdentityIndex.DeleteIdentityKeys("p1"); err != nil {
+				t.Fatalf("failed to delete the identity keys: %v", err)
+			}
+
+			enrichWithEvent(t, "p2", map[string]interface{}{"email": "ann@example.com"})
+			unify(t, "p2")
+			p1, p2 := findProfile(t, "p1"), findProfile(t, "p2")
+			unified := !p2.ProfileHierarchy.IsParent &&
+				p1.ProfileHierarchy.ParentProfileID == p2.ProfileHierarchy.ParentProfileID
+			if unified != test.unified {
+				t.Errorf("profiles unified = %v, want %v: %+v, %+v", unified, test.unified, p1.ProfileHierarchy,
+					p2.ProfileHierarchy)
+			}
+		})
+	}
+}
+
+func TestUnifyProfilesFailsWithoutTheIndexVersion(t *testing.T) {
+	setupMemoryStores(t)
+	addCopyRule(t, "email")
+	addEmailUnificationRule(t)
+	enrichWithEvent(t, "p1", map[string]interface{}{"email": "ann@example.com"})
+	enrichWithEvent(t, "p2", map[string]interface{}{"email": "ann@example.com"})
+
+	countIdentityKeyWrites(t).versionErr = errors.New("read failed")
+	if _, err := unifyProfiles(*findProfile(t, "p2")); err == nil {
+		t.Fatalf("profile p2 was unified without the version of the identity index")
+	}
+	if hierarchy := findProfile(t, "p2").ProfileHierarchy; !hierarchy.IsParent {
+		t.Errorf("profile p2 hierarchy = %+v, want it left unmerged", hierarchy)
+	}
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ValueNormalizer brings a property value into a canonical form before profiles are matched on it. It reports false
+// for values that cannot be normalized, which then match no other value.
+type ValueNormalizer func(value string) (string, bool)
+
+var valueNormalizers = struct {
+	mutex       sync.RWMutex
+	normalizers map[string]ValueNormalizer
+}{
+	normalizers: map[string]ValueNormalizer{
+		"trim":      normalizeTrim,
+		"lowercase": normalizeLowercase,
+		"email":     normalizeEmail,
+		"phone":     normalizePhone,
+	},
+}
+
+// RegisterNormalizer makes a normalizer available to unification rules under the given name, replacing any
+// normalizer registered with the same name
+func RegisterNormalizer(name string, normalizer ValueNormalizer) {
+	valueNormalizers.mutex.Lock()
+	defer valueNormalizers.mutex.Unlock()
+	valueNormalizers.normalizers[strings.ToLower(name)] = normalizer
+}
+
+func findNormalizer(name string) (ValueNormalizer, bool) {
+	valueNormalizers.mutex.RLock()
+	defer valueNormalizers.mutex.RUnlock()
+	normalizer, found := valueNormalizers.normalizers[strings.ToLower(name)]
+	return normalizer, found
+}
+
+// normalizeRuleValues applies the normalizers of a unification rule in order to the values of a property. Without
+// normalizers the string values are compared as they are. Values that a normalizer rejects are left out.
+func normalizeRuleValues(values []interface{}, normalizerNames []string) []interface{} {
+	var normalized []interface{}
+	for _, value := range values {
+		str, isString := value.(string)
+		if len(normalizerNames) == 0 {
+			if isString {
+				normalized = append(normalized, str)
+			}
+			continue
+		}
+		if !isString {
+			str = normalizableString(value)
+		}
+
+		accepted := str != ""
+		for _, name := range normalizerNames {
+			normalizer, found := findNormalizer(name)
+			if !accepted || !found {
+				accepted = false
+				break
+			}
+			str, accepted = normalizer(str)
+		}
+		if accepted && str != "" {
+			normalized = append(normalized, str)
+		}
+	}
+	return normalized
+}
+
+// normalizableString converts numbers, such as phone numbers kept as numbers, to strings. Other values are not
+// normalized.
+func normalizableString(value interface{}) string {
+	switch v := value.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int, int32, int64:
+		return fmt.Sprintf("%d", v)
+	default:
+		return ""
+	}
+}
+
+func normalizeTrim(value string) (string, bool) {
+	return strings.TrimSpace(value), true
+}
+
+func normalizeLowercase(value string) (string, bool) {
+	return strings.ToLower(value), true
+}
+
+// normalizeEmail lowercases the address and removes the plus-addressing tag from its local part. Gmail ignores the
+// dots in the local part as well and treats googlemail.com as gmail.com.
+func normalizeEmail(value string) (string, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	at := strings.LastIndex(value, "@")
+	if at <= 0 || at == len(value)-1 {
+		return "", false
+	}
+	local, domain := value[:at], value[at+1:]
+
+	local, _, _ = strings.Cut(local, "+")
+	if domain == "googlemail.com" {
+		domain = "gmail.com"
+	}
+	if domain == "gmail.com" {
+		local = strings.ReplaceAll(local, ".", "")
+	}
+	if local == "" || strings.Contains(local, "@") {
+		return "", false
+	}
+	return local + "@" + domain, true
+}
+
+// normalizePhone converts the number to E.164, dropping the separators it is commonly written with. Numbers must
+// include the country code, either with a leading + or 00 or as plain digits. National numbers with a trunk prefix
+// are rejected as their country is not known.
+func normalizePhone(value string) (string, bool) {
+	value = strings.TrimSpace(value)
+	var digits strings.Builder
+	for i, r := range value {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r == '+' && i == 0:
+		case strings.ContainsRune(" ()-./", r):
+		default:
+			return "", false
+		}
+	}
+
+	number := digits.String()
+	if !strings.HasPrefix(value, "+") {
+		number = strings.TrimPrefix(number, "00")
+	}
+	if len(number) < 7 || len(number) > 15 || number[0] == '0' {
+		return "", false
+	}
+	return "+" + number, true
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/wso2/identity-customer-data-service/pkg/errors"
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+)
+
+func TestNormalizers(t *testing.T) {
+	for _, test := range []struct {
+		normalizer string
+		value      string
+		want       string
+		rejected   bool
+	}{
+		{normalizer: "trim", value: "  Ann Lee \t", want: "Ann Lee"},
+		{normalizer: "lowercase", value: "Ann LEE", want: "ann lee"},
+
+		{normalizer: "email", value: " Ann.Lee@Example.COM ", want: "ann.lee@example.com"},
+		{normalizer: "email", value: "ann+news@example.com", want: "ann@example.com"},
+		{normalizer: "email", value: "a.n.n+news@gmail.com", want: "ann@gmail.com"},
+		{normalizer: "email", value: "Ann.Lee@googlemail.com", want: "annlee@gmail.com"},
+		{normalizer: "email", value: "ann.lee@gmail.example.com", want: "ann.lee@gmail.example.com"},
+		{normalizer: "email", value: "ann", rejected: true},
+		{normalizer: "email", value: "@example.com", rejected: true},
+		{normalizer: "email", value: "ann@", rejected: true},
+		{normalizer: "email", value: "+news@example.com", rejected: true},
+		{normalizer: "email", value: "ann@lee@example.com", rejected: true},
+
+		{normalizer: "phone", value: "+94 77 123 4567", want: "+94771234567"},
+		{normalizer: "phone", value: "+1 (415) 555-0123", want: "+14155550123"},
+		{normalizer: "phone", value: "0094.77.123.4567", want: "+94771234567"},
+		{normalizer: "phone", value: "94771234567", want: "+94771234567"},
+		{normalizer: "phone", value: "+0094771234567", rejected: true},
+		{normalizer: "phone", value: "077 123 4567", rejected: true},
+		{normalizer: "phone", value: "+94 77 123 456x", rejected: true},
+		{normalizer: "phone", value: "94+771234567", rejected: true},
+		{normalizer: "phone", value: "+123456", rejected: true},
+		{normalizer: "phone", value: "+1234567890123456", rejected: true},
+	} {
+		t.Run(fmt.Sprintf("%s of %q", test.normalizer, test.value), func(t *testing.T) {
+			normalizer, found := findNormalizer(test.normalizer)
+			if !found {
+				t.Fatalf("normalizer %s is not registered", test.normalizer)
+			}
+			got, accepted := normalizer(test.value)
+			if test.rejected {
+				if accepted {
+					t.Errorf("value was normalized to %q, want it rejected", got)
+				}
+				return
+			}
+			if !accepted || got != test.want {
+				t.Errorf("value = (%q, %v), want %q", got, accepted, test.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeRuleValues(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		values      []interface{}
+		normalizers []string
+		want        []interface{}
+	}{
+		{name: "strings without normalizers", values: []interface{}{" Ann ", 94771234567.0, true},
+			want: []interface{}{" Ann "}},
+		{name: "normalizers in order", values: []interface{}{" ANN@Example.com "},
+			normalizers: []string{"trim", "lowercase"}, want: []interface{}{"ann@example.com"}},
+		{name: "numbers as phone numbers", values: []interface{}{94771234567.0, 14155550123},
+			normalizers: []string{"phone"}, want: []interface{}{"+94771234567", "+14155550123"}},
+		{name: "rejected values left out", values: []interface{}{"ann", "bob@example.com", "", true},
+			normalizers: []string{"email"}, want: []interface{}{"bob@example.com"}},
+		{name: "unregistered normalizer", values: []interface{}{"ann@example.com"},
+			normalizers: []string{"email", "missing"}},
+		{name: "normalizer names in any case", values: []interface{}{" Ann "},
+			normalizers: []string{"TRIM", "Lowercase"}, want: []interface{}{"ann"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got := normalizeRuleValues(test.values, test.normalizers)
+			if fmt.Sprintf("%#v", got) != fmt.Sprintf("%#v", test.want) {
+				t.Errorf("values = %#v, want %#v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRegisteredNormalizersAreUsedByRules(t *testing.T) {
+	setupMemoryStores(t)
+	RegisterNormalizer("Digits", func(value string) (string, bool) {
+		digits := strings.Map(func(r rune) rune {
+			if r >= '0' && r <= '9' {
+				return r
+			}
+			return -1
+		}, value)
+		return digits, digits != ""
+	})
+	addCopyRule(t, "loyalty_id")
+
+	rule := models.UnificationRule{RuleId: "rule-loyalty-id", RuleName: "loyalty id", Property: "traits.loyalty_id",
+		Normalizers: []string{"digits"}, Priority: 1, IsActive: true}
+	if err := validateUnificationRule(rule); err != nil {
+		t.Fatalf("rule with a registered normalizer was rejected: %v", err)
+	}
+	unregistered := rule
+	unregistered.Normalizers = []string{"soundex"}
+	if err := validateUnificationRule(unregistered); clientErrorCode(err) != errors.ErrInvalidUnificationRule.Code {
+		t.Errorf("error = %v, want the unregistered normalizer rejected", err)
+	}
+
+	matched, _ := doesProfileMatch(profileWithTraits("p1", map[string]interface{}{"loyalty_id": "LY-0042-17"}),
+		profileWithTraits("p2", map[string]interface{}{"loyalty_id": "ly 004217"}), rule)
+	if !matched {
+		t.Errorf("loyalty ids with the same digits did not match")
+	}
+}
+
+func TestUnifyProfilesOnNormalizedValues(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		property    string
+		normalizers []string
+		values      []string
+		unified     bool
+	}{
+		{name: "gmail addresses", property: "email", normalizers: []string{"email"},
+			values: []string{"Ann.Lee+shop@gmail.com", "annlee@googlemail.com"}, unified: true},
+		{name: "plus addressing", property: "email", normalizers: []string{"email"},
+			values: []string{"ann+news@example.com", "ANN@example.com"}, unified: true},
+		{name: "dots outside gmail", property: "email", normalizers: []string{"email"},
+			values: []string{"ann.lee@example.com", "annlee@example.com"}},
+		{name: "unnormalized addresses", property: "email",
+			values: []string{"ann+news@example.com", "ann@example.com"}},
+		{name: "formatted phone numbers", property: "phone", normalizers: []string{"phone"},
+			values: []string{"+94 (77) 123-4567", "0094771234567"}, unified: true},
+		{name: "national phone numbers", property: "phone", normalizers: []string{"phone"},
+			values: []string{"077 123 4567", "0771234567"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			setupMemoryStores(t)
+			addCopyRule(t, test.property)
+			rule := models.UnificationRule{RuleId: "rule-" + test.property, RuleName: test.property,
+				Property: "traits." + test.property, Normalizers: test.normalizers, Priority: 1, IsActive: true}
+			if err := stores.UnificationRules.AddUnificationRule(rule); err != nil {
+				t.Fatalf("failed to add unification rule: %v", err)
+			}
+			for i, value := range test.values {
+				profileId := fmt.Sprintf("p%d", i+1)
+				enrichWithEvent(t, profileId, map[string]interface{}{test.property: value})
+				unify(t, profileId)
+			}
+
+			p1, p2 := findProfile(t, "p1"), findProfile(t, "p2")
+			unified := !p1.ProfileHierarchy.IsParent &&
+				p1.ProfileHierarchy.ParentProfileID == p2.ProfileHierarchy.ParentProfileID
+			if unified != test.unified {
+				t.Errorf("profiles unified = %v, want %v: %+v, %+v", unified, test.unified, p1.ProfileHierarchy,
+					p2.ProfileHierarchy)
+			}
+		})
+	}
+}
@@ -7,38 +7,51 @@ import (
 	"github.com/wso2/identity-customer-data-service/pkg/locks"
 	"github.com/wso2/identity-customer-data-service/pkg/logger"
 	"github.com/wso2/identity-customer-data-service/pkg/models"
-	"github.com/wso2/identity-customer-data-service/pkg/repository"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 )
 
-func CreateOrUpdateProfile(event models.Event) (*models.Profile, error) {
-
-	mongoDB := locks.GetMongoDBInstance()
-	profileRepo := repositories.NewProfileRepository(mongoDB.Database, constants.ProfileCollection)
+// lockProfile acquires the lock that serializes the writes to the profile, retrying while another writer holds it,
+// and returns the function that releases it
+func lockProfile(profileId string, ttl time.Duration) (func(), error) {
+	return acquireLock("lock:profile:"+profileId, ttl, "profile "+profileId)
+}
 
+// acquireLock acquires the distributed lock of the key, retrying while another owner holds it, and returns the
+// function that releases it. The description names what the lock guards in errors.
+func acquireLock(lockKey string, ttl time.Duration, description string) (func(), error) {
 	lock := locks.GetDistributedLock()
-	lockKey := "lock:profile:" + event.ProfileId
 
 	// 🔁 Retry logic for acquiring the lock
-	var acquired bool
+	var owner string
 	var err error
 	for i := 0; i < constants.MaxRetryAttempts; i++ {
-		acquired, err = lock.Acquire(lockKey, 1*time.Second)
+		owner, err = lock.Acquire(lockKey, ttl)
 		if err != nil {
 			return nil, fmt.Errorf("failed to acquire lock: %v", err)
 		}
-		if acquired {
+		if owner != "" {
 			break
 		}
 		time.Sleep(100 * time.Millisecond)
 	}
-	if !acquired {
-		return nil, fmt.Errorf("could not acquire lock for profile %s after retries", event.ProfileId)
+	if owner == "" {
+		return nil, fmt.Errorf("could not acquire lock for %s after retries", description)
+	}
+	return func() { _ = lock.Release(lockKey, owner) }, nil
+}
+
+func CreateOrUpdateProfile(event models.Event) (*models.Profile, error) {
+
+	profileRepo := stores.Profiles
+
+	unlock, err := lockProfile(event.ProfileId, 1*time.Second)
+	if err != nil {
+		return nil, err
 	}
-	defer lock.Release(lockKey)
+	defer unlock()
 
 	// Safe insert if not exists (upsert)
 	profile := models.Profile{
@@ -64,8 +77,7 @@ func CreateOrUpdateProfile(event models.Event) (*models.Profile, error) {
 // GetProfile retrieves a profile
 func GetProfile(ProfileId string) (*models.Profile, error) {
 
-	mongoDB := locks.GetMongoDBInstance()
-	profileRepo := repositories.NewProfileRepository(mongoDB.Database, constants.ProfileCollection)
+	profileRepo := stores.Profiles
 
 	profile, _ := profileRepo.FindProfileByID(ProfileId)
 	if profile == nil {
@@ -112,11 +124,10 @@ func buildProfileHierarchy(profile *models.Profile, masterProfile *models.Profil
 	return profileHierarchy
 }
 
-// DeleteProfile removes a profile from MongoDB by `perma_id`
+// DeleteProfile removes a profile along with its events by `profile_id`
 func DeleteProfile(ProfileId string) error {
-	mongoDB := locks.GetMongoDBInstance()
-	profileRepo := repositories.NewProfileRepository(mongoDB.Database, constants.ProfileCollection)
-	eventRepo := repositories.NewEventRepository(mongoDB.Database, constants.EventCollection)
+	profileRepo := stores.Profiles
+	eventRepo := stores.Events
 
 	// Fetch the existing profile before deletion
 	profile, err := profileRepo.FindProfileByID(ProfileId)
@@ -184,7 +195,7 @@ func DeleteProfile(ProfileId string) error {
 }
 
 func waitForProfile(ProfileId string, maxRetries int, delay time.Duration) (*models.Profile, error) {
-	profileRepo := repositories.NewProfileRepository(locks.GetMongoDBInstance().Database, constants.ProfileCollection)
+	profileRepo := stores.Profiles
 
 	for i := 0; i < maxRetries; i++ {
 		profile, err := profileRepo.FindProfileByID(ProfileId)
@@ -201,8 +212,7 @@ func waitForProfile(ProfileId string, maxRetries int, delay time.Duration) (*mod
 
 // GetAllProfiles retrieves all profiles
 func GetAllProfiles() ([]models.Profile, error) {
-	mongoDB := locks.GetMongoDBInstance()
-	profileRepo := repositories.NewProfileRepository(mongoDB.Database, constants.ProfileCollection)
+	profileRepo := stores.Profiles
 
 	existingProfiles, err := profileRepo.GetAllProfiles()
 	if err != nil {
@@ -230,12 +240,46 @@ func GetAllProfiles() ([]models.Profile, error) {
 	return result, nil
 }
 
+// findAllMasterProfiles retrieves the master profiles, including the masters created by unification, which are not
+// listed themselves but through their children
+func findAllMasterProfiles() ([]models.Profile, error) {
+	profileRepo := stores.Profiles
+
+	listedProfiles, err := profileRepo.GetAllProfiles()
+	if err != nil {
+		return nil, err
+	}
+	var masters []models.Profile
+	seen := map[string]bool{}
+	for _, profile := range listedProfiles {
+		if profile.ProfileHierarchy == nil || profile.ProfileHierarchy.IsParent {
+			if !seen[profile.ProfileId] {
+				seen[profile.ProfileId] = true
+				masters = append(masters, profile)
+			}
+			continue
+		}
+		parentId := profile.ProfileHierarchy.ParentProfileID
+		if seen[parentId] {
+			continue
+		}
+		seen[parentId] = true
+		master, err := profileRepo.FindProfileByID(parentId)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch master profile %s: %v", parentId, err)
+		}
+		if master != nil {
+			masters = append(masters, *master)
+		}
+	}
+	return masters, nil
+}
+
 // GetAllProfilesWithFilter handles fetching all profiles with filter
 func GetAllProfilesWithFilter(filters []string) ([]models.Profile, error) {
 
-	mongoDB := locks.GetMongoDBInstance()
-	profileRepo := repositories.NewProfileRepository(mongoDB.Database, constants.ProfileCollection)
-	schemaRepo := repositories.NewProfileSchemaRepository(mongoDB.Database, constants.ProfileSchemaCollection)
+	profileRepo := stores.Profiles
+	schemaRepo := stores.EnrichmentRules
 	rules, err := schemaRepo.GetProfileEnrichmentRules()
 	if err != nil {
 		return nil, errors.NewServerError(errors.ErrWhileFetchingProfileEnrichmentRules, err)
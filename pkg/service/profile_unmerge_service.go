@@ -0,0 +1,315 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wso2/identity-customer-data-service/pkg/constants"
+	"github.com/wso2/identity-customer-data-service/pkg/errors"
+	"github.com/wso2/identity-customer-data-service/pkg/locks"
+	"github.com/wso2/identity-customer-data-service/pkg/logger"
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+	repositories "github.com/wso2/identity-customer-data-service/pkg/repository"
+)
+
+// UnmergeProfile splits a profile from the master profile it was unified with, such as when a bad rule or a shared
+// email merged two customers. As the merged values cannot be told apart, the profile becomes a master of its own and
+// both it and the remaining master are rebuilt from their own events. The unmerge is recorded with its reason and
+// keeps the unification rules from merging the profiles again.
+//
+// The profiles are rebuilt aside and swapped in once complete, the master first. A split that fails before the
+// profile is swapped in leaves it merged, so that it can be retried.
+func UnmergeProfile(profileId string, request models.ProfileUnmergeRequest,
+	author string) (*models.ProfileUnmerge, error) {
+	reason := strings.TrimSpace(request.Reason)
+	if reason == "" {
+		return nil, errors.NewClientError(errors.ErrorMessage{
+			Code:        errors.ErrBadRequest.Code,
+			Message:     errors.ErrBadRequest.Message,
+			Description: "A reason is required to unmerge a profile.",
+		}, http.StatusBadRequest)
+	}
+
+	profileRepo := stores.Profiles
+	profile, err := findMergedProfile(profileId)
+	if err != nil {
+		return nil, err
+	}
+	masterId := profile.ProfileHierarchy.ParentProfileID
+
+	// Neither profile is unified or enriched while it is being split
+	unlock, err := lockUnmerge(profileId, masterId)
+	if err != nil {
+		return nil, errors.NewServerError(errors.ErrWhileUnmergingProfile, err)
+	}
+	defer unlock()
+
+	profile, err = findMergedProfile(profileId)
+	if err != nil {
+		return nil, err
+	}
+	if profile.ProfileHierarchy.ParentProfileID != masterId {
+		return nil, errors.NewServerError(errors.ErrWhileUnmergingProfile,
+			fmt.Errorf("profile %s was unified with another master profile meanwhile", profileId))
+	}
+	master, err := profileRepo.FindProfileByID(masterId)
+	if err != nil {
+		return nil, errors.NewServerError(errors.ErrWhileFetchingProfile, err)
+	}
+
+	unmerge := models.ProfileUnmerge{
+		UnmergeId:       uuid.New().String(),
+		ProfileId:       profileId,
+		MasterProfileId: masterId,
+		Reason:          reason,
+		Author:          author,
+		UnmergedAt:      time.Now().UTC().Unix(),
+	}
+	var remainingChildren []models.ChildProfile
+	if master != nil && master.ProfileHierarchy != nil {
+		for _, child := range master.ProfileHierarchy.ChildProfiles {
+			if child.ChildProfileId == profileId {
+				unmerge.RuleName = child.RuleName
+				continue
+			}
+			remainingChildren = append(remainingChildren, child)
+			unmerge.SeparatedFrom = append(unmerge.SeparatedFrom, child.ChildProfileId)
+		}
+	}
+
+	// Step 1: Rebuild the profile and the master from their own events
+	rebuiltProfile, err := rebuildProfile(profileId)
+	if err != nil {
+		return nil, errors.NewServerError(errors.ErrWhileUnmergingProfile, err)
+	}
+	rebuiltProfile.ProfileId = profileId
+	rebuiltProfile.OriginCountry = profile.OriginCountry
+	rebuiltProfile.ProfileHierarchy = &models.ProfileHierarchy{IsParent: true, ListProfile: true}
+
+	var rebuiltMaster *models.Profile
+	if master != nil {
+		if len(remainingChildren) > 0 {
+			rebuiltMaster, err = rebuildProfile(unmerge.SeparatedFrom...)
+			if err != nil {
+				return nil, errors.NewServerError(errors.ErrWhileUnmergingProfile, err)
+			}
+		} else {
+			rebuiltMaster = &models.Profile{}
+		}
+		rebuiltMaster.ProfileId = masterId
+		rebuiltMaster.OriginCountry = master.OriginCountry
+		hierarchy := models.ProfileHierarchy{IsParent: true}
+		if master.ProfileHierarchy != nil {
+			hierarchy = *master.ProfileHierarchy
+		}
+		hierarchy.ChildProfiles = remainingChildren
+		rebuiltMaster.ProfileHierarchy = &hierarchy
+	}
+
+	// Step 2: Swap in the master, then the profile. Until the profile is swapped in it still points to the master and
+	// a retry splits it again.
+	if rebuiltMaster != nil {
+		if err := profileRepo.ReplaceProfile(*rebuiltMaster); err != nil {
+			return nil, errors.NewServerError(errors.ErrWhileUnmergingProfile, err)
+		}
+	}
+	if err := profileRepo.ReplaceProfile(*rebuiltProfile); err != nil {
+		return nil, errors.NewServerError(errors.ErrWhileUnmergingProfile, err)
+	}
+	// A master left without children was only created by unification and is removed
+	if rebuiltMaster != nil && len(remainingChildren) == 0 {
+		if err := profileRepo.DeleteProfile(masterId); err != nil {
+			logger.Error(err, fmt.Sprintf("Failed to delete master profile %s left without children", masterId))
+		}
+	}
+
+	// Step 3: Record the unmerge, which keeps the profiles apart from now on
+	if err := recordProfileUnmerge(unmerge); err != nil {
+		return nil, errors.NewServerError(errors.ErrWhileUnmergingProfile, err)
+	}
+
+	logger.Info(fmt.Sprintf("Unmerged profile %s from master profile %s: %s", profileId, masterId, reason))
+	return &unmerge, nil
+}
+
+// findMergedProfile fetches a profile that was unified with a master profile
+func findMergedProfile(profileId string) (*models.Profile, error) {
+	profile, err := stores.Profiles.FindProfileByID(profileId)
+	if err != nil {
+		return nil, errors.NewServerError(errors.ErrWhileFetchingProfile, err)
+	}
+	if profile == nil {
+		return nil, errors.NewClientError(errors.ErrProfileNotFound, http.StatusNotFound)
+	}
+	if profile.ProfileHierarchy == nil || profile.ProfileHierarchy.IsParent {
+		return nil, errors.NewClientError(errors.ErrProfileNotMerged, http.StatusConflict)
+	}
+	return profile, nil
+}
+
+// lockUnmerge acquires the locks that keep the profile and its master from being unified or written to while the
+// profile is split from the master, and returns the function that releases them
+func lockUnmerge(profileId, masterId string) (func(), error) {
+	lock := locks.GetDistributedLock()
+	var releases []func()
+	release := func() {
+		for i := len(releases) - 1; i >= 0; i-- {
+			releases[i]()
+		}
+	}
+
+	for _, lockKey := range []string{"lock:unify:" + profileId, "lock:unify:" + masterId} {
+		var owner string
+		var err error
+		for i := 0; i < constants.MaxRetryAttempts; i++ {
+			owner, err = lock.Acquire(lockKey, 30*time.Second)
+			if err != nil || owner != "" {
+				break
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		if err == nil && owner == "" {
+			err = fmt.Errorf("could not acquire lock %s after retries", lockKey)
+		}
+		if err != nil {
+			release()
+			return nil, err
+		}
+		releases = append(releases, func() { _ = lock.Release(lockKey, owner) })
+	}
+	for _, lockedId := range []string{masterId, profileId} {
+		unlock, err := lockProfile(lockedId, 30*time.Second)
+		if err != nil {
+			release()
+			return nil, err
+		}
+		releases = append(releases, unlock)
+	}
+	return release, nil
+}
+
+// recordProfileUnmerge stores the unmerge, retrying as the profiles were already split
+func recordProfileUnmerge(unmerge models.ProfileUnmerge) error {
+	var err error
+	for i := 0; i < constants.MaxRetryAttempts; i++ {
+		if err = stores.ProfileUnmerges.AddProfileUnmerge(unmerge); err == nil {
+			return nil
+		}
+		time.Sleep(constants.RetryDelay)
+	}
+	return fmt.Errorf("failed to record the unmerge of profile %s: %v", unmerge.ProfileId, err)
+}
+
+// GetProfileUnmerges retrieves the unmerges that split the profile from its master or split profiles from it, the
+// latest first
+func GetProfileUnmerges(profileId string) ([]models.ProfileUnmerge, error) {
+	unmerges, err := stores.ProfileUnmerges.GetProfileUnmerges([]string{profileId})
+	if err != nil {
+		return nil, errors.NewServerError(errors.ErrWhileFetchingProfileUnmerges, err)
+	}
+	if unmerges == nil {
+		unmerges = []models.ProfileUnmerge{}
+	}
+	return unmerges, nil
+}
+
+// rebuildProfile enriches a new profile with the stored events of the profiles in the order they occurred and
+// returns it. The new profile is neither listed nor unified, and is removed once rebuilt.
+func rebuildProfile(profileIds ...string) (*models.Profile, error) {
+	var events []models.Event
+	for _, profileId := range profileIds {
+		profileEvents, err := stores.Events.FindEventsWithFilter(repositories.EventFilter{ProfileId: profileId})
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch the events of profile %s: %v", profileId, err)
+		}
+		events = append(events, profileEvents...)
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].EventTimestamp < events[j].EventTimestamp
+	})
+
+	rebuiltId := uuid.New().String()
+	err := stores.Profiles.InsertProfile(models.Profile{
+		ProfileId:        rebuiltId,
+		ProfileHierarchy: &models.ProfileHierarchy{},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a profile to rebuild: %v", err)
+	}
+	defer func() {
+		if err := stores.Profiles.DeleteProfile(rebuiltId); err != nil {
+			logger.Error(err, fmt.Sprintf("Failed to delete rebuilt profile %s", rebuiltId))
+		}
+	}()
+
+	for _, event := range events {
+		// The merge strategies read the values written by the previous events
+		rebuilt, err := stores.Profiles.FindProfileByID(rebuiltId)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch the rebuilt profile: %v", err)
+		}
+		if rebuilt == nil {
+			return nil, fmt.Errorf("rebuilt profile %s not found", rebuiltId)
+		}
+		if err := enrichProfileWithEvent(event, rebuilt); err != nil {
+			return nil, fmt.Errorf("failed to replay event %s of profile %s: %v", event.EventId, event.ProfileId, err)
+		}
+	}
+
+	rebuilt, err := stores.Profiles.FindProfileByID(rebuiltId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the rebuilt profile: %v", err)
+	}
+	if rebuilt == nil {
+		return nil, fmt.Errorf("rebuilt profile %s not found", rebuiltId)
+	}
+	return rebuilt, nil
+}
+
+// keptApart reports whether an unmerge separated a profile in the hierarchy of the one profile from a profile in
+// the hierarchy of the other, in which case the two are not unified again
+func keptApart(profile models.Profile, candidate models.Profile) (bool, error) {
+	profileIds := hierarchyProfileIds(profile)
+	candidateIds := hierarchyProfileIds(candidate)
+	unmerges, err := stores.ProfileUnmerges.GetProfileUnmerges(append(append([]string{}, profileIds...),
+		candidateIds...))
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch the unmerges of profile %s: %v", profile.ProfileId, err)
+	}
+	if len(unmerges) == 0 {
+		return false, nil
+	}
+
+	inProfile := map[string]bool{}
+	for _, profileId := range profileIds {
+		inProfile[profileId] = true
+	}
+	inCandidate := map[string]bool{}
+	for _, profileId := range candidateIds {
+		inCandidate[profileId] = true
+	}
+	for _, unmerge := range unmerges {
+		for _, separated := range unmerge.SeparatedFrom {
+			if (inProfile[unmerge.ProfileId] && inCandidate[separated]) ||
+				(inCandidate[unmerge.ProfileId] && inProfile[separated]) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// hierarchyProfileIds lists the profile and its children
+func hierarchyProfileIds(profile models.Profile) []string {
+	profileIds := []string{profile.ProfileId}
+	if profile.ProfileHierarchy != nil {
+		for _, child := range profile.ProfileHierarchy.ChildProfiles {
+			profileIds = append(profileIds, child.ChildProfileId)
+		}
+	}
+	return profileIds
+}
@@ -0,0 +1,203 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/wso2/identity-customer-data-service/pkg/locks"
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+	repositories "github.com/wso2/identity-customer-data-service/pkg/repository"
+)
+
+// failingReplaceStore fails to replace the profile with the given id
+type failingReplaceStore struct {
+	repositories.ProfileStore
+	profileId string
+}
+
+func (s *failingReplaceStore) ReplaceProfile(profile models.Profile) error {
+	if profile.ProfileId == s.profileId {
+		return fmt.Errorf("replace failed")
+	}
+	return s.ProfileStore.ReplaceProfile(profile)
+}
+
+// setupMergedProfiles unifies profiles p1, p2 and p3 by their email, each with a plan of its own, and returns the id
+// of their master
+func setupMergedProfiles(t *testing.T) string {
+	t.Helper()
+	setupMemoryStores(t)
+	addCopyRule(t, "email")
+	addCopyRule(t, "plan")
+	addEmailUnificationRule(t)
+
+	for i, plan := range []string{"gold", "silver", "bronze"} {
+		profileId := fmt.Sprintf("p%d", i+1)
+		enrichWithEvent(t, profileId, map[string]interface{}{"email": "ann@example.com", "plan": plan})
+		unify(t, profileId)
+	}
+	masterId := findProfile(t, "p1").ProfileHierarchy.ParentProfileID
+	if masterId == "" || findProfile(t, "p2").ProfileHierarchy.ParentProfileID != masterId ||
+		findProfile(t, "p3").ProfileHierarchy.ParentProfileID != masterId {
+		t.Fatalf("profiles p1, p2 and p3 were not unified")
+	}
+	return masterId
+}
+
+func unmergeProfile(t *testing.T, profileId string) *models.ProfileUnmerge {
+	t.Helper()
+	unmerge, err := UnmergeProfile(profileId, models.ProfileUnmergeRequest{Reason: "shared email"}, "admin")
+	if err != nil {
+		t.Fatalf("failed to unmerge profile %s: %v", profileId, err)
+	}
+	return unmerge
+}
+
+func TestUnmergeProfileRebuildsBothProfilesFromTheirEvents(t *testing.T) {
+	masterId := setupMergedProfiles(t)
+
+	unmerge := unmergeProfile(t, "p2")
+	if unmerge.MasterProfileId != masterId || unmerge.RuleName != "email" || len(unmerge.SeparatedFrom) != 2 {
+		t.Errorf("unmerge = %+v, want p2 split by rule email from the two other children of %s", unmerge, masterId)
+	}
+
+	p2 := findProfile(t, "p2")
+	if !p2.ProfileHierarchy.IsParent || !p2.ProfileHierarchy.ListProfile || p2.ProfileHierarchy.ParentProfileID != "" {
+		t.Errorf("split profile hierarchy = %+v, want a listed master of its own", p2.ProfileHierarchy)
+	}
+	if p2.Traits["plan"] != "silver" || p2.Traits["email"] != "ann@example.com" {
+		t.Errorf("split profile traits = %v, want its own plan and email", p2.Traits)
+	}
+
+	master := findProfile(t, masterId)
+	if len(master.ProfileHierarchy.ChildProfiles) != 2 {
+		t.Fatalf("master children = %+v, want p1 and p3", master.ProfileHierarchy.ChildProfiles)
+	}
+	for _, child := range master.ProfileHierarchy.ChildProfiles {
+		if child.ChildProfileId == "p2" {
+			t.Errorf("master still lists the split profile")
+		}
+	}
+	if plan := master.Traits["plan"]; plan != "gold" && plan != "bronze" {
+		t.Errorf("master traits.plan = %v, want the plan of p1 or p3", plan)
+	}
+
+	// The rules do not merge the split profile again
+	unify(t, "p2")
+	if p2 := findProfile(t, "p2"); !p2.ProfileHierarchy.IsParent {
+		t.Errorf("split profile was unified again: %+v", p2.ProfileHierarchy)
+	}
+	unmerges, err := GetProfileUnmerges(masterId)
+	if err != nil {
+		t.Fatalf("failed to fetch the unmerges: %v", err)
+	}
+	if len(unmerges) != 1 || unmerges[0].UnmergeId != unmerge.UnmergeId {
+		t.Errorf("unmerges of the master = %+v, want the recorded unmerge", unmerges)
+	}
+}
+
+func TestUnmergeProfileRemovesMasterLeftWithoutChildren(t *testing.T) {
+	masterId := setupMergedProfiles(t)
+
+	for _, profileId := range []string{"p1", "p2", "p3"} {
+		unmergeProfile(t, profileId)
+	}
+	if master, err := stores.Profiles.FindProfileByID(masterId); err != nil || master != nil {
+		t.Errorf("master left without children = %+v, %v, want it removed", master, err)
+	}
+	if _, err := UnmergeProfile("p3", models.ProfileUnmergeRequest{Reason: "again"}, "admin"); err == nil {
+		t.Errorf("unmerging a profile that is not merged succeeded")
+	}
+}
+
+func TestUnmergeProfileCanBeRetriedAfterAFailedSplit(t *testing.T) {
+	masterId := setupMergedProfiles(t)
+
+	store := stores.Profiles
+	stores.Profiles = &failingReplaceStore{ProfileStore: store, profileId: "p2"}
+	if _, err := UnmergeProfile("p2", models.ProfileUnmergeRequest{Reason: "shared email"}, "admin"); err == nil {
+		t.Fatalf("unmerge succeeded although the split profile could not be replaced")
+	}
+	stores.Profiles = store
+
+	if p2 := findProfile(t, "p2"); p2.ProfileHierarchy.IsParent || p2.ProfileHierarchy.ParentProfileID != masterId {
+		t.Fatalf("profile p2 left the master although the split failed: %+v", p2.ProfileHierarchy)
+	}
+	if unmerges, _ := GetProfileUnmerges("p2"); len(unmerges) != 0 {
+		t.Fatalf("failed split was recorded: %+v", unmerges)
+	}
+
+	unmergeProfile(t, "p2")
+	if p2 := findProfile(t, "p2"); !p2.ProfileHierarchy.IsParent || p2.Traits["plan"] != "silver" {
+		t.Errorf("retried split left profile p2 as %+v with traits %v", p2.ProfileHierarchy, p2.Traits)
+	}
+	if unmerges, _ := GetProfileUnmerges("p2"); len(unmerges) != 1 {
+		t.Errorf("unmerges of p2 = %+v, want the retried one", unmerges)
+	}
+}
+
+func TestUnmergeProfileWaitsForWritesToTheMaster(t *testing.T) {
+	masterId := setupMergedProfiles(t)
+
+	lock := locks.GetDistributedLock()
+	owner, err := lock.Acquire("lock:profile:"+masterId, 10*time.Second)
+	if err != nil || owner == "" {
+		t.Fatalf("failed to lock the master profile: %v", err)
+	}
+	if _, err := UnmergeProfile("p2", models.ProfileUnmergeRequest{Reason: "shared email"}, "admin"); err == nil {
+		t.Fatalf("unmerge succeeded while the master profile was being written")
+	}
+	if p2 := findProfile(t, "p2"); p2.ProfileHierarchy.IsParent {
+		t.Errorf("profile p2 was split while the master profile was being written")
+	}
+
+	_ = lock.Release("lock:profile:"+masterId, owner)
+	unmergeProfile(t, "p2")
+}
+
+func TestUnmergeProfileRebuildsTheDevicesOfTheProfiles(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		profileId string
+		wantSplit []string
+		wantRest  []string
+	}{
+		{name: "first child", profileId: "p1", wantSplit: []string{"d1"}, wantRest: []string{"d2", "d3"}},
+		{name: "later child", profileId: "p3", wantSplit: []string{"d3"}, wantRest: []string{"d1", "d2"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			setupMemoryStores(t)
+			addCopyRule(t, "email")
+			addEmailUnificationRule(t)
+			for i := 1; i <= 3; i++ {
+				profileId := fmt.Sprintf("p%d", i)
+				storeAndEnrich(t, models.Event{EventId: fmt.Sprintf("e%d", i), ProfileId: profileId, OrgId: "org",
+					AppId: "app", EventType: "track", EventName: "signup", EventTimestamp: int(time.Now().Unix()) + i,
+					Properties: map[string]interface{}{"email": "ann@example.com"},
+					Context:    map[string]interface{}{"device_id": fmt.Sprintf("d%d", i), "os": "android"}})
+				unify(t, profileId)
+			}
+			masterId := findProfile(t, "p1").ProfileHierarchy.ParentProfileID
+
+			unmergeProfile(t, test.profileId)
+			deviceIds := func(profile *models.Profile) []string {
+				var ids []string
+				for _, app := range profile.ApplicationData {
+					for _, device := range app.Devices {
+						ids = append(ids, device.DeviceId)
+					}
+				}
+				sort.Strings(ids)
+				return ids
+			}
+			if got := deviceIds(findProfile(t, test.profileId)); fmt.Sprint(got) != fmt.Sprint(test.wantSplit) {
+				t.Errorf("devices of the split profile = %v, want %v", got, test.wantSplit)
+			}
+			if got := deviceIds(findProfile(t, masterId)); fmt.Sprint(got) != fmt.Sprint(test.wantRest) {
+				t.Errorf("devices of the master = %v, want %v", got, test.wantRest)
+			}
+		})
+	}
+}
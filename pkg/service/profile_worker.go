@@ -1,18 +1,20 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/google/uuid"
+	"github.com/wso2/identity-customer-data-service/config"
 	"github.com/wso2/identity-customer-data-service/pkg/constants"
 	"github.com/wso2/identity-customer-data-service/pkg/locks"
 	"github.com/wso2/identity-customer-data-service/pkg/logger"
 	"github.com/wso2/identity-customer-data-service/pkg/models"
 	repositories "github.com/wso2/identity-customer-data-service/pkg/repository"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"log"
+	"math"
 	"reflect"
 	"sort"
 	"strconv"
@@ -20,45 +22,83 @@ import (
 	"time"
 )
 
-var EnrichmentQueue chan models.Event
+var profileWorker struct {
+	pool    *workerPool
+	stop    chan struct{}
+	stopped chan struct{}
+}
 
-func StartProfileWorker() {
-	EnrichmentQueue = make(chan models.Event, 1000)
+// StartProfileWorker starts a pool of workers that claim events from the durable event queue and enrich and unify
+// the profiles they belong to. Events of the same profile are processed in order by a single worker.
+func StartProfileWorker(queueConfig config.EventQueueConfig) {
+	eventQueueConfig = withEventQueueDefaults(queueConfig)
+	workerId := uuid.New().String()
+	pool := newWorkerPool(eventQueueConfig.Concurrency, eventQueueConfig.BatchSize, processEvent)
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	profileWorker.pool, profileWorker.stop, profileWorker.stopped = pool, stop, stopped
 
 	go func() {
-		for event := range EnrichmentQueue {
-			profileRepo := repositories.NewProfileRepository(locks.GetMongoDBInstance().Database, constants.ProfileCollection)
+		defer close(stopped)
+		pool.run(workerId, eventQueueConfig.BatchSize, time.Duration(eventQueueConfig.LeaseSeconds)*time.Second,
+			time.Duration(eventQueueConfig.PollIntervalMs)*time.Millisecond, stop)
+	}()
+}
 
-			// Step 1: Enrich
-			if err := EnrichProfile(event); err != nil {
-				logger.Error(err, fmt.Sprintf("Failed to enrich profile %a with event %s ", event.ProfileId,
-					event.EventId))
-				continue
-			}
+// StopProfileWorker stops claiming new events and waits for the claimed ones to be processed. If the context ends
+// first, the claimed events that have not been started are released back to the queue, and the ones in progress
+// become claimable again once their lease expires.
+func StopProfileWorker(ctx context.Context) error {
+	if profileWorker.stop == nil {
+		return nil
+	}
+	close(profileWorker.stop)
+
+	select {
+	case <-profileWorker.stopped:
+		logger.Info("Profile worker drained the claimed events")
+		return nil
+	case <-ctx.Done():
+		profileWorker.pool.abandon()
+		return fmt.Errorf("profile worker did not drain the claimed events in time: %v", ctx.Err())
+	}
+}
 
-			// Step 2: Unify
-			profile, err := profileRepo.FindProfileByID(event.ProfileId)
-			if err == nil && profile != nil {
-				logger.Info("🔄 Unifying profile:", profile.ProfileId)
-				if _, err := unifyProfiles(*profile); err != nil {
-					logger.Error(err, fmt.Sprintf("Failed to unify profile %s with event %s ", event.ProfileId,
-						event.EventId))
-				}
-			}
+// processEvent enriches the profile of the event and unifies it with the existing profiles
+func processEvent(event models.Event) error {
+	profileRepo := stores.Profiles
+
+	// Step 1: Enrich
+	if err := EnrichProfile(event); err != nil {
+		return fmt.Errorf("failed to enrich profile %s with event %s: %v", event.ProfileId, event.EventId, err)
+	}
+
+	// Alias events link profiles explicitly instead of through unification rules
+	if event.EventType == constants.AliasEventType {
+		if err := aliasProfiles(event); err != nil {
+			return fmt.Errorf("failed to alias profile %s with event %s: %v", event.ProfileId, event.EventId, err)
 		}
-	}()
-}
+		return nil
+	}
 
-func EnqueueEventForProcessing(event models.Event) {
-	if EnrichmentQueue != nil {
-		EnrichmentQueue <- event
+	// Step 2: Unify
+	profile, err := profileRepo.FindProfileByID(event.ProfileId)
+	if err != nil {
+		return fmt.Errorf("failed to fetch profile %s for unification: %v", event.ProfileId, err)
 	}
+	if profile != nil {
+		logger.Info("🔄 Unifying profile:", profile.ProfileId)
+		if _, err := unifyProfiles(*profile); err != nil {
+			return fmt.Errorf("failed to unify profile %s with event %s: %v", event.ProfileId, event.EventId, err)
+		}
+	}
+	return nil
 }
 
 // EnrichProfile extracts properties from events and enrich profile based on the enrichment rules
 func EnrichProfile(event models.Event) error {
 
-	profileRepo := repositories.NewProfileRepository(locks.GetMongoDBInstance().Database, constants.ProfileCollection)
+	profileRepo := stores.Profiles
 
 	profile, _ := waitForProfile(event.ProfileId, 5, 100*time.Millisecond)
 
@@ -71,16 +111,79 @@ func EnrichProfile(event models.Event) error {
 			profile, _ = profileRepo.FindProfileByID(profile.ProfileHierarchy.ParentProfileID)
 		}
 	}
+	if profile == nil {
+		return fmt.Errorf("master profile not found to enrich")
+	}
+
+	// The merge strategies read the current values, so the profile is read again once its writes are serialized
+	unlock, err := lockProfile(profile.ProfileId, 5*time.Second)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	profile, err = profileRepo.FindProfileByID(profile.ProfileId)
+	if err != nil {
+		return fmt.Errorf("failed to fetch profile to enrich: %v", err)
+	}
+	if profile == nil {
+		return fmt.Errorf("profile not found to enrich")
+	}
+	return enrichProfileWithEvent(event, profile)
+}
+
+// enrichProfileWithEvent writes the values of the event to the profile, the master profile of the event's profile
+// or a profile being rebuilt in its place. The caller serializes the writes to the profile.
+func enrichProfileWithEvent(event models.Event, profile *models.Profile) error {
+	profileRepo := stores.Profiles
+	// The identity keys are computed once from all the values of the pass
+	defer reindexProfiles(profile.ProfileId)
 
 	err := defaultUpdateAppData(event, profile, profileRepo)
 	if err != nil {
 		return err
 	}
 
+	// Traits of identify events are written to the profile as they are
+	updated := map[string]interface{}{}
+	if strings.ToLower(event.EventType) == "identify" {
+		identifyUpdates, err := applyIdentifyTraits(event, profile, profileRepo)
+		if err != nil {
+			return err
+		}
+		for fieldPath, value := range identifyUpdates {
+			updated[fieldPath] = value
+		}
+	}
+
 	rules, _ := GetEnrichmentRules()
+	ruleUpdates, err := applyEnrichmentRules(event, profile, rules)
+	if err != nil {
+		logger.Error(err, fmt.Sprintf("Failed to apply enrichment rules to profile %s", profile.ProfileId))
+	}
+	for fieldPath, value := range ruleUpdates {
+		updated[fieldPath] = value
+	}
+
+	// Rules with profile triggers see each changed trait once. The traits they update do not trigger rules again.
+	for _, profileEvent := range traitUpdatedEvents(event, profile, updated) {
+		if _, err := applyEnrichmentRules(profileEvent, profile, rules); err != nil {
+			logger.Error(err, fmt.Sprintf("Failed to apply enrichment rules to profile %s", profile.ProfileId))
+		}
+	}
+	return nil
+}
+
+// applyEnrichmentRules applies the rules triggered by the event to the profile and returns the traits and identity
+// attributes that were written, by their paths. A rule that fails to compute or write its value does not stop the
+// other rules, the failures are returned together.
+func applyEnrichmentRules(event models.Event, profile *models.Profile,
+	rules []models.ProfileEnrichmentRule) (map[string]interface{}, error) {
+
+	profileRepo := stores.Profiles
+	updated := map[string]interface{}{}
+	var failures []string
 	for _, rule := range rules {
-		if strings.ToLower(rule.Trigger.EventType) != strings.ToLower(event.EventType) ||
-			strings.ToLower(rule.Trigger.EventName) != strings.ToLower(event.EventName) {
+		if !triggerMatchesEvent(rule.Trigger, event) {
 			continue
 		}
 
@@ -90,45 +193,14 @@ func EnrichProfile(event models.Event) error {
 		}
 
 		// Step 3: Get value to assign
-		var value interface{}
-		if rule.PropertyType == "static" {
-			value = rule.Value
-		} else if rule.PropertyType == "computed" {
-			// Basic "copy" computation
-			switch strings.ToLower(rule.Computation) {
-			case "copy":
-				if len(rule.SourceFields) != 1 {
-					log.Printf("Invalid SourceFields for 'copy' computation. Expected 1, got: %d", len(rule.SourceFields))
-					continue
-				}
-				value = GetFieldFromEvent(event, rule.SourceFields[0])
-			case "concat":
-				if rule.SourceFields != nil && len(rule.SourceFields) >= 2 {
-					var parts []string
-					for _, field := range rule.SourceFields {
-						fieldVal := GetFieldFromEvent(event, field)
-						if fieldVal != nil {
-							parts = append(parts, fmt.Sprintf("%v", fieldVal))
-						}
-					}
-					if len(parts) > 0 {
-						value = strings.Join(parts, "") // You can use a separator if needed
-					}
-				}
-			case "count":
-				// here since events are per profile - going back to child profile
-				count, err := CountEventsMatchingRule(event.ProfileId, rule.Trigger, rule.TimeRange)
-				if err != nil {
-					logger.Info("Failed to compute count for rule %s: %v", rule.RuleId, err)
-					continue
-				}
-				value = count
-			default:
-				logger.Info("Unsupported computation: %s", rule.Computation)
-				continue
-			}
+		value, err := computeRuleValue(rule, event, func() ([]models.Event, error) {
+			// here since events are per profile - going back to child profile
+			return findEventsMatchingRule(event.ProfileId, rule.Trigger, rule.TimeRange)
+		})
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("failed to compute the value of rule %s: %v", rule.RuleId, err))
+			continue
 		}
-
 		if value == nil {
 			continue // skip if value couldn't be extracted
 		}
@@ -143,38 +215,196 @@ func EnrichProfile(event models.Event) error {
 		namespace := traitPath[0]
 		traitName := traitPath[1]
 		fieldPath := fmt.Sprintf("%s.%s", namespace, traitName)
-		if rule.ValueType != "" {
-			value = parseValueForValueType(rule.ValueType, value)
-		}
-		update := bson.M{fieldPath: value}
+		update := map[string]interface{}{fieldPath: value}
 		switch namespace {
 		case "traits":
-			err := profileRepo.UpsertTrait(profile.ProfileId, update)
+			err = profileRepo.UpsertTrait(profile.ProfileId, update)
+		case "identity_attributes":
+			err = profileRepo.UpsertIdentityAttribute(profile.ProfileId, update)
+		case "application_data":
+			err = profileRepo.UpsertAppDatum(profile.ProfileId, event.AppId, update)
+		default:
+			log.Printf("Unsupported trait namespace: %s", namespace)
+			continue
+		}
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("failed to update %s of rule %s: %v", fieldPath, rule.RuleId,
+				err))
+		} else if namespace != "application_data" {
+			updated[fieldPath] = value
+		}
+	}
+
+	if len(failures) > 0 {
+		return updated, fmt.Errorf("%s", strings.Join(failures, "; "))
+	}
+	return updated, nil
+}
+
+// computeRuleValue computes the value that the rule assigns for the event, coerced to the value type of the rule.
+// The count, aggregate and decay computations run over the events given by matchingEvents. Nil is returned when
+// there is no value to assign.
+func computeRuleValue(rule models.ProfileEnrichmentRule, event models.Event,
+	matchingEvents func() ([]models.Event, error)) (interface{}, error) {
+
+	var value interface{}
+	if rule.PropertyType == "static" {
+		value = rule.Value
+	} else if rule.PropertyType == "computed" {
+		computation := strings.ToLower(rule.Computation)
+		switch computation {
+		case "copy":
+			if len(rule.SourceFields) != 1 {
+				return nil, fmt.Errorf("invalid SourceFields for 'copy' computation. Expected 1, got: %d",
+					len(rule.SourceFields))
+			}
+			value = GetFieldFromEvent(event, rule.SourceFields[0])
+		case "concat":
+			if rule.SourceFields != nil && len(rule.SourceFields) >= 2 {
+				var parts []string
+				for _, field := range rule.SourceFields {
+					fieldVal := GetFieldFromEvent(event, field)
+					if fieldVal != nil {
+						parts = append(parts, fmt.Sprintf("%v", fieldVal))
+					}
+				}
+				if len(parts) > 0 {
+					value = strings.Join(parts, "") // You can use a separator if needed
+				}
+			}
+		case "count":
+			events, err := matchingEvents()
 			if err != nil {
-				log.Println("Error updating personality data:", err)
+				return nil, fmt.Errorf("failed to fetch events for counting: %v", err)
 			}
-		case "identity_attributes":
-			err := profileRepo.UpsertIdentityAttribute(profile.ProfileId, update)
+			value = len(events)
+		case "sum", "min", "max", "avg", "first", "last", "distinct":
+			if len(rule.SourceFields) != 1 {
+				return nil, fmt.Errorf("invalid SourceFields for '%s' computation. Expected 1, got: %d",
+					rule.Computation, len(rule.SourceFields))
+			}
+			events, err := matchingEvents()
 			if err != nil {
-				log.Println("Error updating identity data:", err)
+				return nil, fmt.Errorf("failed to fetch events for aggregation: %v", err)
 			}
-			continue
-		case "application_data":
-			err := profileRepo.UpsertAppDatum(profile.ProfileId, event.AppId, update)
+			value = aggregateEventValues(events, computation, rule.SourceFields[0])
+		case "decay":
+			halfLife, err := parseTimeWindow(rule.HalfLife)
 			if err != nil {
-				log.Println("Error updating application data:", err)
+				return nil, fmt.Errorf("invalid half life for 'decay' computation: %v", err)
 			}
-			continue
+			events, err := matchingEvents()
+			if err != nil {
+				return nil, fmt.Errorf("failed to fetch events for the decay score: %v", err)
+			}
+			weightField := ""
+			if len(rule.SourceFields) == 1 {
+				weightField = rule.SourceFields[0]
+			}
+			value = decayScore(events, weightField, halfLife, time.Now().UTC())
+		case "expression":
+			result, err := EvaluateExpression(rule.Expression, event)
+			if err != nil {
+				return nil, fmt.Errorf("failed to evaluate the expression: %v", err)
+			}
+			value = result
 		default:
-			log.Printf("Unsupported trait namespace: %s", namespace)
+			return nil, fmt.Errorf("unsupported computation: %s", rule.Computation)
+		}
+	}
+
+	if value != nil && rule.ValueType != "" {
+		value = parseValueForValueType(rule.ValueType, value)
+	}
+	return value, nil
+}
+
+// computesOverEvents reports whether the rule computes its value over all the events matching its trigger rather
+// than from the triggering event alone
+func computesOverEvents(rule models.ProfileEnrichmentRule) bool {
+	computation := strings.ToLower(rule.Computation)
+	return rule.PropertyType == "computed" &&
+		(computation == "count" || computation == "decay" || constants.AggregateComputations[computation])
+}
+
+// traitUpdatedEvents describes the changes of the traits and identity attributes of the profile made while
+// processing the event as profile events, which trigger the rules with profile triggers
+func traitUpdatedEvents(event models.Event, profile *models.Profile, updated map[string]interface{}) []models.Event {
+	fieldPaths := make([]string, 0, len(updated))
+	for fieldPath := range updated {
+		fieldPaths = append(fieldPaths, fieldPath)
+	}
+	sort.Strings(fieldPaths)
+
+	var profileEvents []models.Event
+	for _, fieldPath := range fieldPaths {
+		value := updated[fieldPath]
+		var previous interface{}
+		namespace, name, _ := strings.Cut(fieldPath, ".")
+		if namespace == "traits" {
+			previous = profile.Traits[name]
+		} else {
+			previous = profile.IdentityAttributes[name]
+		}
+		if previous != nil && fmt.Sprintf("%v", previous) == fmt.Sprintf("%v", value) {
 			continue
 		}
+
+		profileEvents = append(profileEvents, models.Event{
+			ProfileId:      event.ProfileId,
+			EventType:      constants.ProfileTriggerEventType,
+			EventName:      constants.TraitUpdatedEventName,
+			EventId:        event.EventId,
+			AppId:          event.AppId,
+			OrgId:          event.OrgId,
+			EventTimestamp: event.EventTimestamp,
+			Properties: map[string]interface{}{
+				"property_name":  fieldPath,
+				"value":          value,
+				"previous_value": previous,
+			},
+			Context: event.Context,
+		})
 	}
+	return profileEvents
+}
 
-	return nil
+// applyIdentifyTraits writes the traits of an identify event to the profile and returns them by their paths. Traits
+// naming the user are kept as identity attributes and the rest as profile traits.
+func applyIdentifyTraits(event models.Event, profile *models.Profile,
+	profileRepo repositories.ProfileStore) (map[string]interface{}, error) {
+	identityUpdates := map[string]interface{}{}
+	traitUpdates := map[string]interface{}{}
+	for name, value := range event.Properties {
+		if name == "" || strings.Contains(name, ".") || value == nil {
+			logger.Debug(fmt.Sprintf("Skipping trait '%s' of identify event %s", name, event.EventId))
+			continue
+		}
+		if constants.IdentityTraits[name] {
+			identityUpdates["identity_attributes."+name] = value
+		} else {
+			traitUpdates["traits."+name] = value
+		}
+	}
+
+	if len(identityUpdates) > 0 {
+		if err := profileRepo.UpsertIdentityAttribute(profile.ProfileId, identityUpdates); err != nil {
+			return nil, fmt.Errorf("failed to update identity attributes from identify event: %v", err)
+		}
+	}
+	if len(traitUpdates) > 0 {
+		if err := profileRepo.UpsertTrait(profile.ProfileId, traitUpdates); err != nil {
+			return nil, fmt.Errorf("failed to update traits from identify event: %v", err)
+		}
+	}
+
+	for fieldPath, value := range traitUpdates {
+		identityUpdates[fieldPath] = value
+	}
+	return identityUpdates, nil
 }
 
-func defaultUpdateAppData(event models.Event, profile *models.Profile, profileRepo *repositories.ProfileRepository) error {
+func defaultUpdateAppData(event models.Event, profile *models.Profile, profileRepo repositories.ProfileStore) error {
 	if event.Context != nil {
 		if raw, ok := event.Context["device_id"]; ok {
 			if deviceID, ok := raw.(string); ok && deviceID != "" {
@@ -200,11 +430,11 @@ func defaultUpdateAppData(event models.Event, profile *models.Profile, profileRe
 					devices.DeviceType = deviceType
 				}
 
-				profileId := event.ProfileId
-
-				// Enriching only the master profile
+				// Enriching only the master profile, or the profile being rebuilt in its place
 				//todo: Enrich only the permanent profile
-				if !profile.ProfileHierarchy.IsParent {
+				profileId := profile.ProfileId
+				if profile.ProfileHierarchy != nil && profile.ProfileHierarchy.ParentProfileID != "" &&
+					!profile.ProfileHierarchy.IsParent {
 					profileId = profile.ProfileHierarchy.ParentProfileID
 				}
 				appContext := models.ApplicationData{
@@ -223,22 +453,18 @@ func defaultUpdateAppData(event models.Event, profile *models.Profile, profileRe
 }
 
 func unifyProfiles(newProfile models.Profile) (*models.Profile, error) {
-	mongoDB := locks.GetMongoDBInstance()
-
 	lock := locks.GetDistributedLock()
 	lockKey := "lock:unify:" + newProfile.ProfileId
 
 	// Try to acquire the lock before doing unification
-	acquired, err := lock.Acquire(lockKey, 5*time.Second)
+	owner, err := lock.Acquire(lockKey, 5*time.Second)
 	if err != nil {
 		return nil, fmt.Errorf("failed to acquire lock for unification: %v", err)
 	}
-	if !acquired {
+	if owner == "" {
 		return nil, nil // Or retry logic if needed
 	}
-	defer lock.Release(lockKey) // Always release
-
-	profileRepo := repositories.NewProfileRepository(mongoDB.Database, constants.ProfileCollection)
+	defer lock.Release(lockKey, owner) // Always release
 
 	// Step 1: Fetch all unification rules
 	unificationRules, err := GetUnificationRules()
@@ -246,100 +472,220 @@ func unifyProfiles(newProfile models.Profile) (*models.Profile, error) {
 		return nil, errors.New("failed to fetch unification rules")
 	}
 
-	// 🔹 Step 2: Fetch all existing profiles from DB
-	existingMasterProfiles, err := profileRepo.GetAllMasterProfilesExceptForCurrent(newProfile)
+	newJSON, err := json.Marshal(newProfile)
 	if err != nil {
-		return nil, errors.New("failed to fetch existing profiles")
+		return nil, fmt.Errorf("failed to encode profile %s: %v", newProfile.ProfileId, err)
+	}
+
+	indexed, err := isIdentityIndexBuilt(unificationRules)
+	if err != nil {
+		return nil, err
 	}
 
 	sortRulesByPriority(unificationRules)
-	// 🔹 Step 3: Loop through unification rules and compare profiles
+	// 🔹 Step 2: Loop through unification rules and compare profiles
 	for _, rule := range unificationRules {
 
-		for _, existingProfile := range existingMasterProfiles {
+		// 🔹 Step 3: Look up the profiles sharing an identity key of the rule
+		existingMasterProfiles, err := findUnificationCandidates(newProfile, newJSON, rule, indexed)
+		if err != nil {
+			return nil, err
+		}
 
-			if doesProfileMatch(existingProfile, newProfile, rule) {
+		// Probabilistic rules merge with the most similar profile
+		var bestMatch *models.Profile
+		bestConfidence := 0.0
+		for i, existingProfile := range existingMasterProfiles {
 
-				// 🔄 Merge the existing master to the old master of current
-				mongoDB := locks.GetMongoDBInstance()
-				schemaRepo := repositories.NewProfileSchemaRepository(mongoDB.Database, constants.ProfileSchemaCollection)
-				enrichmentRules, _ := schemaRepo.GetProfileEnrichmentRules()
-				newMasterProfile := MergeProfiles(existingProfile, newProfile, enrichmentRules)
+			matched, confidence := doesProfileMatch(existingProfile, newProfile, rule)
+			if !matched || confidence <= bestConfidence {
+				continue
+			}
+			// Profiles that were unmerged are not unified again
+			separated, err := keptApart(newProfile, existingProfile)
+			if err != nil {
+				return nil, err
+			}
+			if separated {
+				continue
+			}
+			bestMatch, bestConfidence = &existingMasterProfiles[i], confidence
+			if confidence >= 1 {
+				break
+			}
+		}
+		if bestMatch != nil {
+			return mergeIntoMaster(*bestMatch, newProfile, rule.RuleName, bestConfidence)
+		}
+	}
 
-				if len(existingProfile.ProfileHierarchy.ChildProfiles) == 0 {
-					newMasterProfile.ProfileId = uuid.New().String()
-					childProfile1 := models.ChildProfile{
-						ChildProfileId: newProfile.ProfileId,
-						RuleName:       rule.RuleName,
-					}
-					childProfile2 := models.ChildProfile{
-						ChildProfileId: existingProfile.ProfileId,
-						RuleName:       rule.RuleName,
-					}
-					newMasterProfile.ProfileHierarchy = &models.ProfileHierarchy{
-						IsParent:      true,
-						ListProfile:   false,
-						ChildProfiles: []models.ChildProfile{childProfile1, childProfile2},
-					}
-					// creating and inserting the new master profile
-					err := profileRepo.InsertProfile(newMasterProfile)
-					if err != nil {
-						return nil, err
-					}
+	// No unification match found, return newProfile as-is
+	return &newProfile, nil
+}
 
-					// Attaching peer profiles for each of the child profiles of old master profile
-					//profileRepo.LinkPeers(newProfile.ProfileId, existingProfile.ProfileId, rule.RuleName)
-					err = profileRepo.UpdateParent(newMasterProfile, newProfile)
-					err = profileRepo.UpdateParent(newMasterProfile, existingProfile)
-					if err != nil {
-						return nil, err
-					}
+// aliasProfiles attaches the profile of the previous id of an alias event as a child of the master of the event's
+// profile. Previous ids without a profile get one so that their later events resolve to the master.
+func aliasProfiles(event models.Event) error {
+	profileRepo := stores.Profiles
+	previousId, _ := event.Properties[constants.AliasPreviousIdProperty].(string)
 
-				} else if (len(existingProfile.ProfileHierarchy.ChildProfiles) > 0) && existingProfile.ProfileHierarchy.IsParent {
-					newChild := models.ChildProfile{
-						ChildProfileId: newProfile.ProfileId,
-						RuleName:       rule.RuleName,
-					}
-					err = profileRepo.AddChildProfile(newMasterProfile, newChild)
-					err = profileRepo.UpdateParent(newMasterProfile, newProfile)
-					if err != nil {
-						return nil, err
-					}
-				}
+	lock := locks.GetDistributedLock()
+	lockKey := "lock:unify:" + previousId
+	owner, err := lock.Acquire(lockKey, 5*time.Second)
+	if err != nil {
+		return fmt.Errorf("failed to acquire lock for alias: %v", err)
+	}
+	if owner == "" {
+		// Retried by the worker as the link must not be lost
+		return fmt.Errorf("profile %s is being unified", previousId)
+	}
+	defer lock.Release(lockKey, owner)
 
-				// Update ApplicationData
-				for _, appCtx := range newMasterProfile.ApplicationData {
-					// todo - upsert app -data -and devices - need to check
-					err := profileRepo.AddOrUpdateAppContext(newMasterProfile.ProfileId, appCtx)
-					if err != nil {
-						log.Println("Failed to update AppContext for:", appCtx.AppId, "Error:", err)
-					}
-				}
+	previousProfile, err := profileRepo.FindProfileByID(previousId)
+	if err != nil {
+		return fmt.Errorf("failed to fetch profile %s: %v", previousId, err)
+	}
+	if previousProfile == nil {
+		previousProfile = &models.Profile{
+			ProfileId: previousId,
+			ProfileHierarchy: &models.ProfileHierarchy{
+				IsParent:    true,
+				ListProfile: true,
+			},
+		}
+		if err := profileRepo.InsertProfile(*previousProfile); err != nil {
+			return fmt.Errorf("failed to insert profile %s: %v", previousId, err)
+		}
+	}
 
-				// Update Traits
-				if newMasterProfile.Traits != nil {
-					err := profileRepo.AddOrUpdateTraitsData(newMasterProfile.ProfileId, newMasterProfile.Traits)
-					if err != nil {
-						log.Println("Failed to update PersonalityData:", err)
-					}
-				}
+	knownMaster, err := findMasterProfile(event.ProfileId)
+	if err != nil {
+		return err
+	}
+	if knownMaster == nil {
+		return fmt.Errorf("profile %s not found", event.ProfileId)
+	}
 
-				// Update Identity
-				if newMasterProfile.IdentityAttributes != nil {
-					err := profileRepo.UpsertIdentityData(newMasterProfile.ProfileId, newMasterProfile.IdentityAttributes)
-					if err != nil {
-						log.Println("Failed to update IdentityData:", err)
-					}
-				}
+	if !previousProfile.ProfileHierarchy.IsParent {
+		if previousProfile.ProfileHierarchy.ParentProfileID != knownMaster.ProfileId {
+			logger.Info(fmt.Sprintf("Profile %s is already unified with profile %s and is not aliased to %s",
+				previousId, previousProfile.ProfileHierarchy.ParentProfileID, event.ProfileId))
+		}
+		return nil
+	}
+	if previousProfile.ProfileId == knownMaster.ProfileId {
+		return nil
+	}
+	if len(previousProfile.ProfileHierarchy.ChildProfiles) > 0 {
+		logger.Info(fmt.Sprintf("Profile %s is a master profile and is not aliased to %s", previousId,
+			event.ProfileId))
+		return nil
+	}
+
+	_, err = mergeIntoMaster(*knownMaster, *previousProfile, constants.AliasRuleName, 1)
+	return err
+}
 
-				return &newMasterProfile, nil
+// findMasterProfile returns the master of the profile, or the profile itself if it is a master
+func findMasterProfile(profileId string) (*models.Profile, error) {
+	profileRepo := stores.Profiles
 
-			}
+	profile, err := profileRepo.FindProfileByID(profileId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch profile %s: %v", profileId, err)
+	}
+	if profile == nil || profile.ProfileHierarchy == nil || profile.ProfileHierarchy.IsParent {
+		return profile, nil
+	}
+	master, err := profileRepo.FindProfileByID(profile.ProfileHierarchy.ParentProfileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch master profile of %s: %v", profileId, err)
+	}
+	return master, nil
+}
+
+// mergeIntoMaster merges the new profile into the existing master profile and links it as a child of the master
+// under the given rule name and the confidence of the match. A master that has no children yet is replaced by a new
+// master of both profiles.
+func mergeIntoMaster(existingProfile models.Profile, newProfile models.Profile, ruleName string,
+	confidence float64) (*models.Profile, error) {
+	profileRepo := stores.Profiles
+
+	// 🔄 Merge the existing master to the old master of current
+	enrichmentRules, _ := stores.EnrichmentRules.GetProfileEnrichmentRules()
+	newMasterProfile := MergeProfiles(existingProfile, newProfile, enrichmentRules)
+
+	if len(existingProfile.ProfileHierarchy.ChildProfiles) == 0 {
+		newMasterProfile.ProfileId = uuid.New().String()
+		childProfile1 := models.ChildProfile{
+			ChildProfileId: newProfile.ProfileId,
+			RuleName:       ruleName,
+			Confidence:     confidence,
+		}
+		childProfile2 := models.ChildProfile{
+			ChildProfileId: existingProfile.ProfileId,
+			RuleName:       ruleName,
+			Confidence:     confidence,
+		}
+		newMasterProfile.ProfileHierarchy = &models.ProfileHierarchy{
+			IsParent:      true,
+			ListProfile:   false,
+			ChildProfiles: []models.ChildProfile{childProfile1, childProfile2},
+		}
+		// creating and inserting the new master profile
+		err := profileRepo.InsertProfile(newMasterProfile)
+		if err != nil {
+			return nil, err
+		}
+
+		// Attaching peer profiles for each of the child profiles of old master profile
+		//profileRepo.LinkPeers(newProfile.ProfileId, existingProfile.ProfileId, ruleName)
+		err = profileRepo.UpdateParent(newMasterProfile, newProfile)
+		err = profileRepo.UpdateParent(newMasterProfile, existingProfile)
+		if err != nil {
+			return nil, err
+		}
+
+	} else if (len(existingProfile.ProfileHierarchy.ChildProfiles) > 0) && existingProfile.ProfileHierarchy.IsParent {
+		newChild := models.ChildProfile{
+			ChildProfileId: newProfile.ProfileId,
+			RuleName:       ruleName,
+			Confidence:     confidence,
+		}
+		err := profileRepo.AddChildProfile(newMasterProfile, newChild)
+		err = profileRepo.UpdateParent(newMasterProfile, newProfile)
+		if err != nil {
+			return nil, err
 		}
 	}
 
-	// No unification match found, return newProfile as-is
-	return &newProfile, nil
+	// Update ApplicationData
+	for _, appCtx := range newMasterProfile.ApplicationData {
+		// todo - upsert app -data -and devices - need to check
+		err := profileRepo.AddOrUpdateAppContext(newMasterProfile.ProfileId, appCtx)
+		if err != nil {
+			log.Println("Failed to update AppContext for:", appCtx.AppId, "Error:", err)
+		}
+	}
+
+	// Update Traits
+	if newMasterProfile.Traits != nil {
+		err := profileRepo.AddOrUpdateTraitsData(newMasterProfile.ProfileId, newMasterProfile.Traits)
+		if err != nil {
+			log.Println("Failed to update PersonalityData:", err)
+		}
+	}
+
+	// Update Identity
+	if newMasterProfile.IdentityAttributes != nil {
+		err := profileRepo.UpsertIdentityData(newMasterProfile.ProfileId, newMasterProfile.IdentityAttributes)
+		if err != nil {
+			log.Println("Failed to update IdentityData:", err)
+		}
+	}
+	reindexProfiles(newMasterProfile.ProfileId)
+
+	return &newMasterProfile, nil
 }
 
 func sortRulesByPriority(rules []models.UnificationRule) {
@@ -405,17 +751,70 @@ func MergeProfiles(existingProfile models.Profile, incomingProfile models.Profil
 	return merged
 }
 
-// doesProfileMatch checks if two profiles have matching attributes based on a unification rule
-func doesProfileMatch(existingProfile models.Profile, newProfile models.Profile, rule models.UnificationRule) bool {
+// doesProfileMatch checks if two profiles have matching attributes based on a unification rule and returns the
+// confidence of the match. The values are compared once normalized by the normalizers of the rule. The properties of
+// composite rules must all match, or any of them with the 'or' operator, and match with full confidence.
+// Probabilistic rules match when the confidence reaches their threshold.
+func doesProfileMatch(existingProfile models.Profile, newProfile models.Profile,
+	rule models.UnificationRule) (bool, float64) {
+
+	properties := unificationRuleProperties(rule)
+	if len(properties) == 0 {
+		return false, 0
+	}
 
 	existingJSON, _ := json.Marshal(existingProfile)
 	newJSON, _ := json.Marshal(newProfile)
-	existingValues := extractFieldFromJSON(existingJSON, rule.Property)
-	newValues := extractFieldFromJSON(newJSON, rule.Property)
-	if checkForMatch(existingValues, newValues) {
-		return true //  Match found
+	if isProbabilisticRule(rule) {
+		confidence := matchConfidence(existingJSON, newJSON, rule)
+		return confidence >= rule.Threshold, confidence
 	}
-	return false
+
+	matchAny := unificationRuleOperator(rule) == constants.UnificationOperatorOr
+	for _, property := range properties {
+		existingValues := normalizeRuleValues(extractFieldFromJSON(existingJSON, property), rule.Normalizers)
+		newValues := normalizeRuleValues(extractFieldFromJSON(newJSON, property), rule.Normalizers)
+		matched := checkForMatch(existingValues, newValues)
+		if matched && matchAny {
+			return true, 1 //  Match found
+		}
+		if !matched && !matchAny {
+			return false, 0
+		}
+	}
+	if matchAny {
+		return false, 0
+	}
+	return true, 1
+}
+
+// matchConfidence is the weighted average of the similarities of the attributes of a probabilistic rule, rounded
+// to four decimals. The similarity of an attribute is that of its most similar values, and 0 when either profile
+// has no value.
+func matchConfidence(existingJSON, newJSON []byte, rule models.UnificationRule) float64 {
+	totalWeight, score := 0.0, 0.0
+	for _, attribute := range rule.Attributes {
+		weight := attribute.Weight
+		if weight == 0 {
+			weight = 1
+		}
+		totalWeight += weight
+
+		existingValues := normalizeRuleValues(extractFieldFromJSON(existingJSON, attribute.Property), rule.Normalizers)
+		newValues := normalizeRuleValues(extractFieldFromJSON(newJSON, attribute.Property), rule.Normalizers)
+		similarity := 0.0
+		for _, existingValue := range existingValues {
+			for _, newValue := range newValues {
+				similarity = math.Max(similarity, compareValues(strings.ToLower(attribute.Comparator),
+					existingValue.(string), newValue.(string)))
+			}
+		}
+		score += weight * similarity
+	}
+	if totalWeight == 0 {
+		return 0
+	}
+	return math.Round(score/totalWeight*10000) / 10000
 }
 
 // extractFieldFromJSON extracts a nested field from raw JSON (`[]byte`) without pre-converting to a map
@@ -430,33 +829,23 @@ func extractFieldFromJSON(jsonData []byte, fieldPath string) []interface{} {
 	return getNestedJSONField(jsonObj, fieldPath)
 }
 
-// getNestedJSONField retrieves a nested field from a parsed JSON object
+// getNestedJSONField retrieves a nested field from a parsed JSON object. Values of lists along the path are
+// collected from each of their items and a list value is returned as its items.
 func getNestedJSONField(jsonObj interface{}, fieldPath string) []interface{} {
-	fields := strings.Split(fieldPath, ".")
-	var value interface{} = jsonObj
-
-	for _, field := range fields {
-		if nestedMap, ok := value.(map[string]interface{}); ok {
-			value = nestedMap[field]
-		} else if nestedSlice, ok := value.([]interface{}); ok {
-			var results []interface{}
-			for _, item := range nestedSlice {
-				if itemMap, ok := item.(map[string]interface{}); ok {
-					extracted := getNestedJSONField(itemMap, strings.Join(fields[1:], "."))
-					results = append(results, extracted...)
-				}
-			}
-			return results
-		} else {
-			return nil
-		}
+	steps, err := parseFieldPath(fieldPath)
+	if err != nil {
+		return nil
 	}
 
-	if list, ok := value.([]interface{}); ok {
-		return list // Return extracted values from the list
+	var results []interface{}
+	for _, value := range lookupFieldPath(jsonObj, steps, true) {
+		if list, ok := value.([]interface{}); ok {
+			results = append(results, list...)
+		} else {
+			results = append(results, value)
+		}
 	}
-
-	return []interface{}{value} // Wrap a single value in a list
+	return results
 }
 
 // checkForMatch checks if at least one value from `newProfile` exists in `existingProfile`
@@ -0,0 +1,281 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/wso2/identity-customer-data-service/pkg/errors"
+	"github.com/wso2/identity-customer-data-service/pkg/locks"
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+	"github.com/wso2/identity-customer-data-service/pkg/repository/memory"
+)
+
+// setupMemoryStores points the service layer at a fresh set of in-memory stores
+func setupMemoryStores(t *testing.T) {
+	t.Helper()
+	InitStores(memory.NewStores())
+	locks.InitLocks(locks.NewInMemoryLock())
+}
+
+// addCopyRule adds a rule that copies a property of signup events to a trait. Rules are added through the store so
+// that no backfill job runs in the background.
+func addCopyRule(t *testing.T, trait string) {
+	t.Helper()
+	rule := models.ProfileEnrichmentRule{
+		RuleId:        "rule-" + trait,
+		PropertyName:  "traits." + trait,
+		PropertyType:  "computed",
+		Computation:   "copy",
+		SourceFields:  []string{"properties." + trait},
+		ValueType:     "string",
+		MergeStrategy: "overwrite",
+		Trigger:       models.RuleTrigger{EventType: "track", EventName: "signup"},
+	}
+	if err := stores.EnrichmentRules.UpsertEnrichmentRule(rule); err != nil {
+		t.Fatalf("failed to add enrichment rule: %v", err)
+	}
+}
+
+func addEmailUnificationRule(t *testing.T) {
+	t.Helper()
+	rule := models.UnificationRule{
+		RuleId:   "rule-email",
+		RuleName: "email",
+		Property: "traits.email",
+		Priority: 1,
+		IsActive: true,
+	}
+	if err := stores.UnificationRules.AddUnificationRule(rule); err != nil {
+		t.Fatalf("failed to add unification rule: %v", err)
+	}
+}
+
+var testEventCounter int
+
+// enrichWithEvent creates the profile if needed, stores a signup event with the properties and enriches the profile
+// with it as the worker does
+func enrichWithEvent(t *testing.T, profileId string, properties map[string]interface{}) models.Event {
+	t.Helper()
+	testEventCounter++
+	event := models.Event{
+		ProfileId:      profileId,
+		EventType:      "track",
+		EventName:      "signup",
+		EventId:        fmt.Sprintf("event-%d", testEventCounter),
+		OrgId:          "org",
+		EventTimestamp: int(time.Now().Unix()) + testEventCounter,
+		Properties:     properties,
+	}
+	if _, err := CreateOrUpdateProfile(event); err != nil {
+		t.Fatalf("failed to create profile %s: %v", profileId, err)
+	}
+	if _, err := stores.Events.AddEvent(event); err != nil {
+		t.Fatalf("failed to store event: %v", err)
+	}
+	if err := EnrichProfile(event); err != nil {
+		t.Fatalf("failed to enrich profile %s: %v", profileId, err)
+	}
+	return event
+}
+
+func findProfile(t *testing.T, profileId string) *models.Profile {
+	t.Helper()
+	profile, err := stores.Profiles.FindProfileByID(profileId)
+	if err != nil {
+		t.Fatalf("failed to fetch profile %s: %v", profileId, err)
+	}
+	if profile == nil {
+		t.Fatalf("profile %s not found", profileId)
+	}
+	return profile
+}
+
+func unify(t *testing.T, profileId string) {
+	t.Helper()
+	if _, err := unifyProfiles(*findProfile(t, profileId)); err != nil {
+		t.Fatalf("failed to unify profile %s: %v", profileId, err)
+	}
+}
+
+func TestEnrichProfileAppliesTriggeredRules(t *testing.T) {
+	setupMemoryStores(t)
+	addCopyRule(t, "email")
+	addCopyRule(t, "plan")
+
+	enrichWithEvent(t, "p1", map[string]interface{}{"email": "ann@example.com", "plan": "gold"})
+
+	profile := findProfile(t, "p1")
+	if got := profile.Traits["email"]; got != "ann@example.com" {
+		t.Errorf("traits.email = %v, want ann@example.com", got)
+	}
+	if got := profile.Traits["plan"]; got != "gold" {
+		t.Errorf("traits.plan = %v, want gold", got)
+	}
+
+	// Events that trigger no rule leave the traits as they are
+	event := models.Event{ProfileId: "p1", EventType: "track", EventName: "login", EventId: "login-1",
+		Properties: map[string]interface{}{"plan": "silver"}}
+	if err := EnrichProfile(event); err != nil {
+		t.Fatalf("failed to enrich profile: %v", err)
+	}
+	if got := findProfile(t, "p1").Traits["plan"]; got != "gold" {
+		t.Errorf("traits.plan = %v after an untriggered event, want gold", got)
+	}
+}
+
+func TestUnifyProfilesMergesProfilesMatchingARule(t *testing.T) {
+	setupMemoryStores(t)
+	addCopyRule(t, "email")
+	addCopyRule(t, "plan")
+	addEmailUnificationRule(t)
+
+	enrichWithEvent(t, "p1", map[string]interface{}{"email": "ann@example.com", "plan": "gold"})
+	unify(t, "p1")
+	enrichWithEvent(t, "p2", map[string]interface{}{"email": "bob@example.com"})
+	unify(t, "p2")
+	enrichWithEvent(t, "p3", map[string]interface{}{"email": "ann@example.com"})
+	unify(t, "p3")
+
+	p1, p3 := findProfile(t, "p1"), findProfile(t, "p3")
+	if p1.ProfileHierarchy.IsParent || p3.ProfileHierarchy.IsParent {
+		t.Fatalf("profiles sharing an email were not unified")
+	}
+	masterId := p1.ProfileHierarchy.ParentProfileID
+	if p3.ProfileHierarchy.ParentProfileID != masterId {
+		t.Fatalf("profiles p1 and p3 have different masters %s and %s", masterId,
+			p3.ProfileHierarchy.ParentProfileID)
+	}
+
+	master := findProfile(t, masterId)
+	if !master.ProfileHierarchy.IsParent || len(master.ProfileHierarchy.ChildProfiles) != 2 {
+		t.Fatalf("master profile hierarchy = %+v, want a parent of two children", master.ProfileHierarchy)
+	}
+	for _, child := range master.ProfileHierarchy.ChildProfiles {
+		if child.RuleName != "email" {
+			t.Errorf("child %s was unified by rule %q, want email", child.ChildProfileId, child.RuleName)
+		}
+	}
+	if got := master.Traits["plan"]; got != "gold" {
+		t.Errorf("master traits.plan = %v, want gold", got)
+	}
+
+	if p2 := findProfile(t, "p2"); !p2.ProfileHierarchy.IsParent || p2.ProfileHierarchy.ParentProfileID != "" {
+		t.Errorf("profile p2 with another email was unified: %+v", p2.ProfileHierarchy)
+	}
+}
+
+// addEnrichmentRule adds the rule through the store, triggered by signup events unless the rule has a trigger
+func addEnrichmentRule(t *testing.T, rule models.ProfileEnrichmentRule) {
+	t.Helper()
+	if rule.Trigger.EventType == "" {
+		rule.Trigger = models.RuleTrigger{EventType: "track", EventName: "signup"}
+	}
+	if rule.MergeStrategy == "" {
+		rule.MergeStrategy = "overwrite"
+	}
+	if err, valid := validateEnrichmentRule(rule); !valid {
+		t.Fatalf("invalid enrichment rule: %v", err)
+	}
+	if err := stores.EnrichmentRules.UpsertEnrichmentRule(rule); err != nil {
+		t.Fatalf("failed to add enrichment rule: %v", err)
+	}
+}
+
+// storeAndEnrich creates the profile if needed, stores the event and enriches the profile with it as the worker does
+func storeAndEnrich(t *testing.T, event models.Event) {
+	t.Helper()
+	if _, err := CreateOrUpdateProfile(event); err != nil {
+		t.Fatalf("failed to create profile %s: %v", event.ProfileId, err)
+	}
+	if _, err := stores.Events.AddEvent(event); err != nil {
+		t.Fatalf("failed to store event: %v", err)
+	}
+	if err := EnrichProfile(event); err != nil {
+		t.Fatalf("failed to enrich profile %s: %v", event.ProfileId, err)
+	}
+}
+
+func TestAggregateComputationsRunOverTheMatchingEvents(t *testing.T) {
+	now := int(time.Now().Unix())
+	events := []models.Event{
+		// Too old for the rules with a time range
+		{EventName: "purchase", EventTimestamp: now - 3*24*3600,
+			Properties: map[string]interface{}{"total": 100.0, "category": "books"}},
+		{EventName: "purchase", EventTimestamp: now - 300,
+			Properties: map[string]interface{}{"total": 25.0, "category": []interface{}{"music", "books"}}},
+		{EventName: "purchase", EventTimestamp: now - 200, Properties: map[string]interface{}{"total": "10"}},
+		{EventName: "purchase", EventTimestamp: now - 100,
+			Properties: map[string]interface{}{"total": "n/a", "category": "music"}},
+		// Events of other names are not aggregated
+		{EventName: "refund", EventTimestamp: now - 50, Properties: map[string]interface{}{"total": 1000.0}},
+	}
+
+	for _, test := range []struct {
+		computation string
+		timeRange   string
+		valueType   string
+		field       string
+		want        interface{}
+	}{
+		{computation: "sum", field: "total", want: 135.0},
+		{computation: "sum", timeRange: "1d", field: "total", want: 35.0},
+		{computation: "min", timeRange: "1d", field: "total", want: 10.0},
+		{computation: "max", field: "total", want: 100.0},
+		{computation: "avg", timeRange: "1d", field: "total", want: 17.5},
+		{computation: "avg", timeRange: "1d", valueType: "int", field: "total", want: 17.5},
+		{computation: "sum", timeRange: "1d", valueType: "string", field: "total", want: "35"},
+		{computation: "first", timeRange: "1d", field: "total", want: 25.0},
+		{computation: "last", field: "total", want: "n/a"},
+		{computation: "distinct", field: "category", want: []interface{}{"books", "music"}},
+		{computation: "distinct", timeRange: "1d", valueType: "arrayOfString", field: "category",
+			want: []string{"music", "books"}},
+		{computation: "max", field: "missing"},
+	} {
+		name := fmt.Sprintf("%s of %s in %q as %q", test.computation, test.field, test.timeRange, test.valueType)
+		t.Run(name, func(t *testing.T) {
+			setupMemoryStores(t)
+			addEnrichmentRule(t, models.ProfileEnrichmentRule{
+				RuleId:       "rule-aggregate",
+				PropertyName: "traits.aggregate",
+				PropertyType: "computed",
+				Computation:  test.computation,
+				SourceFields: []string{"properties." + test.field},
+				TimeRange:    test.timeRange,
+				ValueType:    test.valueType,
+				Trigger:      models.RuleTrigger{EventType: "track", EventName: "purchase"},
+			})
+			for i, event := range events {
+				event.EventId = fmt.Sprintf("e%d", i+1)
+				event.ProfileId, event.OrgId, event.EventType = "p1", "org", "track"
+				storeAndEnrich(t, event)
+			}
+
+			got, assigned := findProfile(t, "p1").Traits["aggregate"]
+			if test.want == nil {
+				if assigned {
+					t.Errorf("traits.aggregate = %v, want no value", got)
+				}
+				return
+			}
+			if fmt.Sprintf("%#v", got) != fmt.Sprintf("%#v", test.want) {
+				t.Errorf("traits.aggregate = %#v, want %#v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestAggregateComputationsNeedOneSourceField(t *testing.T) {
+	for _, sourceFields := range [][]string{nil, {"properties.total", "properties.tax"}} {
+		err, valid := validateEnrichmentRule(models.ProfileEnrichmentRule{
+			PropertyName: "traits.total",
+			PropertyType: "computed",
+			Computation:  "sum",
+			SourceFields: sourceFields,
+			Trigger:      models.RuleTrigger{EventType: "track", EventName: "purchase"},
+		})
+		if valid || clientErrorCode(err) != errors.ErrSourceFieldValidation.Code {
+			t.Errorf("sum of %v = %v, want the source fields rejected", sourceFields, err)
+		}
+	}
+}
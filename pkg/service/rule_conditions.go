@@ -0,0 +1,290 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wso2/identity-customer-data-service/pkg/constants"
+	"github.com/wso2/identity-customer-data-service/pkg/errors"
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+)
+
+// conditionRegexes caches the compiled patterns of regex conditions
+var conditionRegexes sync.Map
+
+// triggerMatchesEvent checks the event type and name of the event against the trigger. Wildcards match any event
+// type or name but not profile events, which only match profile triggers.
+func triggerMatchesEvent(trigger models.RuleTrigger, event models.Event) bool {
+	triggerType := strings.ToLower(trigger.EventType)
+	if event.EventType == constants.ProfileTriggerEventType || triggerType != constants.WildcardTrigger {
+		if triggerType != strings.ToLower(event.EventType) {
+			return false
+		}
+	}
+
+	names := trigger.EventNames
+	if len(names) == 0 {
+		names = []string{trigger.EventName}
+	}
+	for _, name := range names {
+		if name == constants.WildcardTrigger || strings.EqualFold(name, event.EventName) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateTrigger checks that the trigger names an event type and event names that rules can be triggered by
+func validateTrigger(trigger models.RuleTrigger) error {
+	if trigger.EventType == "" || (trigger.EventName == "" && len(trigger.EventNames) == 0) {
+		return invalidTrigger("Both 'event_type' and 'event_name' (or 'event_names') must be provided inside trigger")
+	}
+
+	triggerType := strings.ToLower(trigger.EventType)
+	if triggerType != constants.WildcardTrigger && triggerType != constants.ProfileTriggerEventType &&
+		!constants.AllowedEventTypes[triggerType] {
+		return invalidTrigger(fmt.Sprintf("Event type '%s' is not supported.", trigger.EventType))
+	}
+
+	names := append([]string{trigger.EventName}, trigger.EventNames...)
+	for i, name := range names {
+		if i > 0 && name == "" {
+			return invalidTrigger("'event_names' must not contain empty names.")
+		}
+		if triggerType == constants.ProfileTriggerEventType && name != "" && name != constants.WildcardTrigger &&
+			!strings.EqualFold(name, constants.TraitUpdatedEventName) {
+			return invalidTrigger(fmt.Sprintf("Profile triggers support the '%s' event name only.",
+				constants.TraitUpdatedEventName))
+		}
+	}
+	return nil
+}
+
+// evaluateConditionTree evaluates a condition, which is a comparison or a group of all, any or not conditions
+func evaluateConditionTree(event models.Event, condition models.RuleCondition) bool {
+	switch {
+	case len(condition.All) > 0:
+		for _, child := range condition.All {
+			if !evaluateConditionTree(event, child) {
+				return false
+			}
+		}
+		return true
+	case len(condition.Any) > 0:
+		for _, child := range condition.Any {
+			if evaluateConditionTree(event, child) {
+				return true
+			}
+		}
+		return false
+	case condition.Not != nil:
+		return !evaluateConditionTree(event, *condition.Not)
+	default:
+		return EvaluateCondition(GetFieldFromEvent(event, condition.Field), condition.Operator, condition.Value)
+	}
+}
+
+// validateCondition checks that a condition is either a valid comparison or a group of exactly one kind, nested at
+// most MaxConditionDepth levels
+func validateCondition(condition models.RuleCondition, depth int) error {
+	if depth > constants.MaxConditionDepth {
+		return invalidTrigger(fmt.Sprintf("Conditions can be nested at most %d levels deep.",
+			constants.MaxConditionDepth))
+	}
+
+	groups := 0
+	var children []models.RuleCondition
+	if len(condition.All) > 0 {
+		groups++
+		children = condition.All
+	}
+	if len(condition.Any) > 0 {
+		groups++
+		children = condition.Any
+	}
+	if condition.Not != nil {
+		groups++
+		children = []models.RuleCondition{*condition.Not}
+	}
+
+	if groups == 0 {
+		return validateComparison(condition)
+	}
+	if groups > 1 || condition.Field != "" || condition.Operator != "" {
+		return invalidTrigger("A condition must be either a comparison or one of an 'all', 'any' or 'not' group.")
+	}
+	for _, child := range children {
+		if err := validateCondition(child, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateComparison(condition models.RuleCondition) error {
+	if condition.Field == "" || condition.Operator == "" {
+		return invalidTrigger("Each condition must have a field and operator defined.")
+	}
+	if _, err := parseFieldPath(condition.Field); err != nil {
+		return invalidTrigger(err.Error())
+	}
+
+	operator := strings.ToLower(condition.Operator)
+	if !constants.AllowedConditionOperators[operator] {
+		return errors.NewClientError(errors.ErrorMessage{
+			Code:        errors.ErrConditionOpValidation.Code,
+			Message:     errors.ErrConditionOpValidation.Message,
+			Description: fmt.Sprintf("Operator '%s' is not supported.", condition.Operator),
+		}, http.StatusBadRequest)
+	}
+	switch operator {
+	case "in", "not_in":
+		if len(parseConditionList(condition.Value)) == 0 {
+			return invalidTrigger(fmt.Sprintf("Operator '%s' requires a list of values.", condition.Operator))
+		}
+	case "regex":
+		if _, err := compileConditionRegex(condition.Value); err != nil {
+			return invalidTrigger(fmt.Sprintf("Invalid regex '%s': %v", condition.Value, err))
+		}
+	case "before", "after":
+		if _, ok := parseDateValue(condition.Value); !ok {
+			return invalidTrigger(fmt.Sprintf("Operator '%s' requires a date, got '%s'.", condition.Operator,
+				condition.Value))
+		}
+	case "within_last":
+		if _, err := parseTimeWindow(condition.Value); err != nil {
+			return invalidTrigger(fmt.Sprintf("Operator 'within_last' requires a duration such as 30d or 12h, "+
+				"got '%s'.", condition.Value))
+		}
+	}
+	return nil
+}
+
+func invalidTrigger(description string) error {
+	return errors.NewClientError(errors.ErrorMessage{
+		Code:        errors.ErrTriggerValidation.Code,
+		Message:     errors.ErrTriggerValidation.Message,
+		Description: description,
+	}, http.StatusBadRequest)
+}
+
+// parseConditionList parses the values of in and not_in conditions, given as a JSON array or comma separated
+func parseConditionList(value string) []string {
+	var items []interface{}
+	if err := json.Unmarshal([]byte(value), &items); err == nil {
+		list := make([]string, 0, len(items))
+		for _, item := range items {
+			list = append(list, fmt.Sprintf("%v", item))
+		}
+		return list
+	}
+
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// containsConditionValue checks if the actual value, or any of its items, is one of the listed values
+func containsConditionValue(actual interface{}, expected string) bool {
+	if actual == nil {
+		return false
+	}
+	listed := map[string]bool{}
+	for _, item := range parseConditionList(expected) {
+		listed[item] = true
+	}
+
+	items, isList := asList(actual)
+	if !isList {
+		items = []interface{}{actual}
+	}
+	for _, item := range items {
+		if listed[fmt.Sprintf("%v", item)] {
+			return true
+		}
+	}
+	return false
+}
+
+func compileConditionRegex(pattern string) (*regexp.Regexp, error) {
+	if compiled, ok := conditionRegexes.Load(pattern); ok {
+		return compiled.(*regexp.Regexp), nil
+	}
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	conditionRegexes.Store(pattern, compiled)
+	return compiled, nil
+}
+
+// compareDate compares a date field with a date (before, after) or with a window up to now (within_last)
+func compareDate(actual interface{}, expected string, operator string) bool {
+	actualTime, ok := parseDateValue(actual)
+	if !ok {
+		return false
+	}
+
+	switch operator {
+	case "before", "after":
+		expectedTime, ok := parseDateValue(expected)
+		if !ok {
+			return false
+		}
+		if operator == "before" {
+			return actualTime.Before(expectedTime)
+		}
+		return actualTime.After(expectedTime)
+	case "within_last":
+		window, err := parseTimeWindow(expected)
+		if err != nil {
+			return false
+		}
+		now := time.Now()
+		return !actualTime.Before(now.Add(-window)) && !actualTime.After(now)
+	default:
+		return false
+	}
+}
+
+// parseDateValue parses RFC 3339 dates, plain dates and Unix timestamps in seconds
+func parseDateValue(value interface{}) (time.Time, bool) {
+	if str, ok := value.(string); ok {
+		for _, layout := range []string{time.RFC3339Nano, time.DateOnly} {
+			if parsed, err := time.Parse(layout, str); err == nil {
+				return parsed, true
+			}
+		}
+	}
+	seconds, err := toFloat(value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(seconds), 0), true
+}
+
+// parseTimeWindow parses durations such as 90m or 12h, and days such as 30d
+func parseTimeWindow(value string) (time.Duration, error) {
+	if days, found := strings.CutSuffix(value, "d"); found {
+		count, err := strconv.Atoi(days)
+		if err != nil || count <= 0 {
+			return 0, fmt.Errorf("invalid number of days: %s", value)
+		}
+		return time.Duration(count) * 24 * time.Hour, nil
+	}
+	window, err := time.ParseDuration(value)
+	if err == nil && window <= 0 {
+		return 0, fmt.Errorf("window must be positive: %s", value)
+	}
+	return window, err
+}
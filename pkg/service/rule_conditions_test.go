@@ -0,0 +1,320 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/wso2/identity-customer-data-service/pkg/constants"
+	"github.com/wso2/identity-customer-data-service/pkg/errors"
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+)
+
+func TestEvaluateConditionOperators(t *testing.T) {
+	now := time.Now().UTC()
+	for _, test := range []struct {
+		operator string
+		actual   interface{}
+		expected string
+		want     bool
+	}{
+		{operator: "equals", actual: "gold", expected: "gold", want: true},
+		{operator: "EQUALS", actual: 3.0, expected: "3", want: true},
+		{operator: "not_equals", actual: "gold", expected: "silver", want: true},
+		{operator: "exists", actual: "", want: false},
+		{operator: "not_exists", actual: nil, want: true},
+		{operator: "contains", actual: "ann@example.com", expected: "example", want: true},
+		{operator: "not_contains", actual: 42.0, expected: "4", want: false},
+		{operator: "greater_than", actual: "10", expected: "9.5", want: true},
+		{operator: "less_than_equals", actual: 9.5, expected: "9.5", want: true},
+		{operator: "greater_than", actual: "ten", expected: "9", want: false},
+		{operator: "in", actual: "gold", expected: `["gold", "silver"]`, want: true},
+		{operator: "in", actual: 2.0, expected: "1, 2, 3", want: true},
+		{operator: "in", actual: []interface{}{"music", "books"}, expected: "books,games", want: true},
+		{operator: "in", actual: nil, expected: "gold", want: false},
+		{operator: "not_in", actual: "bronze", expected: "gold,silver", want: true},
+		{operator: "not_in", actual: nil, expected: "gold,silver", want: false},
+		{operator: "regex", actual: "order-1234", expected: `^order-\d+$`, want: true},
+		{operator: "regex", actual: "order-12a", expected: `^order-\d+$`, want: false},
+		{operator: "regex", actual: "order", expected: `(`, want: false},
+		{operator: "starts_with", actual: "+94771234567", expected: "+94", want: true},
+		{operator: "ends_with", actual: "ann@example.com", expected: ".org", want: false},
+		{operator: "before", actual: "2024-01-01", expected: "2024-03-01T00:00:00Z", want: true},
+		{operator: "after", actual: 1709287200.0, expected: "2024-03-01", want: true},
+		{operator: "after", actual: "yesterday", expected: "2024-03-01", want: false},
+		{operator: "within_last", actual: now.Add(-2 * time.Hour).Format(time.RFC3339), expected: "1d", want: true},
+		{operator: "within_last", actual: float64(now.Add(-48 * time.Hour).Unix()), expected: "1d", want: false},
+		{operator: "within_last", actual: now.Add(time.Hour).Format(time.RFC3339), expected: "1d", want: false},
+		{operator: "unknown", actual: "gold", expected: "gold", want: false},
+	} {
+		name := fmt.Sprintf("%v %s %s", test.actual, test.operator, test.expected)
+		t.Run(name, func(t *testing.T) {
+			if got := EvaluateCondition(test.actual, test.operator, test.expected); got != test.want {
+				t.Errorf("condition = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateConditionGroups(t *testing.T) {
+	event := models.Event{EventType: "track", EventName: "purchase", Properties: map[string]interface{}{
+		"plan": "gold", "total": 120.0, "country": "lk"}}
+	comparison := func(field, operator, value string) models.RuleCondition {
+		return models.RuleCondition{Field: field, Operator: operator, Value: value}
+	}
+	gold, silver := comparison("plan", "equals", "gold"), comparison("plan", "equals", "silver")
+	large := comparison("total", "greater_than", "100")
+
+	for _, test := range []struct {
+		name       string
+		conditions []models.RuleCondition
+		want       bool
+	}{
+		{name: "no conditions", want: true},
+		{name: "top level conditions are all required", conditions: []models.RuleCondition{gold, silver}},
+		{name: "all", conditions: []models.RuleCondition{{All: []models.RuleCondition{gold, large}}}, want: true},
+		{name: "any", conditions: []models.RuleCondition{{Any: []models.RuleCondition{silver, large}}}, want: true},
+		{name: "none of any", conditions: []models.RuleCondition{{Any: []models.RuleCondition{silver}}}},
+		{name: "not", conditions: []models.RuleCondition{{Not: &silver}}, want: true},
+		{name: "nested", conditions: []models.RuleCondition{{All: []models.RuleCondition{
+			{Any: []models.RuleCondition{silver, {Not: &models.RuleCondition{Any: []models.RuleCondition{
+				comparison("country", "in", "us,uk")}}}}},
+			large,
+		}}}, want: true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if got := EvaluateConditions(event, test.conditions); got != test.want {
+				t.Errorf("conditions = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestValidateConditionRejectsInvalidConditions(t *testing.T) {
+	comparison := models.RuleCondition{Field: "plan", Operator: "equals", Value: "gold"}
+	deep := comparison
+	for i := 0; i < constants.MaxConditionDepth; i++ {
+		deep = models.RuleCondition{Not: &models.RuleCondition{All: []models.RuleCondition{deep}}}
+	}
+
+	for _, test := range []struct {
+		name      string
+		condition models.RuleCondition
+		code      string
+	}{
+		{name: "no field", condition: models.RuleCondition{Operator: "exists"}, code: errors.ErrTriggerValidation.Code},
+		{name: "invalid field", condition: models.RuleCondition{Field: "items[", Operator: "exists"},
+			code: errors.ErrTriggerValidation.Code},
+		{name: "unsupported operator", condition: models.RuleCondition{Field: "plan", Operator: "like"},
+			code: errors.ErrConditionOpValidation.Code},
+		{name: "empty list", condition: models.RuleCondition{Field: "plan", Operator: "in", Value: "[]"},
+			code: errors.ErrTriggerValidation.Code},
+		{name: "invalid regex", condition: models.RuleCondition{Field: "plan", Operator: "regex", Value: "("},
+			code: errors.ErrTriggerValidation.Code},
+		{name: "invalid date", condition: models.RuleCondition{Field: "born", Operator: "before", Value: "soon"},
+			code: errors.ErrTriggerValidation.Code},
+		{name: "invalid window", condition: models.RuleCondition{Field: "seen", Operator: "within_last", Value: "-1d"},
+			code: errors.ErrTriggerValidation.Code},
+		{name: "two groups", condition: models.RuleCondition{All: []models.RuleCondition{comparison},
+			Not: &comparison}, code: errors.ErrTriggerValidation.Code},
+		{name: "group with a field", condition: models.RuleCondition{Field: "plan",
+			Any: []models.RuleCondition{comparison}}, code: errors.ErrTriggerValidation.Code},
+		{name: "invalid nested comparison", condition: models.RuleCondition{Any: []models.RuleCondition{comparison,
+			{Field: "plan", Operator: "like"}}}, code: errors.ErrConditionOpValidation.Code},
+		{name: "too deep", condition: deep, code: errors.ErrTriggerValidation.Code},
+		{name: "valid", condition: models.RuleCondition{Any: []models.RuleCondition{comparison,
+			{Not: &models.RuleCondition{Field: "tags", Operator: "IN", Value: `["a", "b"]`}}}}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if err := validateCondition(test.condition, 1); clientErrorCode(err) != test.code {
+				t.Errorf("error = %v, want code %q", err, test.code)
+			}
+		})
+	}
+}
+
+func TestEnrichmentRulesApplyOnlyWhenTheirConditionsHold(t *testing.T) {
+	setupMemoryStores(t)
+	addEnrichmentRule(t, models.ProfileEnrichmentRule{
+		RuleId:       "rule-big-spender",
+		PropertyName: "traits.big_spender",
+		PropertyType: "static",
+		Value:        "true",
+		ValueType:    "boolean",
+		Trigger: models.RuleTrigger{EventType: "track", EventName: "purchase", Conditions: []models.RuleCondition{
+			{Any: []models.RuleCondition{
+				{Field: "total", Operator: "greater_than_equals", Value: "100"},
+				{Field: "plan", Operator: "in", Value: "gold,platinum"},
+			}},
+			{Not: &models.RuleCondition{Field: "context.test_mode", Operator: "equals", Value: "true"}},
+		}},
+	})
+
+	for i, test := range []struct {
+		profileId  string
+		properties map[string]interface{}
+		testMode   bool
+		want       bool
+	}{
+		{profileId: "p1", properties: map[string]interface{}{"total": 150.0}, want: true},
+		{profileId: "p2", properties: map[string]interface{}{"total": 50.0, "plan": "gold"}, want: true},
+		{profileId: "p3", properties: map[string]interface{}{"total": 50.0, "plan": "silver"}},
+		{profileId: "p4", properties: map[string]interface{}{"total": 150.0}, testMode: true},
+	} {
+		storeAndEnrich(t, models.Event{EventId: fmt.Sprintf("e%d", i+1), ProfileId: test.profileId, OrgId: "org",
+			EventType: "track", EventName: "purchase", Properties: test.properties,
+			Context: map[string]interface{}{"test_mode": test.testMode}})
+		if got := findProfile(t, test.profileId).Traits["big_spender"] == true; got != test.want {
+			t.Errorf("profile %s big spender = %v, want %v", test.profileId, got, test.want)
+		}
+	}
+}
+
+func TestTriggerMatchesEvent(t *testing.T) {
+	traitUpdated := models.Event{EventType: constants.ProfileTriggerEventType, EventName: constants.TraitUpdatedEventName}
+	for _, test := range []struct {
+		name    string
+		trigger models.RuleTrigger
+		event   models.Event
+		want    bool
+	}{
+		{name: "exact", trigger: models.RuleTrigger{EventType: "track", EventName: "Signup"},
+			event: models.Event{EventType: "track", EventName: "signup"}, want: true},
+		{name: "other name", trigger: models.RuleTrigger{EventType: "track", EventName: "signup"},
+			event: models.Event{EventType: "track", EventName: "login"}},
+		{name: "other type", trigger: models.RuleTrigger{EventType: "track", EventName: "signup"},
+			event: models.Event{EventType: "page", EventName: "signup"}},
+		{name: "any name of a type", trigger: models.RuleTrigger{EventType: "track", EventName: "*"},
+			event: models.Event{EventType: "track", EventName: "login"}, want: true},
+		{name: "any event", trigger: models.RuleTrigger{EventType: "*", EventName: "*"},
+			event: models.Event{EventType: "page", EventName: "home"}, want: true},
+		{name: "any type of a name", trigger: models.RuleTrigger{EventType: "*", EventName: "home"},
+			event: models.Event{EventType: "screen", EventName: "home"}, want: true},
+		{name: "listed name", trigger: models.RuleTrigger{EventType: "track", EventNames: []string{"signup", "Login"}},
+			event: models.Event{EventType: "track", EventName: "login"}, want: true},
+		{name: "unlisted name", trigger: models.RuleTrigger{EventType: "track", EventNames: []string{"signup"}},
+			event: models.Event{EventType: "track", EventName: "login"}},
+		{name: "profile event of a wildcard", trigger: models.RuleTrigger{EventType: "*", EventName: "*"},
+			event: traitUpdated},
+		{name: "profile event", trigger: models.RuleTrigger{EventType: "profile", EventName: "trait_updated"},
+			event: traitUpdated, want: true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if got := triggerMatchesEvent(test.trigger, test.event); got != test.want {
+				t.Errorf("trigger matches = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestValidateTriggerRejectsInvalidTriggers(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		trigger models.RuleTrigger
+		valid   bool
+	}{
+		{name: "no event type", trigger: models.RuleTrigger{EventName: "signup"}},
+		{name: "no event name", trigger: models.RuleTrigger{EventType: "track"}},
+		{name: "unsupported type", trigger: models.RuleTrigger{EventType: "click", EventName: "*"}},
+		{name: "empty listed name", trigger: models.RuleTrigger{EventType: "track", EventNames: []string{"signup", ""}}},
+		{name: "other profile event", trigger: models.RuleTrigger{EventType: "profile", EventName: "profile_created"}},
+		{name: "wildcards", trigger: models.RuleTrigger{EventType: "*", EventName: "*"}, valid: true},
+		{name: "listed names", trigger: models.RuleTrigger{EventType: "track", EventNames: []string{"signup", "login"}},
+			valid: true},
+		{name: "trait updates", trigger: models.RuleTrigger{EventType: "PROFILE", EventName: "Trait_Updated"},
+			valid: true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateTrigger(test.trigger)
+			if test.valid && err != nil {
+				t.Errorf("valid trigger was rejected: %v", err)
+			}
+			if !test.valid && clientErrorCode(err) != errors.ErrTriggerValidation.Code {
+				t.Errorf("error = %v, want the trigger rejected", err)
+			}
+		})
+	}
+}
+
+func TestWildcardAndMultiEventTriggers(t *testing.T) {
+	setupMemoryStores(t)
+	addEnrichmentRule(t, models.ProfileEnrichmentRule{
+		RuleId:       "rule-last-active",
+		PropertyName: "traits.last_active_at",
+		PropertyType: "computed",
+		Computation:  "copy",
+		SourceFields: []string{"event_timestamp"},
+		Trigger:      models.RuleTrigger{EventType: "*", EventName: "*"},
+	})
+	addEnrichmentRule(t, models.ProfileEnrichmentRule{
+		RuleId:       "rule-sessions",
+		PropertyName: "traits.sessions",
+		PropertyType: "computed",
+		Computation:  "count",
+		Trigger:      models.RuleTrigger{EventType: "track", EventNames: []string{"signup", "login"}},
+	})
+
+	for i, event := range []struct {
+		eventType string
+		eventName string
+		sessions  interface{}
+	}{
+		{eventType: "track", eventName: "signup", sessions: 1},
+		{eventType: "page", eventName: "home", sessions: 1},
+		{eventType: "track", eventName: "login", sessions: 2},
+		{eventType: "track", eventName: "purchase", sessions: 2},
+	} {
+		timestamp := 1709287200 + i
+		storeAndEnrich(t, models.Event{EventId: fmt.Sprintf("e%d", i+1), ProfileId: "p1", OrgId: "org",
+			EventType: event.eventType, EventName: event.eventName, EventTimestamp: timestamp})
+
+		traits := findProfile(t, "p1").Traits
+		if fmt.Sprintf("%v", traits["last_active_at"]) != fmt.Sprintf("%d", timestamp) {
+			t.Errorf("after %s:%s traits.last_active_at = %v, want %d", event.eventType, event.eventName,
+				traits["last_active_at"], timestamp)
+		}
+		if traits["sessions"] != event.sessions {
+			t.Errorf("after %s:%s traits.sessions = %v, want %v", event.eventType, event.eventName,
+				traits["sessions"], event.sessions)
+		}
+	}
+}
+
+func TestProfileTriggersFireOnTraitUpdates(t *testing.T) {
+	setupMemoryStores(t)
+	addCopyRule(t, "plan")
+	addEnrichmentRule(t, models.ProfileEnrichmentRule{
+		RuleId:       "rule-previous-plan",
+		PropertyName: "traits.previous_plan",
+		PropertyType: "computed",
+		Computation:  "copy",
+		SourceFields: []string{"properties.previous_value"},
+		Trigger: models.RuleTrigger{EventType: "profile", EventName: "trait_updated",
+			Conditions: []models.RuleCondition{{Field: "property_name", Operator: "equals", Value: "traits.plan"}}},
+	})
+	// Wildcard rules are not triggered by trait updates
+	addEnrichmentRule(t, models.ProfileEnrichmentRule{
+		RuleId:       "rule-changes",
+		PropertyName: "traits.changes",
+		PropertyType: "computed",
+		Computation:  "count",
+		Trigger:      models.RuleTrigger{EventType: "*", EventName: "*"},
+	})
+
+	for _, test := range []struct {
+		plan         string
+		previousPlan interface{}
+	}{
+		{plan: "gold"},
+		{plan: "gold"},
+		{plan: "silver", previousPlan: "gold"},
+	} {
+		enrichWithEvent(t, "p1", map[string]interface{}{"plan": test.plan})
+		if got := findProfile(t, "p1").Traits["previous_plan"]; got != test.previousPlan {
+			t.Errorf("after plan %s traits.previous_plan = %v, want %v", test.plan, got, test.previousPlan)
+		}
+	}
+	if changes := findProfile(t, "p1").Traits["changes"]; changes != 3 {
+		t.Errorf("traits.changes = %v, want a count of the 3 events only", changes)
+	}
+}
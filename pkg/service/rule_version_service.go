@@ -0,0 +1,259 @@
+package service
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/wso2/identity-customer-data-service/pkg/constants"
+	"github.com/wso2/identity-customer-data-service/pkg/errors"
+	"github.com/wso2/identity-customer-data-service/pkg/logger"
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+	repositories "github.com/wso2/identity-customer-data-service/pkg/repository"
+)
+
+// unversionedRuleFields are the fields of a rule that identify it or change with every version and are left out
+// of the diffs
+var unversionedRuleFields = map[string]bool{
+	"rule_id":    true,
+	"created_at": true,
+	"updated_at": true,
+}
+
+// GetEnrichmentRuleVersions retrieves the change history of an enrichment rule, the latest version first
+func GetEnrichmentRuleVersions(ruleId string) ([]models.RuleVersion, error) {
+	return getRuleVersions(constants.EnrichmentRuleType, ruleId)
+}
+
+// GetUnificationRuleVersions retrieves the change history of a unification rule, the latest version first
+func GetUnificationRuleVersions(ruleId string) ([]models.RuleVersion, error) {
+	return getRuleVersions(constants.UnificationRuleType, ruleId)
+}
+
+// lockRule acquires the lock that serializes the changes of a rule, so that each change is recorded as a version
+// against the state the previous change left, and returns the function that releases it
+func lockRule(ruleType, ruleId string) (func(), error) {
+	return acquireLock("lock:rule:"+ruleType+":"+ruleId, 10*time.Second, ruleType+" rule "+ruleId)
+}
+
+// RollbackEnrichmentRule restores an enrichment rule as it was in a previous version, recording the rollback as a
+// new version. Rules that were deleted since are restored as well.
+func RollbackEnrichmentRule(ruleId string, version int, author string) (*models.ProfileEnrichmentRule, error) {
+	ruleVersion, err := getRuleVersion(constants.EnrichmentRuleType, ruleId, version)
+	if err != nil {
+		return nil, err
+	}
+	rule := *ruleVersion.EnrichmentRule
+	if err, isValid := validateEnrichmentRule(rule); !isValid {
+		return nil, err
+	}
+
+	unlock, err := lockRule(constants.EnrichmentRuleType, ruleId)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	previous, exists := findEnrichmentRule(ruleId)
+	rule.UpdatedAt = time.Now().UTC().Unix()
+	if err := stores.EnrichmentRules.UpsertEnrichmentRule(rule); err != nil {
+		return nil, err
+	}
+	recordRuleVersion(constants.EnrichmentRuleType, ruleId, constants.RuleRolledBack, author,
+		ruleSnapshot(previous, exists), rule, version)
+	backfillEnrichmentRule(rule)
+	return &rule, nil
+}
+
+// RollbackUnificationRule restores a unification rule as it was in a previous version, recording the rollback as a
+// new version. Rules that were deleted since are restored as well.
+func RollbackUnificationRule(ruleId string, version int, author string) (*models.UnificationRule, error) {
+	ruleVersion, err := getRuleVersion(constants.UnificationRuleType, ruleId, version)
+	if err != nil {
+		return nil, err
+	}
+	rule := *ruleVersion.UnificationRule
+	if err := validateUnificationRule(rule); err != nil {
+		return nil, err
+	}
+
+	unlock, err := lockRule(constants.UnificationRuleType, ruleId)
+	if err != nil {
+		return nil, errors.NewServerError(errors.ErrWhileUpdatingUnificationRule, err)
+	}
+	defer unlock()
+	unificationRuleRepo := stores.UnificationRules
+	previous, exists := findUnificationRule(ruleId)
+	if exists {
+		err = unificationRuleRepo.PatchUnificationRule(ruleId, map[string]interface{}{
+			"rule_name":   rule.RuleName,
+			"property":    rule.Property,
+			"properties":  rule.Properties,
+			"operator":    rule.Operator,
+			"normalizers": rule.Normalizers,
+			"mode":        rule.Mode,
+			"attributes":  rule.Attributes,
+			"threshold":   rule.Threshold,
+			"priority":    rule.Priority,
+			"is_active":   rule.IsActive,
+		})
+	} else {
+		rule.UpdatedAt = time.Now().UTC().Unix()
+		err = unificationRuleRepo.AddUnificationRule(rule)
+	}
+	if err != nil {
+		return nil, errors.NewServerError(errors.ErrWhileUpdatingUnificationRule, err)
+	}
+
+	RebuildIdentityIndex()
+
+	restored, _ := findUnificationRule(ruleId)
+	recordRuleVersion(constants.UnificationRuleType, ruleId, constants.RuleRolledBack, author,
+		ruleSnapshot(previous, exists), restored, version)
+	return &restored, nil
+}
+
+func getRuleVersions(ruleType, ruleId string) ([]models.RuleVersion, error) {
+	versions, err := stores.RuleVersions.GetRuleVersions(ruleType, ruleId)
+	if err != nil {
+		return nil, errors.NewServerError(errors.ErrWhileFetchingRuleVersions, err)
+	}
+	if versions == nil {
+		versions = []models.RuleVersion{}
+	}
+	return versions, nil
+}
+
+func getRuleVersion(ruleType, ruleId string, version int) (*models.RuleVersion, error) {
+	ruleVersion, err := stores.RuleVersions.GetRuleVersion(ruleType, ruleId, version)
+	if err != nil {
+		return nil, errors.NewServerError(errors.ErrWhileFetchingRuleVersions, err)
+	}
+	if ruleVersion == nil || (ruleVersion.EnrichmentRule == nil && ruleVersion.UnificationRule == nil) {
+		return nil, errors.NewClientError(errors.ErrRuleVersionNotFound, http.StatusNotFound)
+	}
+	return ruleVersion, nil
+}
+
+// findEnrichmentRule retrieves the current state of an enrichment rule and reports whether it exists
+func findEnrichmentRule(ruleId string) (models.ProfileEnrichmentRule, bool) {
+	rule, err := stores.EnrichmentRules.GetSchemaRule(ruleId)
+	return rule, err == nil && rule.RuleId != ""
+}
+
+// findUnificationRule retrieves the current state of a unification rule and reports whether it exists
+func findUnificationRule(ruleId string) (models.UnificationRule, bool) {
+	rule, err := stores.UnificationRules.GetUnificationRule(ruleId)
+	return rule, err == nil && rule.RuleId != ""
+}
+
+// ruleSnapshot returns the rule for a diff, or nil when it did not exist
+func ruleSnapshot(rule interface{}, exists bool) interface{} {
+	if !exists {
+		return nil
+	}
+	return rule
+}
+
+// recordRuleVersion records a change of a rule as its next version. Previous is nil for rules that did not exist
+// and current is nil for deleted rules, in which case the version keeps the rule as it was before the deletion.
+// Concurrent changes of the rule that take the same version number are retried with the next one. The change is
+// already applied when it is recorded, so a failure to record it is logged rather than failing the change.
+func recordRuleVersion(ruleType, ruleId, change, author string, previous, current interface{}, rolledBackTo int) {
+	ruleVersion := models.RuleVersion{
+		RuleId:       ruleId,
+		RuleType:     ruleType,
+		Change:       change,
+		RolledBackTo: rolledBackTo,
+		Author:       author,
+		Diff:         diffRules(previous, current),
+		CreatedAt:    time.Now().UTC().Unix(),
+	}
+	snapshot := current
+	if snapshot == nil {
+		snapshot = previous
+	}
+	switch rule := snapshot.(type) {
+	case models.ProfileEnrichmentRule:
+		ruleVersion.EnrichmentRule = &rule
+	case models.UnificationRule:
+		ruleVersion.UnificationRule = &rule
+	}
+
+	var err error
+	for attempt := 0; attempt < constants.MaxRetryAttempts; attempt++ {
+		var latest int
+		if latest, err = stores.RuleVersions.GetLatestRuleVersion(ruleType, ruleId); err != nil {
+			break
+		}
+		ruleVersion.Version = latest + 1
+		if err = stores.RuleVersions.AddRuleVersion(ruleVersion); !stderrors.Is(err,
+			repositories.ErrRuleVersionExists) {
+			break
+		}
+	}
+	if err != nil {
+		logger.Error(err, fmt.Sprintf("Failed to record the %s change of %s rule %s", change, ruleType, ruleId))
+	}
+}
+
+// diffRules lists the top level fields of the rule that differ between the two states, by their json names
+func diffRules(previous, current interface{}) []models.RuleFieldChange {
+	previousFields := ruleDocument(previous)
+	currentFields := ruleDocument(current)
+
+	fields := map[string]bool{}
+	for field := range previousFields {
+		fields[field] = true
+	}
+	for field := range currentFields {
+		fields[field] = true
+	}
+	names := make([]string, 0, len(fields))
+	for field := range fields {
+		if !unversionedRuleFields[field] {
+			names = append(names, field)
+		}
+	}
+	sort.Strings(names)
+
+	var changes []models.RuleFieldChange
+	for _, field := range names {
+		if reflect.DeepEqual(previousFields[field], currentFields[field]) {
+			continue
+		}
+		changes = append(changes, models.RuleFieldChange{
+			Field:    field,
+			Previous: previousFields[field],
+			Current:  currentFields[field],
+		})
+	}
+	return changes
+}
+
+// ruleDocument converts a rule into its json fields
+func ruleDocument(rule interface{}) map[string]interface{} {
+	fields := map[string]interface{}{}
+	if rule == nil {
+		return fields
+	}
+	data, err := json.Marshal(rule)
+	if err != nil {
+		return fields
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return map[string]interface{}{}
+	}
+	return fields
+}
+
+// ruleChange names the change of writing a rule depending on whether it existed before
+func ruleChange(existed bool) string {
+	if existed {
+		return constants.RuleUpdated
+	}
+	return constants.RuleCreated
+}
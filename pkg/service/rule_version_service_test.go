@@ -0,0 +1,131 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/wso2/identity-customer-data-service/pkg/constants"
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+	repositories "github.com/wso2/identity-customer-data-service/pkg/repository"
+)
+
+// racingRuleVersionStore records a version of a concurrent change right after the latest version is read, for the
+// given number of reads
+type racingRuleVersionStore struct {
+	repositories.RuleVersionStore
+	races int
+	reads int
+}
+
+func (store *racingRuleVersionStore) GetLatestRuleVersion(ruleType, ruleId string) (int, error) {
+	latest, err := store.RuleVersionStore.GetLatestRuleVersion(ruleType, ruleId)
+	store.reads++
+	if err == nil && store.reads <= store.races {
+		concurrent := models.RuleVersion{RuleType: ruleType, RuleId: ruleId, Version: latest + 1,
+			Change: constants.RuleUpdated}
+		if err := store.RuleVersionStore.AddRuleVersion(concurrent); err != nil {
+			return 0, err
+		}
+	}
+	return latest, err
+}
+
+// failingRuleVersionStore fails to record versions
+type failingRuleVersionStore struct {
+	repositories.RuleVersionStore
+}
+
+func (store failingRuleVersionStore) AddRuleVersion(version models.RuleVersion) error {
+	return errors.New("write failed")
+}
+
+func TestRecordRuleVersionRetriesAConcurrentlyTakenVersion(t *testing.T) {
+	setupMemoryStores(t)
+	stores.RuleVersions = &racingRuleVersionStore{RuleVersionStore: stores.RuleVersions, races: 1}
+
+	rule := models.UnificationRule{RuleId: "rule-email", RuleName: "email", Property: "traits.email"}
+	recordRuleVersion(constants.UnificationRuleType, rule.RuleId, constants.RuleCreated, "ann", nil, rule, 0)
+
+	versions, err := GetUnificationRuleVersions(rule.RuleId)
+	if err != nil {
+		t.Fatalf("failed to fetch rule versions: %v", err)
+	}
+	if len(versions) != 2 || versions[0].Version != 2 || versions[0].Author != "ann" {
+		t.Fatalf("versions = %+v, want the change recorded as version 2 after the concurrent version 1", versions)
+	}
+}
+
+func TestRecordRuleVersionGivesUpAfterTheRetries(t *testing.T) {
+	setupMemoryStores(t)
+	racing := &racingRuleVersionStore{RuleVersionStore: stores.RuleVersions, races: constants.MaxRetryAttempts + 1}
+	stores.RuleVersions = racing
+
+	rule := models.UnificationRule{RuleId: "rule-email", RuleName: "email", Property: "traits.email"}
+	recordRuleVersion(constants.UnificationRuleType, rule.RuleId, constants.RuleCreated, "ann", nil, rule, 0)
+
+	if racing.reads != constants.MaxRetryAttempts {
+		t.Errorf("latest version was read %d times, want one read per attempt (%d)", racing.reads,
+			constants.MaxRetryAttempts)
+	}
+	versions, err := GetUnificationRuleVersions(rule.RuleId)
+	if err != nil {
+		t.Fatalf("failed to fetch rule versions: %v", err)
+	}
+	if len(versions) != constants.MaxRetryAttempts {
+		t.Fatalf("%d versions recorded, want only the %d concurrent ones", len(versions), constants.MaxRetryAttempts)
+	}
+	for _, version := range versions {
+		if version.Author == "ann" {
+			t.Errorf("version %d was recorded although every attempt lost the race", version.Version)
+		}
+	}
+}
+
+func TestRollbackWaitsForTheChangeInProgress(t *testing.T) {
+	setupMemoryStores(t)
+	rule := models.ProfileEnrichmentRule{RuleId: "rule-plan", PropertyName: "traits.plan", PropertyType: "computed",
+		Computation: "copy", SourceFields: []string{"properties.plan"}, ValueType: "string",
+		Trigger: models.RuleTrigger{EventType: "track", EventName: "signup"}}
+	if err := PutEnrichmentRule(rule, "ann"); err != nil {
+		t.Fatalf("failed to add the rule: %v", err)
+	}
+	rule.SourceFields = []string{"properties.tier"}
+	if err := PutEnrichmentRule(rule, "ann"); err != nil {
+		t.Fatalf("failed to update the rule: %v", err)
+	}
+
+	unlock, err := lockRule(constants.EnrichmentRuleType, rule.RuleId)
+	if err != nil {
+		t.Fatalf("failed to lock the rule: %v", err)
+	}
+	if _, err := RollbackEnrichmentRule(rule.RuleId, 1, "bob"); err == nil {
+		t.Errorf("rollback succeeded while another change of the rule held its lock")
+	}
+	unlock()
+
+	if _, err := RollbackEnrichmentRule(rule.RuleId, 1, "bob"); err != nil {
+		t.Fatalf("rollback failed after the lock was released: %v", err)
+	}
+	versions, err := GetEnrichmentRuleVersions(rule.RuleId)
+	if err != nil {
+		t.Fatalf("failed to fetch rule versions: %v", err)
+	}
+	if len(versions) != 3 || versions[0].Change != constants.RuleRolledBack || versions[0].RolledBackTo != 1 {
+		t.Errorf("versions = %+v, want the rollback recorded once as version 3", versions)
+	}
+}
+
+func TestRuleChangeIsAppliedWhenItsVersionFailsToBeRecorded(t *testing.T) {
+	setupMemoryStores(t)
+	stores.RuleVersions = failingRuleVersionStore{stores.RuleVersions}
+	addCopyRule(t, "email")
+
+	rule := models.UnificationRule{RuleId: "rule-email", RuleName: "email", Property: "traits.email", Priority: 1,
+		IsActive: true}
+	if err := AddUnificationRule(rule, "ann"); err != nil {
+		t.Fatalf("adding the rule failed although it was stored: %v", err)
+	}
+	if _, exists := findUnificationRule(rule.RuleId); !exists {
+		t.Errorf("unification rule was not stored")
+	}
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/wso2/identity-customer-data-service/pkg/constants"
+	"github.com/wso2/identity-customer-data-service/pkg/errors"
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+)
+
+// segmentDeviceFields maps Segment context fields to the flat context fields that are used to keep the devices of
+// the profile
+var segmentDeviceFields = map[string]string{
+	"device_id":   "device.id",
+	"device_type": "device.type",
+	"os":          "os.name",
+	"ip":          "ip",
+}
+
+// AddSegmentEvent ingests a single Segment call of the given type
+func AddSegmentEvent(callType string, message models.SegmentMessage) error {
+	message.Type = callType
+	event, err := segmentMessageToEvent(message)
+	if err != nil {
+		return err
+	}
+	_, _, err = AddEvents(event)
+	return err
+}
+
+// AddSegmentBatch ingests a batch of Segment calls. Calls are accepted or rejected individually like AddEventBatch.
+func AddSegmentBatch(batch models.SegmentBatch) (*models.EventBatchResponse, error) {
+	return addEventBatch(batch.Batch, func(rawMessage json.RawMessage) (models.Event, error) {
+		var message models.SegmentMessage
+		if err := json.Unmarshal(rawMessage, &message); err != nil {
+			return models.Event{}, err
+		}
+		if message.Context == nil {
+			message.Context = batch.Context
+		}
+		return segmentMessageToEvent(message)
+	})
+}
+
+// segmentMessageToEvent maps a Segment call onto an event of the profile identified by the user id, or by the
+// anonymous id for calls of unidentified users
+func segmentMessageToEvent(message models.SegmentMessage) (models.Event, error) {
+	callType := strings.ToLower(message.Type)
+	invalid := func(description string) (models.Event, error) {
+		return models.Event{}, errors.NewClientError(errors.ErrorMessage{
+			Code:        errors.ErrInvalidEvent.Code,
+			Message:     errors.ErrInvalidEvent.Message,
+			Description: description,
+		}, http.StatusBadRequest)
+	}
+
+	event := models.Event{
+		ProfileId:  message.UserId,
+		EventType:  callType,
+		EventId:    message.MessageId,
+		Properties: message.Properties,
+		Context:    segmentContext(message),
+	}
+	if event.ProfileId == "" {
+		event.ProfileId = message.AnonymousId
+	}
+	if event.ProfileId == "" {
+		return invalid("Either userId or anonymousId is required.")
+	}
+
+	switch callType {
+	case "track":
+		if message.Event == "" {
+			return invalid("event is required for track calls.")
+		}
+		event.EventName = message.Event
+	case "page", "screen":
+		event.EventName = message.Name
+		if event.EventName == "" {
+			event.EventName = callType
+		}
+		if message.Category != "" {
+			event.Properties = withProperty(event.Properties, "category", message.Category)
+		}
+	case "identify":
+		event.EventName = callType
+		event.Properties = message.Traits
+	case "group":
+		if message.GroupId == "" {
+			return invalid("groupId is required for group calls.")
+		}
+		event.EventName = callType
+		event.Properties = withProperty(message.Traits, "groupId", message.GroupId)
+	case "alias":
+		if message.UserId == "" || message.PreviousId == "" {
+			return invalid("Both userId and previousId are required for alias calls.")
+		}
+		event.EventName = callType
+		event.Properties = withProperty(event.Properties, constants.AliasPreviousIdProperty, message.PreviousId)
+	default:
+		return invalid(fmt.Sprintf("Unsupported call type: %s", message.Type))
+	}
+
+	timestamp := message.Timestamp
+	if timestamp == "" {
+		timestamp = message.OriginalTimestamp
+	}
+	if timestamp == "" {
+		event.EventTimestamp = int(time.Now().UTC().Unix())
+	} else {
+		parsed, err := time.Parse(time.RFC3339Nano, timestamp)
+		if err != nil {
+			return invalid(fmt.Sprintf("timestamp must be an ISO-8601 date: %s", timestamp))
+		}
+		event.EventTimestamp = int(parsed.UTC().Unix())
+	}
+	return event, nil
+}
+
+// segmentContext copies the context of the call along with the ids of the user and the device fields of the profile
+func segmentContext(message models.SegmentMessage) map[string]interface{} {
+	eventContext := make(map[string]interface{}, len(message.Context)+2)
+	for key, value := range message.Context {
+		eventContext[key] = value
+	}
+	if message.AnonymousId != "" {
+		eventContext["anonymous_id"] = message.AnonymousId
+	}
+	if message.UserId != "" {
+		eventContext["user_id"] = message.UserId
+	}
+	for field, path := range segmentDeviceFields {
+		// Flat fields sent by the client are kept, while Segment objects of the same name, such as os, are replaced
+		if _, flat := eventContext[field].(string); flat {
+			continue
+		}
+		if value, ok := FieldPathValue(message.Context, path).(string); ok && value != "" {
+			eventContext[field] = value
+		}
+	}
+	return eventContext
+}
+
+// withProperty returns a copy of the properties with the property set
+func withProperty(properties map[string]interface{}, name string, value interface{}) map[string]interface{} {
+	updated := make(map[string]interface{}, len(properties)+1)
+	for key, existing := range properties {
+		updated[key] = existing
+	}
+	updated[name] = value
+	return updated
+}
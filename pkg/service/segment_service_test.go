@@ -0,0 +1,181 @@
+package service
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/wso2/identity-customer-data-service/pkg/errors"
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+)
+
+func TestSegmentMessageToEvent(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		message models.SegmentMessage
+		want    models.Event
+		invalid bool
+	}{
+		{
+			name: "track",
+			message: models.SegmentMessage{Type: "Track", MessageId: "m1", UserId: "u1", Event: "Order Completed",
+				Properties: map[string]interface{}{"total": 25.0}, Timestamp: "2024-03-01T10:00:00.500Z"},
+			want: models.Event{EventId: "m1", ProfileId: "u1", EventType: "track", EventName: "Order Completed",
+				EventTimestamp: 1709287200, Properties: map[string]interface{}{"total": 25.0},
+				Context: map[string]interface{}{"user_id": "u1"}},
+		},
+		{
+			name: "anonymous page",
+			message: models.SegmentMessage{Type: "page", AnonymousId: "a1", Category: "docs",
+				OriginalTimestamp: "2024-03-01T10:00:00+05:30"},
+			want: models.Event{ProfileId: "a1", EventType: "page", EventName: "page", EventTimestamp: 1709267400,
+				Properties: map[string]interface{}{"category": "docs"},
+				Context:    map[string]interface{}{"anonymous_id": "a1"}},
+		},
+		{
+			name:    "named screen",
+			message: models.SegmentMessage{Type: "screen", UserId: "u1", Name: "Home", Timestamp: "2024-03-01T10:00:00Z"},
+			want: models.Event{ProfileId: "u1", EventType: "screen", EventName: "Home", EventTimestamp: 1709287200,
+				Context: map[string]interface{}{"user_id": "u1"}},
+		},
+		{
+			name: "identify",
+			message: models.SegmentMessage{Type: "identify", UserId: "u1", AnonymousId: "a1",
+				Traits: map[string]interface{}{"email": "ann@example.com"}, Timestamp: "2024-03-01T10:00:00Z"},
+			want: models.Event{ProfileId: "u1", EventType: "identify", EventName: "identify",
+				EventTimestamp: 1709287200, Properties: map[string]interface{}{"email": "ann@example.com"},
+				Context: map[string]interface{}{"user_id": "u1", "anonymous_id": "a1"}},
+		},
+		{
+			name: "group",
+			message: models.SegmentMessage{Type: "group", UserId: "u1", GroupId: "g1",
+				Traits: map[string]interface{}{"plan": "gold"}, Timestamp: "2024-03-01T10:00:00Z"},
+			want: models.Event{ProfileId: "u1", EventType: "group", EventName: "group", EventTimestamp: 1709287200,
+				Properties: map[string]interface{}{"plan": "gold", "groupId": "g1"},
+				Context:    map[string]interface{}{"user_id": "u1"}},
+		},
+		{
+			name: "alias",
+			message: models.SegmentMessage{Type: "alias", UserId: "u1", PreviousId: "a1",
+				Timestamp: "2024-03-01T10:00:00Z"},
+			want: models.Event{ProfileId: "u1", EventType: "alias", EventName: "alias", EventTimestamp: 1709287200,
+				Properties: map[string]interface{}{"previous_id": "a1"},
+				Context:    map[string]interface{}{"user_id": "u1"}},
+		},
+		{
+			name: "device context",
+			message: models.SegmentMessage{Type: "track", UserId: "u1", Event: "Login", Timestamp: "2024-03-01T10:00:00Z",
+				Context: map[string]interface{}{
+					"device": map[string]interface{}{"id": "d1", "type": "ios"},
+					"os":     map[string]interface{}{"name": "iOS"},
+					"ip":     "10.0.0.1",
+				}},
+			want: models.Event{ProfileId: "u1", EventType: "track", EventName: "Login", EventTimestamp: 1709287200,
+				Context: map[string]interface{}{
+					"device":      map[string]interface{}{"id": "d1", "type": "ios"},
+					"os":          "iOS",
+					"ip":          "10.0.0.1",
+					"device_id":   "d1",
+					"device_type": "ios",
+					"user_id":     "u1",
+				}},
+		},
+		{name: "no ids", message: models.SegmentMessage{Type: "track", Event: "Login"}, invalid: true},
+		{name: "track without event", message: models.SegmentMessage{Type: "track", UserId: "u1"}, invalid: true},
+		{name: "group without id", message: models.SegmentMessage{Type: "group", UserId: "u1"}, invalid: true},
+		{name: "anonymous alias", message: models.SegmentMessage{Type: "alias", AnonymousId: "a1", PreviousId: "a0"},
+			invalid: true},
+		{name: "unsupported call", message: models.SegmentMessage{Type: "batch", UserId: "u1"}, invalid: true},
+		{name: "invalid timestamp", message: models.SegmentMessage{Type: "track", UserId: "u1", Event: "Login",
+			Timestamp: "01/03/2024"}, invalid: true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			event, err := segmentMessageToEvent(test.message)
+			if test.invalid {
+				if clientErrorCode(err) != errors.ErrInvalidEvent.Code {
+					t.Errorf("error = %v, want the call rejected as an invalid event", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to map the call: %v", err)
+			}
+			if !reflect.DeepEqual(event, test.want) {
+				t.Errorf("event = %+v\nwant %+v", event, test.want)
+			}
+		})
+	}
+}
+
+func TestSegmentMessageWithoutTimestampIsStampedNow(t *testing.T) {
+	before := time.Now().UTC().Unix()
+	event, err := segmentMessageToEvent(models.SegmentMessage{Type: "track", UserId: "u1", Event: "Login"})
+	if err != nil {
+		t.Fatalf("failed to map the call: %v", err)
+	}
+	if int64(event.EventTimestamp) < before || int64(event.EventTimestamp) > time.Now().UTC().Unix() {
+		t.Errorf("event_timestamp = %d, want the time the call was received", event.EventTimestamp)
+	}
+}
+
+func TestSegmentIdentifyWritesTraitsToTheProfile(t *testing.T) {
+	setupMemoryStores(t)
+
+	err := AddSegmentEvent("identify", models.SegmentMessage{UserId: "u1", MessageId: "m1",
+		Traits: map[string]interface{}{"email": "ann@example.com", "plan": "gold", "address.city": "Colombo"}})
+	if err != nil {
+		t.Fatalf("failed to add the identify call: %v", err)
+	}
+	processQueuedEvents(t)
+
+	profile := findProfile(t, "u1")
+	if profile.IdentityAttributes["email"] != "ann@example.com" || profile.Traits["plan"] != "gold" {
+		t.Errorf("identity attributes %v and traits %v, want the email as an identity attribute and the plan as a "+
+			"trait", profile.IdentityAttributes, profile.Traits)
+	}
+	if _, written := profile.Traits["address.city"]; written {
+		t.Errorf("trait with a dotted name was written: %v", profile.Traits)
+	}
+}
+
+func TestSegmentBatchUsesTheBatchContext(t *testing.T) {
+	setupMemoryStores(t)
+
+	var calls []json.RawMessage
+	for _, call := range []map[string]interface{}{
+		{"type": "track", "messageId": "m1", "userId": "u1", "event": "Login"},
+		{"type": "track", "messageId": "m2", "userId": "u1", "event": "Login", "context": map[string]interface{}{
+			"device_id": "d2"}},
+		{"type": "track", "messageId": "m3", "userId": "u1"},
+		{"type": "track", "messageId": "m4", "userId": "u1", "event": "Login", "timestamp": 1709287200},
+	} {
+		data, _ := json.Marshal(call)
+		calls = append(calls, data)
+	}
+	response, err := AddSegmentBatch(models.SegmentBatch{Batch: calls,
+		Context: map[string]interface{}{"device_id": "d1"}})
+	if err != nil {
+		t.Fatalf("failed to add the batch: %v", err)
+	}
+	if response.Accepted != 2 || response.Rejected != 2 {
+		t.Fatalf("batch accepted %d and rejected %d, want 2 of each", response.Accepted, response.Rejected)
+	}
+	if response.Results[2].ErrorCode != errors.ErrInvalidEvent.Code ||
+		response.Results[3].ErrorCode != errors.ErrBadRequest.Code {
+		t.Errorf("rejected results = %+v and %+v, want an invalid event and a bad request", response.Results[2],
+			response.Results[3])
+	}
+
+	devices := map[string]string{}
+	for _, eventId := range []string{"m1", "m2"} {
+		event, err := stores.Events.FindEvent(eventId)
+		if err != nil || event == nil {
+			t.Fatalf("event %s was not stored: %v", eventId, err)
+		}
+		devices[eventId], _ = event.Context["device_id"].(string)
+	}
+	if devices["m1"] != "d1" || devices["m2"] != "d2" {
+		t.Errorf("devices = %v, want the batch context only for the call without one", devices)
+	}
+}
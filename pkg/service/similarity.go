@@ -0,0 +1,160 @@
+package service
+
+import (
+	"strings"
+	"unicode"
+)
+
+// compareValues scores the similarity of two values between 0 and 1 with a comparator of probabilistic unification
+// rules. Apart from exact, the comparators ignore case.
+func compareValues(comparator, a, b string) float64 {
+	if comparator != "" && comparator != "exact" {
+		a, b = strings.ToLower(a), strings.ToLower(b)
+	}
+	switch comparator {
+	case "levenshtein":
+		return levenshteinSimilarity(a, b)
+	case "jaro_winkler":
+		return jaroWinklerSimilarity(a, b)
+	case "soundex":
+		codeA, codeB := soundex(a), soundex(b)
+		if codeA != "" && codeA == codeB {
+			return 1
+		}
+		return 0
+	default:
+		if a == b {
+			return 1
+		}
+		return 0
+	}
+}
+
+// levenshteinSimilarity is one minus the edit distance of the strings relative to the length of the longer one
+func levenshteinSimilarity(a, b string) float64 {
+	runesA, runesB := []rune(a), []rune(b)
+	longest := max(len(runesA), len(runesB))
+	if longest == 0 {
+		return 1
+	}
+
+	previous := make([]int, len(runesB)+1)
+	current := make([]int, len(runesB)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(runesA); i++ {
+		current[0] = i
+		for j := 1; j <= len(runesB); j++ {
+			cost := 1
+			if runesA[i-1] == runesB[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return 1 - float64(previous[len(runesB)])/float64(longest)
+}
+
+// jaroWinklerSimilarity is the Jaro similarity of the strings, boosted for a common prefix of up to four characters
+func jaroWinklerSimilarity(a, b string) float64 {
+	runesA, runesB := []rune(a), []rune(b)
+	if len(runesA) == 0 && len(runesB) == 0 {
+		return 1
+	}
+	if len(runesA) == 0 || len(runesB) == 0 {
+		return 0
+	}
+
+	matchDistance := max(len(runesA), len(runesB))/2 - 1
+	if matchDistance < 0 {
+		matchDistance = 0
+	}
+	matchedA := make([]bool, len(runesA))
+	matchedB := make([]bool, len(runesB))
+	matches := 0
+	for i, r := range runesA {
+		from := max(0, i-matchDistance)
+		to := min(len(runesB), i+matchDistance+1)
+		for j := from; j < to; j++ {
+			if matchedB[j] || runesB[j] != r {
+				continue
+			}
+			matchedA[i], matchedB[j] = true, true
+			matches++
+			break
+		}
+	}
+	if matches == 0 {
+		return 0
+	}
+
+	transpositions := 0
+	j := 0
+	for i, r := range runesA {
+		if !matchedA[i] {
+			continue
+		}
+		for !matchedB[j] {
+			j++
+		}
+		if r != runesB[j] {
+			transpositions++
+		}
+		j++
+	}
+
+	m := float64(matches)
+	jaro := (m/float64(len(runesA)) + m/float64(len(runesB)) + (m-float64(transpositions)/2)/m) / 3
+
+	prefix := 0
+	for prefix < min(4, len(runesA), len(runesB)) && runesA[prefix] == runesB[prefix] {
+		prefix++
+	}
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
+
+// soundex encodes the letters of the value by how they sound in English, such as R163 for both Robert and Rupert.
+// Values without letters have no code.
+func soundex(value string) string {
+	codes := map[rune]byte{
+		'b': '1', 'f': '1', 'p': '1', 'v': '1',
+		'c': '2', 'g': '2', 'j': '2', 'k': '2', 'q': '2', 's': '2', 'x': '2', 'z': '2',
+		'd': '3', 't': '3',
+		'l': '4',
+		'm': '5', 'n': '5',
+		'r': '6',
+	}
+
+	var code []byte
+	var previous byte
+	for _, r := range strings.ToLower(value) {
+		if r > unicode.MaxASCII || !unicode.IsLetter(r) {
+			continue
+		}
+		digit := codes[r]
+		if len(code) == 0 {
+			code = append(code, byte(unicode.ToUpper(r)))
+			previous = digit
+			continue
+		}
+		if digit != 0 && digit != previous {
+			code = append(code, digit)
+			if len(code) == 4 {
+				break
+			}
+		}
+		// h and w do not separate letters with the same code, vowels do
+		if r != 'h' && r != 'w' {
+			previous = digit
+		}
+	}
+	if len(code) == 0 {
+		return ""
+	}
+	for len(code) < 4 {
+		code = append(code, '0')
+	}
+	return string(code)
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/wso2/identity-customer-data-service/pkg/errors"
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+)
+
+func TestCompareValues(t *testing.T) {
+	for _, test := range []struct {
+		comparator string
+		a, b       string
+		want       float64
+	}{
+		{comparator: "jaro_winkler", a: "MARTHA", b: "MARHTA", want: 0.9611},
+		{comparator: "jaro_winkler", a: "DWAYNE", b: "DUANE", want: 0.84},
+		{comparator: "jaro_winkler", a: "DIXON", b: "DICKSONX", want: 0.8133},
+		{comparator: "jaro_winkler", a: "crate", b: "trace", want: 0.7333},
+		{comparator: "jaro_winkler", a: "Catherine", b: "katherine", want: 0.9259},
+		{comparator: "jaro_winkler", a: "abc", b: "xyz"},
+		{comparator: "jaro_winkler", a: "ann", b: ""},
+		{comparator: "jaro_winkler", a: "", b: "", want: 1},
+
+		{comparator: "levenshtein", a: "kitten", b: "sitting", want: 0.5714},
+		{comparator: "levenshtein", a: "flaw", b: "lawn", want: 0.5},
+		{comparator: "levenshtein", a: "Catherine", b: "KATHERINE", want: 0.8889},
+		{comparator: "levenshtein", a: "café", b: "cafe", want: 0.75},
+		{comparator: "levenshtein", a: "ann", b: "", want: 0},
+		{comparator: "levenshtein", a: "", b: "", want: 1},
+
+		{comparator: "soundex", a: "Robert", b: "rupert", want: 1},
+		{comparator: "soundex", a: "Robert", b: "Rubin"},
+		{comparator: "soundex", a: "Ashcraft", b: "Ashcroft", want: 1},
+		{comparator: "soundex", a: "123", b: "456"},
+
+		{comparator: "exact", a: "Ann", b: "Ann", want: 1},
+		{comparator: "exact", a: "Ann", b: "ann"},
+		{comparator: "", a: "Ann", b: "ann"},
+	} {
+		t.Run(fmt.Sprintf("%s of %s and %s", test.comparator, test.a, test.b), func(t *testing.T) {
+			got := math.Round(compareValues(test.comparator, test.a, test.b)*10000) / 10000
+			if got != test.want {
+				t.Errorf("similarity = %v, want %v", got, test.want)
+			}
+			if reversed := math.Round(compareValues(test.comparator, test.b, test.a)*10000) / 10000; reversed != got {
+				t.Errorf("similarity = %v with the values reversed, want %v", reversed, got)
+			}
+		})
+	}
+}
+
+func TestSoundex(t *testing.T) {
+	for value, want := range map[string]string{
+		"Robert":   "R163",
+		"Rupert":   "R163",
+		"Rubin":    "R150",
+		"Ashcraft": "A261",
+		"Tymczak":  "T522",
+		"Pfister":  "P236",
+		"Honeyman": "H555",
+		"Lee":      "L000",
+		"O'Brien":  "O165",
+		"123":      "",
+		"":         "",
+	} {
+		if got := soundex(value); got != want {
+			t.Errorf("soundex of %q = %q, want %q", value, got, want)
+		}
+	}
+}
+
+func TestProbabilisticRulesMergeFromTheirThreshold(t *testing.T) {
+	existing := profileWithTraits("p1", map[string]interface{}{"first_name": "Catherine", "city": "Colombo",
+		"nicknames": []interface{}{"Cathy", "Kate"}})
+	attributes := []models.MatchAttribute{
+		{Property: "traits.first_name", Comparator: "jaro_winkler", Weight: 2},
+		{Property: "traits.city"},
+	}
+
+	for _, test := range []struct {
+		name       string
+		traits     map[string]interface{}
+		attributes []models.MatchAttribute
+		threshold  float64
+		confidence float64
+		matched    bool
+	}{
+		{name: "all attributes equal", threshold: 1, confidence: 1, matched: true,
+			traits: map[string]interface{}{"first_name": "Catherine", "city": "Colombo"}},
+		{name: "weighted similarities above the threshold", threshold: 0.9, confidence: 0.9506, matched: true,
+			traits: map[string]interface{}{"first_name": "Katherine", "city": "Colombo"}},
+		{name: "weighted similarities at the threshold", threshold: 0.9506, confidence: 0.9506, matched: true,
+			traits: map[string]interface{}{"first_name": "Katherine", "city": "Colombo"}},
+		{name: "weighted similarities below the threshold", threshold: 0.9, confidence: 0.6173,
+			traits: map[string]interface{}{"first_name": "Katherine", "city": "Kandy"}},
+		{name: "missing attribute", threshold: 0.6, confidence: 0.3333,
+			traits: map[string]interface{}{"city": "Colombo"}},
+		{name: "most similar of several values", threshold: 0.8, confidence: 1, matched: true,
+			attributes: []models.MatchAttribute{{Property: "traits.nicknames", Comparator: "levenshtein"}},
+			traits:     map[string]interface{}{"nicknames": []interface{}{"Katie", "kate"}}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			rule := models.UnificationRule{RuleName: "similar", Mode: "probabilistic", Attributes: attributes,
+				Threshold: test.threshold}
+			if test.attributes != nil {
+				rule.Attributes = test.attributes
+			}
+			matched, confidence := doesProfileMatch(existing, profileWithTraits("p2", test.traits), rule)
+			if matched != test.matched || confidence != test.confidence {
+				t.Errorf("match = (%v, %v), want (%v, %v)", matched, confidence, test.matched, test.confidence)
+			}
+		})
+	}
+}
+
+func TestValidateProbabilisticRules(t *testing.T) {
+	firstName := models.MatchAttribute{Property: "traits.first_name", Comparator: "jaro_winkler"}
+	for _, test := range []struct {
+		name  string
+		rule  models.UnificationRule
+		valid bool
+	}{
+		{name: "valid", valid: true, rule: models.UnificationRule{Attributes: []models.MatchAttribute{firstName,
+			{Property: "traits.city", Comparator: "Exact", Weight: 0.5}}, Threshold: 0.85}},
+		{name: "threshold of one", valid: true, rule: models.UnificationRule{
+			Attributes: []models.MatchAttribute{firstName}, Threshold: 1}},
+		{name: "no attributes", rule: models.UnificationRule{Threshold: 0.85}},
+		{name: "repeated attribute", rule: models.UnificationRule{
+			Attributes: []models.MatchAttribute{firstName, firstName}, Threshold: 0.85}},
+		{name: "attribute without a property", rule: models.UnificationRule{
+			Attributes: []models.MatchAttribute{{Comparator: "soundex"}}, Threshold: 0.85}},
+		{name: "unsupported comparator", rule: models.UnificationRule{
+			Attributes: []models.MatchAttribute{{Property: "traits.first_name", Comparator: "metaphone"}},
+			Threshold:  0.85}},
+		{name: "negative weight", rule: models.UnificationRule{
+			Attributes: []models.MatchAttribute{{Property: "traits.first_name", Weight: -1}}, Threshold: 0.85}},
+		{name: "no threshold", rule: models.UnificationRule{Attributes: []models.MatchAttribute{firstName}}},
+		{name: "threshold above one", rule: models.UnificationRule{
+			Attributes: []models.MatchAttribute{firstName}, Threshold: 1.5}},
+		{name: "property instead of attributes", rule: models.UnificationRule{Property: "traits.first_name",
+			Attributes: []models.MatchAttribute{firstName}, Threshold: 0.85}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			test.rule.Mode = "Probabilistic"
+			err := validateRuleProperties(test.rule)
+			if test.valid {
+				if err != nil {
+					t.Errorf("rule was rejected: %v", err)
+				}
+				return
+			}
+			if clientErrorCode(err) != errors.ErrInvalidUnificationRule.Code {
+				t.Errorf("error = %v, want the rule rejected as invalid", err)
+			}
+		})
+	}
+}
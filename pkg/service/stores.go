@@ -0,0 +1,16 @@
+package service
+
+import (
+	repositories "github.com/wso2/identity-customer-data-service/pkg/repository"
+)
+
+// stores holds the storage backends used by the service layer
+var stores repositories.Stores
+
+// InitStores sets the storage backends used by the service layer. Profile writes go through the identity index.
+func InitStores(s repositories.Stores) {
+	if s.IdentityIndex != nil {
+		s.Profiles = &indexedProfileStore{ProfileStore: s.Profiles}
+	}
+	stores = s
+}
@@ -0,0 +1,248 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/wso2/identity-customer-data-service/config"
+	"github.com/wso2/identity-customer-data-service/pkg/locks"
+	"github.com/wso2/identity-customer-data-service/pkg/logger"
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+)
+
+const traitRefreshLockKey = "lock:trait-refresh"
+
+var traitRefresh struct {
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// withTraitRefreshDefaults fills in the refresh settings that are not configured
+func withTraitRefreshDefaults(refreshConfig config.TraitRefreshConfig) config.TraitRefreshConfig {
+	if refreshConfig.IntervalSeconds <= 0 {
+		refreshConfig.IntervalSeconds = 300
+	}
+	return refreshConfig
+}
+
+// StartTraitRefresh periodically re-evaluates the computed traits that change with time even when the profile
+// receives no events: counts and aggregates over a time range, whose events leave the window, and decay scores,
+// whose events age. A single instance refreshes the traits on each interval.
+func StartTraitRefresh(refreshConfig config.TraitRefreshConfig) {
+	refreshConfig = withTraitRefreshDefaults(refreshConfig)
+	if refreshConfig.Disabled {
+		return
+	}
+	interval := time.Duration(refreshConfig.IntervalSeconds) * time.Second
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	traitRefresh.stop, traitRefresh.stopped = stop, stopped
+
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				refreshTimeDependentTraits(stop, interval)
+			}
+		}
+	}()
+}
+
+// StopTraitRefresh stops the periodic refresh, waiting for the profile being refreshed
+func StopTraitRefresh(ctx context.Context) error {
+	if traitRefresh.stop == nil {
+		return nil
+	}
+	close(traitRefresh.stop)
+
+	select {
+	case <-traitRefresh.stopped:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("trait refresh did not stop in time: %v", ctx.Err())
+	}
+}
+
+// refreshTimeDependentTraits re-evaluates the time dependent rules for all the master profiles. The lock is left to
+// expire rather than released so that the other instances skip the interval.
+func refreshTimeDependentTraits(stop <-chan struct{}, interval time.Duration) {
+	owner, err := locks.GetDistributedLock().Acquire(traitRefreshLockKey, interval*9/10)
+	if err != nil {
+		logger.Error(err, "Failed to acquire the lock to refresh the traits")
+		return
+	}
+	if owner == "" {
+		return
+	}
+
+	rules, err := GetEnrichmentRules()
+	if err != nil {
+		logger.Error(err, "Failed to fetch the enrichment rules to refresh the traits")
+		return
+	}
+	var timeDependentRules []models.ProfileEnrichmentRule
+	for _, rule := range rules {
+		if isTimeDependentRule(rule) {
+			timeDependentRules = append(timeDependentRules, rule)
+		}
+	}
+	if len(timeDependentRules) == 0 {
+		return
+	}
+
+	masters, err := findAllMasterProfiles()
+	if err != nil {
+		logger.Error(err, "Failed to fetch the master profiles to refresh the traits")
+		return
+	}
+	refreshed := 0
+	for _, master := range masters {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		changed, err := refreshProfileTraits(master, timeDependentRules, rules)
+		if err != nil {
+			logger.Error(err, fmt.Sprintf("Failed to refresh the traits of profile %s", master.ProfileId))
+			continue
+		}
+		if changed {
+			refreshed++
+		}
+	}
+	if refreshed > 0 {
+		logger.Info(fmt.Sprintf("Refreshed the time dependent traits of %d profiles", refreshed))
+	}
+}
+
+// isTimeDependentRule reports whether the value of the rule changes with time alone
+func isTimeDependentRule(rule models.ProfileEnrichmentRule) bool {
+	if !computesOverEvents(rule) {
+		return false
+	}
+	return strings.ToLower(rule.Computation) == "decay" || rule.TimeRange != ""
+}
+
+// refreshProfileTraits re-evaluates the time dependent rules that already assigned a value to the master profile
+// and reports whether any value changed. As in the worker, a rule is evaluated over the events of the profile in
+// the hierarchy that triggered it last. The rules with profile triggers then see the changed values.
+func refreshProfileTraits(master models.Profile, timeDependentRules []models.ProfileEnrichmentRule,
+	rules []models.ProfileEnrichmentRule) (bool, error) {
+	profileRepo := stores.Profiles
+	now := models.Event{ProfileId: master.ProfileId, EventTimestamp: int(time.Now().UTC().Unix())}
+	updated := map[string]interface{}{}
+	// The identity keys are computed once from all the values that changed
+	defer func() {
+		if len(updated) > 0 {
+			reindexProfiles(master.ProfileId)
+		}
+	}()
+	for _, rule := range timeDependentRules {
+		namespace, name, _ := strings.Cut(rule.PropertyName, ".")
+		var current interface{}
+		var assigned bool
+		switch namespace {
+		case "traits":
+			current, assigned = master.Traits[name]
+		case "identity_attributes":
+			current, assigned = master.IdentityAttributes[name]
+		default:
+			continue // application data is assigned per application when events arrive
+		}
+		if !assigned {
+			continue
+		}
+
+		profileId, err := lastTriggeredProfile(master, rule.Trigger)
+		if err != nil {
+			return false, err
+		}
+		if profileId == "" {
+			continue
+		}
+		event := now
+		event.ProfileId = profileId
+		value, err := computeRuleValue(rule, event, func() ([]models.Event, error) {
+			return findEventsMatchingRule(profileId, rule.Trigger, rule.TimeRange)
+		})
+		if err != nil {
+			return false, fmt.Errorf("failed to compute the value of rule %s: %v", rule.RuleId, err)
+		}
+		// Values of aggregates whose window has no events left are cleared
+		if sameTraitValue(current, value) {
+			continue
+		}
+
+		fieldPath := fmt.Sprintf("%s.%s", namespace, name)
+		update := map[string]interface{}{fieldPath: value}
+		if namespace == "traits" {
+			err = profileRepo.UpsertTrait(master.ProfileId, update)
+		} else {
+			err = profileRepo.UpsertIdentityAttribute(master.ProfileId, update)
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed to update %s: %v", fieldPath, err)
+		}
+		updated[fieldPath] = value
+	}
+
+	if len(updated) == 0 {
+		return false, nil
+	}
+	for _, profileEvent := range traitUpdatedEvents(now, &master, updated) {
+		if _, err := applyEnrichmentRules(profileEvent, &master, rules); err != nil {
+			logger.Error(err, fmt.Sprintf("Failed to apply enrichment rules to profile %s", master.ProfileId))
+		}
+	}
+	return true, nil
+}
+
+// lastTriggeredProfile finds the profile in the hierarchy of the master whose events last triggered a rule with the
+// trigger, or an empty id when none did
+func lastTriggeredProfile(master models.Profile, trigger models.RuleTrigger) (string, error) {
+	profileIds := []string{master.ProfileId}
+	if master.ProfileHierarchy != nil {
+		for _, child := range master.ProfileHierarchy.ChildProfiles {
+			profileIds = append(profileIds, child.ChildProfileId)
+		}
+	}
+
+	lastProfileId := ""
+	lastTimestamp := 0
+	for _, profileId := range profileIds {
+		events, err := findEventsMatchingRule(profileId, trigger, "")
+		if err != nil {
+			return "", fmt.Errorf("failed to fetch the events of profile %s: %v", profileId, err)
+		}
+		if len(events) == 0 {
+			continue
+		}
+		if latest := events[len(events)-1]; lastProfileId == "" || latest.EventTimestamp > lastTimestamp {
+			lastProfileId, lastTimestamp = profileId, latest.EventTimestamp
+		}
+	}
+	return lastProfileId, nil
+}
+
+// sameTraitValue compares a stored value with a computed one by their json form, as stores may decode numbers with
+// a different type than the one computed
+func sameTraitValue(stored, computed interface{}) bool {
+	storedJSON, err := json.Marshal(stored)
+	if err != nil {
+		return false
+	}
+	computedJSON, err := json.Marshal(computed)
+	if err != nil {
+		return false
+	}
+	return string(storedJSON) == string(computedJSON)
+}
@@ -0,0 +1,255 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/wso2/identity-customer-data-service/pkg/errors"
+	"github.com/wso2/identity-customer-data-service/pkg/locks"
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+)
+
+func TestDecayScoreHalvesTheWeightOfEventsEveryHalfLife(t *testing.T) {
+	now := time.Unix(1709287200, 0)
+	day := 24 * time.Hour
+	event := func(age time.Duration, points interface{}) models.Event {
+		return models.Event{EventTimestamp: int(now.Add(-age).Unix()),
+			Properties: map[string]interface{}{"points": points}}
+	}
+
+	for _, test := range []struct {
+		name        string
+		events      []models.Event
+		weightField string
+		want        float64
+	}{
+		{name: "no events"},
+		{name: "event of now", events: []models.Event{event(0, nil)}, want: 1},
+		{name: "event one half life old", events: []models.Event{event(day, nil)}, want: 0.5},
+		{name: "event two half lives old", events: []models.Event{event(2*day, nil)}, want: 0.25},
+		{name: "events of several ages", events: []models.Event{event(0, nil), event(day, nil), event(2*day, nil)},
+			want: 1.75},
+		{name: "event from the future", events: []models.Event{event(-day, nil)}, want: 1},
+		{name: "rounded to four decimals", events: []models.Event{event(8*time.Hour, nil)}, want: 0.7937},
+		{name: "weighted events", events: []models.Event{event(day, 10.0), event(0, "4")}, weightField: "points",
+			want: 9},
+		{name: "events without a numeric weight", events: []models.Event{event(0, "n/a"), event(0, nil)},
+			weightField: "points"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if got := decayScore(test.events, test.weightField, day, now); got != test.want {
+				t.Errorf("score = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDecayRulesNeedAHalfLifeAndAtMostOneWeight(t *testing.T) {
+	for _, test := range []struct {
+		name         string
+		sourceFields []string
+		halfLife     string
+		code         string
+	}{
+		{name: "unweighted", halfLife: "7d"},
+		{name: "weighted", sourceFields: []string{"properties.points"}, halfLife: "12h"},
+		{name: "two weights", sourceFields: []string{"properties.points", "properties.bonus"}, halfLife: "7d",
+			code: errors.ErrSourceFieldValidation.Code},
+		{name: "no half life", code: errors.ErrInvalidTimeWindow.Code},
+		{name: "invalid half life", halfLife: "a week", code: errors.ErrInvalidTimeWindow.Code},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			err, valid := validateEnrichmentRule(models.ProfileEnrichmentRule{
+				PropertyName: "traits.engagement",
+				PropertyType: "computed",
+				Computation:  "decay",
+				SourceFields: test.sourceFields,
+				HalfLife:     test.halfLife,
+				Trigger:      models.RuleTrigger{EventType: "track", EventName: "purchase"},
+			})
+			if test.code == "" {
+				if !valid {
+					t.Errorf("rule was rejected: %v", err)
+				}
+				return
+			}
+			if valid || clientErrorCode(err) != test.code {
+				t.Errorf("error = %v, want %s", err, test.code)
+			}
+		})
+	}
+}
+
+func TestIsTimeDependentRule(t *testing.T) {
+	for _, test := range []struct {
+		computation string
+		timeRange   string
+		want        bool
+	}{
+		{computation: "copy"},
+		{computation: "copy", timeRange: "1d"},
+		{computation: "count"},
+		{computation: "count", timeRange: "1d", want: true},
+		{computation: "sum", timeRange: "3600", want: true},
+		{computation: "decay", want: true},
+	} {
+		rule := models.ProfileEnrichmentRule{PropertyType: "computed", Computation: test.computation,
+			TimeRange: test.timeRange}
+		if got := isTimeDependentRule(rule); got != test.want {
+			t.Errorf("%s in %q is time dependent = %v, want %v", test.computation, test.timeRange, got, test.want)
+		}
+	}
+}
+
+// addPurchaseRule adds a rule computing the trait over the purchase events
+func addPurchaseRule(t *testing.T, trait, computation, timeRange string, sourceFields ...string) {
+	t.Helper()
+	rule := models.ProfileEnrichmentRule{
+		RuleId:       "rule-" + trait,
+		PropertyName: "traits." + trait,
+		PropertyType: "computed",
+		Computation:  computation,
+		SourceFields: sourceFields,
+		TimeRange:    timeRange,
+		Trigger:      models.RuleTrigger{EventType: "track", EventName: "purchase"},
+	}
+	if computation == "decay" {
+		rule.HalfLife = "1d"
+	}
+	addEnrichmentRule(t, rule)
+}
+
+// setStaleTraits overwrites traits of the profile as if time had passed since they were computed
+func setStaleTraits(t *testing.T, profileId string, traits map[string]interface{}) {
+	t.Helper()
+	updates := map[string]interface{}{}
+	for name, value := range traits {
+		updates["traits."+name] = value
+	}
+	if err := stores.Profiles.UpsertTrait(profileId, updates); err != nil {
+		t.Fatalf("failed to set the traits of profile %s: %v", profileId, err)
+	}
+}
+
+func refreshTraits(t *testing.T) {
+	t.Helper()
+	refreshTimeDependentTraits(make(chan struct{}), time.Minute)
+}
+
+func TestRefreshRecomputesTheTimeDependentTraits(t *testing.T) {
+	setupMemoryStores(t)
+	now := time.Now()
+	addPurchaseRule(t, "recent_purchases", "count", "1d")
+	addPurchaseRule(t, "recent_total", "sum", "1d", "properties.total")
+	addPurchaseRule(t, "hourly_total", "sum", "1h", "properties.total")
+	addPurchaseRule(t, "engagement", "decay", "")
+	addPurchaseRule(t, "purchases", "count", "")
+	addEnrichmentRule(t, models.ProfileEnrichmentRule{
+		RuleId:       "rule-refunds",
+		PropertyName: "traits.recent_refunds",
+		PropertyType: "computed",
+		Computation:  "count",
+		TimeRange:    "1d",
+		Trigger:      models.RuleTrigger{EventType: "track", EventName: "refund"},
+	})
+	addEnrichmentRule(t, models.ProfileEnrichmentRule{
+		RuleId:       "rule-previous-recent-purchases",
+		PropertyName: "traits.previous_recent_purchases",
+		PropertyType: "computed",
+		Computation:  "copy",
+		SourceFields: []string{"properties.previous_value"},
+		Trigger: models.RuleTrigger{EventType: "profile", EventName: "trait_updated",
+			Conditions: []models.RuleCondition{{Field: "property_name", Operator: "equals",
+				Value: "traits.recent_purchases"}}},
+	})
+
+	events := []models.Event{
+		{EventId: "e1", EventTimestamp: int(now.Add(-48 * time.Hour).Unix()),
+			Properties: map[string]interface{}{"total": 10.0}},
+		{EventId: "e2", EventTimestamp: int(now.Add(-2 * time.Hour).Unix()),
+			Properties: map[string]interface{}{"total": 5.0}},
+	}
+	for _, event := range events {
+		event.ProfileId, event.OrgId, event.EventType, event.EventName = "p1", "org", "track", "purchase"
+		storeAndEnrich(t, event)
+	}
+	setStaleTraits(t, "p1", map[string]interface{}{"recent_purchases": 5, "recent_total": 100.0,
+		"hourly_total": 100.0, "engagement": 9.0, "purchases": 99})
+
+	refreshTraits(t)
+	traits := findProfile(t, "p1").Traits
+	for name, want := range map[string]interface{}{
+		"recent_purchases":          1,
+		"recent_total":              5.0,
+		"hourly_total":              nil,
+		"purchases":                 99,
+		"previous_recent_purchases": 5,
+	} {
+		if fmt.Sprint(traits[name]) != fmt.Sprint(want) {
+			t.Errorf("traits.%s = %v, want %v", name, traits[name], want)
+		}
+	}
+	// Two days and two hours old events of a one day half life
+	engagement, _ := traits["engagement"].(float64)
+	if want := math.Exp2(-2) + math.Exp2(-2.0/24); math.Abs(engagement-want) > 0.001 {
+		t.Errorf("traits.engagement = %v, want about %v", traits["engagement"], want)
+	}
+	if _, assigned := traits["recent_refunds"]; assigned {
+		t.Errorf("traits.recent_refunds = %v, want traits that were never assigned left out", traits["recent_refunds"])
+	}
+}
+
+func TestRefreshEvaluatesTheLastTriggeredProfileOfTheHierarchy(t *testing.T) {
+	masterId := setupMergedProfiles(t)
+	addEnrichmentRule(t, models.ProfileEnrichmentRule{
+		RuleId:       "rule-recent-signups",
+		PropertyName: "traits.recent_signups",
+		PropertyType: "computed",
+		Computation:  "count",
+		TimeRange:    "1d",
+	})
+	// A later event of p2 makes it the last profile to trigger the rule
+	storeAndEnrich(t, models.Event{EventId: "late", ProfileId: "p2", OrgId: "org", EventType: "track",
+		EventName: "signup", EventTimestamp: int(time.Now().Unix()) + 3600})
+	setStaleTraits(t, masterId, map[string]interface{}{"recent_signups": 7})
+
+	refreshTraits(t)
+	if got := findProfile(t, masterId).Traits["recent_signups"]; fmt.Sprint(got) != "2" {
+		t.Errorf("master traits.recent_signups = %v, want the count over the 2 events of p2", got)
+	}
+}
+
+func TestRefreshIsSkipped(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		prepare func(t *testing.T) chan struct{}
+	}{
+		{name: "while another instance refreshes", prepare: func(t *testing.T) chan struct{} {
+			if owner, err := locks.GetDistributedLock().Acquire(traitRefreshLockKey, time.Minute); err != nil ||
+				owner == "" {
+				t.Fatalf("failed to take the refresh lock: %v", err)
+			}
+			return make(chan struct{})
+		}},
+		{name: "once stopped", prepare: func(t *testing.T) chan struct{} {
+			stop := make(chan struct{})
+			close(stop)
+			return stop
+		}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			setupMemoryStores(t)
+			addPurchaseRule(t, "recent_purchases", "count", "1d")
+			storeAndEnrich(t, models.Event{EventId: "e1", ProfileId: "p1", OrgId: "org", EventType: "track",
+				EventName: "purchase", EventTimestamp: int(time.Now().Unix())})
+			setStaleTraits(t, "p1", map[string]interface{}{"recent_purchases": 5})
+
+			refreshTimeDependentTraits(test.prepare(t), time.Minute)
+			if got := findProfile(t, "p1").Traits["recent_purchases"]; fmt.Sprint(got) != "5" {
+				t.Errorf("traits.recent_purchases = %v, want the stale value kept", got)
+			}
+		})
+	}
+}
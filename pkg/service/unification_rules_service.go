@@ -4,67 +4,237 @@ import (
 	"fmt"
 	"github.com/wso2/identity-customer-data-service/pkg/constants"
 	"github.com/wso2/identity-customer-data-service/pkg/errors"
-	"github.com/wso2/identity-customer-data-service/pkg/locks"
 	"github.com/wso2/identity-customer-data-service/pkg/models"
-	"github.com/wso2/identity-customer-data-service/pkg/repository"
-	"go.mongodb.org/mongo-driver/bson"
 	"net/http"
+	"sort"
+	"strings"
 	"time"
 )
 
 // AddUnificationRule Adds a new unification rule.
-func AddUnificationRule(rule models.UnificationRule) error {
+func AddUnificationRule(rule models.UnificationRule, author string) error {
 
-	mongoDB := locks.GetMongoDBInstance()
-	unificationRuleRepo := repositories.NewUnificationRuleRepository(mongoDB.Database, constants.UnificationRulesCollection)
+	if err := validateUnificationRule(rule); err != nil {
+		return err
+	}
 
-	// Check if the attribute exists in profile schema enrichment rules
-	filter := fmt.Sprintf("property_name eq %s", rule.Property)
-	profileEnrichmentRules, err := GetEnrichmentRulesByFilter([]string{filter})
+	// Set timestamps
+	now := time.Now().UTC().Unix()
+	rule.CreatedAt = now
+	rule.UpdatedAt = now
 
-	if err != nil {
-		errors.ErrWhileAddingUnificationRules.Description = "Failed when validating if the attribute exists in " +
-			"enrichment rules."
-		return errors.NewServerError(errors.ErrWhileFetchingEnrichmentRules, err)
+	if err := stores.UnificationRules.AddUnificationRule(rule); err != nil {
+		return err
 	}
+	RebuildIdentityIndex()
+	recordRuleVersion(constants.UnificationRuleType, rule.RuleId, constants.RuleCreated, author, nil, rule, 0)
+	return nil
+}
 
-	if len(profileEnrichmentRules) == 0 {
-		// Property does not exist in profile enrichment rules
-		return errors.NewClientError(errors.ErrorMessage{
-			Code:        errors.ErrPropDoesntExists.Code,
-			Message:     errors.ErrPropDoesntExists.Message,
-			Description: fmt.Sprintf("Property %s does not exist as a profile enrichment rule", rule.Property),
-		}, http.StatusConflict)
+// validateUnificationRule checks that the properties of the rule are written by enrichment rules and that no other
+// unification rule matches on the same properties
+func validateUnificationRule(rule models.UnificationRule) error {
+
+	unificationRuleRepo := stores.UnificationRules
+
+	if err := validateRuleProperties(rule); err != nil {
+		return err
+	}
+	if err := validateRuleNormalizers(rule); err != nil {
+		return err
+	}
+
+	// Check if the attributes exist in profile schema enrichment rules
+	for _, property := range unificationRuleProperties(rule) {
+		filter := fmt.Sprintf("property_name eq %s", property)
+		profileEnrichmentRules, err := GetEnrichmentRulesByFilter([]string{filter})
+
+		if err != nil {
+			errors.ErrWhileAddingUnificationRules.Description = "Failed when validating if the attribute exists in " +
+				"enrichment rules."
+			return errors.NewServerError(errors.ErrWhileFetchingEnrichmentRules, err)
+		}
+
+		if len(profileEnrichmentRules) == 0 {
+			// Property does not exist in profile enrichment rules
+			return errors.NewClientError(errors.ErrorMessage{
+				Code:        errors.ErrPropDoesntExists.Code,
+				Message:     errors.ErrPropDoesntExists.Message,
+				Description: fmt.Sprintf("Property %s does not exist as a profile enrichment rule", property),
+			}, http.StatusConflict)
+		}
 	}
 
 	// Check if a similar unification rule already exists
-	existingRule, err := unificationRuleRepo.GetUnificationRuleByPropertyName(rule.Property)
+	var existingRule models.UnificationRule
+	var err error
+	if rule.Property != "" {
+		existingRule, err = unificationRuleRepo.GetUnificationRuleByPropertyName(rule.Property)
+	} else if !isProbabilisticRule(rule) {
+		existingRule, err = findCompositeUnificationRule(rule)
+	}
 	if err != nil {
 		errors.ErrWhileAddingUnificationRules.Description = "Failed to fetch existing unification rules"
 		return errors.NewServerError(errors.ErrWhileFetchingUnificationRules, err)
 	}
-	if existingRule.RuleId != "" {
+	if existingRule.RuleId != "" && existingRule.RuleId != rule.RuleId {
 		// Resolution rule already exists
 		return errors.NewClientError(errors.ErrorMessage{
 			Code:        errors.ErrResolutionRuleAlreadyExists.Code,
 			Message:     errors.ErrResolutionRuleAlreadyExists.Message,
-			Description: fmt.Sprintf("Unification rule with property %s already exists", rule.Property),
+			Description: fmt.Sprintf("Unification rule with property %s already exists", describeRuleProperties(rule)),
 		}, http.StatusConflict)
 	}
+	return nil
+}
 
-	// Set timestamps
-	now := time.Now().UTC().Unix()
-	rule.CreatedAt = now
-	rule.UpdatedAt = now
+// validateRuleProperties checks that the rule matches either on a single property or on at least two distinct
+// properties combined by a supported operator
+func validateRuleProperties(rule models.UnificationRule) error {
+	invalidRule := func(description string) error {
+		return errors.NewClientError(errors.ErrorMessage{
+			Code:        errors.ErrInvalidUnificationRule.Code,
+			Message:     errors.ErrInvalidUnificationRule.Message,
+			Description: description,
+		}, http.StatusBadRequest)
+	}
+
+	mode := strings.ToLower(rule.Mode)
+	if mode != "" && mode != constants.UnificationModeExact && mode != constants.UnificationModeProbabilistic {
+		return invalidRule(fmt.Sprintf("Mode '%s' is not supported. Use 'exact' or 'probabilistic'.", rule.Mode))
+	}
+	if isProbabilisticRule(rule) {
+		return validateMatchAttributes(rule, invalidRule)
+	}
+	if len(rule.Attributes) > 0 || rule.Threshold != 0 {
+		return invalidRule("Attributes and threshold are only used by probabilistic rules.")
+	}
+
+	if rule.Property != "" {
+		if len(rule.Properties) > 0 || rule.Operator != "" {
+			return invalidRule("Either property or properties with an operator can be provided, not both.")
+		}
+		return nil
+	}
+	if len(rule.Properties) < 2 {
+		return invalidRule("A property or at least two properties must be provided.")
+	}
+	seen := map[string]bool{}
+	for _, property := range rule.Properties {
+		if property == "" || seen[property] {
+			return invalidRule(fmt.Sprintf("Properties must be distinct and not empty: %v", rule.Properties))
+		}
+		seen[property] = true
+	}
+	operator := strings.ToLower(rule.Operator)
+	if operator != "" && operator != constants.UnificationOperatorAnd && operator != constants.UnificationOperatorOr {
+		return invalidRule(fmt.Sprintf("Operator '%s' is not supported. Use 'and' or 'or'.", rule.Operator))
+	}
+	return nil
+}
+
+// validateMatchAttributes checks that a probabilistic rule compares distinct attributes with supported comparators
+// and merges from a threshold between 0 and 1
+func validateMatchAttributes(rule models.UnificationRule, invalidRule func(description string) error) error {
+	if rule.Property != "" || len(rule.Properties) > 0 || rule.Operator != "" {
+		return invalidRule("Probabilistic rules compare attributes instead of property or properties.")
+	}
+	if len(rule.Attributes) == 0 {
+		return invalidRule("At least one attribute must be provided for probabilistic matching.")
+	}
+	seen := map[string]bool{}
+	for _, attribute := range rule.Attributes {
+		if attribute.Property == "" || seen[attribute.Property] {
+			return invalidRule("Attribute properties must be distinct and not empty.")
+		}
+		seen[attribute.Property] = true
+		if attribute.Comparator != "" && !constants.MatchComparators[strings.ToLower(attribute.Comparator)] {
+			return invalidRule(fmt.Sprintf("Comparator '%s' is not supported.", attribute.Comparator))
+		}
+		if attribute.Weight < 0 {
+			return invalidRule(fmt.Sprintf("Weight of attribute %s must not be negative.", attribute.Property))
+		}
+	}
+	if rule.Threshold <= 0 || rule.Threshold > 1 {
+		return invalidRule("Threshold must be greater than 0 and at most 1.")
+	}
+	return nil
+}
+
+// validateRuleNormalizers checks that the normalizers of the rule are registered
+func validateRuleNormalizers(rule models.UnificationRule) error {
+	for _, name := range rule.Normalizers {
+		if _, found := findNormalizer(name); !found {
+			return errors.NewClientError(errors.ErrorMessage{
+				Code:        errors.ErrInvalidUnificationRule.Code,
+				Message:     errors.ErrInvalidUnificationRule.Message,
+				Description: fmt.Sprintf("Normalizer '%s' is not supported.", name),
+			}, http.StatusBadRequest)
+		}
+	}
+	return nil
+}
 
-	return unificationRuleRepo.AddUnificationRule(rule)
+// findCompositeUnificationRule finds the rule that combines the same properties by the same operator as the given
+// composite rule. An empty rule is returned if none exists.
+func findCompositeUnificationRule(rule models.UnificationRule) (models.UnificationRule, error) {
+	rules, err := stores.UnificationRules.GetUnificationRules()
+	if err != nil {
+		return models.UnificationRule{}, err
+	}
+	for _, existing := range rules {
+		if existing.Property == "" && !isProbabilisticRule(existing) &&
+			describeRuleProperties(existing) == describeRuleProperties(rule) {
+			return existing, nil
+		}
+	}
+	return models.UnificationRule{}, nil
+}
+
+// unificationRuleProperties lists the properties the rule matches on
+func unificationRuleProperties(rule models.UnificationRule) []string {
+	if isProbabilisticRule(rule) {
+		properties := make([]string, 0, len(rule.Attributes))
+		for _, attribute := range rule.Attributes {
+			properties = append(properties, attribute.Property)
+		}
+		return properties
+	}
+	if rule.Property != "" {
+		return []string{rule.Property}
+	}
+	return rule.Properties
+}
+
+// isProbabilisticRule reports whether the rule scores the similarity of its attributes rather than matching its
+// properties exactly
+func isProbabilisticRule(rule models.UnificationRule) bool {
+	return strings.ToLower(rule.Mode) == constants.UnificationModeProbabilistic
+}
+
+// unificationRuleOperator returns the operator combining the properties of the rule, and by default
+func unificationRuleOperator(rule models.UnificationRule) string {
+	if strings.ToLower(rule.Operator) == constants.UnificationOperatorOr {
+		return constants.UnificationOperatorOr
+	}
+	return constants.UnificationOperatorAnd
+}
+
+// describeRuleProperties describes the properties of the rule independent of their order, such as
+// "traits.first_name and traits.last_name"
+func describeRuleProperties(rule models.UnificationRule) string {
+	if rule.Property != "" {
+		return rule.Property
+	}
+	properties := append([]string(nil), rule.Properties...)
+	sort.Strings(properties)
+	return strings.Join(properties, " "+unificationRuleOperator(rule)+" ")
 }
 
 // GetUnificationRules Fetches all resolution rules.
 func GetUnificationRules() ([]models.UnificationRule, error) {
 
-	mongoDB := locks.GetMongoDBInstance()
-	unificationRepo := repositories.NewUnificationRuleRepository(mongoDB.Database, constants.UnificationRulesCollection)
+	unificationRepo := stores.UnificationRules
 	rules, err := unificationRepo.GetUnificationRules()
 	return rules, err
 }
@@ -72,8 +242,7 @@ func GetUnificationRules() ([]models.UnificationRule, error) {
 // GetUnificationRule Fetches a specific resolution rule.
 func GetUnificationRule(ruleId string) (models.UnificationRule, error) {
 
-	mongoDB := locks.GetMongoDBInstance()
-	unificationRepo := repositories.NewUnificationRuleRepository(mongoDB.Database, constants.UnificationRulesCollection)
+	unificationRepo := stores.UnificationRules
 	rule, err := unificationRepo.GetUnificationRule(ruleId)
 	if rule.RuleId == "" {
 		clientError := errors.NewClientError(errors.ErrorMessage{
@@ -88,10 +257,9 @@ func GetUnificationRule(ruleId string) (models.UnificationRule, error) {
 }
 
 // PatchResolutionRule Applies a partial update on a specific resolution rule.
-func PatchResolutionRule(ruleId string, updates bson.M) error {
+func PatchResolutionRule(ruleId string, updates map[string]interface{}, author string) error {
 
-	mongoDB := locks.GetMongoDBInstance()
-	unificationRulesRepo := repositories.NewUnificationRuleRepository(mongoDB.Database, constants.UnificationRulesCollection)
+	unificationRulesRepo := stores.UnificationRules
 
 	// Only allow patching specific fields
 	allowedFields := map[string]bool{
@@ -112,12 +280,40 @@ func PatchResolutionRule(ruleId string, updates bson.M) error {
 		}
 	}
 
-	return unificationRulesRepo.PatchUnificationRule(ruleId, updates)
+	unlock, err := lockRule(constants.UnificationRuleType, ruleId)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	previous, exists := findUnificationRule(ruleId)
+	if !exists {
+		return errors.NewClientError(errors.ErrResolutionRuleNotFound, http.StatusNotFound)
+	}
+	if err := unificationRulesRepo.PatchUnificationRule(ruleId, updates); err != nil {
+		return err
+	}
+	current, _ := findUnificationRule(ruleId)
+	recordRuleVersion(constants.UnificationRuleType, ruleId, constants.RuleUpdated, author, previous, current, 0)
+	return nil
 }
 
 // DeleteUnificationRule Removes a  unification rule.
-func DeleteUnificationRule(ruleId string) error {
-	mongoDB := locks.GetMongoDBInstance()
-	unificationRepo := repositories.NewUnificationRuleRepository(mongoDB.Database, constants.UnificationRulesCollection)
-	return unificationRepo.DeleteUnificationRule(ruleId)
+func DeleteUnificationRule(ruleId string, author string) error {
+	unificationRepo := stores.UnificationRules
+
+	unlock, err := lockRule(constants.UnificationRuleType, ruleId)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	previous, exists := findUnificationRule(ruleId)
+	if err := unificationRepo.DeleteUnificationRule(ruleId); err != nil {
+		return err
+	}
+	if !exists {
+		return nil
+	}
+	RebuildIdentityIndex()
+	recordRuleVersion(constants.UnificationRuleType, ruleId, constants.RuleDeleted, author, previous, nil, 0)
+	return nil
 }
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/wso2/identity-customer-data-service/pkg/errors"
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+)
+
+func profileWithTraits(profileId string, traits map[string]interface{}) models.Profile {
+	return models.Profile{ProfileId: profileId, Traits: traits,
+		ProfileHierarchy: &models.ProfileHierarchy{IsParent: true}}
+}
+
+func TestDoesProfileMatchCompositeRules(t *testing.T) {
+	ann := profileWithTraits("p1", map[string]interface{}{"first_name": "Ann", "last_name": "Lee",
+		"email": "ann@example.com", "phone": "+94771234567"})
+
+	for _, test := range []struct {
+		name     string
+		operator string
+		traits   map[string]interface{}
+		want     bool
+	}{
+		{name: "and of all matching properties", operator: "and",
+			traits: map[string]interface{}{"first_name": "Ann", "last_name": "Lee"}, want: true},
+		{name: "and with one property differing", operator: "and",
+			traits: map[string]interface{}{"first_name": "Ann", "last_name": "Chen"}},
+		{name: "and with one property missing", operator: "and",
+			traits: map[string]interface{}{"first_name": "Ann"}},
+		{name: "and by default", traits: map[string]interface{}{"first_name": "Ann", "last_name": "Lee"},
+			want: true},
+		{name: "and by default with one property differing",
+			traits: map[string]interface{}{"first_name": "Ann", "last_name": "Chen"}},
+		{name: "or of one matching property", operator: "OR",
+			traits: map[string]interface{}{"first_name": "Bob", "last_name": "Lee"}, want: true},
+		{name: "or of no matching property", operator: "or",
+			traits: map[string]interface{}{"first_name": "Bob", "last_name": "Chen"}},
+		{name: "or of missing properties", operator: "or", traits: map[string]interface{}{}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			rule := models.UnificationRule{RuleName: "name",
+				Properties: []string{"traits.first_name", "traits.last_name"}, Operator: test.operator}
+			matched, confidence := doesProfileMatch(ann, profileWithTraits("p2", test.traits), rule)
+			if matched != test.want {
+				t.Errorf("matched = %v, want %v", matched, test.want)
+			}
+			wantConfidence := 0.0
+			if test.want {
+				wantConfidence = 1
+			}
+			if confidence != wantConfidence {
+				t.Errorf("confidence = %v, want %v", confidence, wantConfidence)
+			}
+		})
+	}
+}
+
+func TestValidateRulePropertiesRejectsInvalidCompositeRules(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		rule  models.UnificationRule
+		valid bool
+	}{
+		{name: "single property", rule: models.UnificationRule{Property: "traits.email"}, valid: true},
+		{name: "and of properties", valid: true, rule: models.UnificationRule{
+			Properties: []string{"traits.first_name", "traits.last_name"}, Operator: "and"}},
+		{name: "or of properties", valid: true, rule: models.UnificationRule{
+			Properties: []string{"traits.email", "traits.phone"}, Operator: "Or"}},
+		{name: "properties without an operator", valid: true, rule: models.UnificationRule{
+			Properties: []string{"traits.first_name", "traits.last_name"}}},
+		{name: "no property", rule: models.UnificationRule{}},
+		{name: "one of properties", rule: models.UnificationRule{Properties: []string{"traits.email"}}},
+		{name: "property and properties", rule: models.UnificationRule{Property: "traits.email",
+			Properties: []string{"traits.first_name", "traits.last_name"}}},
+		{name: "property with an operator", rule: models.UnificationRule{Property: "traits.email",
+			Operator: "or"}},
+		{name: "repeated properties", rule: models.UnificationRule{
+			Properties: []string{"traits.email", "traits.email"}}},
+		{name: "empty property", rule: models.UnificationRule{Properties: []string{"traits.email", ""}}},
+		{name: "unsupported operator", rule: models.UnificationRule{
+			Properties: []string{"traits.email", "traits.phone"}, Operator: "xor"}},
+		{name: "unsupported mode", rule: models.UnificationRule{Property: "traits.email", Mode: "fuzzy"}},
+		{name: "threshold of an exact rule", rule: models.UnificationRule{Property: "traits.email",
+			Threshold: 0.8}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateRuleProperties(test.rule)
+			if test.valid {
+				if err != nil {
+					t.Errorf("rule was rejected: %v", err)
+				}
+				return
+			}
+			if clientErrorCode(err) != errors.ErrInvalidUnificationRule.Code {
+				t.Errorf("error = %v, want the rule rejected as invalid", err)
+			}
+		})
+	}
+}
+
+func TestCompositeRulesAreUniquePerPropertiesAndOperator(t *testing.T) {
+	setupMemoryStores(t)
+	for _, trait := range []string{"first_name", "last_name"} {
+		addCopyRule(t, trait)
+	}
+	existing := models.UnificationRule{RuleId: "rule-name", RuleName: "name",
+		Properties: []string{"traits.first_name", "traits.last_name"}, Priority: 1, IsActive: true}
+	if err := stores.UnificationRules.AddUnificationRule(existing); err != nil {
+		t.Fatalf("failed to add unification rule: %v", err)
+	}
+
+	for _, test := range []struct {
+		name      string
+		rule      models.UnificationRule
+		duplicate bool
+	}{
+		{name: "same properties in another order", duplicate: true, rule: models.UnificationRule{RuleId: "rule-new",
+			Properties: []string{"traits.last_name", "traits.first_name"}, Operator: "AND"}},
+		{name: "same properties by another operator", rule: models.UnificationRule{RuleId: "rule-new",
+			Properties: []string{"traits.first_name", "traits.last_name"}, Operator: "or"}},
+		{name: "the existing rule itself", rule: existing},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateUnificationRule(test.rule)
+			if test.duplicate {
+				if clientErrorCode(err) != errors.ErrResolutionRuleAlreadyExists.Code {
+					t.Errorf("error = %v, want the rule rejected as a duplicate", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("rule was rejected: %v", err)
+			}
+		})
+	}
+
+	unknown := models.UnificationRule{RuleId: "rule-new", Properties: []string{"traits.first_name", "traits.nickname"}}
+	if err := validateUnificationRule(unknown); clientErrorCode(err) != errors.ErrPropDoesntExists.Code {
+		t.Errorf("error = %v, want the property without an enrichment rule rejected", err)
+	}
+}
+
+func TestUnifyProfilesWithCompositeRules(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		operator string
+		unified  []string
+		apart    []string
+	}{
+		{name: "and", operator: "and", unified: []string{"p1", "p3"}, apart: []string{"p2", "p4"}},
+		{name: "or", operator: "or", unified: []string{"p1", "p2", "p3"}, apart: []string{"p4"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			setupMemoryStores(t)
+			for _, trait := range []string{"first_name", "last_name"} {
+				addCopyRule(t, trait)
+			}
+			rule := models.UnificationRule{RuleId: "rule-name", RuleName: "name",
+				Properties: []string{"traits.first_name", "traits.last_name"}, Operator: test.operator, Priority: 1,
+				IsActive: true}
+			if err := stores.UnificationRules.AddUnificationRule(rule); err != nil {
+				t.Fatalf("failed to add unification rule: %v", err)
+			}
+
+			for _, profile := range []struct{ profileId, first, last string }{
+				{"p1", "Ann", "Lee"}, {"p2", "Ann", "Chen"}, {"p3", "Ann", "Lee"}, {"p4", "Bob", "Perera"},
+			} {
+				enrichWithEvent(t, profile.profileId, map[string]interface{}{"first_name": profile.first,
+					"last_name": profile.last})
+				unify(t, profile.profileId)
+			}
+
+			masterId := findProfile(t, test.unified[0]).ProfileHierarchy.ParentProfileID
+			for _, profileId := range test.unified {
+				hierarchy := findProfile(t, profileId).ProfileHierarchy
+				if hierarchy.IsParent || hierarchy.ParentProfileID != masterId {
+					t.Errorf("profile %s hierarchy = %+v, want it unified under %s", profileId, hierarchy, masterId)
+				}
+			}
+			if master := findProfile(t, masterId); childRuleName(master, test.unified[1]) != "name" {
+				t.Errorf("master children = %+v, want them linked by the name rule",
+					master.ProfileHierarchy.ChildProfiles)
+			}
+			for _, profileId := range test.apart {
+				if hierarchy := findProfile(t, profileId).ProfileHierarchy; !hierarchy.IsParent {
+					t.Errorf("profile %s was unified: %+v", profileId, hierarchy)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/wso2/identity-customer-data-service/pkg/logger"
+	"github.com/wso2/identity-customer-data-service/pkg/models"
+)
+
+// workerPool processes claimed events concurrently. Events are partitioned by profile id so that the events of a
+// profile are always handled by the same worker. The pool claims more events as soon as it has room for them, so a
+// slow event only holds back its own partition.
+type workerPool struct {
+	partitions []chan models.QueuedEvent
+	capacity   int
+	queued     atomic.Int64 // events dispatched but not yet handled
+	pending    sync.WaitGroup
+	handled    chan struct{}
+	process    func(event models.Event) error
+	abandoned  atomic.Bool
+}
+
+// workerMetrics accumulates the processing statistics reported by GetEventQueueStats
+type workerMetrics struct {
+	workers         atomic.Int64
+	inFlight        atomic.Int64
+	processed       atomic.Int64
+	failed          atomic.Int64
+	processingNanos atomic.Int64
+	queueNanos      atomic.Int64
+}
+
+var processingMetrics workerMetrics
+
+func newWorkerPool(concurrency, bufferSize int, process func(event models.Event) error) *workerPool {
+	pool := &workerPool{
+		partitions: make([]chan models.QueuedEvent, concurrency),
+		capacity:   concurrency * bufferSize,
+		handled:    make(chan struct{}, 1),
+		process:    process,
+	}
+	for i := range pool.partitions {
+		// A partition can hold every event the pool has room for, so dispatching never blocks
+		partition := make(chan models.QueuedEvent, pool.capacity)
+		pool.partitions[i] = partition
+		go func() {
+			for queuedEvent := range partition {
+				pool.handle(queuedEvent)
+				pool.queued.Add(-1)
+				pool.pending.Done()
+				select {
+				case pool.handled <- struct{}{}:
+				default:
+				}
+			}
+		}()
+	}
+	processingMetrics.workers.Store(int64(concurrency))
+	return pool
+}
+
+// run claims events for the worker and dispatches them until `stop` is closed, and then waits for the dispatched
+// events to be handled. Events are claimed whenever the pool has room for them, and the queue is polled only when it
+// has nothing to claim.
+func (pool *workerPool) run(workerId string, batchSize int, lease time.Duration, pollInterval time.Duration,
+	stop <-chan struct{}) {
+	defer pool.drain()
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		if room := min(batchSize, pool.capacity-int(pool.queued.Load())); room > 0 {
+			claimed, err := stores.EventQueue.Claim(workerId, room, lease)
+			if err != nil {
+				logger.Error(err, "Failed to claim events from the event queue")
+			}
+			pool.dispatch(claimed)
+			if len(claimed) == room {
+				continue
+			}
+		}
+		// Wait for the pool to make room or for the queue to have new events
+		select {
+		case <-stop:
+			return
+		case <-pool.handled:
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// dispatch hands the events to the workers owning their profiles without waiting for them to be handled
+func (pool *workerPool) dispatch(events []models.QueuedEvent) {
+	for _, queuedEvent := range events {
+		pool.pending.Add(1)
+		pool.queued.Add(1)
+		pool.partitions[pool.partitionOf(queuedEvent.Event.ProfileId)] <- queuedEvent
+	}
+}
+
+// drain waits until the dispatched events are handled
+func (pool *workerPool) drain() {
+	pool.pending.Wait()
+}
+
+func (pool *workerPool) partitionOf(profileId string) int {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(profileId))
+	return int(hash.Sum32() % uint32(len(pool.partitions)))
+}
+
+// abandon makes the workers release the events they have not started yet back to the queue instead of
+// processing them
+func (pool *workerPool) abandon() {
+	pool.abandoned.Store(true)
+}
+
+func (pool *workerPool) handle(queuedEvent models.QueuedEvent) {
+	if pool.abandoned.Load() {
+		releaseUnprocessed(queuedEvent)
+		return
+	}
+
+	processingMetrics.inFlight.Add(1)
+	defer processingMetrics.inFlight.Add(-1)
+
+	start := time.Now()
+	err := handleQueuedEvent(queuedEvent, pool.process)
+	end := time.Now()
+
+	processingMetrics.processingNanos.Add(end.Sub(start).Nanoseconds())
+	processingMetrics.queueNanos.Add(end.UnixNano() - queuedEvent.EnqueuedAt)
+	if err != nil {
+		processingMetrics.failed.Add(1)
+	} else {
+		processingMetrics.processed.Add(1)
+	}
+}
+
+// releaseUnprocessed returns a claimed event to the queue without counting the attempt made by the claim
+func releaseUnprocessed(queuedEvent models.QueuedEvent) {
+	queuedEvent.Attempts--
+	if err := stores.EventQueue.Release(queuedEvent, time.Now().UnixMilli(), queuedEvent.LastError); err != nil {
+		logger.Error(err, fmt.Sprintf("Failed to release event %s", queuedEvent.Event.EventId))
+	}
+}
+
+// GetEventQueueStats reports the depth of the event queue and the latency of processing the queued events
+func GetEventQueueStats() (*models.EventQueueStats, error) {
+	depth, err := stores.EventQueue.Depth()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &models.EventQueueStats{
+		QueueDepth: depth,
+		Workers:    int(processingMetrics.workers.Load()),
+		InFlight:   processingMetrics.inFlight.Load(),
+		Processed:  processingMetrics.processed.Load(),
+		Failed:     processingMetrics.failed.Load(),
+	}
+	if handled := stats.Processed + stats.Failed; handled > 0 {
+		stats.AverageProcessingMs = float64(processingMetrics.processingNanos.Load()) / float64(handled) / 1e6
+		stats.AverageQueueLatencyMs = float64(processingMetrics.queueNanos.Load()) / float64(handled) / 1e6
+	}
+	return stats, nil
+}